@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go-example/admin-example/internal/config"
+	"go-example/admin-example/internal/infrastructure"
+	"go-example/admin-example/internal/migrate"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/migrations"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const usage = `使い方:
+  api                                  サーバーを起動する
+  api migrate up                       未適用のマイグレーションを全て適用する
+  api migrate down [n]                 最後に適用したものから n 個 (省略時は1個) 戻す
+  api migrate redo                     最後に適用したものを戻してから、もう一度適用する
+  api migrate status                   マイグレーションの適用状況を表示する
+  api create-account <email> <role>    ログイン用アカウントを作る (role: admin / editor / viewer)
+                                       パスワードは APP_ACCOUNT_PASSWORD か標準入力から読む`
+
+// runCommand はサーバー起動以外のサブコマンドを実行する
+func runCommand(ctx context.Context, cfg config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return fmt.Errorf("引数が足りません\n%s", usage)
+		}
+		return runMigrate(ctx, cfg, args[1:])
+	case "create-account":
+		if len(args) != 3 {
+			return fmt.Errorf("引数が足りません\n%s", usage)
+		}
+		return createAccount(ctx, cfg, args[1], model.Role(args[2]))
+	default:
+		return fmt.Errorf("不明なコマンドです: %s\n%s", args[0], usage)
+	}
+}
+
+// runMigrate は migrations パッケージに埋め込んだSQLをDBに適用する
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	switch args[0] {
+	case "up", "down", "redo", "status":
+	default:
+		return fmt.Errorf("不明なコマンドです: migrate %s\n%s", args[0], usage)
+	}
+	if cfg.DB.Driver != config.DriverMySQL {
+		return errors.New("マイグレーションは db.driver が mysql の時だけ使えます")
+	}
+	list, err := migrate.Load(migrations.FS)
+	if err != nil {
+		return err
+	}
+	db, err := infrastructure.OpenDB(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := migrate.New(db, list)
+	m.Out = os.Stdout
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("戻す数は1以上の数値で指定してください: %q", args[1])
+			}
+			steps = n
+		}
+		return m.Down(ctx, steps)
+	case "redo":
+		return m.Redo(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "未適用"
+			if s.Applied {
+				state = "適用済み " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			switch {
+			case s.Missing:
+				state += " (ファイルがありません)"
+			case s.Modified:
+				state += " (適用後に書き換えられています)"
+			}
+			fmt.Printf("%-40s %s\n", s.ID, state)
+		}
+	}
+	return nil
+}
+
+// createAccount は最初の管理者などを作るためのコマンド
+func createAccount(ctx context.Context, cfg config.Config, email string, role model.Role) error {
+	password := os.Getenv("APP_ACCOUNT_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "パスワード: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("パスワードを読み込めません: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	repos, closeRepos, err := infrastructure.OpenRepositories(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer closeRepos()
+
+	svc := service.NewAuthService(repos.Accounts)
+	id, err := svc.CreateAccount(ctx, email, password, role)
+	if err != nil {
+		return fmt.Errorf("アカウントを作成できません: %w", err)
+	}
+	fmt.Printf("アカウントを作成しました (id=%d, email=%s, role=%s)\n", id, email, role)
+	return nil
+}
@@ -1,11 +1,29 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"go-example/admin-example/internal/avatar"
+	"go-example/admin-example/internal/config"
 	"go-example/admin-example/internal/controller"
 	"go-example/admin-example/internal/infrastructure"
-	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/logging"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/ratelimit"
+	"go-example/admin-example/internal/secretbox"
 	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/sessionstore"
+	"go-example/admin-example/internal/token"
+	"go-example/admin-example/internal/validation"
+	"go-example/admin-example/views"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	_ "github.com/go-sql-driver/mysql"
@@ -25,50 +43,269 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 }
 
 func main() {
-	// 1. DB接続 (ここは共通パッケージ等に切り出してもOK)
-	db, _ := sql.Open("mysql", "root@tcp(127.0.0.1:3306)/test?parseTime=true")
+	// 0. 設定の読み込み (config/config.yaml + config.<APP_ENV>.yaml + 環境変数)
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("設定を読み込めませんでした: %v", err)
+	}
+
+	// ログは JSON で標準出力に書く。リクエストの中で書いたものには request_id が付く (internal/logging)
+	// SetDefault すると log.Printf などで書いたものも同じ形式になる
+	level, _ := logging.ParseLevel(cfg.Log.Level) // 値は config.Validate でチェック済み
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	// サブコマンドが指定されていればそれを実行して終わる (commands.go)
+	// 例) go run ./cmd/api migrate up
+	//     go run ./cmd/api create-account admin@example.com admin
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), cfg, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// サーバーが途中で止まった時の終了コード。os.Exit は defer を飛ばすので、
+	// 最初に登録して closeRepos などの後に実行させる
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	// 1. DB接続 (つながるまでリトライし、ダメなら起動しない)
+	// db.driver が memory なら MySQL を使わずメモリに保存する
+	repos, closeRepos, err := infrastructure.OpenRepositories(context.Background(), cfg.DB)
+	if err != nil {
+		log.Fatalf("DBに接続できないため起動を中止します: %v", err)
+	}
+	defer closeRepos()
 
 	// 2. DI (依存性の注入)
-	repo := repository.NewUserRepository(db)  // Repoを作る
-	svc := service.NewUserService(repo)       // RepoをServiceに入れる
-	ctrl := controller.NewUserController(svc) // ServiceをControllerに入れる
+	avatars, err := avatar.NewStore(filepath.Join(cfg.Server.UploadDir, "avatars"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	svc := service.NewUserService(repos.Users, repos.Tx) // RepoをServiceに入れる
+	ctrl := controller.NewUserController(svc, avatars)   // ServiceをControllerに入れる
+	apiCtrl := controller.NewAPIUserController(svc)      // JSON API も同じServiceを使う
+	healthCtrl := controller.NewHealthController(repos.Health)
+	authSvc := service.NewAuthService(repos.Accounts)
+	authCtrl := controller.NewAuthController(authSvc)
+	auditCtrl := controller.NewAuditController(service.NewAuditService(repos.Audit))
+
+	// セッションの中身の保存先 (session.store)。db なら db.driver と同じところ
+	sessionBackend := repos.Sessions
+	if cfg.Session.Store == config.SessionStoreMemory {
+		sessionBackend = sessionstore.NewMemoryBackend()
+	}
+	sessionCtrl := controller.NewSessionController(service.NewSessionService(sessionBackend, repos.Accounts, repos.Users))
+
+	// 招待メールの送り方は mail.driver (smtp / file / memory)
+	mailer, err := infrastructure.NewMailer(cfg.Mail)
+	if err != nil {
+		log.Fatal(err)
+	}
+	signer := token.NewSigner([]byte(cfg.TokenSecret()))
+	inviteSvc := service.NewInviteService(repos.Users, repos.Tx, mailer, signer,
+		service.InviteOptions{TTL: cfg.Token.InviteTTL, BaseURL: cfg.Server.BaseURL})
+	inviteCtrl := controller.NewInviteController(inviteSvc)
+	// パスワードの再設定。申し込みの回数は IP アドレスごと・メールアドレスごとに制限する (config の rate_limit)
+	resetSvc := service.NewPasswordResetService(repos.Accounts, repos.Tx, sessionBackend, mailer, signer,
+		service.PasswordResetOptions{TTL: cfg.Token.PasswordResetTTL, BaseURL: cfg.Server.BaseURL})
+	resetCtrl := controller.NewPasswordResetController(resetSvc,
+		ratelimit.New(cfg.RateLimit.PasswordResetPerIP, cfg.RateLimit.PasswordResetWindow),
+		ratelimit.New(cfg.RateLimit.PasswordResetPerAccount, cfg.RateLimit.PasswordResetWindow))
+
+	// 2要素認証。認証アプリの秘密鍵は mfa.encryption_key で暗号化して保存する
+	var mfaRoles []model.Role
+	for _, r := range cfg.MFA.RequiredRoles {
+		mfaRoles = append(mfaRoles, model.Role(r))
+	}
+	twoFactorSvc := service.NewTwoFactorService(repos.Accounts, repos.Tx, secretbox.New(cfg.MFAEncryptionKey()),
+		service.TwoFactorOptions{Issuer: cfg.MFA.Issuer, RequiredRoles: mfaRoles})
+	// コードを間違えた回数はアカウントごとに数える (rate_limit.two_factor_failures)
+	twoFactorCtrl := controller.NewTwoFactorController(twoFactorSvc,
+		ratelimit.New(cfg.RateLimit.TwoFactorFailures, cfg.RateLimit.TwoFactorWindow))
+
+	// SIGINT / SIGTERM を受けたら終わる ctx (テンプレートの監視と、5. の停止処理で使う)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// 3. Echoの起動
 	e := echo.New()
-	e.Use(middleware.Logger())
+	// エラーは 404 / 422 / 500 などに振り分けて、画面なら views/errors、API なら JSON で返す
+	e.HTTPErrorHandler = controller.HTTPErrorHandler
+	// c.RealIP() で使う利用者の IP アドレス。プロキシの後ろなら X-Forwarded-For を信じる
+	if cfg.Server.TrustProxy {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
+	e.Use(middleware.RequestID())           // X-Request-Id を発行する
+	e.Use(controller.RequestIDToContext)    // それを監査ログなどで使えるよう context に載せる
+	e.Use(controller.RequestLogger(logger)) // リクエストごとに1行、JSON で書く
 	//e.Renderer = &TemplateRenderer{}
-	e.Renderer = &infrastructure.TemplateRenderer{}
+	renderer := &infrastructure.TemplateRenderer{
+		ViewsDir: cfg.Server.ViewsDir,
+		Globals:  controller.TemplateGlobals, // ヘッダーのログイン情報など
+	}
+	if cfg.Server.ViewsDir == "" {
+		renderer.FS = views.FS // バイナリに埋め込んだテンプレート
+	}
+	if cfg.Server.TemplateReload {
+		go renderer.Watch(ctx, time.Second)
+	}
+	e.Renderer = renderer
 
 	// 1. セッションの設定を追加（これが今回のエラーの直接の原因）
-	// "secret-key" は適当な文字列でOKです。これがセッションの暗号化に使われます。
-	e.Use(session.Middleware(sessions.NewCookieStore([]byte("secret-key"))))
+	// 中身はサーバー側 (sessionBackend) に置き、Cookie には署名・暗号化した ID だけを入れる
+	// 鍵は APP_SESSION_SECRET から。替える時は前の鍵を APP_SESSION_PREVIOUS_SECRETS に入れておく
+	store := sessionstore.NewStore(sessionBackend, sessionstore.Options{
+		Secrets: cfg.Session.Secrets(),
+		// JavaScript から読めないようにし、他サイトからの POST には Cookie を付けない
+		Cookie: sessions.Options{
+			Path:     "/",
+			MaxAge:   int(cfg.Session.MaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		IPExtractor: e.IPExtractor, // 一覧画面に出す IP アドレスも c.RealIP() と同じ取り方にする
+	})
+	go store.Sweep(ctx, cfg.Session.SweepInterval) // 期限切れのセッションを消す
+	e.Use(session.Middleware(store))
 
 	// バリデーターを登録
-	e.Validator = &CustomValidator{validator: validator.New()}
+	e.Validator = &CustomValidator{validator: validation.New()} // エラーのキーはフォームの name になる
 
 	// CSRFミドルウェアを登録
-	/*e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-		TokenLookup: "form:csrf", // フォーム内の <input name="csrf"> を見る
-	}))*/
+	// POST などはフォームの csrf か X-CSRF-Token ヘッダーのトークンが無いと 403 (controller/csrf.go)
+	e.Use(controller.VerifyCSRF)
 
 	// 静的ファイルの配信
-	e.Static("/static", "public")
+	e.Static("/static", cfg.Server.PublicDir)
 
 	// 4. ルーティング
-	e.GET("/users", ctrl.Index)
-	e.POST("/users/:id/update", ctrl.Update)
+	// ヘルスチェック (liveness / readiness)
+	e.GET("/healthz", healthCtrl.Healthz)
+	e.GET("/readyz", healthCtrl.Readyz)
+
+	// ログイン・ログアウト (ここはログインしていなくても使える)
+	e.GET("/login", authCtrl.LoginPage)
+	e.POST("/login", authCtrl.Login)
+	e.POST("/logout", authCtrl.Logout)
+	// 2要素認証を有効にしている人は、パスワードの後にここで確認コードを入れる
+	e.GET("/login/2fa", twoFactorCtrl.LoginPage)
+	e.POST("/login/2fa", twoFactorCtrl.Login)
+	// 表示する言語の切り替え (ja / en)
+	e.GET("/locale/:lang", controller.SetLocale)
+	// 招待メールのリンクから、招待された人がパスワードを決める
+	e.GET("/invitations/accept", inviteCtrl.AcceptPage)
+	e.POST("/invitations/accept", inviteCtrl.Accept)
+	// パスワードを忘れた時の再設定 (申し込み → メールのリンクから新しいパスワードを決める)
+	e.GET("/password/forgot", resetCtrl.ForgotPage)
+	e.POST("/password/forgot", resetCtrl.Forgot)
+	e.GET("/password/reset", resetCtrl.ResetPage)
+	e.POST("/password/reset", resetCtrl.Reset)
+
+	// 自分の2要素認証の設定。必須の役割でも設定する前に開けるよう、RequireTwoFactor は付けない
+	account := e.Group("/account", authCtrl.RequireLogin, controller.Authorize(controller.RoutePermissions))
+	account.GET("/2fa", twoFactorCtrl.Show)
+	account.POST("/2fa/setup", twoFactorCtrl.Setup)
+	account.GET("/2fa/qr.png", twoFactorCtrl.QRCode)
+	account.POST("/2fa/enable", twoFactorCtrl.Enable)
+	account.POST("/2fa/disable", twoFactorCtrl.Disable)
+	account.POST("/2fa/recovery-codes", twoFactorCtrl.RegenerateRecoveryCodes)
+
+	// ここから下はログインが必要。必要な権限は controller.RoutePermissions を参照
+	// 役割で2要素認証が必須 (mfa.required_roles) なのに設定していなければ、設定画面へ送る
+	admin := e.Group("", authCtrl.RequireLogin, controller.Authorize(controller.RoutePermissions),
+		controller.RequireTwoFactor(twoFactorSvc))
+
+	admin.GET("/users", ctrl.Index)
+	admin.POST("/users/:id/update", ctrl.Update)
 
 	// 【新規登録】
 	// 1. 画面を表示する
-	e.GET("/users/create", ctrl.New) // ← これを足す
+	admin.GET("/users/create", ctrl.New) // ← これを足す
 	// 2. フォームから送られてきたデータを保存する
-	e.POST("/users", ctrl.Create)
+	admin.POST("/users", ctrl.Create)
 
 	// 【編集・更新】
 	// 1. 画面を表示する (IDをURLに含む)
-	e.GET("/users/edit/:id", ctrl.Edit) // ← これを足す
+	admin.GET("/users/edit/:id", ctrl.Edit) // ← これを足す
 	// 2. データを更新する
-	e.POST("/users/update", ctrl.Update)
+	admin.POST("/users/update", ctrl.Update)
+
+	// 【削除】
+	// 一覧画面の hx-delete ボタンから呼ばれる (論理削除)
+	admin.DELETE("/users/:id", ctrl.Delete)
+	admin.GET("/users/:id/avatar", ctrl.Avatar) // ?size=thumb でサムネイル
+
+	// 【招待】
+	// 招待中のユーザーを登録してメールを送る。再送・取り消しは一覧の行のボタンから
+	admin.GET("/users/invite", inviteCtrl.New)
+	admin.POST("/users/invite", inviteCtrl.Create)
+	admin.POST("/users/:id/invite/resend", inviteCtrl.Resend)
+	admin.POST("/users/:id/invite/revoke", inviteCtrl.Revoke)
+
+	// 【ゴミ箱・復元】
+	admin.GET("/users/trash", ctrl.Trash)
+	admin.POST("/users/:id/restore", ctrl.Restore)
+
+	// 【CSV 出力・取り込み】
+	// 取り込みは 確認 (POST /users/import) → 登録 (POST /users/import/commit) の2段階
+	admin.GET("/users/export", ctrl.Export)
+	admin.GET("/users/import", ctrl.ImportForm)
+	admin.POST("/users/import", ctrl.ImportPreview)
+	admin.POST("/users/import/commit", ctrl.ImportCommit)
+
+	// 【監査ログ】
+	admin.GET("/audit", auditCtrl.Index)
+
+	// 【ログイン中のセッション】
+	// 一覧から他の端末のセッションを終了させる（パスワードは変えずに強制ログアウト）
+	admin.GET("/sessions", sessionCtrl.Index)
+	admin.POST("/sessions/:id/revoke", sessionCtrl.Revoke)
+
+	// 【JSON API】
+	// 画面と同じServiceを /api/v1 以下で JSON として公開する
+	api := admin.Group("/api/v1", controller.RequireJSON)
+	api.GET("/users", apiCtrl.List)
+	api.GET("/users/:id", apiCtrl.Get)
+	api.POST("/users", apiCtrl.Create)
+	api.PUT("/users/:id", apiCtrl.Update)
+	api.DELETE("/users/:id", apiCtrl.Delete)
+
+	// 5. 起動と停止
+	// SIGINT / SIGTERM を受けたら、まず /readyz を 503 にしてロードバランサーに外してもらう
+	// shutdown_drain の間はそのまま受け付け、そのあと新規受付をやめて処理中のリクエストが終わるのを待つ
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := e.Start(cfg.Server.Addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		// ここで log.Fatal すると defer が実行されないので、main に戻して終わる
+		slog.Error("サーバーを起動できませんでした", "error", err.Error())
+		exitCode = 1
+		return
+	case <-ctx.Done():
+	}
+	stop() // もう一度シグナルを送れば、待たずにすぐ止まる
+	healthCtrl.MarkShuttingDown()
+	slog.Info("停止シグナルを受け取りました。ロードバランサーから外れるのを待ちます", "drain", cfg.Server.ShutdownDrain.String())
+	time.Sleep(cfg.Server.ShutdownDrain)
 
-	e.Logger.Fatal(e.Start(":8080"))
+	slog.Info("新規受付をやめ、処理中のリクエストが終わるのを待ちます", "timeout", cfg.Server.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		slog.Error("時間内に停止できませんでした", "error", err.Error())
+	}
+	// closeRepos() は defer で実行される
 }
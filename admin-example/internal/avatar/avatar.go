@@ -0,0 +1,225 @@
+// Package avatar はユーザーのアバター画像をローカルのディスクに保存する。
+//
+// アップロードされた画像 (PNG / JPEG / GIF) は、そのまま置かずに正方形に切り抜いて縮小し、
+// PNG に変換し直してから保存する（画像に紛れ込ませたスクリプトや、巨大な画像を配信しないため）
+//
+//	<dir>/<key>.png        … 表示用 (ImageSize px)
+//	<dir>/<key>_thumb.png  … 一覧用のサムネイル (ThumbSize px)
+//
+// key はランダムな文字列で、DB の users.avatar_path にはこれだけを入れる
+package avatar
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // image.Decode で GIF を読めるようにする
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const (
+	MaxFileSize = 5 << 20 // アップロードできるファイルの大きさ (5MB)
+	// 読み込む画像の縦横の上限。小さなファイルでも展開すると巨大になる画像を弾く
+	MaxDimension = 4000
+
+	ImageSize = 256
+	ThumbSize = 64
+)
+
+var (
+	// ErrUnsupported: 画像として読めない（対応していない形式）
+	ErrUnsupported = errors.New("PNG / JPEG / GIF の画像を選んでください")
+	// ErrTooLarge: ファイルか画像の縦横が大きすぎる
+	ErrTooLarge = fmt.Errorf("画像が大きすぎます（%dMB・縦横 %dpx まで）", MaxFileSize>>20, MaxDimension)
+	// ErrNotFound: key が正しくないか、ファイルが無い
+	ErrNotFound = errors.New("画像が見つかりません")
+)
+
+// key の形式（Save が作るもの）。それ以外はファイル名として使わない（../ などを防ぐ）
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Store: アバター画像の保存先
+type Store struct {
+	dir string
+}
+
+// NewStore は dir に保存する Store を作る（dir が無ければ作る）
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("アバター画像の保存先 %s を作れません: %w", dir, err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Save は r の画像を表示用とサムネイルに縮小して保存し、key を返す
+func (s *Store) Save(r io.Reader) (string, error) {
+	// 上限 + 1 バイトまで読んで、超えていたら弾く
+	b, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > MaxFileSize {
+		return "", ErrTooLarge
+	}
+
+	// 先にヘッダーだけ読んで大きさを確認してから、全体を展開する
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return "", ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+
+	key, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	square := cropSquare(img)
+	if err := s.write(s.file(key, false), resize(square, ImageSize)); err != nil {
+		return "", err
+	}
+	if err := s.write(s.file(key, true), resize(square, ThumbSize)); err != nil {
+		s.Remove(key)
+		return "", err
+	}
+	return key, nil
+}
+
+// Path は key の画像ファイルの場所を返す。thumb ならサムネイル
+func (s *Store) Path(key string, thumb bool) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", ErrNotFound
+	}
+	path := s.file(key, thumb)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Remove は key の画像を消す。無ければ何もしない
+func (s *Store) Remove(key string) error {
+	if !keyPattern.MatchString(key) {
+		return nil
+	}
+	for _, thumb := range []bool{false, true} {
+		if err := os.Remove(s.file(key, thumb)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) file(key string, thumb bool) string {
+	if thumb {
+		return filepath.Join(s.dir, key+"_thumb.png")
+	}
+	return filepath.Join(s.dir, key+".png")
+}
+
+// write は一時ファイルに書いてから名前を変える（書きかけのファイルを配信しないため）
+func (s *Store) write(path string, img image.Image) error {
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // 名前を変えた後なら何もしない
+
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, img); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func newKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// cropSquare は画像の真ん中を正方形に切り抜く
+func cropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	size := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-size)/2
+	y := b.Min.Y + (b.Dy()-size)/2
+	return subImage(img, image.Rect(x, y, x+size, y+size))
+}
+
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	// SubImage が無い画像は見えている範囲ごと使う（標準のデコーダーの画像には全てある）
+	return img
+}
+
+// resize は正方形の画像を size px に縮小する（小さい画像は拡大する）。
+// 縮小先の1ピクセルに入る元のピクセルを平均する (area average)。
+// 拡大する時は一番近いピクセルの色になる
+func resize(src image.Image, size int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	b := src.Bounds()
+	for y := 0; y < size; y++ {
+		y0 := b.Min.Y + y*b.Dy()/size
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/size, y0+1)
+		for x := 0; x < size; x++ {
+			x0 := b.Min.X + x*b.Dx()/size
+			x1 := max(b.Min.X+(x+1)*b.Dx()/size, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					// 透明な部分の色が混ざらないよう、不透明度で重み付けする
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a == 0 {
+				continue // 全て透明
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a >> 8),
+				G: uint8(g / a >> 8),
+				B: uint8(bl / a >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,101 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pngOf は w x h の、左半分が赤・右半分が青の PNG を作る
+func pngOf(t *testing.T, w, h int) *bytes.Buffer {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestStore_Save(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	assert.NoError(t, err)
+
+	// 横長の画像は真ん中を正方形に切り抜く
+	key, err := s.Save(pngOf(t, 600, 300))
+	assert.NoError(t, err)
+
+	path, err := s.Path(key, false)
+	assert.NoError(t, err)
+	img := decodePNG(t, path)
+	assert.Equal(t, image.Rect(0, 0, ImageSize, ImageSize), img.Bounds())
+	// 左端は赤・右端は青のまま
+	r, _, b, _ := img.At(0, 0).RGBA()
+	assert.True(t, r > b)
+	r, _, b, _ = img.At(ImageSize-1, 0).RGBA()
+	assert.True(t, b > r)
+
+	thumb, err := s.Path(key, true)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, ThumbSize, ThumbSize), decodePNG(t, thumb).Bounds())
+}
+
+func TestStore_SaveRejects(t *testing.T) {
+	s, _ := NewStore(t.TempDir())
+
+	_, err := s.Save(strings.NewReader("<script>alert(1)</script>"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = s.Save(pngOf(t, MaxDimension+1, 1))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	_, err = s.Save(bytes.NewReader(make([]byte, MaxFileSize+1)))
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestStore_PathAndRemove(t *testing.T) {
+	s, _ := NewStore(t.TempDir())
+	key, err := s.Save(pngOf(t, 10, 10))
+	assert.NoError(t, err)
+
+	// Save が作った形式以外の key はファイル名に使わない
+	_, err = s.Path("../../etc/passwd", false)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, s.Remove(key))
+	_, err = s.Path(key, false)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Path(key, true)
+	assert.ErrorIs(t, err, ErrNotFound)
+	// 2回目もエラーにしない
+	assert.NoError(t, s.Remove(key))
+}
@@ -0,0 +1,505 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-example/admin-example/internal/logging"
+	"go-example/admin-example/internal/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 環境（プロファイル）名
+const (
+	EnvDev  = "dev"
+	EnvTest = "test"
+	EnvProd = "prod"
+)
+
+// 保存先 (db.driver)
+const (
+	DriverMySQL  = "mysql"
+	DriverMemory = "memory" // プロセス内のメモリに保存する。テストやDBの無い環境向け
+)
+
+// メールの送り方 (mail.driver)
+const (
+	MailDriverSMTP   = "smtp"
+	MailDriverFile   = "file"   // 送らずに mail.dir に .eml で保存する（開発用）
+	MailDriverMemory = "memory" // 送らずにメモリに溜める（テスト用）
+)
+
+// セッションの保存先 (session.store)
+const (
+	SessionStoreDB     = "db"     // db.driver と同じところ (mysql なら sessions テーブル)
+	SessionStoreMemory = "memory" // プロセス内のメモリ (Redis の代わり)。再起動で消え、複数台では共有されない
+)
+
+// 本番で使ってはいけないセッション鍵（開発用設定に書いてあるもの）
+const insecureSessionSecret = "secret-key"
+
+// Config: アプリ全体の設定
+// 読み込み順は config.yaml → config.<env>.yaml → 環境変数（後のものが優先）
+type Config struct {
+	Env       string          `yaml:"env"`
+	Server    ServerConfig    `yaml:"server"`
+	DB        DBConfig        `yaml:"db"`
+	Session   SessionConfig   `yaml:"session"`
+	Mail      MailConfig      `yaml:"mail"`
+	Token     TokenConfig     `yaml:"token"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	MFA       MFAConfig       `yaml:"mfa"`
+	Log       LogConfig       `yaml:"log"`
+}
+
+type ServerConfig struct {
+	Addr      string `yaml:"addr"`       // 例) ":8080"
+	ViewsDir  string `yaml:"views_dir"`  // Ace テンプレートの置き場所。空ならバイナリに埋め込んだもの (views.FS) を使う
+	PublicDir string `yaml:"public_dir"` // /static で配信するディレクトリ
+	// アップロードされたファイル（アバター画像）の保存先。/static とは別にして、直接は配信しない
+	UploadDir string `yaml:"upload_dir"`
+	// メールに書くリンクの先頭（例: https://admin.example.com）。末尾の / は不要
+	BaseURL string `yaml:"base_url"`
+	// ロードバランサーなどの後ろで動かす時は true。利用者の IP アドレスを X-Forwarded-For から取る
+	// (直接公開している時に true にすると、ヘッダーを偽って回数の制限を逃れられてしまう)
+	TrustProxy bool `yaml:"trust_proxy"`
+	// 開発用。views_dir のテンプレートを書き換えたら、再起動しなくても反映する
+	TemplateReload bool `yaml:"template_reload"`
+	// 停止シグナル (SIGINT/SIGTERM) を受けてから、処理中のリクエストを待つ最大時間
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// 停止シグナルを受けてから新規受付をやめるまでの時間。この間 /readyz は 503 を返すので、
+	// ロードバランサーが振り分け先から外すのを待てる（ロードバランサーの確認間隔より長くする）
+	ShutdownDrain time.Duration `yaml:"shutdown_drain"`
+}
+
+type DBConfig struct {
+	Driver string `yaml:"driver"` // mysql / memory
+	DSN    string `yaml:"dsn"`    // driver が mysql の時だけ使う
+	// 起動時の疎通確認 (Ping) のリトライ回数と、最初の待ち時間（失敗するたびに倍にする）
+	PingRetries  int           `yaml:"ping_retries"`
+	PingInterval time.Duration `yaml:"ping_interval"`
+	// これより時間がかかったクエリを warn でログに書く。0 なら書かない
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold"`
+}
+
+type SessionConfig struct {
+	Secret string `yaml:"secret"` // Cookie の署名・暗号化に使う鍵
+	// 替える前の鍵（Cookie を読むだけ）。鍵を替えてもログイン中の人がログアウトされないようにする
+	PreviousSecrets []string `yaml:"previous_secrets"`
+	Store           string   `yaml:"store"` // db / memory
+	// ログインしたままでいられる期間
+	MaxAge time.Duration `yaml:"max_age"`
+	// 期限切れのセッションを消す間隔
+	SweepInterval time.Duration `yaml:"sweep_interval"`
+}
+
+// Secrets は Cookie の鍵を、今の鍵・古い鍵の順に返す
+func (c SessionConfig) Secrets() []string {
+	return append([]string{c.Secret}, c.PreviousSecrets...)
+}
+
+type MailConfig struct {
+	Driver string     `yaml:"driver"` // smtp / file / memory
+	From   string     `yaml:"from"`   // 差出人 (例: "Admin <no-reply@example.com>")
+	Dir    string     `yaml:"dir"`    // driver が file の時の保存先
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Addr     string `yaml:"addr"`     // 例) "smtp.example.com:587"
+	Username string `yaml:"username"` // 空なら認証しない
+	Password string `yaml:"password"`
+}
+
+type TokenConfig struct {
+	// 招待リンクなどの署名に使う鍵。空なら session.secret を使う
+	Secret string `yaml:"secret"`
+	// 招待リンクの有効期間
+	InviteTTL time.Duration `yaml:"invite_ttl"`
+	// パスワードの再設定のリンクの有効期間
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl"`
+}
+
+// RateLimitConfig: 回数の制限
+// パスワードの再設定の申し込みは（メールの送りつけを防ぐ）、window の間に
+// 同じ IP アドレスから per_ip 回、同じメールアドレスに per_account 回まで
+type RateLimitConfig struct {
+	PasswordResetPerIP      int           `yaml:"password_reset_per_ip"`
+	PasswordResetPerAccount int           `yaml:"password_reset_per_account"`
+	PasswordResetWindow     time.Duration `yaml:"password_reset_window"`
+	// 2要素認証のコードは、two_factor_window の間にアカウントごとに two_factor_failures 回まで間違えられる
+	// 超えたら、セッションやパスワードを入れ直しても、そのアカウントは window が過ぎるまでコードを受け付けない（総当たりを防ぐ）
+	// 回数はプロセスのメモリで数える (internal/ratelimit)。複数台なら台数分まで間違えられる
+	TwoFactorFailures int           `yaml:"two_factor_failures"`
+	TwoFactorWindow   time.Duration `yaml:"two_factor_window"`
+}
+
+// TokenSecret はリンクの署名に使う鍵を返す（token.secret が無ければ session.secret）
+func (c Config) TokenSecret() string {
+	if c.Token.Secret != "" {
+		return c.Token.Secret
+	}
+	return c.Session.Secret
+}
+
+// MFAConfig: 2要素認証 (TOTP)
+type MFAConfig struct {
+	// 2要素認証を必ず使う役割（例: [admin]）。まだ設定していない人は、設定するまで管理画面を使えない
+	RequiredRoles []string `yaml:"required_roles"`
+	// 認証アプリに表示する発行者の名前
+	Issuer string `yaml:"issuer"`
+	// 認証アプリの秘密鍵を DB に保存する時の暗号化の鍵。空なら session.secret を使う
+	// (session.secret を替えると2要素認証を設定し直すことになるので、本番では別に設定する)
+	EncryptionKey string `yaml:"encryption_key"`
+}
+
+// MFAEncryptionKey は秘密鍵の暗号化に使う鍵を返す（mfa.encryption_key が無ければ session.secret）
+func (c Config) MFAEncryptionKey() string {
+	if c.MFA.EncryptionKey != "" {
+		return c.MFA.EncryptionKey
+	}
+	return c.Session.Secret
+}
+
+type LogConfig struct {
+	// debug / info / warn / error。debug にすると全ての SQL と実行時間を書く
+	Level string `yaml:"level"`
+}
+
+// Default は設定ファイルに何も書かれていない時の値
+func Default() Config {
+	return Config{
+		Env: EnvDev,
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ViewsDir:        "views",
+			PublicDir:       "public",
+			UploadDir:       "uploads",
+			BaseURL:         "http://localhost:8080",
+			ShutdownTimeout: 10 * time.Second,
+		},
+		DB: DBConfig{
+			Driver:             DriverMySQL,
+			PingRetries:        5,
+			PingInterval:       500 * time.Millisecond,
+			SlowQueryThreshold: 200 * time.Millisecond,
+		},
+		Session: SessionConfig{
+			Store:         SessionStoreDB,
+			MaxAge:        7 * 24 * time.Hour,
+			SweepInterval: 10 * time.Minute,
+		},
+		Mail: MailConfig{
+			Driver: MailDriverFile,
+			From:   "Admin <no-reply@example.com>",
+			Dir:    "tmp/mail",
+		},
+		Token: TokenConfig{InviteTTL: 72 * time.Hour, PasswordResetTTL: time.Hour},
+		RateLimit: RateLimitConfig{
+			PasswordResetPerIP:      10,
+			PasswordResetPerAccount: 3,
+			PasswordResetWindow:     time.Hour,
+			TwoFactorFailures:       10,
+			TwoFactorWindow:         time.Hour,
+		},
+		MFA: MFAConfig{Issuer: "Admin"},
+		Log: LogConfig{Level: "info"},
+	}
+}
+
+// Load は APP_ENV（省略時は dev）と APP_CONFIG_DIR（省略時は "config"）を見て設定を読み込む
+func Load() (Config, error) {
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = EnvDev
+	}
+	dir := os.Getenv("APP_CONFIG_DIR")
+	if dir == "" {
+		dir = "config"
+	}
+	return LoadFrom(dir, env)
+}
+
+// LoadFrom は dir 以下の config.yaml と config.<env>.yaml を読み込み、
+// 環境変数で上書きしてからチェックする
+func LoadFrom(dir, env string) (Config, error) {
+	cfg := Default()
+
+	// 共通設定 → 環境ごとの設定 の順に重ねる（ファイルが無ければ飛ばす）
+	for _, name := range []string{"config.yaml", "config." + env + ".yaml"} {
+		if err := mergeFile(&cfg, filepath.Join(dir, name)); err != nil {
+			return Config{}, err
+		}
+	}
+	cfg.Env = env
+
+	if err := applyEnv(&cfg); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// mergeFile は YAML ファイルの内容を cfg に上書きする（書かれていない項目はそのまま）
+func mergeFile(cfg *Config, path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("設定ファイル %s を読み込めません: %w", path, err)
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("設定ファイル %s の形式が正しくありません: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv は環境変数で設定を上書きする（秘密情報はファイルではなくこちらで渡す）
+func applyEnv(cfg *Config) error {
+	strVars := map[string]*string{
+		"APP_SERVER_ADDR":        &cfg.Server.Addr,
+		"APP_VIEWS_DIR":          &cfg.Server.ViewsDir,
+		"APP_PUBLIC_DIR":         &cfg.Server.PublicDir,
+		"APP_UPLOAD_DIR":         &cfg.Server.UploadDir,
+		"APP_BASE_URL":           &cfg.Server.BaseURL,
+		"APP_DB_DRIVER":          &cfg.DB.Driver,
+		"APP_DB_DSN":             &cfg.DB.DSN,
+		"APP_SESSION_SECRET":     &cfg.Session.Secret,
+		"APP_SESSION_STORE":      &cfg.Session.Store,
+		"APP_MAIL_DRIVER":        &cfg.Mail.Driver,
+		"APP_MAIL_FROM":          &cfg.Mail.From,
+		"APP_MAIL_DIR":           &cfg.Mail.Dir,
+		"APP_SMTP_ADDR":          &cfg.Mail.SMTP.Addr,
+		"APP_SMTP_USERNAME":      &cfg.Mail.SMTP.Username,
+		"APP_SMTP_PASSWORD":      &cfg.Mail.SMTP.Password,
+		"APP_TOKEN_SECRET":       &cfg.Token.Secret,
+		"APP_MFA_ISSUER":         &cfg.MFA.Issuer,
+		"APP_MFA_ENCRYPTION_KEY": &cfg.MFA.EncryptionKey,
+		"APP_LOG_LEVEL":          &cfg.Log.Level,
+	}
+	for key, dst := range strVars {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+
+	// 鍵を替える時は、前の鍵をカンマ区切りで渡す
+	if v, ok := os.LookupEnv("APP_SESSION_PREVIOUS_SECRETS"); ok {
+		cfg.Session.PreviousSecrets = nil
+		for _, secret := range strings.Split(v, ",") {
+			if secret = strings.TrimSpace(secret); secret != "" {
+				cfg.Session.PreviousSecrets = append(cfg.Session.PreviousSecrets, secret)
+			}
+		}
+	}
+	// 2要素認証を必ず使う役割もカンマ区切り（空にすると誰にも求めない）
+	if v, ok := os.LookupEnv("APP_MFA_REQUIRED_ROLES"); ok {
+		cfg.MFA.RequiredRoles = nil
+		for _, role := range strings.Split(v, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				cfg.MFA.RequiredRoles = append(cfg.MFA.RequiredRoles, role)
+			}
+		}
+	}
+	if v, ok := os.LookupEnv("APP_TEMPLATE_RELOAD"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("APP_TEMPLATE_RELOAD は true か false で指定してください: %q", v)
+		}
+		cfg.Server.TemplateReload = b
+	}
+	if v, ok := os.LookupEnv("APP_TRUST_PROXY"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("APP_TRUST_PROXY は true か false で指定してください: %q", v)
+		}
+		cfg.Server.TrustProxy = b
+	}
+	if v, ok := os.LookupEnv("APP_SHUTDOWN_TIMEOUT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_SHUTDOWN_TIMEOUT は 10s や 1m の形式で指定してください: %q", v)
+		}
+		cfg.Server.ShutdownTimeout = d
+	}
+	if v, ok := os.LookupEnv("APP_SHUTDOWN_DRAIN"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_SHUTDOWN_DRAIN は 5s や 1m の形式で指定してください: %q", v)
+		}
+		cfg.Server.ShutdownDrain = d
+	}
+	if v, ok := os.LookupEnv("APP_DB_PING_RETRIES"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("APP_DB_PING_RETRIES は数値で指定してください: %q", v)
+		}
+		cfg.DB.PingRetries = n
+	}
+	if v, ok := os.LookupEnv("APP_DB_PING_INTERVAL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_DB_PING_INTERVAL は 500ms や 2s の形式で指定してください: %q", v)
+		}
+		cfg.DB.PingInterval = d
+	}
+	if v, ok := os.LookupEnv("APP_DB_SLOW_QUERY_THRESHOLD"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_DB_SLOW_QUERY_THRESHOLD は 200ms や 1s の形式で指定してください: %q", v)
+		}
+		cfg.DB.SlowQueryThreshold = d
+	}
+	if v, ok := os.LookupEnv("APP_INVITE_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_INVITE_TTL は 72h や 30m の形式で指定してください: %q", v)
+		}
+		cfg.Token.InviteTTL = d
+	}
+	if v, ok := os.LookupEnv("APP_PASSWORD_RESET_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("APP_PASSWORD_RESET_TTL は 1h や 30m の形式で指定してください: %q", v)
+		}
+		cfg.Token.PasswordResetTTL = d
+	}
+	return nil
+}
+
+// Validate は起動に必要な項目が揃っているかをチェックする
+// 足りないものはまとめて報告する
+func (c Config) Validate() error {
+	var problems []string
+
+	switch c.Env {
+	case EnvDev, EnvTest, EnvProd:
+	default:
+		problems = append(problems, fmt.Sprintf("env は dev / test / prod のどれかにしてください (%q)", c.Env))
+	}
+	if c.Server.Addr == "" {
+		problems = append(problems, "server.addr (APP_SERVER_ADDR) が未設定です")
+	}
+	// 埋め込んだテンプレートは書き換えられないので、読み直すなら views_dir が要る
+	if c.Server.TemplateReload && c.Server.ViewsDir == "" {
+		problems = append(problems, "server.template_reload を使う時は server.views_dir (APP_VIEWS_DIR) を指定してください")
+	}
+	if c.Server.UploadDir == "" {
+		problems = append(problems, "server.upload_dir (APP_UPLOAD_DIR) が未設定です")
+	}
+	if c.Server.BaseURL == "" {
+		problems = append(problems, "server.base_url (APP_BASE_URL) が未設定です")
+	} else if u, err := url.Parse(c.Server.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("server.base_url (APP_BASE_URL) は http(s)://ホスト名 の形式にしてください (%q)", c.Server.BaseURL))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		problems = append(problems, "server.shutdown_timeout は 0 より大きくしてください")
+	}
+	if c.Server.ShutdownDrain < 0 {
+		problems = append(problems, "server.shutdown_drain は 0 以上にしてください")
+	}
+	switch c.DB.Driver {
+	case DriverMySQL:
+		if c.DB.DSN == "" {
+			problems = append(problems, "db.dsn (APP_DB_DSN) が未設定です")
+		}
+	case DriverMemory:
+		// 再起動でデータが消えるので、本番では使わせない
+		if c.Env == EnvProd {
+			problems = append(problems, "本番では db.driver に memory は使えません")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("db.driver (APP_DB_DRIVER) は mysql / memory のどちらかにしてください (%q)", c.DB.Driver))
+	}
+	if c.DB.PingRetries < 0 {
+		problems = append(problems, "db.ping_retries は 0 以上にしてください")
+	}
+	if c.DB.SlowQueryThreshold < 0 {
+		problems = append(problems, "db.slow_query_threshold は 0 以上にしてください（0 なら遅いクエリのログを書かない）")
+	}
+	if c.Session.Secret == "" {
+		problems = append(problems, "session.secret (APP_SESSION_SECRET) が未設定です")
+	}
+	// 本番で開発用の鍵や短すぎる鍵を使うのは事故のもと
+	if c.Env == EnvProd && (c.Session.Secret == insecureSessionSecret || len(c.Session.Secret) < 32) {
+		problems = append(problems, "本番では session.secret に32文字以上のランダムな値を設定してください")
+	}
+	switch c.Session.Store {
+	case SessionStoreDB:
+	case SessionStoreMemory:
+		// 再起動でログアウトされ、複数台で動かすとログインが共有されない
+		if c.Env == EnvProd {
+			problems = append(problems, "本番では session.store に memory は使えません")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("session.store (APP_SESSION_STORE) は db / memory のどちらかにしてください (%q)", c.Session.Store))
+	}
+	if c.Session.MaxAge < time.Second || c.Session.SweepInterval <= 0 {
+		problems = append(problems, "session.max_age は1秒以上、session.sweep_interval は 0 より大きくしてください")
+	}
+	switch c.Mail.Driver {
+	case MailDriverSMTP:
+		if c.Mail.SMTP.Addr == "" {
+			problems = append(problems, "mail.smtp.addr (APP_SMTP_ADDR) が未設定です")
+		}
+	case MailDriverFile:
+		if c.Mail.Dir == "" {
+			problems = append(problems, "mail.dir (APP_MAIL_DIR) が未設定です")
+		}
+	case MailDriverMemory:
+		// 誰にも届かないので、本番では使わせない
+		if c.Env == EnvProd {
+			problems = append(problems, "本番では mail.driver に memory は使えません")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("mail.driver (APP_MAIL_DRIVER) は smtp / file / memory のどれかにしてください (%q)", c.Mail.Driver))
+	}
+	if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+		problems = append(problems, fmt.Sprintf("mail.from (APP_MAIL_FROM) はメールアドレスにしてください (%q)", c.Mail.From))
+	}
+	if c.Env == EnvProd && c.Token.Secret != "" && len(c.Token.Secret) < 32 {
+		problems = append(problems, "本番では token.secret に32文字以上のランダムな値を設定してください")
+	}
+	if c.Token.InviteTTL <= 0 {
+		problems = append(problems, "token.invite_ttl は 0 より大きくしてください")
+	}
+	if c.Token.PasswordResetTTL <= 0 {
+		problems = append(problems, "token.password_reset_ttl は 0 より大きくしてください")
+	}
+	if c.RateLimit.PasswordResetPerIP <= 0 || c.RateLimit.PasswordResetPerAccount <= 0 || c.RateLimit.PasswordResetWindow <= 0 {
+		problems = append(problems, "rate_limit.password_reset_per_ip / password_reset_per_account / password_reset_window は 0 より大きくしてください")
+	}
+	if c.RateLimit.TwoFactorFailures <= 0 || c.RateLimit.TwoFactorWindow <= 0 {
+		problems = append(problems, "rate_limit.two_factor_failures / two_factor_window は 0 より大きくしてください")
+	}
+	for _, role := range c.MFA.RequiredRoles {
+		if !model.Role(role).Valid() {
+			problems = append(problems, fmt.Sprintf("mfa.required_roles (APP_MFA_REQUIRED_ROLES) に知らない役割があります (%q)", role))
+		}
+	}
+	if c.MFA.Issuer == "" {
+		problems = append(problems, "mfa.issuer (APP_MFA_ISSUER) が未設定です")
+	}
+	if c.Env == EnvProd && len(c.MFA.EncryptionKey) < 32 {
+		problems = append(problems, "本番では mfa.encryption_key (APP_MFA_ENCRYPTION_KEY) に32文字以上のランダムな値を設定してください")
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level (APP_LOG_LEVEL) は debug / info / warn / error のどれかにしてください (%q)", c.Log.Level))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("設定に誤りがあります:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
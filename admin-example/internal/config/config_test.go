@@ -0,0 +1,310 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig はテスト用の設定ファイルを一時ディレクトリに書き出す
+func writeConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFrom_Profile(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml":     "server:\n  addr: \":9000\"\ndb:\n  ping_interval: 2s\n",
+		"config.dev.yaml": "db:\n  dsn: dev-dsn\nsession:\n  secret: dev-secret\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+
+	assert.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)           // 共通設定
+	assert.Equal(t, "dev-dsn", cfg.DB.DSN)              // 環境ごとの設定
+	assert.Equal(t, "views", cfg.Server.ViewsDir)       // どこにも無ければデフォルト
+	assert.Equal(t, 2*time.Second, cfg.DB.PingInterval) // 時間は "2s" 形式で書ける
+}
+
+func TestLoadFrom_EnvOverride(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.dev.yaml": "db:\n  dsn: file-dsn\nsession:\n  secret: dev-secret\n",
+	})
+	t.Setenv("APP_DB_DSN", "env-dsn")
+	t.Setenv("APP_DB_PING_RETRIES", "3")
+	t.Setenv("APP_SHUTDOWN_TIMEOUT", "30s")
+	t.Setenv("APP_SHUTDOWN_DRAIN", "15s")
+	t.Setenv("APP_UPLOAD_DIR", "/var/lib/admin/uploads")
+
+	cfg, err := LoadFrom(dir, EnvDev)
+
+	// 環境変数がファイルより優先される
+	assert.NoError(t, err)
+	assert.Equal(t, "env-dsn", cfg.DB.DSN)
+	assert.Equal(t, 3, cfg.DB.PingRetries)
+	assert.Equal(t, 30*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, 15*time.Second, cfg.Server.ShutdownDrain)
+	assert.Equal(t, "/var/lib/admin/uploads", cfg.Server.UploadDir)
+}
+
+func TestLoadFrom_MissingRequired(t *testing.T) {
+	dir := writeConfig(t, map[string]string{})
+
+	_, err := LoadFrom(dir, EnvDev)
+
+	// 足りない項目がまとめて報告されること
+	if assert.Error(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "APP_DB_DSN"))
+		assert.True(t, strings.Contains(err.Error(), "APP_SESSION_SECRET"))
+	}
+}
+
+func TestLoadFrom_ProdRejectsDevSecret(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.prod.yaml": "db:\n  dsn: prod-dsn\nsession:\n  secret: secret-key\n",
+	})
+
+	_, err := LoadFrom(dir, EnvProd)
+
+	assert.Error(t, err)
+}
+
+func TestLoadFrom_UnknownEnv(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  dsn: dsn\nsession:\n  secret: s\n",
+	})
+
+	_, err := LoadFrom(dir, "staging")
+
+	assert.Error(t, err)
+}
+
+func TestLoadFrom_MemoryDriver(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+
+	// memory なら DSN は要らない
+	assert.NoError(t, err)
+	assert.Equal(t, DriverMemory, cfg.DB.Driver)
+
+	// 本番では使えない / 知らない driver はエラー
+	t.Setenv("APP_SESSION_SECRET", strings.Repeat("x", 32))
+	_, err = LoadFrom(dir, EnvProd)
+	assert.Error(t, err)
+
+	t.Setenv("APP_DB_DRIVER", "sqlite")
+	_, err = LoadFrom(dir, EnvDev)
+	assert.Error(t, err)
+}
+
+func TestLoadFrom_TemplateReload(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "server:\n  views_dir: \"\"\ndb:\n  driver: memory\nsession:\n  secret: s\n",
+	})
+
+	// views_dir が空なら埋め込みのテンプレートを使う
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, "", cfg.Server.ViewsDir)
+
+	// 埋め込みのテンプレートは読み直せない
+	t.Setenv("APP_TEMPLATE_RELOAD", "true")
+	_, err = LoadFrom(dir, EnvDev)
+	assert.Error(t, err)
+
+	t.Setenv("APP_VIEWS_DIR", "views")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Server.TemplateReload)
+}
+
+func TestLoadFrom_Log(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\n  slow_query_threshold: 1s\nsession:\n  secret: s\nlog:\n  level: debug\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, "debug", cfg.Log.Level)
+	assert.Equal(t, time.Second, cfg.DB.SlowQueryThreshold)
+
+	t.Setenv("APP_DB_SLOW_QUERY_THRESHOLD", "0")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.DB.SlowQueryThreshold) // 0 なら遅いクエリのログを書かない
+
+	// 知らないレベルはエラー
+	t.Setenv("APP_LOG_LEVEL", "verbose")
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "APP_LOG_LEVEL")
+	}
+}
+
+func TestLoadFrom_BrokenYAML(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "server: [\n",
+	})
+
+	_, err := LoadFrom(dir, EnvDev)
+
+	assert.Error(t, err)
+}
+
+func TestLoadFrom_MailAndToken(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\n",
+	})
+
+	// デフォルトはファイルに保存し、署名には session.secret を使う
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, MailDriverFile, cfg.Mail.Driver)
+	assert.Equal(t, "http://localhost:8080", cfg.Server.BaseURL)
+	assert.Equal(t, 72*time.Hour, cfg.Token.InviteTTL)
+	assert.Equal(t, "s", cfg.TokenSecret())
+
+	t.Setenv("APP_TOKEN_SECRET", "token-secret")
+	t.Setenv("APP_INVITE_TTL", "24h")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-secret", cfg.TokenSecret())
+	assert.Equal(t, 24*time.Hour, cfg.Token.InviteTTL)
+
+	// smtp なら送り先のサーバーが要る
+	t.Setenv("APP_MAIL_DRIVER", "smtp")
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "APP_SMTP_ADDR")
+	}
+	t.Setenv("APP_SMTP_ADDR", "smtp.example.com:587")
+	_, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+
+	// リンクに使えない base_url はエラー
+	t.Setenv("APP_BASE_URL", "admin.example.com")
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "APP_BASE_URL")
+	}
+}
+
+func TestLoadFrom_PasswordReset(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\nrate_limit:\n  password_reset_per_ip: 5\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, cfg.Token.PasswordResetTTL)
+	assert.Equal(t, 5, cfg.RateLimit.PasswordResetPerIP)
+	assert.Equal(t, 3, cfg.RateLimit.PasswordResetPerAccount) // 書いていないものはデフォルト
+	assert.Equal(t, 10, cfg.RateLimit.TwoFactorFailures)
+	assert.Equal(t, time.Hour, cfg.RateLimit.TwoFactorWindow)
+	assert.False(t, cfg.Server.TrustProxy)
+
+	t.Setenv("APP_TRUST_PROXY", "true")
+	t.Setenv("APP_PASSWORD_RESET_TTL", "30m")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Server.TrustProxy)
+	assert.Equal(t, 30*time.Minute, cfg.Token.PasswordResetTTL)
+
+	// 制限なし (0) にはできない
+	dir = writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\nrate_limit:\n  password_reset_per_account: 0\n",
+	})
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "rate_limit")
+	}
+}
+
+func TestLoadFrom_Session(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, SessionStoreDB, cfg.Session.Store)
+	assert.Equal(t, 7*24*time.Hour, cfg.Session.MaxAge)
+	assert.Equal(t, []string{"s"}, cfg.Session.Secrets())
+
+	// 鍵を替える時は、古い鍵をカンマ区切りで渡す（今の鍵が先頭）
+	t.Setenv("APP_SESSION_SECRET", "new")
+	t.Setenv("APP_SESSION_PREVIOUS_SECRETS", "s, older")
+	t.Setenv("APP_SESSION_STORE", "memory")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new", "s", "older"}, cfg.Session.Secrets())
+	assert.Equal(t, SessionStoreMemory, cfg.Session.Store)
+
+	t.Setenv("APP_SESSION_STORE", "redis")
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "session.store")
+	}
+}
+
+func TestLoadFrom_MFA(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.yaml": "db:\n  driver: memory\nsession:\n  secret: s\nmfa:\n  required_roles: [admin]\n",
+	})
+
+	cfg, err := LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, cfg.MFA.RequiredRoles)
+	assert.Equal(t, "Admin", cfg.MFA.Issuer)
+	// 鍵が無ければ session.secret を使う
+	assert.Equal(t, "s", cfg.MFAEncryptionKey())
+
+	t.Setenv("APP_MFA_REQUIRED_ROLES", "admin, editor")
+	t.Setenv("APP_MFA_ENCRYPTION_KEY", "mfa-key")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "editor"}, cfg.MFA.RequiredRoles)
+	assert.Equal(t, "mfa-key", cfg.MFAEncryptionKey())
+
+	// 空にすると誰にも求めない
+	t.Setenv("APP_MFA_REQUIRED_ROLES", "")
+	cfg, err = LoadFrom(dir, EnvDev)
+	assert.NoError(t, err)
+	assert.Empty(t, cfg.MFA.RequiredRoles)
+
+	t.Setenv("APP_MFA_REQUIRED_ROLES", "owner")
+	_, err = LoadFrom(dir, EnvDev)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "mfa.required_roles")
+	}
+}
+
+func TestLoadFrom_ProdRequiresMFAEncryptionKey(t *testing.T) {
+	dir := writeConfig(t, map[string]string{
+		"config.prod.yaml": "db:\n  dsn: prod-dsn\n",
+	})
+	t.Setenv("APP_SESSION_SECRET", strings.Repeat("x", 32))
+
+	_, err := LoadFrom(dir, EnvProd)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "APP_MFA_ENCRYPTION_KEY")
+	}
+
+	t.Setenv("APP_MFA_ENCRYPTION_KEY", strings.Repeat("k", 32))
+	_, err = LoadFrom(dir, EnvProd)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APIUserController: HTML画面と同じ UserService を JSON で公開する (/api/v1/users)
+type APIUserController struct {
+	BaseController // バリデーションメッセージ (GetValidationErrors) を共通で使う
+	svc            service.UserService
+}
+
+func NewAPIUserController(s service.UserService) *APIUserController {
+	return &APIUserController{svc: s}
+}
+
+// RequireJSON は更新系のAPIに JSON 以外のリクエストが来たら 415 で弾くミドルウェア。
+// API はトークンチェックをしないので、他サイトの <form> から POST されるのを防ぐ目的もある
+func RequireJSON(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		switch c.Request().Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			ct := c.Request().Header.Get(echo.HeaderContentType)
+			if !strings.HasPrefix(ct, echo.MIMEApplicationJSON) {
+				return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type は application/json にしてください")
+			}
+		}
+		return next(c)
+	}
+}
+
+// 一覧 (GET /api/v1/users?page=&per_page=&sort=&q=)
+func (a *APIUserController) List(c echo.Context) error {
+	q := new(model.UserListQuery)
+	if err := c.Bind(q); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "検索条件が正しくありません")
+	}
+	q.Normalize()
+
+	users, total, err := a.svc.Search(c.Request().Context(), *q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "一覧の取得に失敗しました").SetInternal(err)
+	}
+	q.ClampPage(total) // 返した一覧と同じページ番号にする
+	return c.JSON(http.StatusOK, model.NewUserListJSON(users, total, *q))
+}
+
+// 1件取得 (GET /api/v1/users/:id)
+func (a *APIUserController) Get(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+	return a.respondUser(c, http.StatusOK, id)
+}
+
+// 登録 (POST /api/v1/users)
+func (a *APIUserController) Create(c echo.Context) error {
+	form := new(model.UserCreateForm)
+	if err := c.Bind(form); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "リクエストの形式が正しくありません")
+	}
+
+	// 画面と同じ UserCreateForm のルールでチェックする
+	if err := c.Validate(form); err != nil {
+		return a.validationError(c, err, form)
+	}
+
+	id, err := a.svc.Register(c.Request().Context(), createInput(form))
+	if err != nil {
+		return a.serviceError(c, err)
+	}
+	return a.respondUser(c, http.StatusCreated, id)
+}
+
+// 更新 (PUT /api/v1/users/:id)
+func (a *APIUserController) Update(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+
+	form := new(model.UserUpdateForm)
+	if err := c.Bind(form); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "リクエストの形式が正しくありません")
+	}
+	// ID は本文ではなくURLのものを正とする
+	form.ID = id
+
+	if err := c.Validate(form); err != nil {
+		return a.validationError(c, err, form)
+	}
+
+	// アバター画像は画面からだけ変更する（API では今の画像のまま）
+	if _, err := a.svc.UpdateProfile(c.Request().Context(), id, updateInput(form), form.Version); err != nil {
+		return a.serviceError(c, err)
+	}
+	return a.respondUser(c, http.StatusOK, id)
+}
+
+// 削除 (DELETE /api/v1/users/:id)
+func (a *APIUserController) Delete(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+
+	if err := a.svc.DeleteUser(c.Request().Context(), id); err != nil {
+		return a.findError(err)
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// --- ヘルパーメソッド ---
+
+// respondUser はユーザーを取り直して JSON で返す
+func (a *APIUserController) respondUser(c echo.Context, status int, id uint64) error {
+	user, err := a.svc.FindByID(c.Request().Context(), id)
+	if err != nil {
+		return a.findError(err)
+	}
+	return c.JSON(status, model.NewUserJSON(user))
+}
+
+// validationError は {"errors": {フィールド: メッセージ}} を 422 で返す
+func (a *APIUserController) validationError(c echo.Context, err error, form interface{}) error {
+	return c.JSON(http.StatusUnprocessableEntity, map[string]interface{}{
+		"errors": a.GetValidationErrors(c, err, form),
+	})
+}
+
+// serviceError は Service のエラーをステータスにする
+//
+//	ErrValidation / ErrDuplicateName / ErrDuplicateEmail → 422 / 409 と {"errors": {項目: メッセージ}}（入力エラーと同じ形）
+//	ErrConflict → 409、ErrNotFound → 404、それ以外 → 500
+func (a *APIUserController) serviceError(c echo.Context, err error) error {
+	switch {
+	case isFieldError(err):
+		errs, status := fieldErrors(err)
+		return c.JSON(status, map[string]interface{}{"errors": errs})
+	case errors.Is(err, service.ErrConflict):
+		return echo.NewHTTPError(http.StatusConflict, "他の人が先に更新しました。取得し直してから、もう一度送ってください")
+	}
+	return a.findError(err)
+}
+
+// findError は「見つからない」を 404、それ以外を 500 にする
+func (a *APIUserController) findError(err error) error {
+	if errors.Is(err, service.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "ユーザーの取得に失敗しました").SetInternal(err)
+}
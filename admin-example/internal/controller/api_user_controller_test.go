@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/validation"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// main.go の CustomValidator と同じもの（テスト用）
+type testValidator struct {
+	validator *validator.Validate
+}
+
+func (v *testValidator) Validate(i interface{}) error {
+	return v.validator.Struct(i)
+}
+
+// newAPIEcho は main.go と同じ /api/v1 のルーティングを組み立てる
+func newAPIEcho(ctrl *APIUserController) *echo.Echo {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	api := e.Group("/api/v1", RequireJSON)
+	api.GET("/users", ctrl.List)
+	api.GET("/users/:id", ctrl.Get)
+	api.POST("/users", ctrl.Create)
+	api.PUT("/users/:id", ctrl.Update)
+	api.DELETE("/users/:id", ctrl.Delete)
+	return e
+}
+
+func doJSON(e *echo.Echo, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIUserController_List(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodGet, "/api/v1/users", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	users := body["users"].([]interface{})
+	assert.Len(t, users, 1)
+	// sql.NullString が普通の文字列になっていること
+	assert.Equal(t, "コントローラーテスト", users[0].(map[string]interface{})["name"])
+	// sql.NullTime が無効なら null
+	assert.Nil(t, users[0].(map[string]interface{})["updated_at"])
+	assert.Equal(t, float64(1), body["total"])
+}
+
+func TestAPIUserController_Get_NotFound(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&notFoundUserService{}))
+
+	rec := doJSON(e, http.MethodGet, "/api/v1/users/99", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPIUserController_Create(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodPost, "/api/v1/users", `{"name": "新しい人", "email": "new@example.com", "role": "editor"}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":1`)
+}
+
+func TestAPIUserController_Create_ValidationError(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodPost, "/api/v1/users", `{"name": "ab", "email": "not-an-address", "status": "deleted"}`)
+
+	// 画面と同じルールでチェックされ、422 で {"errors": {...}} が返る
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body map[string]map[string]string
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	// キーは JSON の名前と同じ
+	assert.Contains(t, body["errors"], "name")
+	assert.Contains(t, body["errors"], "email")
+	assert.Contains(t, body["errors"], "status")
+}
+
+// 同じ名前のユーザーがいる場合の偽物
+type duplicateNameUserService struct {
+	mockUserService
+}
+
+func (m *duplicateNameUserService) Register(ctx context.Context, in service.UserInput) (uint64, error) {
+	return 0, service.ErrDuplicateName
+}
+
+func TestAPIUserController_Create_DuplicateName(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&duplicateNameUserService{}))
+
+	rec := doJSON(e, http.MethodPost, "/api/v1/users", `{"name": "山田太郎"}`)
+
+	// 500 ではなく 409 で、どの項目の問題かを返す
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var body map[string]map[string]string
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, service.ErrDuplicateName.Error(), body["errors"]["name"])
+}
+
+func TestAPIUserController_Create_NotJSON(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader("name=abc"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
+
+func TestAPIUserController_Update(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodPut, "/api/v1/users/5", `{"name": "変更後の名前", "version": 1}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":5`)
+}
+
+func TestAPIUserController_Update_Conflict(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&conflictUserService{}))
+
+	rec := doJSON(e, http.MethodPut, "/api/v1/users/5", `{"name": "変更後の名前", "version": 1}`)
+
+	// 他の人が先に更新していたら 409
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestAPIUserController_Update_MissingVersion(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodPut, "/api/v1/users/5", `{"name": "変更後の名前"}`)
+
+	// version が無いと、上書きしてよいか判断できない
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"version"`)
+}
+
+func TestAPIUserController_Update_NotFound(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&notFoundUserService{}))
+
+	rec := doJSON(e, http.MethodPut, "/api/v1/users/99", `{"name": "変更後の名前", "version": 1}`)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPIUserController_Delete(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&mockUserService{}))
+
+	rec := doJSON(e, http.MethodDelete, "/api/v1/users/1", "")
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestAPIUserController_Delete_NotFound(t *testing.T) {
+	e := newAPIEcho(NewAPIUserController(&notFoundUserService{}))
+
+	rec := doJSON(e, http.MethodDelete, "/api/v1/users/99", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
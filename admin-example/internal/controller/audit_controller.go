@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AuditController: 監査ログの閲覧
+type AuditController struct {
+	BaseController
+	svc service.AuditService
+}
+
+func NewAuditController(s service.AuditService) *AuditController {
+	return &AuditController{svc: s}
+}
+
+// 一覧表示 (GET /audit?actor=&target_id=&from=&to=&page=)
+func (a *AuditController) Index(c echo.Context) error {
+	q := new(model.AuditListQuery)
+	if err := c.Bind(q); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "検索条件が正しくありません")
+	}
+	q.Normalize()
+
+	logs, total, err := a.svc.Search(c.Request().Context(), *q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "監査ログの取得に失敗しました").SetInternal(err)
+	}
+	q.ClampPage(total)
+
+	return c.Render(http.StatusOK, "audit/index", map[string]interface{}{
+		"Logs":  logs,
+		"Query": q,
+		"Pager": model.NewPager("/audit", q.Page, q.PerPage, total, q.Params()),
+	})
+}
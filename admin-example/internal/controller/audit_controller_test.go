@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-example/admin-example/internal/logging"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+// AuditService の偽物。受け取った検索条件を覚えておく
+type mockAuditService struct {
+	query model.AuditListQuery
+}
+
+func (m *mockAuditService) Search(ctx context.Context, q model.AuditListQuery) ([]repository.AuditLog, int64, error) {
+	m.query = q
+	return []repository.AuditLog{
+		{ID: 1, ActorEmail: "admin@example.com", Action: "user.update", TargetType: "user", TargetID: 5},
+	}, 1, nil
+}
+
+// 監査ログ画面用の Renderer の偽物
+type auditRenderer struct{}
+
+func (r *auditRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	if logs, ok := data.(map[string]interface{})["Logs"].([]repository.AuditLog); ok && len(logs) > 0 {
+		w.Write([]byte(logs[0].Action))
+	}
+	return nil
+}
+
+func TestAuditController_Index(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &auditRenderer{}
+	req := httptest.NewRequest(http.MethodGet, "/audit?actor=admin@example.com&target_id=5&from=2026-04-01&to=bad", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	svc := &mockAuditService{}
+
+	err := NewAuditController(svc).Index(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "user.update")
+	assert.Equal(t, "admin@example.com", svc.query.Actor)
+	assert.Equal(t, uint64(5), svc.query.TargetID)
+	assert.Equal(t, "2026-04-01", svc.query.From)
+	assert.Empty(t, svc.query.To) // 読めない日付は無視
+}
+
+func TestRequestIDToContext(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestID(), RequestIDToContext)
+	var got string
+	e.GET("/", func(c echo.Context) error {
+		got = requestctx.RequestIDFrom(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	// 受け取った X-Request-Id がそのまま context に載ること
+	assert.Equal(t, "req-123", got)
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(middleware.RequestID(), RequestIDToContext, RequestLogger(logging.New(&buf, slog.LevelInfo)))
+	e.GET("/users/:id", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/users/9", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	// エラーは HTTPErrorHandler が返したステータスで、400 系は warn で書く
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "WARN", m["level"])
+	assert.Equal(t, "req-123", m["request_id"])
+	assert.Equal(t, "/users/:id", m["route"])
+	assert.Equal(t, float64(http.StatusNotFound), m["status"])
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/sessionstore"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// セッションにログイン中のアカウントIDを入れるキー
+// (セッションの一覧画面に出すため、sessionstore も同じキーを見る)
+const sessionKeyAccountID = sessionstore.AccountIDKey
+
+// ログインした時のアカウントの session_version を入れるキー
+// パスワードの再設定などで DB 側の値が増えると、それより前のセッションは使えなくなる
+// (再設定ではセッションの保存先からも消すが、保存先は accounts と別のトランザクションで、
+// Redis など別の場所のこともある。消し損ねたり、消した直後に読み込み済みのリクエストが
+// 残っていたりしても、RequireLogin がこの値を毎回比べるのでログインしたままにはならない)
+const sessionKeySessionVersion = "session_version"
+
+// echo.Context にログイン中のアカウントを入れるキー
+const contextKeyAccount = "account"
+
+// CurrentAccount はログイン中のアカウントを返す（RequireLogin を通った後で使う）
+func CurrentAccount(c echo.Context) (repository.Account, bool) {
+	acc, ok := c.Get(contextKeyAccount).(repository.Account)
+	return acc, ok
+}
+
+// CurrentRole はログイン中のアカウントの役割を返す（未ログインなら空）
+func CurrentRole(c echo.Context) model.Role {
+	acc, ok := CurrentAccount(c)
+	if !ok {
+		return ""
+	}
+	return model.Role(acc.Role)
+}
+
+// TemplateGlobals はすべての画面に共通で渡す値（レイアウトのヘッダーなどで使う）
+// main.go で infrastructure.TemplateRenderer.Globals に登録する
+func TemplateGlobals(c echo.Context) map[string]interface{} {
+	globals := map[string]interface{}{
+		"CurrentRole": CurrentRole(c),
+		"Flashes":     Flashes(c), // 表示したら消える
+		"CSRFToken":   "",
+		"Locale":      Locale(c),
+		"csrf":        &formToken{c: c}, // {{csrfField .csrf}} で使った時だけ発行する
+	}
+	if acc, ok := CurrentAccount(c); ok {
+		globals["CurrentAccount"] = acc
+		// htmx やログアウトのボタンはログイン中の画面にしか無い。
+		// ログインしていない人の画面ごとにセッション (csrf_secret) を作って保存しないよう、ここでだけ作る
+		globals["CSRFToken"] = CSRFToken(c)
+	}
+	return globals
+}
+
+// RequireLogin はログインしていなければログイン画面へ送るミドルウェア
+// (API は画面遷移できないので 401 を返す)
+func (a *AuthController) RequireLogin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sess, err := session.Get("session", c)
+		if err != nil {
+			return err
+		}
+
+		id, ok := sess.Values[sessionKeyAccountID].(uint64)
+		if !ok {
+			return unauthenticated(c)
+		}
+		// 役割が変わっていても反映されるよう、毎回DBから読み直す
+		acc, err := a.svc.FindAccount(c.Request().Context(), id)
+		// アカウントが消されていたり、パスワードの再設定の前にログインしたセッションならログアウト扱い
+		ver, _ := sess.Values[sessionKeySessionVersion].(uint32)
+		if err != nil || ver != acc.SessionVersion {
+			delete(sess.Values, sessionKeyAccountID)
+			delete(sess.Values, sessionKeySessionVersion)
+			sess.Save(c.Request(), c.Response())
+			return unauthenticated(c)
+		}
+
+		c.Set(contextKeyAccount, acc)
+		// Service / Repository 層（監査ログなど）からも「誰の操作か」が分かるようにする
+		ctx := requestctx.WithActor(c.Request().Context(), requestctx.Actor{ID: acc.ID, Email: acc.Email, Role: acc.Role})
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// Authorize はルートごとに必要な権限 (RoutePermissions) を確認するミドルウェア
+// 表に載っていないルートは「うっかり公開」を防ぐため拒否する
+func Authorize(table map[string]model.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// グループの「該当するルートが無い」時の受け皿 ("/*") はそのまま 404 にする
+			if strings.HasSuffix(c.Path(), "/*") {
+				return next(c)
+			}
+			perm, ok := table[c.Request().Method+" "+c.Path()]
+			if !ok || !CurrentRole(c).Can(perm) {
+				return echo.NewHTTPError(http.StatusForbidden, "この操作を行う権限がありません")
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireTwoFactor は、役割で2要素認証が必須 (mfa.required_roles) なのに設定していない人を
+// 設定画面 (/account/2fa) へ送るミドルウェア。RequireLogin の後に登録する
+// (設定画面そのものは、このミドルウェアを付けないグループに置くこと)
+func RequireTwoFactor(svc service.TwoFactorService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			acc, ok := CurrentAccount(c)
+			if !ok || acc.TotpEnabledAt.Valid || !svc.Required(model.Role(acc.Role)) {
+				return next(c)
+			}
+			const msg = "この役割では2要素認証が必要です。認証アプリを設定してください"
+			if isAPIRequest(c) {
+				return echo.NewHTTPError(http.StatusForbidden, msg)
+			}
+			if c.Request().Header.Get("HX-Request") == "true" {
+				c.Response().Header().Set("HX-Redirect", "/account/2fa")
+				return c.NoContent(http.StatusForbidden)
+			}
+			b := &BaseController{}
+			return b.redirectWithFlash(c, FlashWarning, msg, "/account/2fa")
+		}
+	}
+}
+
+// unauthenticated は未ログイン時の応答
+func unauthenticated(c echo.Context) error {
+	if isAPIRequest(c) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "ログインしてください")
+	}
+	// htmx からのリクエストはリダイレクトを追えないので、HX-Redirect でページごと移動させる
+	loginURL := "/login?next=" + url.QueryEscape(c.Request().URL.RequestURI())
+	if c.Request().Header.Get("HX-Request") == "true" {
+		c.Response().Header().Set("HX-Redirect", loginURL)
+		return c.NoContent(http.StatusUnauthorized)
+	}
+	return c.Redirect(http.StatusSeeOther, loginURL)
+}
+
+func isAPIRequest(c echo.Context) bool {
+	return strings.HasPrefix(c.Request().URL.Path, "/api/")
+}
+
+// safeNext はログイン後の戻り先。外部サイトへ飛ばされないよう、サイト内のパスだけ許可する
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/users"
+	}
+	return next
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+	"net/http"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// AuthController: ログイン・ログアウト
+type AuthController struct {
+	BaseController
+	svc service.AuthService
+}
+
+func NewAuthController(s service.AuthService) *AuthController {
+	return &AuthController{svc: s}
+}
+
+// ログイン画面 (GET /login)
+func (a *AuthController) LoginPage(c echo.Context) error {
+	return a.renderLogin(c, map[string]string{}, "", c.QueryParam("next"))
+}
+
+// ログイン (POST /login)
+func (a *AuthController) Login(c echo.Context) error {
+	if !a.IsValidAndDestroyToken(c) {
+		return a.doubleSubmitted(c, "/login")
+	}
+
+	form := new(model.LoginForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	next := c.FormValue("next")
+
+	if err := c.Validate(form); err != nil {
+		return a.renderLogin(c, a.GetValidationErrors(c, err, form), form.Email, next)
+	}
+
+	acc, err := a.svc.Authenticate(c.Request().Context(), form.Email, form.Password)
+	if err != nil {
+		msg := "ログインに失敗しました"
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			msg = err.Error()
+		}
+		return a.renderLogin(c, map[string]string{"Main": msg}, form.Email, next)
+	}
+
+	// 2要素認証を有効にしていれば、コードを入れてもらうまでログインにしない
+	if acc.TotpEnabledAt.Valid {
+		if err := startTwoFactor(c, acc, next); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, "/login/2fa")
+	}
+	if err := logIn(c, acc); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, safeNext(next))
+}
+
+// ログアウト (POST /logout)
+func (a *AuthController) Logout(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	// Cookie ごと消す
+	sess.Options.MaxAge = -1
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, "/login")
+}
+
+// logIn はセッションをログイン済みにする（パスワードの確認や2要素認証が済んだ後に呼ぶ）
+func logIn(c echo.Context, acc repository.Account) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	// ログイン前のセッションの中身は引き継がない
+	// (アカウントIDが変わるので、保存する時にセッションIDも新しくなる)
+	for k := range sess.Values {
+		delete(sess.Values, k)
+	}
+	sess.Values[sessionKeyAccountID] = acc.ID
+	sess.Values[sessionKeySessionVersion] = acc.SessionVersion
+	return sess.Save(c.Request(), c.Response())
+}
+
+func (a *AuthController) renderLogin(c echo.Context, vErrors map[string]string, email, next string) error {
+	return c.Render(http.StatusOK, "auth/login", map[string]interface{}{
+		"Errors": vErrors,
+		"Email":  email,
+		"Next":   next,
+	})
+}
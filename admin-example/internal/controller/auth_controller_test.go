@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/validation"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// AuthService の偽物。パスワードは "correct-horse" だけ通す
+type mockAuthService struct {
+	accounts map[uint64]repository.Account
+}
+
+func newMockAuthService() *mockAuthService {
+	return &mockAuthService{accounts: map[uint64]repository.Account{
+		1: {ID: 1, Email: "admin@example.com", Role: "admin"},
+		2: {ID: 2, Email: "editor@example.com", Role: "editor"},
+		3: {ID: 3, Email: "viewer@example.com", Role: "viewer"},
+	}}
+}
+
+func (m *mockAuthService) Authenticate(ctx context.Context, email, password string) (repository.Account, error) {
+	for _, a := range m.accounts {
+		if a.Email == email && password == "correct-horse" {
+			return a, nil
+		}
+	}
+	return repository.Account{}, service.ErrInvalidCredentials
+}
+
+func (m *mockAuthService) FindAccount(ctx context.Context, id uint64) (repository.Account, error) {
+	a, ok := m.accounts[id]
+	if !ok {
+		return repository.Account{}, sql.ErrNoRows
+	}
+	return a, nil
+}
+
+func (m *mockAuthService) CreateAccount(ctx context.Context, email, password string, role model.Role) (uint64, error) {
+	return 0, nil
+}
+
+// newAuthEcho は main.go と同じ構成（ログイン必須グループ + 権限チェック）を組み立てる
+func newAuthEcho(auth *AuthController) *echo.Echo {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &mockRenderer{}
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+
+	// テスト用: 指定したアカウントでログインした状態の Cookie を作る
+	e.GET("/test-login/:id", func(c echo.Context) error {
+		id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+		sess, _ := session.Get("session", c)
+		sess.Values[sessionKeyAccountID] = id
+		sess.Save(c.Request(), c.Response())
+		return c.NoContent(http.StatusOK)
+	})
+
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	admin := e.Group("", auth.RequireLogin, Authorize(RoutePermissions))
+	admin.GET("/users", ok)
+	admin.DELETE("/users/:id", ok)
+	admin.GET("/api/v1/users", ok)
+	admin.GET("/not-in-table", ok)
+	return e
+}
+
+// loginAs は指定アカウントのセッション Cookie を返す
+func loginAs(e *echo.Echo, id uint64) []*http.Cookie {
+	req := httptest.NewRequest(http.MethodGet, "/test-login/"+strconv.FormatUint(id, 10), nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Result().Cookies()
+}
+
+func request(e *echo.Echo, method, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequireLogin_RedirectsToLogin(t *testing.T) {
+	e := newAuthEcho(NewAuthController(newMockAuthService()))
+
+	rec := request(e, http.MethodGet, "/users?page=2", nil)
+
+	// ログイン後に元のページへ戻れるよう next を付ける
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login?next="+url.QueryEscape("/users?page=2"), rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestRequireLogin_APIUnauthorized(t *testing.T) {
+	e := newAuthEcho(NewAuthController(newMockAuthService()))
+
+	rec := request(e, http.MethodGet, "/api/v1/users", nil)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthorize_ByRole(t *testing.T) {
+	e := newAuthEcho(NewAuthController(newMockAuthService()))
+
+	tests := []struct {
+		name   string
+		id     uint64
+		method string
+		path   string
+		want   int
+	}{
+		{"viewer は一覧を見られる", 3, http.MethodGet, "/users", http.StatusOK},
+		{"viewer は削除できない", 3, http.MethodDelete, "/users/1", http.StatusForbidden},
+		{"editor は削除できない", 2, http.MethodDelete, "/users/1", http.StatusForbidden},
+		{"admin は削除できる", 1, http.MethodDelete, "/users/1", http.StatusOK},
+		{"表に無いルートは admin でも拒否", 1, http.MethodGet, "/not-in-table", http.StatusForbidden},
+		{"存在しないルートは 404", 1, http.MethodGet, "/no-such-page", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(e, tt.method, tt.path, loginAs(e, tt.id))
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestRequireLogin_DeletedAccount(t *testing.T) {
+	svc := newMockAuthService()
+	e := newAuthEcho(NewAuthController(svc))
+	cookies := loginAs(e, 3)
+
+	// ログイン後にアカウントが消された
+	delete(svc.accounts, 3)
+	rec := request(e, http.MethodGet, "/users", cookies)
+
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+}
+
+func TestRequireLogin_SessionVersionChanged(t *testing.T) {
+	svc := newMockAuthService()
+	e := newAuthEcho(NewAuthController(svc))
+	cookies := loginAs(e, 3)
+	assert.Equal(t, http.StatusOK, request(e, http.MethodGet, "/users", cookies).Code)
+
+	// パスワードの再設定で session_version が増えると、それより前のセッションはログアウト扱い
+	acc := svc.accounts[3]
+	acc.SessionVersion++
+	svc.accounts[3] = acc
+	rec := request(e, http.MethodGet, "/users", cookies)
+
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+}
+
+// newLoginContext は POST /login のリクエストを組み立てる
+func newLoginContext(e *echo.Echo, form url.Values) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+// setFormToken はフォームの csrf にトークンを入れる
+func setFormToken(c echo.Context, token string) {
+	c.Request().ParseForm()
+	c.Request().Form.Set("csrf", token)
+}
+
+func TestAuthController_Login(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	ctrl := NewAuthController(newMockAuthService())
+	c, rec := newLoginContext(e, url.Values{
+		"email":    {"editor@example.com"},
+		"password": {"correct-horse"},
+		"next":     {"/users?page=2"},
+	})
+
+	err := withSession(func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return ctrl.Login(c)
+	})(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users?page=2", rec.Header().Get(echo.HeaderLocation))
+	assert.NotEmpty(t, rec.Result().Cookies())
+}
+
+func TestAuthController_Login_WrongPassword(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &mockRenderer{}
+	ctrl := NewAuthController(newMockAuthService())
+	c, rec := newLoginContext(e, url.Values{
+		"email":    {"editor@example.com"},
+		"password": {"wrong"},
+	})
+
+	err := withSession(func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return ctrl.Login(c)
+	})(c)
+
+	// ログイン画面を再表示する（リダイレクトしない）
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestSafeNext(t *testing.T) {
+	assert.Equal(t, "/users?page=2", safeNext("/users?page=2"))
+	// 外部サイトへのリダイレクトは許さない
+	assert.Equal(t, "/users", safeNext("https://evil.example.com"))
+	assert.Equal(t, "/users", safeNext("//evil.example.com"))
+	assert.Equal(t, "/users", safeNext(""))
+}
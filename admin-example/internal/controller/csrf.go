@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// CSRF 対策と二重送信の防止
+//
+// セッションごとに秘密の値 (csrf_secret) を作り、トークンは「ランダムな値.署名」の形にする。
+// 署名は秘密の値で作るので、他のサイトは正しいトークンを作れない (VerifyCSRF で確認する)。
+//
+// フォームのトークン (IssueToken) は1回だけ使える。発行したものをセッションに最大 maxFormKeys 個まで
+// 覚えておき、使ったら消す。複数のタブで別々の画面を開いても、それぞれのフォームから送信できる
+
+const (
+	sessionKeyCSRFSecret = "csrf_secret"
+	sessionKeyFormKeys   = "form_keys"
+
+	// 覚えておくフォームのトークンの数。超えたら古いものから捨てる
+	maxFormKeys = 32
+
+	// htmx のヘッダー用トークンの「ランダムな値」の部分。使い捨てにしないので固定
+	headerNonce = "header"
+)
+
+// csrfSecret はセッションの秘密の値を返す。まだ無ければ作って保存する
+func csrfSecret(c echo.Context) (string, bool) {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return "", false
+	}
+	if secret, ok := sess.Values[sessionKeyCSRFSecret].(string); ok && secret != "" {
+		return secret, true
+	}
+	secret := randomHex(32)
+	sess.Values[sessionKeyCSRFSecret] = secret
+	sess.Save(c.Request(), c.Response())
+	return secret, true
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand が失敗するのは OS の異常なので続けられない
+	}
+	return hex.EncodeToString(b)
+}
+
+func signToken(secret, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(nonce))
+	return nonce + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyToken は署名を確かめて、ランダムな値の部分を返す
+func verifyToken(secret, token string) (string, bool) {
+	nonce, _, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return "", false
+	}
+	return nonce, hmac.Equal([]byte(signToken(secret, nonce)), []byte(token))
+}
+
+// CSRFToken は htmx などのヘッダー (X-CSRF-Token) に載せるトークン。
+// layout/base.ace が body の hx-headers に入れるので、全ての htmx リクエストに自動で付く
+func CSRFToken(c echo.Context) string {
+	secret, ok := csrfSecret(c)
+	if !ok {
+		return ""
+	}
+	return signToken(secret, headerNonce)
+}
+
+// submittedToken はヘッダーの X-CSRF-Token か、フォームの csrf を返す
+func submittedToken(c echo.Context) string {
+	if token := c.Request().Header.Get("X-CSRF-Token"); token != "" {
+		return token
+	}
+	return c.FormValue("csrf")
+}
+
+// VerifyCSRF は POST / PUT / PATCH / DELETE のトークンを確認するミドルウェア。
+// /api/ は RequireJSON で application/json しか受け付けず、他のサイトのフォームからは送れないので対象外
+func VerifyCSRF(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		if isAPIRequest(c) {
+			return next(c)
+		}
+		if !(&BaseController{}).IsValidToken(c) {
+			return ErrInvalidCSRFToken
+		}
+		return next(c)
+	}
+}
+
+// 二重送信防止：フォームのトークンを確認し、使用済みにする。
+// 使用済み・覚えていない（古すぎる）トークンは false
+func (b *BaseController) IsValidAndDestroyToken(c echo.Context) bool {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return false
+	}
+	secret, _ := sess.Values[sessionKeyCSRFSecret].(string)
+	if secret == "" {
+		return false
+	}
+	nonce, ok := verifyToken(secret, c.FormValue("csrf"))
+	if !ok {
+		return false
+	}
+
+	keys, _ := sess.Values[sessionKeyFormKeys].([]string)
+	for i, k := range keys {
+		if k == nonce {
+			sess.Values[sessionKeyFormKeys] = append(keys[:i:i], keys[i+1:]...)
+			sess.Save(c.Request(), c.Response())
+			return true
+		}
+	}
+	return false
+}
+
+// トークンチェック（破棄しない版）
+// htmx の hx-delete のように、同じ画面から何度も送られるリクエスト用。
+// フォームの "csrf" か、ヘッダーの "X-CSRF-Token" のどちらかで受け取る（署名だけを確認する）
+func (b *BaseController) IsValidToken(c echo.Context) bool {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return false
+	}
+	secret, _ := sess.Values[sessionKeyCSRFSecret].(string)
+	if secret == "" {
+		return false
+	}
+	_, ok := verifyToken(secret, submittedToken(c))
+	return ok
+}
+
+// トークン発行（フォーム1つにつき1つ）
+func (b *BaseController) IssueToken(c echo.Context) string {
+	secret, ok := csrfSecret(c)
+	if !ok {
+		// セッションが使えない（ミドルウェア未設定）場合は空のトークン
+		return ""
+	}
+	sess, _ := session.Get("session", c)
+
+	nonce := randomHex(16)
+	keys, _ := sess.Values[sessionKeyFormKeys].([]string)
+	keys = append(keys, nonce)
+	if len(keys) > maxFormKeys {
+		keys = keys[len(keys)-maxFormKeys:]
+	}
+	sess.Values[sessionKeyFormKeys] = keys
+	sess.Save(c.Request(), c.Response())
+	return signToken(secret, nonce)
+}
+
+// formToken はテンプレートで {{csrfField .csrf}} が使われた時に初めてトークンを発行する。
+// TemplateGlobals が全ての画面に入れるので、コントローラーでトークンを渡さなくてよい
+// (フォームの無い画面では発行しないので、覚えておくトークンを無駄に使わない)
+type formToken struct {
+	c     echo.Context
+	once  sync.Once
+	value string
+}
+
+func (t *formToken) String() string {
+	t.once.Do(func() {
+		t.value = (&BaseController{}).IssueToken(t.c)
+	})
+	return t.value
+}
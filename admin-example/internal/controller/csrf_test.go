@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCSRFEcho は main.go と同じく VerifyCSRF を通したルーティングを組み立てる
+func newCSRFEcho() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	e.Use(VerifyCSRF)
+	b := &BaseController{}
+
+	// フォームの画面を開く (1回だけ使えるトークンを発行する)
+	e.GET("/form", func(c echo.Context) error { return c.String(http.StatusOK, b.IssueToken(c)) })
+	// 一覧の画面を開く (htmx のヘッダー用のトークン)
+	e.GET("/list", func(c echo.Context) error { return c.String(http.StatusOK, CSRFToken(c)) })
+	e.POST("/submit", func(c echo.Context) error {
+		if !b.IsValidAndDestroyToken(c) {
+			return ErrDoubleSubmit
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	e.DELETE("/items/1", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.POST("/api/v1/items", func(c echo.Context) error { return c.NoContent(http.StatusCreated) })
+	return e
+}
+
+// browser は Cookie を覚えておく、ブラウザの代わり
+type browser struct {
+	e       *echo.Echo
+	cookies map[string]*http.Cookie
+}
+
+func newBrowser(e *echo.Echo) *browser {
+	return &browser{e: e, cookies: map[string]*http.Cookie{}}
+}
+
+func (b *browser) do(req *http.Request) *httptest.ResponseRecorder {
+	for _, c := range b.cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	b.e.ServeHTTP(rec, req)
+	for _, c := range rec.Result().Cookies() {
+		b.cookies[c.Name] = c
+	}
+	return rec
+}
+
+func (b *browser) get(path string) string {
+	return b.do(httptest.NewRequest(http.MethodGet, path, nil)).Body.String()
+}
+
+func (b *browser) submit(token string) int {
+	req := httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(url.Values{"csrf": {token}}.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return b.do(req).Code
+}
+
+func TestCSRF_MultipleTabs(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+
+	// 2つのタブでフォームを開き、先に開いた方から送信しても両方とも通る
+	tab1 := b.get("/form")
+	tab2 := b.get("/form")
+
+	assert.NotEqual(t, tab1, tab2)
+	assert.Equal(t, http.StatusOK, b.submit(tab1))
+	assert.Equal(t, http.StatusOK, b.submit(tab2))
+}
+
+func TestCSRF_Replay(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+	token := b.get("/form")
+
+	assert.Equal(t, http.StatusOK, b.submit(token))
+	// 同じトークンでもう一度送ると二重送信 (署名は正しいので 403 ではなく 400)
+	assert.Equal(t, http.StatusBadRequest, b.submit(token))
+}
+
+func TestCSRF_OldTokensAreForgotten(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+	first := b.get("/form")
+	for i := 0; i < maxFormKeys; i++ {
+		b.get("/form")
+	}
+
+	// 覚えておける数を超えたら、古いものから使えなくなる
+	assert.Equal(t, http.StatusBadRequest, b.submit(first))
+}
+
+func TestCSRF_Forged(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+	token := b.get("/form")
+	nonce, _, _ := strings.Cut(token, ".")
+
+	// 署名が正しくない・トークンが無い・他のセッションのトークンは 403
+	assert.Equal(t, http.StatusForbidden, b.submit(nonce+".0000"))
+	assert.Equal(t, http.StatusForbidden, b.submit(""))
+	other := newBrowser(b.e)
+	assert.Equal(t, http.StatusForbidden, other.submit(token))
+}
+
+func TestCSRF_HTMXHeader(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+	header := b.get("/list")
+
+	del := func(token string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/items/1", nil)
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		return b.do(req).Code
+	}
+	// ヘッダーのトークンは使い捨てではないので、何度でも使える
+	assert.Equal(t, http.StatusOK, del(header))
+	assert.Equal(t, http.StatusOK, del(header))
+	assert.Equal(t, http.StatusForbidden, del(""))
+
+	// ヘッダー用のトークンはフォームの1回限りのトークンとしては使えない
+	assert.Equal(t, http.StatusBadRequest, b.submit(header))
+}
+
+func TestCSRF_SkipsAPI(t *testing.T) {
+	b := newBrowser(newCSRFEcho())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/items", strings.NewReader("{}"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	assert.Equal(t, http.StatusCreated, b.do(req).Code)
+}
+
+func TestFormToken_IssuedOnlyWhenUsed(t *testing.T) {
+	e := echo.New()
+	h := func(c echo.Context) error {
+		tok := &formToken{c: c}
+		sess, _ := session.Get("session", c)
+
+		// 使われるまでは発行しない
+		_, issued := sess.Values[sessionKeyFormKeys]
+		assert.False(t, issued)
+
+		// 何度使っても同じトークン (1つの画面に複数のフォームがあっても良い)
+		first := fmt.Sprint(tok)
+		assert.NotEmpty(t, first)
+		assert.Equal(t, first, tok.String())
+		assert.Len(t, sess.Values[sessionKeyFormKeys], 1)
+		return nil
+	}
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	assert.NoError(t, withSession(h)(c))
+}
+
+func TestTemplateGlobals_AnonymousDoesNotSaveSession(t *testing.T) {
+	e := echo.New()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	e.GET("/login", func(c echo.Context) error {
+		globals := TemplateGlobals(c)
+		return c.String(http.StatusOK, globals["CSRFToken"].(string))
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+	// ログインしていない人の画面では、フォームが無ければセッションを保存しない
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Empty(t, rec.Result().Cookies())
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go-example/admin-example/internal/service"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// 送信用トークン (BaseController) のチェックに失敗した時のエラー
+// echo.ErrNotFound などと同じく、そのまま return すれば HTTPErrorHandler が画面を出す
+var (
+	// ErrDoubleSubmit: トークンが無い・使用済み（二重送信や、古い画面からの送信）
+	ErrDoubleSubmit = echo.NewHTTPError(http.StatusBadRequest, "二重送信エラーです。前の画面に戻ってやり直してください。")
+	// ErrInvalidCSRFToken: htmx などから送られたトークンが正しくない
+	ErrInvalidCSRFToken = echo.NewHTTPError(http.StatusForbidden, "不正なリクエストです。画面を再読み込みしてください。")
+)
+
+// errorResponse: エラーの種類ごとのステータスと、利用者に見せてよいメッセージ
+type errorResponse struct {
+	Status  int
+	Message string
+	Errors  map[string]string // バリデーションエラーの内訳（422 の時だけ）
+}
+
+// classifyError はハンドラーが返したエラーを、ステータスとメッセージに振り分ける。
+// 想定外のエラーの中身 (err.Error()) は利用者には見せない
+func classifyError(c echo.Context, err error) errorResponse {
+	var he *echo.HTTPError
+	var ve validator.ValidationErrors
+	switch {
+	case errors.As(err, &he):
+		msg, ok := he.Message.(string)
+		if !ok || msg == "" {
+			msg = http.StatusText(he.Code)
+		}
+		return errorResponse{Status: he.Code, Message: msg}
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return errorResponse{Status: http.StatusNotFound, Message: "お探しのデータは見つかりませんでした"}
+	case errors.Is(err, service.ErrConflict):
+		return errorResponse{Status: http.StatusConflict, Message: "他の人が先に更新しました。画面を再読み込みしてから、もう一度やり直してください"}
+	case errors.Is(err, service.ErrDuplicateName), errors.Is(err, service.ErrDuplicateEmail), errors.Is(err, service.ErrValidation):
+		errs, status := fieldErrors(err)
+		return errorResponse{Status: status, Message: "入力内容に誤りがあります", Errors: errs}
+	case errors.As(err, &ve):
+		return errorResponse{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "入力内容に誤りがあります",
+			Errors:  (&BaseController{}).GetValidationErrors(c, ve, nil),
+		}
+	default:
+		return errorResponse{Status: http.StatusInternalServerError, Message: "サーバーでエラーが発生しました"}
+	}
+}
+
+// fieldErrors は Service のドメインのエラーを「項目 → メッセージ」とステータスにする。
+// 入力のエラーは 422、名前・メールアドレスの重複は 409 (どれもフォームの項目の下に出せる)
+func fieldErrors(err error) (map[string]string, int) {
+	var ve *service.ValidationError
+	switch {
+	case errors.As(err, &ve):
+		return map[string]string{ve.Field: ve.Message}, http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrDuplicateName):
+		return map[string]string{"name": service.ErrDuplicateName.Error()}, http.StatusConflict
+	case errors.Is(err, service.ErrDuplicateEmail):
+		return map[string]string{"email": service.ErrDuplicateEmail.Error()}, http.StatusConflict
+	}
+	return map[string]string{}, http.StatusUnprocessableEntity
+}
+
+// isFieldError はフォームの項目に出せるドメインのエラー (fieldErrors で変換できるもの) かを返す
+func isFieldError(err error) bool {
+	var ve *service.ValidationError
+	return errors.As(err, &ve) || errors.Is(err, service.ErrDuplicateName) || errors.Is(err, service.ErrDuplicateEmail)
+}
+
+// HTTPErrorHandler: echo の e.HTTPErrorHandler に登録する。
+// 画面には Ace のエラーテンプレート (views/errors) を、API と JSON を求めるリクエストには JSON を返す。
+// 500 系はログに残し、問い合わせ用に X-Request-Id (相関ID) を表示する
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	res := classifyError(c, err)
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if res.Status >= http.StatusInternalServerError {
+		// request_id は ctx から付く (internal/logging)
+		slog.ErrorContext(c.Request().Context(), "エラー",
+			"method", c.Request().Method, "path", c.Request().URL.Path, "status", res.Status, "error", err.Error())
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(res.Status)
+	} else if wantsJSON(c) {
+		body := map[string]interface{}{"message": res.Message}
+		if res.Errors != nil {
+			body["errors"] = res.Errors
+		}
+		if res.Status >= http.StatusInternalServerError {
+			body["request_id"] = requestID
+		}
+		err = c.JSON(res.Status, body)
+	} else {
+		err = renderErrorPage(c, res, requestID)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "エラー画面を表示できませんでした", "error", err.Error())
+	}
+}
+
+// renderErrorPage はエラー画面を表示する。テンプレート自体が壊れていたら文字だけ返す
+func renderErrorPage(c echo.Context, res errorResponse, requestID string) error {
+	name := "errors/error"
+	if res.Status == http.StatusNotFound {
+		name = "errors/404"
+	}
+	data := map[string]interface{}{
+		"Status":  res.Status,
+		"Title":   http.StatusText(res.Status),
+		"Message": res.Message,
+		"Errors":  res.Errors,
+	}
+	if res.Status >= http.StatusInternalServerError {
+		data["RequestID"] = requestID
+	}
+
+	if c.Echo().Renderer != nil {
+		err := c.Render(res.Status, name, data)
+		if err == nil {
+			return nil
+		}
+		slog.ErrorContext(c.Request().Context(), "エラー画面のテンプレートを表示できませんでした", "template", name, "error", err.Error())
+	}
+	if requestID != "" && res.Status >= http.StatusInternalServerError {
+		return c.String(res.Status, res.Message+" (お問い合わせ番号: "+requestID+")")
+	}
+	return c.String(res.Status, res.Message)
+}
+
+// wantsJSON は API か、JSON を求めている (Accept: application/json) リクエストかを返す
+func wantsJSON(c echo.Context) bool {
+	if isAPIRequest(c) {
+		return true
+	}
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	return strings.Contains(accept, echo.MIMEApplicationJSON) && !strings.Contains(accept, echo.MIMETextHTML)
+}
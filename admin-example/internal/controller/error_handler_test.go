@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/validation"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// どのテンプレートが選ばれたかと、渡されたメッセージを書き出すだけの偽物
+type errorPageRenderer struct{}
+
+func (r *errorPageRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	d := data.(map[string]interface{})
+	w.Write([]byte(name + ":" + d["Message"].(string)))
+	if id, ok := d["RequestID"].(string); ok {
+		w.Write([]byte(":" + id))
+	}
+	return nil
+}
+
+func newErrorContext(method, path, accept string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	e.Renderer = &errorPageRenderer{}
+	req := httptest.NewRequest(method, path, nil)
+	if accept != "" {
+		req.Header.Set(echo.HeaderAccept, accept)
+	}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Response().Header().Set(echo.HeaderXRequestID, "req-123")
+	return c, rec
+}
+
+func TestHTTPErrorHandler_HTML(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{"見つからない", sql.ErrNoRows, http.StatusNotFound, "errors/404:お探しのデータは見つかりませんでした"},
+		{"Service: 見つからない", fmt.Errorf("%w: %w", service.ErrNotFound, sql.ErrNoRows), http.StatusNotFound, "errors/404:お探しのデータは見つかりませんでした"},
+		{"Service: 競合", service.ErrConflict, http.StatusConflict, "errors/error:他の人が先に更新しました"},
+		{"Service: 名前の重複", service.ErrDuplicateName, http.StatusConflict, "errors/error:入力内容に誤りがあります"},
+		{"Service: メールアドレスの重複", service.ErrDuplicateEmail, http.StatusConflict, "errors/error:入力内容に誤りがあります"},
+		{"ルートが無い", echo.ErrNotFound, http.StatusNotFound, "errors/404:Not Found"},
+		{"二重送信", ErrDoubleSubmit, http.StatusBadRequest, "errors/error:二重送信エラーです"},
+		{"トークン不正", ErrInvalidCSRFToken, http.StatusForbidden, "errors/error:不正なリクエストです"},
+		{"想定外", errors.New("dial tcp: connection refused"), http.StatusInternalServerError, "errors/error:サーバーでエラーが発生しました:req-123"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, rec := newErrorContext(http.MethodGet, "/users/1/edit", "text/html")
+
+			HTTPErrorHandler(tc.err, c)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tc.wantBody)
+			assert.NotContains(t, rec.Body.String(), "connection refused")
+		})
+	}
+}
+
+func TestHTTPErrorHandler_JSON(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		accept   string
+		err      error
+		wantCode int
+	}{
+		{"API の 404", "/api/users/9", "", sql.ErrNoRows, http.StatusNotFound},
+		{"Accept: application/json の 500", "/users", echo.MIMEApplicationJSON, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, rec := newErrorContext(http.MethodGet, tc.path, tc.accept)
+
+			HTTPErrorHandler(tc.err, c)
+
+			assert.Equal(t, tc.wantCode, rec.Code)
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.NotEmpty(t, body["message"])
+			assert.NotContains(t, rec.Body.String(), "boom")
+			// 相関IDは 500 の時だけ返す
+			if tc.wantCode >= http.StatusInternalServerError {
+				assert.Equal(t, "req-123", body["request_id"])
+			} else {
+				assert.NotContains(t, body, "request_id")
+			}
+		})
+	}
+}
+
+func TestHTTPErrorHandler_Validation(t *testing.T) {
+	v := &testValidator{validator: validation.New()}
+	err := v.Validate(&struct {
+		Name string `validate:"required" label:"名前"`
+	}{})
+	c, rec := newErrorContext(http.MethodPost, "/api/users", "")
+
+	HTTPErrorHandler(err, c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body struct {
+		Errors map[string]string `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Contains(t, body.Errors, "Name")
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// フラッシュメッセージ: リダイレクトした先の画面で1回だけ表示するメッセージ
+// (「登録しました」など)。セッションに入れておき、次に画面を表示した時に取り出して消す
+
+type FlashLevel string
+
+const (
+	FlashSuccess FlashLevel = "success"
+	FlashInfo    FlashLevel = "info"
+	FlashWarning FlashLevel = "warning"
+	FlashError   FlashLevel = "error"
+)
+
+type Flash struct {
+	Level   FlashLevel
+	Message string
+}
+
+// セッションに入れる時のキー。gob に型を登録しなくて済むよう "level:message" の文字列で入れる
+const flashKey = "flash"
+
+// AddFlash はメッセージをセッションに入れる。表示は layout/base.ace が行う
+func (b *BaseController) AddFlash(c echo.Context, level FlashLevel, msg string) {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return
+	}
+	sess.AddFlash(string(level)+":"+msg, flashKey)
+	sess.Save(c.Request(), c.Response())
+}
+
+// redirectWithFlash はメッセージを入れてから url へリダイレクトする
+func (b *BaseController) redirectWithFlash(c echo.Context, level FlashLevel, msg, url string) error {
+	b.AddFlash(c, level, msg)
+	return c.Redirect(http.StatusSeeOther, url)
+}
+
+// doubleSubmitted は送信用トークンが使えなかった時の応答。
+// 普通の画面なら注意を出して back へ戻し、リダイレクトを追えない htmx や API には 400 を返す
+func (b *BaseController) doubleSubmitted(c echo.Context, back string) error {
+	if c.Request().Header.Get("HX-Request") == "true" || wantsJSON(c) {
+		return ErrDoubleSubmit
+	}
+	return b.redirectWithFlash(c, FlashWarning, "この画面は送信済みか、有効期限が切れています。もう一度操作してください。", back)
+}
+
+// Flashes は溜まっているメッセージを入れた順に取り出す（取り出したものはセッションから消える）
+func Flashes(c echo.Context) []Flash {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return nil
+	}
+	values := sess.Flashes(flashKey)
+	if len(values) == 0 {
+		return nil
+	}
+	sess.Save(c.Request(), c.Response())
+
+	flashes := make([]Flash, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		level, msg, _ := strings.Cut(s, ":")
+		flashes = append(flashes, Flash{Level: FlashLevel(level), Message: msg})
+	}
+	return flashes
+}
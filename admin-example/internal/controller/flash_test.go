@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFlashEcho はメッセージを入れる画面と、取り出す画面だけのルーティングを組み立てる
+func newFlashEcho() (*echo.Echo, *[]Flash) {
+	e := echo.New()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	b := &BaseController{}
+
+	e.POST("/save", func(c echo.Context) error {
+		b.AddFlash(c, FlashInfo, "1件目")
+		return b.redirectWithFlash(c, FlashSuccess, "保存しました", "/list")
+	})
+	e.POST("/stale", func(c echo.Context) error {
+		return b.doubleSubmitted(c, "/list")
+	})
+	got := new([]Flash)
+	e.GET("/list", func(c echo.Context) error {
+		*got = Flashes(c)
+		return c.NoContent(http.StatusOK)
+	})
+	return e, got
+}
+
+// follow はレスポンスの Cookie を付けて次のリクエストを送る。
+// 1回のリクエストで何度も Save すると Set-Cookie が複数付くので、ブラウザと同じく最後のものを使う
+func follow(e *echo.Echo, prev *httptest.ResponseRecorder, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	latest := map[string]*http.Cookie{}
+	for _, c := range prev.Result().Cookies() {
+		latest[c.Name] = c
+	}
+	for _, c := range latest {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFlash_ShownOnceAfterRedirect(t *testing.T) {
+	e, got := newFlashEcho()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/save", nil))
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/list", rec.Header().Get(echo.HeaderLocation))
+
+	// リダイレクト先で、入れた順に取り出せる
+	rec = follow(e, rec, http.MethodGet, "/list")
+	assert.Equal(t, []Flash{
+		{Level: FlashInfo, Message: "1件目"},
+		{Level: FlashSuccess, Message: "保存しました"},
+	}, *got)
+
+	// 一度表示したら消える
+	follow(e, rec, http.MethodGet, "/list")
+	assert.Empty(t, *got)
+}
+
+func TestFlash_DoubleSubmitted(t *testing.T) {
+	e, got := newFlashEcho()
+
+	// 普通の画面なら、注意を出して戻す
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stale", nil))
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	follow(e, rec, http.MethodGet, "/list")
+	if assert.Len(t, *got, 1) {
+		assert.Equal(t, FlashWarning, (*got)[0].Level)
+	}
+
+	// htmx はリダイレクトを追えないので 400
+	req := httptest.NewRequest(http.MethodPost, "/stale", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestFlash_NoSession(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	// セッションが無くても落ちない
+	(&BaseController{}).AddFlash(c, FlashError, "x")
+	assert.Nil(t, Flashes(c))
+}
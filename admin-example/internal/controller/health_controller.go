@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"go-example/admin-example/internal/repository"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readiness チェックでDBの応答を待つ時間
+const readyTimeout = 2 * time.Second
+
+// HealthController: オーケストレーター（k8s など）から叩かれるヘルスチェック
+//   - /healthz: プロセスが生きているか（liveness）。DBは見ない
+//   - /readyz:  リクエストを受けてよいか（readiness）。DBに Ping する
+type HealthController struct {
+	repo repository.HealthRepository
+	// シャットダウンが始まったら true。新しいリクエストを振られないよう readyz を落とす
+	shuttingDown atomic.Bool
+}
+
+func NewHealthController(r repository.HealthRepository) *HealthController {
+	return &HealthController{repo: r}
+}
+
+// MarkShuttingDown はシャットダウン開始を記録する（以降 /readyz は 503 を返す）
+func (h *HealthController) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// 生存確認 (GET /healthz)
+func (h *HealthController) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// 準備完了確認 (GET /readyz)
+func (h *HealthController) Readyz(c echo.Context) error {
+	if h.shuttingDown.Load() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readyTimeout)
+	defer cancel()
+	if err := h.repo.Ping(ctx); err != nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "db unavailable"})
+	}
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
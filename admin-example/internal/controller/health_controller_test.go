@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// HealthRepository の偽物。err を返すとDBが落ちている扱い
+type mockHealthRepository struct {
+	err error
+}
+
+func (m *mockHealthRepository) Ping(ctx context.Context) error { return m.err }
+
+func newHealthEcho(h *HealthController) *echo.Echo {
+	e := echo.New()
+	e.GET("/healthz", h.Healthz)
+	e.GET("/readyz", h.Readyz)
+	return e
+}
+
+func serve(e *echo.Echo, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHealthController_Healthz(t *testing.T) {
+	// DBが落ちていても liveness は OK（再起動しても直らないので）
+	e := newHealthEcho(NewHealthController(&mockHealthRepository{err: sql.ErrConnDone}))
+
+	rec := serve(e, "/healthz")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHealthController_Readyz(t *testing.T) {
+	e := newHealthEcho(NewHealthController(&mockHealthRepository{}))
+
+	rec := serve(e, "/readyz")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "ok")
+}
+
+func TestHealthController_Readyz_DBDown(t *testing.T) {
+	e := newHealthEcho(NewHealthController(&mockHealthRepository{err: sql.ErrConnDone}))
+
+	rec := serve(e, "/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestHealthController_Readyz_ShuttingDown(t *testing.T) {
+	h := NewHealthController(&mockHealthRepository{})
+	e := newHealthEcho(h)
+
+	h.MarkShuttingDown()
+	rec := serve(e, "/readyz")
+
+	// シャットダウン中は新しいリクエストを振られないように 503
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/service"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// InviteController: ユーザーの招待（管理画面）と、招待された人の受諾（ログイン不要の画面）
+type InviteController struct {
+	BaseController
+	svc service.InviteService
+}
+
+func NewInviteController(s service.InviteService) *InviteController {
+	return &InviteController{svc: s}
+}
+
+// 招待画面 (GET /users/invite)
+func (i *InviteController) New(c echo.Context) error {
+	return i.renderInvite(c, http.StatusOK, map[string]string{}, &model.UserInviteForm{})
+}
+
+// 招待 (POST /users/invite)
+func (i *InviteController) Create(c echo.Context) error {
+	if !i.IsValidAndDestroyToken(c) {
+		return i.doubleSubmitted(c, "/users")
+	}
+
+	form := new(model.UserInviteForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return i.renderInvite(c, http.StatusUnprocessableEntity, i.GetValidationErrors(c, err, form), form)
+	}
+
+	_, err := i.svc.Invite(c.Request().Context(), service.InviteInput{
+		Email:       form.Email,
+		DisplayName: form.DisplayName,
+		Role:        model.Role(form.Role),
+	})
+	switch {
+	case errors.Is(err, service.ErrInviteMailFailed):
+		// 招待は登録できているので、画面は進めて再送してもらう
+		slog.WarnContext(c.Request().Context(), "招待メールを送れませんでした", "error", err.Error())
+		return i.redirectWithFlash(c, FlashWarning, "「"+form.Email+"」を招待しましたが、"+service.ErrInviteMailFailed.Error(), "/users?status=invited")
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return i.renderInvite(c, status, vErrors, form)
+	case err != nil:
+		return err
+	}
+	return i.redirectWithFlash(c, FlashSuccess, "「"+form.Email+"」に招待メールを送りました", "/users?status=invited")
+}
+
+// 招待の再送 (POST /users/:id/invite/resend)
+func (i *InviteController) Resend(c echo.Context) error {
+	return i.handleInvitation(c, i.svc.Resend, "招待メールを送り直しました")
+}
+
+// 招待の取り消し (POST /users/:id/invite/revoke)
+func (i *InviteController) Revoke(c echo.Context) error {
+	return i.handleInvitation(c, i.svc.Revoke, "招待のリンクを取り消しました")
+}
+
+// handleInvitation は一覧の行のボタン（再送・取り消し）の共通処理。終わったら一覧に戻る
+func (i *InviteController) handleInvitation(c echo.Context, fn func(ctx context.Context, id uint64) error, done string) error {
+	if !i.IsValidAndDestroyToken(c) {
+		return i.doubleSubmitted(c, "/users")
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+
+	back := "/users?status=invited"
+	err = fn(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, service.ErrNotInvited):
+		// 一覧を開いている間に受諾された
+		return i.redirectWithFlash(c, FlashWarning, err.Error(), back)
+	case errors.Is(err, service.ErrInviteMailFailed):
+		slog.WarnContext(c.Request().Context(), "招待メールを送れませんでした", "user_id", id, "error", err.Error())
+		return i.redirectWithFlash(c, FlashError, service.ErrInviteMailFailed.Error(), back)
+	case err != nil:
+		// 見つからない (service.ErrNotFound → 404) か、想定外のエラー (500)
+		return err
+	}
+	return i.redirectWithFlash(c, FlashSuccess, done, back)
+}
+
+// 受諾画面 (GET /invitations/accept?token=)
+func (i *InviteController) AcceptPage(c echo.Context) error {
+	tok := c.QueryParam("token")
+	inv, err := i.svc.CheckInvitation(c.Request().Context(), tok)
+	if err != nil {
+		return i.renderInvalidInvitation(c, err)
+	}
+	return i.renderAccept(c, http.StatusOK, map[string]string{}, tok, inv.Email)
+}
+
+// 受諾 (POST /invitations/accept)
+func (i *InviteController) Accept(c echo.Context) error {
+	form := new(model.InvitationAcceptForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if !i.IsValidAndDestroyToken(c) {
+		return i.doubleSubmitted(c, "/invitations/accept?token="+url.QueryEscape(form.Token))
+	}
+
+	// 画面にメールアドレスを出し直すため、先にリンクを確かめる
+	inv, err := i.svc.CheckInvitation(c.Request().Context(), form.Token)
+	if err != nil {
+		return i.renderInvalidInvitation(c, err)
+	}
+	if err := c.Validate(form); err != nil {
+		return i.renderAccept(c, http.StatusUnprocessableEntity, i.GetValidationErrors(c, err, form), form.Token, inv.Email)
+	}
+
+	err = i.svc.Accept(c.Request().Context(), form.Token, form.Password)
+	switch {
+	case errors.Is(err, service.ErrInvitationInvalid), errors.Is(err, service.ErrInvitationExpired):
+		return i.renderInvalidInvitation(c, err)
+	case errors.Is(err, service.ErrEmailAlreadyUsed):
+		// 画面にメールアドレスの入力欄は無いので、全体のメッセージにする
+		return i.renderAccept(c, http.StatusConflict, map[string]string{"Main": err.Error()}, form.Token, inv.Email)
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return i.renderAccept(c, status, vErrors, form.Token, inv.Email)
+	case err != nil:
+		return err
+	}
+	return i.redirectWithFlash(c, FlashSuccess, "パスワードを設定しました。ログインしてください", "/login")
+}
+
+func (i *InviteController) renderInvite(c echo.Context, status int, vErrors map[string]string, form *model.UserInviteForm) error {
+	return c.Render(status, "users/invite", map[string]interface{}{
+		"Errors":      vErrors,
+		"Email":       form.Email,
+		"DisplayName": form.DisplayName,
+		"Role":        form.Role,
+		"Roles":       model.Roles,
+	})
+}
+
+func (i *InviteController) renderAccept(c echo.Context, status int, vErrors map[string]string, tok, email string) error {
+	return c.Render(status, "invitations/accept", map[string]interface{}{
+		"Errors": vErrors,
+		"Token":  tok,
+		"Email":  email,
+	})
+}
+
+// renderInvalidInvitation はリンクが使えないことを表示する（フォームは出さない）
+// 期限切れは 410、それ以外（改ざん・使用済み・取り消し）は区別せず 404
+func (i *InviteController) renderInvalidInvitation(c echo.Context, err error) error {
+	status := http.StatusNotFound
+	msg := service.ErrInvitationInvalid.Error()
+	switch {
+	case errors.Is(err, service.ErrInvitationExpired):
+		status, msg = http.StatusGone, err.Error()
+	case !errors.Is(err, service.ErrInvitationInvalid):
+		return err
+	}
+	return c.Render(status, "invitations/accept", map[string]interface{}{
+		"Errors":  map[string]string{},
+		"Invalid": msg,
+	})
+}
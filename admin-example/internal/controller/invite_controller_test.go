@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/mail"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/token"
+	"go-example/admin-example/internal/validation"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// InviteService の偽物。トークン "good" だけを有効なリンクとして扱う
+type mockInviteService struct {
+	inviteErr error // Invite が返すエラー
+	resendErr error
+	accepted  string // Accept で受け取ったパスワード
+}
+
+func (m *mockInviteService) Invite(ctx context.Context, in service.InviteInput) (uint64, error) {
+	return 10, m.inviteErr
+}
+func (m *mockInviteService) Resend(ctx context.Context, userID uint64) error { return m.resendErr }
+func (m *mockInviteService) Revoke(ctx context.Context, userID uint64) error { return nil }
+
+func (m *mockInviteService) CheckInvitation(ctx context.Context, tok string) (repository.UserInvitation, error) {
+	switch tok {
+	case "good":
+		return repository.UserInvitation{ID: 1, UserID: 10, Email: "taro@example.com"}, nil
+	case "expired":
+		return repository.UserInvitation{}, service.ErrInvitationExpired
+	}
+	return repository.UserInvitation{}, service.ErrInvitationInvalid
+}
+
+func (m *mockInviteService) Accept(ctx context.Context, tok, password string) error {
+	if _, err := m.CheckInvitation(ctx, tok); err != nil {
+		return err
+	}
+	m.accepted = password
+	return nil
+}
+
+// inviteRenderer は招待の画面に渡されたエラーを書き出す Renderer
+type inviteRenderer struct{}
+
+func (r *inviteRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	d := data.(map[string]interface{})
+	if msg, _ := d["Invalid"].(string); msg != "" {
+		w.Write([]byte(msg))
+	}
+	errs, _ := d["Errors"].(map[string]string)
+	for field, msg := range errs {
+		fmt.Fprintf(w, "%s:%s\n", field, msg)
+	}
+	return nil
+}
+
+func newInviteEcho() *echo.Echo {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &inviteRenderer{}
+	return e
+}
+
+// postInviteForm は送信用トークンを付けてフォームを POST する
+func postInviteForm(e *echo.Echo, ctrl *InviteController, path string, form url.Values, h func(echo.Context) error, params ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if len(params) == 2 {
+		c.SetParamNames(params[0])
+		c.SetParamValues(params[1])
+	}
+
+	err := withSession(func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return h(c)
+	})(c)
+	if err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestInviteController_Create(t *testing.T) {
+	e := newInviteEcho()
+	ctrl := NewInviteController(&mockInviteService{})
+
+	rec := postInviteForm(e, ctrl, "/users/invite", url.Values{"email": {"taro@example.com"}, "role": {"editor"}}, ctrl.Create)
+
+	// 招待中のユーザーの一覧に戻る
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users?status=invited", rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestInviteController_Create_Errors(t *testing.T) {
+	e := newInviteEcho()
+
+	// 入力の誤りは 422 で再表示
+	ctrl := NewInviteController(&mockInviteService{})
+	rec := postInviteForm(e, ctrl, "/users/invite", url.Values{"email": {"taro"}}, ctrl.Create)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "email:")
+
+	// 登録済みのメールアドレスは 409 で項目の下に出す
+	ctrl = NewInviteController(&mockInviteService{inviteErr: service.ErrDuplicateEmail})
+	rec = postInviteForm(e, ctrl, "/users/invite", url.Values{"email": {"taro@example.com"}}, ctrl.Create)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "email:"+service.ErrDuplicateEmail.Error())
+
+	// メールだけ送れなかった時は、招待はできているので一覧に進む
+	ctrl = NewInviteController(&mockInviteService{inviteErr: fmt.Errorf("%w (timeout)", service.ErrInviteMailFailed)})
+	rec = postInviteForm(e, ctrl, "/users/invite", url.Values{"email": {"taro@example.com"}}, ctrl.Create)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+}
+
+func TestInviteController_Resend_NotInvited(t *testing.T) {
+	e := newInviteEcho()
+	ctrl := NewInviteController(&mockInviteService{resendErr: service.ErrNotInvited})
+
+	rec := postInviteForm(e, ctrl, "/users/10/invite/resend", url.Values{}, ctrl.Resend, "id", "10")
+
+	// 受諾済みなどは注意を出して一覧に戻る
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users?status=invited", rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestInviteController_AcceptPage(t *testing.T) {
+	e := newInviteEcho()
+	ctrl := NewInviteController(&mockInviteService{})
+
+	for tok, want := range map[string]int{
+		"good":     http.StatusOK,
+		"expired":  http.StatusGone,
+		"tampered": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/invitations/accept?token="+tok, nil)
+		rec := httptest.NewRecorder()
+		err := withSession(ctrl.AcceptPage)(e.NewContext(req, rec))
+
+		assert.NoError(t, err, tok)
+		assert.Equal(t, want, rec.Code, tok)
+	}
+}
+
+func TestInviteController_Accept(t *testing.T) {
+	e := newInviteEcho()
+	svc := &mockInviteService{}
+	ctrl := NewInviteController(svc)
+
+	// 確認用のパスワードが違えば 422
+	rec := postInviteForm(e, ctrl, "/invitations/accept", url.Values{
+		"token": {"good"}, "password": {"correct-horse"}, "password_confirm": {"correct-hose"},
+	}, ctrl.Accept)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "password_confirm:")
+	assert.Empty(t, svc.accepted)
+
+	// 設定できたらログイン画面へ
+	rec = postInviteForm(e, ctrl, "/invitations/accept", url.Values{
+		"token": {"good"}, "password": {"correct-horse"}, "password_confirm": {"correct-horse"},
+	}, ctrl.Accept)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, "correct-horse", svc.accepted)
+}
+
+// 編集者が自分のアドレスを管理者として招待し、受諾して管理者になる、ということができないこと。
+// RequireLogin が載せた役割を本物の InviteService が確かめるところまで通す
+func TestInviteController_Create_RoleNotGrantable(t *testing.T) {
+	s := repository.NewMemoryStore()
+	mailer := mail.NewMemoryMailer()
+	ctrl := NewInviteController(service.NewInviteService(repository.NewMemoryUserRepository(s), repository.NewMemoryTxManager(s),
+		mailer, token.NewSigner([]byte("test-secret")), service.InviteOptions{TTL: time.Hour, BaseURL: "https://admin.example.com"}))
+	auth := NewAuthController(newMockAuthService())
+
+	e := newInviteEcho()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	e.POST("/login", func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return auth.Login(c)
+	})
+	users := e.Group("/users", auth.RequireLogin, Authorize(RoutePermissions))
+	users.POST("/invite", func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return ctrl.Create(c)
+	})
+
+	editor := newBrowser(e)
+	send(editor, http.MethodPost, "/login", url.Values{"email": {"editor@example.com"}, "password": {"correct-horse"}})
+	for _, role := range []string{"admin", "editor"} {
+		rec := send(editor, http.MethodPost, "/users/invite", url.Values{"email": {"me@example.com"}, "role": {role}})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, role)
+		assert.Contains(t, rec.Body.String(), "role:"+service.ErrRoleNotGrantable.Error())
+	}
+	assert.Empty(t, mailer.Sent())
+
+	// 管理者なら招待できる
+	admin := newBrowser(e)
+	send(admin, http.MethodPost, "/login", url.Values{"email": {"admin@example.com"}, "password": {"correct-horse"}})
+	rec := send(admin, http.MethodPost, "/users/invite", url.Values{"email": {"me@example.com"}, "role": {"admin"}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Len(t, mailer.Sent(), 1)
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+
+	"go-example/admin-example/internal/validation"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+const sessionKeyLocale = "locale"
+
+// Locale は表示する言語を返す。
+// 画面で選んだもの (セッション) → ブラウザの設定 (Accept-Language) → 日本語 の順
+func Locale(c echo.Context) string {
+	if sess, err := session.Get("session", c); err == nil {
+		if l, ok := sess.Values[sessionKeyLocale].(string); ok && validation.IsSupported(l) {
+			return l
+		}
+	}
+	return validation.MatchLocale(c.Request().Header.Get("Accept-Language"))
+}
+
+// SetLocale は言語を切り替えて、元の画面に戻る (GET /locale/:lang)
+func SetLocale(c echo.Context) error {
+	lang := c.Param("lang")
+	if !validation.IsSupported(lang) {
+		return echo.NewHTTPError(http.StatusNotFound, "対応していない言語です")
+	}
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	sess.Values[sessionKeyLocale] = lang
+	if err := sess.Save(c.Request(), c.Response()); err != nil {
+		return err
+	}
+
+	// 戻り先は Referer のパスだけを使う（外部サイトへは戻さない）
+	back := "/users"
+	if ref, err := url.Parse(c.Request().Referer()); err == nil && ref.Path != "" {
+		back = safeNext(ref.RequestURI())
+	}
+	return c.Redirect(http.StatusSeeOther, back)
+}
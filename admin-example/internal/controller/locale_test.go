@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocale(t *testing.T) {
+	e := echo.New()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	e.GET("/locale/:lang", SetLocale)
+	e.GET("/current", func(c echo.Context) error { return c.String(http.StatusOK, Locale(c)) })
+	b := newBrowser(e)
+
+	current := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/current", nil)
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+		return b.do(req).Body.String()
+	}
+	// 選んでいなければブラウザの設定
+	assert.Equal(t, "en", current())
+
+	// 画面で選んだら、ブラウザの設定より優先する。戻り先は Referer のパスだけ
+	req := httptest.NewRequest(http.MethodGet, "/locale/ja", nil)
+	req.Header.Set("Referer", "https://evil.example.com/users?page=2")
+	rec := b.do(req)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users?page=2", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, "ja", current())
+
+	// 対応していない言語
+	assert.Equal(t, http.StatusNotFound, b.do(httptest.NewRequest(http.MethodGet, "/locale/fr", nil)).Code)
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"go-example/admin-example/internal/requestctx"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// RequestIDToContext は Echo の RequestID ミドルウェアが付けたIDを context.Context に載せる
+// (監査ログなどに、どのリクエストでの操作かを残すため)
+// middleware.RequestID() より後に登録すること
+func RequestIDToContext(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Response().Header().Get(echo.HeaderXRequestID)
+		if id != "" {
+			ctx := requestctx.WithRequestID(c.Request().Context(), id)
+			c.SetRequest(c.Request().WithContext(ctx))
+		}
+		return next(c)
+	}
+}
+
+// RequestLogger はリクエストごとに1行、logger に JSON で書く (middleware.Logger() の代わり)
+// RequestIDToContext より後に登録すると、request_id も付く (internal/logging)
+//
+//	500 系 → error、400 系 → warn、それ以外 → info
+func RequestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		// エラーはここで HTTPErrorHandler に渡し、実際に返したステータスを書く
+		HandleError:     true,
+		LogMethod:       true,
+		LogURI:          true,
+		LogRoutePath:    true,
+		LogStatus:       true,
+		LogLatency:      true,
+		LogRemoteIP:     true,
+		LogResponseSize: true,
+		LogError:        true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			level := slog.LevelInfo
+			switch {
+			case v.Status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case v.Status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("method", v.Method),
+				slog.String("uri", v.URI),
+				slog.String("route", v.RoutePath),
+				slog.Int("status", v.Status),
+				slog.Float64("latency_ms", float64(v.Latency.Microseconds())/1000),
+				slog.String("remote_ip", v.RemoteIP),
+				slog.Int64("bytes", v.ResponseSize),
+			}
+			if v.Error != nil {
+				attrs = append(attrs, slog.String("error", v.Error.Error()))
+			}
+			// ハンドラーの中で載せた値（ログインしたアカウントなど）も付くよう、今の ctx を使う
+			logger.LogAttrs(c.Request().Context(), level, "リクエスト", attrs...)
+			return nil
+		},
+	})
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/ratelimit"
+	"go-example/admin-example/internal/service"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 申し込んだ後は、アカウントがあっても無くても同じ表示にする（登録されているメールアドレスかを教えない）
+const passwordResetRequested = "入力したメールアドレスのアカウントがあれば、パスワードの再設定のメールを送りました。メールのリンクから新しいパスワードを決めてください"
+
+// PasswordResetController: パスワードを忘れた時の再設定（ログイン不要の画面）
+type PasswordResetController struct {
+	BaseController
+	svc service.PasswordResetService
+	// 申し込みの回数の制限。byIP は IP アドレスごと、byAccount は入力されたメールアドレスごと
+	byIP      *ratelimit.Limiter
+	byAccount *ratelimit.Limiter
+}
+
+func NewPasswordResetController(s service.PasswordResetService, byIP, byAccount *ratelimit.Limiter) *PasswordResetController {
+	return &PasswordResetController{svc: s, byIP: byIP, byAccount: byAccount}
+}
+
+// 申し込み画面 (GET /password/forgot)
+func (p *PasswordResetController) ForgotPage(c echo.Context) error {
+	return p.renderForgot(c, http.StatusOK, map[string]string{}, "")
+}
+
+// 申し込み (POST /password/forgot)
+func (p *PasswordResetController) Forgot(c echo.Context) error {
+	if !p.IsValidAndDestroyToken(c) {
+		return p.doubleSubmitted(c, "/password/forgot")
+	}
+
+	form := new(model.PasswordForgotForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return p.renderForgot(c, http.StatusUnprocessableEntity, p.GetValidationErrors(c, err, form), form.Email)
+	}
+
+	// 同じ IP アドレスからの申し込みが多すぎる時は、はっきり断る
+	// (IP アドレスの取り方は server.trust_proxy で決まる。main.go の e.IPExtractor)
+	ip := c.RealIP()
+	if !p.byIP.Allow(ip) {
+		return p.renderForgot(c, http.StatusTooManyRequests,
+			map[string]string{"Main": "申し込みが多すぎます。しばらく時間をおいてからもう一度お試しください"}, form.Email)
+	}
+	// 同じメールアドレスへの申し込みが多すぎる時は、黙ってメールを送らない
+	// (断るとアカウントがあることが分かってしまうので、表示は送った時と同じにする)
+	email := strings.ToLower(strings.TrimSpace(form.Email))
+	if !p.byAccount.Allow(email) {
+		slog.WarnContext(c.Request().Context(), "パスワードの再設定の申し込みが多すぎるため、メールを送りませんでした", "ip", ip)
+		return p.redirectWithFlash(c, FlashInfo, passwordResetRequested, "/login")
+	}
+
+	err := p.svc.Request(c.Request().Context(), email, ip)
+	switch {
+	case errors.Is(err, service.ErrResetMailFailed):
+		// 送れたかどうかも表示では区別しない
+		slog.WarnContext(c.Request().Context(), "パスワードの再設定のメールを送れませんでした", "error", err.Error())
+	case err != nil:
+		return err
+	}
+	return p.redirectWithFlash(c, FlashInfo, passwordResetRequested, "/login")
+}
+
+// 再設定画面 (GET /password/reset?token=)
+func (p *PasswordResetController) ResetPage(c echo.Context) error {
+	tok := c.QueryParam("token")
+	acc, err := p.svc.CheckReset(c.Request().Context(), tok)
+	if err != nil {
+		return p.renderInvalidReset(c, err)
+	}
+	return p.renderReset(c, http.StatusOK, map[string]string{}, tok, acc.Email)
+}
+
+// 再設定 (POST /password/reset)
+func (p *PasswordResetController) Reset(c echo.Context) error {
+	form := new(model.PasswordResetForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if !p.IsValidAndDestroyToken(c) {
+		return p.doubleSubmitted(c, "/password/reset?token="+url.QueryEscape(form.Token))
+	}
+
+	// 画面にメールアドレスを出し直すため、先にリンクを確かめる
+	acc, err := p.svc.CheckReset(c.Request().Context(), form.Token)
+	if err != nil {
+		return p.renderInvalidReset(c, err)
+	}
+	if err := c.Validate(form); err != nil {
+		return p.renderReset(c, http.StatusUnprocessableEntity, p.GetValidationErrors(c, err, form), form.Token, acc.Email)
+	}
+
+	err = p.svc.Reset(c.Request().Context(), form.Token, form.Password)
+	switch {
+	case errors.Is(err, service.ErrResetInvalid), errors.Is(err, service.ErrResetExpired):
+		return p.renderInvalidReset(c, err)
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return p.renderReset(c, status, vErrors, form.Token, acc.Email)
+	case err != nil:
+		return err
+	}
+	return p.redirectWithFlash(c, FlashSuccess, "パスワードを変更しました。新しいパスワードでログインしてください", "/login")
+}
+
+func (p *PasswordResetController) renderForgot(c echo.Context, status int, vErrors map[string]string, email string) error {
+	return c.Render(status, "password/forgot", map[string]interface{}{
+		"Errors": vErrors,
+		"Email":  email,
+	})
+}
+
+func (p *PasswordResetController) renderReset(c echo.Context, status int, vErrors map[string]string, tok, email string) error {
+	return c.Render(status, "password/reset", map[string]interface{}{
+		"Errors": vErrors,
+		"Token":  tok,
+		"Email":  email,
+	})
+}
+
+// renderInvalidReset はリンクが使えないことを表示する（フォームは出さない）
+// 期限切れは 410、それ以外（改ざん・使用済み）は区別せず 404
+func (p *PasswordResetController) renderInvalidReset(c echo.Context, err error) error {
+	status := http.StatusNotFound
+	msg := service.ErrResetInvalid.Error()
+	switch {
+	case errors.Is(err, service.ErrResetExpired):
+		status, msg = http.StatusGone, err.Error()
+	case !errors.Is(err, service.ErrResetInvalid):
+		return err
+	}
+	return c.Render(status, "password/reset", map[string]interface{}{
+		"Errors":  map[string]string{},
+		"Invalid": msg,
+	})
+}
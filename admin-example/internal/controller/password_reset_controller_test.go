@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/ratelimit"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// PasswordResetService の偽物。トークン "good" だけを有効なリンクとして扱う
+type mockPasswordResetService struct {
+	requested []string // Request で受け取ったメールアドレス
+	reset     string   // Reset で受け取ったパスワード
+}
+
+func (m *mockPasswordResetService) Request(ctx context.Context, email, ip string) error {
+	m.requested = append(m.requested, email)
+	return nil
+}
+
+func (m *mockPasswordResetService) CheckReset(ctx context.Context, tok string) (repository.Account, error) {
+	switch tok {
+	case "good":
+		return repository.Account{ID: 1, Email: "admin@example.com"}, nil
+	case "expired":
+		return repository.Account{}, service.ErrResetExpired
+	}
+	return repository.Account{}, service.ErrResetInvalid
+}
+
+func (m *mockPasswordResetService) Reset(ctx context.Context, tok, password string) error {
+	if _, err := m.CheckReset(ctx, tok); err != nil {
+		return err
+	}
+	m.reset = password
+	return nil
+}
+
+// postResetForm は送信用トークンを付けてフォームを POST する
+func postResetForm(e *echo.Echo, ctrl *PasswordResetController, path string, form url.Values, h func(echo.Context) error) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := withSession(func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c))
+		return h(c)
+	})(c)
+	if err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func TestPasswordResetController_Forgot_RateLimit(t *testing.T) {
+	e := newInviteEcho()
+	svc := &mockPasswordResetService{}
+	ctrl := NewPasswordResetController(svc, ratelimit.New(3, time.Hour), ratelimit.New(2, time.Hour))
+	forgot := func(email string) *httptest.ResponseRecorder {
+		return postResetForm(e, ctrl, "/password/forgot", url.Values{"email": {email}}, ctrl.Forgot)
+	}
+
+	// 同じメールアドレスは2回まで。3回目は送らないが、表示は同じ
+	for i := 0; i < 3; i++ {
+		rec := forgot("Admin@Example.com")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	}
+	assert.Equal(t, []string{"admin@example.com", "admin@example.com"}, svc.requested)
+
+	// 同じ IP アドレスからは3回まで。超えたら 429
+	rec := forgot("other@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Len(t, svc.requested, 2)
+}
+
+func TestPasswordResetController_Forgot_Invalid(t *testing.T) {
+	e := newInviteEcho()
+	svc := &mockPasswordResetService{}
+	ctrl := NewPasswordResetController(svc, ratelimit.New(3, time.Hour), ratelimit.New(3, time.Hour))
+
+	rec := postResetForm(e, ctrl, "/password/forgot", url.Values{"email": {"admin"}}, ctrl.Forgot)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "email:")
+	assert.Empty(t, svc.requested)
+}
+
+func TestPasswordResetController_ResetPage(t *testing.T) {
+	e := newInviteEcho()
+	ctrl := NewPasswordResetController(&mockPasswordResetService{}, ratelimit.New(1, time.Hour), ratelimit.New(1, time.Hour))
+
+	for tok, want := range map[string]int{
+		"good":     http.StatusOK,
+		"expired":  http.StatusGone,
+		"tampered": http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/password/reset?token="+tok, nil)
+		rec := httptest.NewRecorder()
+		err := withSession(ctrl.ResetPage)(e.NewContext(req, rec))
+
+		assert.NoError(t, err, tok)
+		assert.Equal(t, want, rec.Code, tok)
+	}
+}
+
+func TestPasswordResetController_Reset(t *testing.T) {
+	e := newInviteEcho()
+	svc := &mockPasswordResetService{}
+	ctrl := NewPasswordResetController(svc, ratelimit.New(1, time.Hour), ratelimit.New(1, time.Hour))
+
+	// 確認用のパスワードが違えば 422
+	rec := postResetForm(e, ctrl, "/password/reset", url.Values{
+		"token": {"good"}, "password": {"correct-horse"}, "password_confirm": {"correct-hose"},
+	}, ctrl.Reset)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "password_confirm:")
+	assert.Empty(t, svc.reset)
+
+	// 使えないリンクはフォームを出さない
+	rec = postResetForm(e, ctrl, "/password/reset", url.Values{
+		"token": {"expired"}, "password": {"correct-horse"}, "password_confirm": {"correct-horse"},
+	}, ctrl.Reset)
+	assert.Equal(t, http.StatusGone, rec.Code)
+
+	// 変更できたらログイン画面へ
+	rec = postResetForm(e, ctrl, "/password/reset", url.Values{
+		"token": {"good"}, "password": {"correct-horse"}, "password_confirm": {"correct-horse"},
+	}, ctrl.Reset)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, "correct-horse", svc.reset)
+}
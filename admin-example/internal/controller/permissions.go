@@ -0,0 +1,48 @@
+package controller
+
+import "go-example/admin-example/internal/model"
+
+// RoutePermissions: ログインが必要なルートと、そのルートに必要な権限の対応表
+// キーは "メソッド ルート定義" (c.Path() と同じ形)。
+// ここに無いルートは Authorize ミドルウェアで拒否されるので、ルートを足したら必ずここにも足すこと
+var RoutePermissions = map[string]model.Permission{
+	// 画面
+	"GET /users":                    model.PermUserView,
+	"GET /users/create":             model.PermUserEdit,
+	"POST /users":                   model.PermUserEdit,
+	"GET /users/edit/:id":           model.PermUserEdit,
+	"POST /users/update":            model.PermUserEdit,
+	"POST /users/:id/update":        model.PermUserEdit,
+	"DELETE /users/:id":             model.PermUserDelete,
+	"GET /users/:id/avatar":         model.PermUserView,
+	"GET /users/invite":             model.PermUserEdit,
+	"POST /users/invite":            model.PermUserEdit,
+	"POST /users/:id/invite/resend": model.PermUserEdit,
+	"POST /users/:id/invite/revoke": model.PermUserEdit,
+	"GET /users/trash":              model.PermUserView,
+	"POST /users/:id/restore":       model.PermUserDelete,
+	"GET /users/export":             model.PermUserView,
+	"GET /users/import":             model.PermUserEdit,
+	"POST /users/import":            model.PermUserEdit,
+	"POST /users/import/commit":     model.PermUserEdit,
+
+	"GET /audit": model.PermAuditView,
+
+	"GET /sessions":             model.PermSessionManage,
+	"POST /sessions/:id/revoke": model.PermSessionManage,
+
+	// 自分の2要素認証の設定（2要素認証が必須でも、設定する前に開ける）
+	"GET /account/2fa":                 model.PermAccountSelf,
+	"POST /account/2fa/setup":          model.PermAccountSelf,
+	"GET /account/2fa/qr.png":          model.PermAccountSelf,
+	"POST /account/2fa/enable":         model.PermAccountSelf,
+	"POST /account/2fa/disable":        model.PermAccountSelf,
+	"POST /account/2fa/recovery-codes": model.PermAccountSelf,
+
+	// JSON API
+	"GET /api/v1/users":        model.PermUserView,
+	"GET /api/v1/users/:id":    model.PermUserView,
+	"POST /api/v1/users":       model.PermUserEdit,
+	"PUT /api/v1/users/:id":    model.PermUserEdit,
+	"DELETE /api/v1/users/:id": model.PermUserDelete,
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/sessionstore"
+	"net/http"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// SessionController: ログイン中のセッションの一覧と強制終了（管理者用）
+type SessionController struct {
+	BaseController
+	svc service.SessionService
+}
+
+func NewSessionController(s service.SessionService) *SessionController {
+	return &SessionController{svc: s}
+}
+
+// 一覧表示 (GET /sessions)
+func (s *SessionController) Index(c echo.Context) error {
+	list, err := s.svc.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "セッションの一覧の取得に失敗しました").SetInternal(err)
+	}
+	return c.Render(http.StatusOK, "sessions/index", map[string]interface{}{
+		"Sessions": list,
+		"Current":  currentSessionID(c), // 今使っているセッションには終了ボタンを出さない
+	})
+}
+
+// 強制終了 (POST /sessions/:id/revoke)
+func (s *SessionController) Revoke(c echo.Context) error {
+	if !s.IsValidAndDestroyToken(c) {
+		return s.doubleSubmitted(c, "/sessions")
+	}
+	id := c.Param("id")
+	if id == currentSessionID(c) {
+		return s.redirectWithFlash(c, FlashWarning, "今使っているセッションは、ログアウトで終了してください", "/sessions")
+	}
+
+	err := s.svc.Revoke(c.Request().Context(), id)
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		return s.redirectWithFlash(c, FlashWarning, err.Error(), "/sessions")
+	case err != nil:
+		return err
+	}
+	return s.redirectWithFlash(c, FlashSuccess, "セッションを終了しました。その端末では次の操作でログイン画面に戻ります", "/sessions")
+}
+
+// currentSessionID は今のリクエストのセッションの、保存先での ID (sessionstore.Record.ID)
+func currentSessionID(c echo.Context) string {
+	sess, err := session.Get("session", c)
+	if err != nil || sess.ID == "" {
+		return ""
+	}
+	return sessionstore.RecordID(sess.ID)
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/sessionstore"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSessionService struct {
+	revoked []string
+}
+
+func (m *mockSessionService) List(ctx context.Context) ([]service.SessionInfo, error) {
+	return nil, nil
+}
+
+func (m *mockSessionService) Revoke(ctx context.Context, id string) error {
+	if id == "ended" {
+		return service.ErrSessionNotFound
+	}
+	m.revoked = append(m.revoked, id)
+	return nil
+}
+
+// postRevoke は本物の sessionstore のセッションで POST /sessions/:id/revoke を呼ぶ
+// id が空なら、今のリクエストのセッションを終了させようとする
+func postRevoke(ctrl *SessionController, id string) *httptest.ResponseRecorder {
+	e := echo.New()
+	store := sessionstore.NewStore(sessionstore.NewMemoryBackend(), sessionstore.Options{
+		Secrets: []string{"test-secret"},
+		Cookie:  sessions.Options{Path: "/", MaxAge: 3600},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/sessions/"+id+"/revoke", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	session.Middleware(store)(func(c echo.Context) error {
+		setFormToken(c, ctrl.IssueToken(c)) // ここでセッションが保存され、ID が決まる
+		if id == "" {
+			id = currentSessionID(c)
+		}
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		return ctrl.Revoke(c)
+	})(c)
+	return rec
+}
+
+func TestSessionController_Revoke(t *testing.T) {
+	svc := &mockSessionService{}
+	ctrl := NewSessionController(svc)
+
+	rec := postRevoke(ctrl, "other")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/sessions", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, []string{"other"}, svc.revoked)
+
+	// もう終わっているセッションは注意を出して一覧に戻る
+	rec = postRevoke(ctrl, "ended")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+	// 今使っているセッションは終了させない（ログアウトを使う）
+	rec = postRevoke(ctrl, "")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, []string{"other"}, svc.revoked)
+}
@@ -0,0 +1,350 @@
+package controller
+
+import (
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/qrcode"
+	"go-example/admin-example/internal/ratelimit"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// パスワードは合っていて、2要素認証のコードを待っている間にセッションに入れるキー
+// (アカウントIDは sessionKeyAccountID とは別のキーに入れ、コードを確かめるまではログインにしない)
+const (
+	sessionKeyTwoFactorAccountID = "2fa_account_id"
+	sessionKeyTwoFactorStartedAt = "2fa_started_at" // Unix 時間 (秒)
+	sessionKeyTwoFactorNext      = "2fa_next"       // ログイン後の戻り先
+	sessionKeyTwoFactorFailures  = "2fa_failures"   // コードを間違えた回数
+)
+
+const (
+	// パスワードを入れてから、コードを入れるまでの時間の上限
+	twoFactorPendingTTL = 5 * time.Minute
+	// 1回のログインの中でコードをこの回数間違えたら、パスワードから入れ直してもらう
+	// (総当たりへの備えは、アカウントごとに数える TwoFactorController.failures の方)
+	maxTwoFactorFailures = 5
+)
+
+// TwoFactorController: 2要素認証のログインの2段階目 (/login/2fa) と、自分の設定画面 (/account/2fa)
+type TwoFactorController struct {
+	BaseController
+	svc service.TwoFactorService
+	// コードを間違えた回数（アカウントごと）。セッションに持つとログインし直すたびに数え直しになるので、サーバーで数える
+	// 数はこのプロセスのメモリにあるので、複数台で動かすと台数分まで間違えられ、再起動すると数え直しになる
+	failures *ratelimit.Limiter
+	now      func() time.Time // テストで時刻を進めるため差し替えられる
+}
+
+func NewTwoFactorController(s service.TwoFactorService, failures *ratelimit.Limiter) *TwoFactorController {
+	return &TwoFactorController{svc: s, failures: failures, now: time.Now}
+}
+
+// errTwoFactorLocked: アカウントごとの間違えられる回数 (rate_limit.two_factor_failures) を超えた
+var errTwoFactorLocked = errors.New("確認コードを何度も間違えたため、しばらくこのアカウントのコードは受け付けません。時間をおいてやり直してください")
+
+// verifyCode はコードを確かめる前に、間違えた1回分を先に数えておく（枠が無ければ確かめない）
+// 確かめてから数えると、同時に送られた分は全部確かめてしまい、上限より多く試せるため。
+// 間違いでなければ（合っていた・別のエラー）数えた分を取り消す
+// 設定画面の確認（無効にする・リカバリーコードを作り直す）も同じ回数で数える
+func (t *TwoFactorController) verifyCode(c echo.Context, accountID uint64, verify func() error) error {
+	cancel, ok := t.failures.Reserve(strconv.FormatUint(accountID, 10))
+	if !ok {
+		slog.WarnContext(c.Request().Context(), "2要素認証のコードを間違えた回数が上限を超えています", "account_id", accountID)
+		return errTwoFactorLocked
+	}
+	err := verify()
+	if !errors.Is(err, service.ErrTwoFactorInvalidCode) {
+		cancel()
+	}
+	return err
+}
+
+// startTwoFactor はパスワードの確認が済んだことをセッションに入れる（まだログインにはしない）
+func startTwoFactor(c echo.Context, acc repository.Account, next string) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	for k := range sess.Values {
+		delete(sess.Values, k)
+	}
+	sess.Values[sessionKeyTwoFactorAccountID] = acc.ID
+	sess.Values[sessionKeyTwoFactorStartedAt] = time.Now().Unix()
+	sess.Values[sessionKeyTwoFactorNext] = next
+	sess.Values[sessionKeyTwoFactorFailures] = 0
+	return sess.Save(c.Request(), c.Response())
+}
+
+// pending はコードを待っているアカウントのIDを返す。期限が切れていれば ok は false
+func (t *TwoFactorController) pending(sess *sessions.Session) (uint64, bool) {
+	id, ok := sess.Values[sessionKeyTwoFactorAccountID].(uint64)
+	if !ok {
+		return 0, false
+	}
+	started, _ := sess.Values[sessionKeyTwoFactorStartedAt].(int64)
+	if t.now().Sub(time.Unix(started, 0)) > twoFactorPendingTTL {
+		return 0, false
+	}
+	return id, true
+}
+
+// restartLogin はコードを待っている状態を消して、ログイン画面からやり直してもらう
+func (t *TwoFactorController) restartLogin(c echo.Context, sess *sessions.Session, msg string) error {
+	for _, k := range []string{sessionKeyTwoFactorAccountID, sessionKeyTwoFactorStartedAt, sessionKeyTwoFactorNext, sessionKeyTwoFactorFailures} {
+		delete(sess.Values, k)
+	}
+	return t.redirectWithFlash(c, FlashWarning, msg, "/login")
+}
+
+// コードの入力画面 (GET /login/2fa)
+func (t *TwoFactorController) LoginPage(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.pending(sess); !ok {
+		return t.restartLogin(c, sess, "もう一度ログインしてください")
+	}
+	return t.renderLogin(c, http.StatusOK, map[string]string{})
+}
+
+// コードの確認 (POST /login/2fa)
+func (t *TwoFactorController) Login(c echo.Context) error {
+	if !t.IsValidAndDestroyToken(c) {
+		return t.doubleSubmitted(c, "/login/2fa")
+	}
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+	id, ok := t.pending(sess)
+	if !ok {
+		return t.restartLogin(c, sess, "時間が経ちすぎたため、もう一度ログインしてください")
+	}
+
+	form := new(model.TwoFactorCodeForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return t.renderLogin(c, http.StatusUnprocessableEntity, t.GetValidationErrors(c, err, form))
+	}
+
+	var acc repository.Account
+	var recovery bool
+	err = t.verifyCode(c, id, func() (err error) {
+		acc, recovery, err = t.svc.Verify(c.Request().Context(), id, form.Code)
+		return err
+	})
+	switch {
+	case errors.Is(err, errTwoFactorLocked):
+		return t.restartLogin(c, sess, err.Error())
+	case errors.Is(err, service.ErrTwoFactorInvalidCode):
+		// 総当たりされないよう、間違えられる回数に上限を付ける
+		failures, _ := sess.Values[sessionKeyTwoFactorFailures].(int)
+		failures++
+		if failures >= maxTwoFactorFailures {
+			return t.restartLogin(c, sess, "確認コードを続けて間違えたため、もう一度ログインしてください")
+		}
+		sess.Values[sessionKeyTwoFactorFailures] = failures
+		if err := sess.Save(c.Request(), c.Response()); err != nil {
+			return err
+		}
+		vErrors, status := fieldErrors(err)
+		return t.renderLogin(c, status, vErrors)
+	case errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrNotFound):
+		// 待っている間に2要素認証が無効にされた・アカウントが消された
+		return t.restartLogin(c, sess, "もう一度ログインしてください")
+	case err != nil:
+		return err
+	}
+
+	next, _ := sess.Values[sessionKeyTwoFactorNext].(string)
+	if err := logIn(c, acc); err != nil {
+		return err
+	}
+	if recovery {
+		return t.redirectWithFlash(c, FlashWarning, "リカバリーコードでログインしました。使ったコードはもう使えません。残りの数は2要素認証の設定画面で確認できます", safeNext(next))
+	}
+	return c.Redirect(http.StatusSeeOther, safeNext(next))
+}
+
+func (t *TwoFactorController) renderLogin(c echo.Context, status int, vErrors map[string]string) error {
+	return c.Render(status, "auth/two_factor", map[string]interface{}{
+		"Errors": vErrors,
+	})
+}
+
+// 設定画面 (GET /account/2fa)
+func (t *TwoFactorController) Show(c echo.Context) error {
+	return t.renderSettings(c, http.StatusOK, map[string]string{})
+}
+
+// 設定を始める (POST /account/2fa/setup)。秘密鍵を作り、設定画面に QR コードを出す
+func (t *TwoFactorController) Setup(c echo.Context) error {
+	if !t.IsValidAndDestroyToken(c) {
+		return t.doubleSubmitted(c, "/account/2fa")
+	}
+	acc, _ := CurrentAccount(c)
+	_, err := t.svc.BeginEnrollment(c.Request().Context(), acc.ID)
+	switch {
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		return t.redirectWithFlash(c, FlashWarning, err.Error(), "/account/2fa")
+	case err != nil:
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, "/account/2fa")
+}
+
+// QR コードの画像 (GET /account/2fa/qr.png)
+// 秘密鍵が入っているので、外部のサービスでは作らずキャッシュもさせない
+func (t *TwoFactorController) QRCode(c echo.Context) error {
+	acc, _ := CurrentAccount(c)
+	enrollment, err := t.svc.Enrollment(c.Request().Context(), acc.ID)
+	switch {
+	case errors.Is(err, service.ErrTwoFactorNotEnrolling):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case err != nil:
+		return err
+	}
+	png, err := qrcode.PNG(enrollment.URI, 4)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Blob(http.StatusOK, "image/png", png)
+}
+
+// 有効にする (POST /account/2fa/enable)。リカバリーコードはこの画面で1回だけ見せる
+func (t *TwoFactorController) Enable(c echo.Context) error {
+	if !t.IsValidAndDestroyToken(c) {
+		return t.doubleSubmitted(c, "/account/2fa")
+	}
+	form := new(model.TwoFactorCodeForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return t.renderSettings(c, http.StatusUnprocessableEntity, t.GetValidationErrors(c, err, form))
+	}
+
+	acc, _ := CurrentAccount(c)
+	codes, err := t.svc.Enable(c.Request().Context(), acc.ID, form.Code)
+	switch {
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return t.renderSettings(c, status, vErrors)
+	case errors.Is(err, service.ErrTwoFactorNotEnrolling), errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		return t.redirectWithFlash(c, FlashWarning, err.Error(), "/account/2fa")
+	case err != nil:
+		return err
+	}
+	return t.renderRecoveryCodes(c, "2要素認証を有効にしました", codes)
+}
+
+// 無効にする (POST /account/2fa/disable)
+func (t *TwoFactorController) Disable(c echo.Context) error {
+	if !t.IsValidAndDestroyToken(c) {
+		return t.doubleSubmitted(c, "/account/2fa")
+	}
+	form := new(model.TwoFactorCodeForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return t.renderSettings(c, http.StatusUnprocessableEntity, t.GetValidationErrors(c, err, form))
+	}
+
+	acc, _ := CurrentAccount(c)
+	err := t.verifyCode(c, acc.ID, func() error {
+		return t.svc.Disable(c.Request().Context(), acc.ID, form.Code)
+	})
+	switch {
+	case errors.Is(err, errTwoFactorLocked):
+		return t.redirectWithFlash(c, FlashError, err.Error(), "/account/2fa")
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return t.renderSettings(c, status, vErrors)
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		return t.redirectWithFlash(c, FlashWarning, err.Error(), "/account/2fa")
+	case err != nil:
+		return err
+	}
+	if t.svc.Required(model.Role(acc.Role)) {
+		return t.redirectWithFlash(c, FlashWarning, "2要素認証を無効にしました。この役割では必須のため、設定し直すまで管理画面は使えません", "/account/2fa")
+	}
+	return t.redirectWithFlash(c, FlashSuccess, "2要素認証を無効にしました", "/account/2fa")
+}
+
+// リカバリーコードを作り直す (POST /account/2fa/recovery-codes)
+func (t *TwoFactorController) RegenerateRecoveryCodes(c echo.Context) error {
+	if !t.IsValidAndDestroyToken(c) {
+		return t.doubleSubmitted(c, "/account/2fa")
+	}
+	form := new(model.TwoFactorCodeForm)
+	if err := c.Bind(form); err != nil {
+		return err
+	}
+	if err := c.Validate(form); err != nil {
+		return t.renderSettings(c, http.StatusUnprocessableEntity, t.GetValidationErrors(c, err, form))
+	}
+
+	acc, _ := CurrentAccount(c)
+	var codes []string
+	err := t.verifyCode(c, acc.ID, func() (err error) {
+		codes, err = t.svc.RegenerateRecoveryCodes(c.Request().Context(), acc.ID, form.Code)
+		return err
+	})
+	switch {
+	case errors.Is(err, errTwoFactorLocked):
+		return t.redirectWithFlash(c, FlashError, err.Error(), "/account/2fa")
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return t.renderSettings(c, status, vErrors)
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		return t.redirectWithFlash(c, FlashWarning, err.Error(), "/account/2fa")
+	case err != nil:
+		return err
+	}
+	return t.renderRecoveryCodes(c, "リカバリーコードを作り直しました。前のコードはもう使えません", codes)
+}
+
+func (t *TwoFactorController) renderSettings(c echo.Context, status int, vErrors map[string]string) error {
+	acc, _ := CurrentAccount(c)
+	ctx := c.Request().Context()
+	st, err := t.svc.Status(ctx, acc.ID)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{
+		"Status": st,
+		"Errors": vErrors,
+	}
+	// 設定の途中なら、QR コードを読めない時のために秘密鍵の文字列も出す
+	if st.Enrolling {
+		enrollment, err := t.svc.Enrollment(ctx, acc.ID)
+		if err != nil {
+			return err
+		}
+		data["Secret"] = enrollment.Secret
+	}
+	return c.Render(status, "account/two_factor", data)
+}
+
+// renderRecoveryCodes はリカバリーコードを見せる（保存していないので、この画面を閉じると二度と見られない）
+func (t *TwoFactorController) renderRecoveryCodes(c echo.Context, msg string, codes []string) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Render(http.StatusOK, "account/recovery_codes", map[string]interface{}{
+		"Message": msg,
+		"Codes":   codes,
+	})
+}
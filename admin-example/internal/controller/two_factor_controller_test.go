@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/ratelimit"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// TwoFactorService の偽物。コードは "123456"（認証アプリ）と "abcde-fghjk"（リカバリーコード）だけ通す
+// admin だけ2要素認証が必須
+type mockTwoFactorService struct {
+	auth      *mockAuthService
+	enrolling bool
+}
+
+func (m *mockTwoFactorService) Required(role model.Role) bool { return role == model.RoleAdmin }
+
+func (m *mockTwoFactorService) Status(ctx context.Context, accountID uint64) (service.TwoFactorStatus, error) {
+	acc := m.auth.accounts[accountID]
+	return service.TwoFactorStatus{Enabled: acc.TotpEnabledAt.Valid, Enrolling: m.enrolling, Required: m.Required(model.Role(acc.Role))}, nil
+}
+
+func (m *mockTwoFactorService) BeginEnrollment(ctx context.Context, accountID uint64) (service.Enrollment, error) {
+	m.enrolling = true
+	return m.Enrollment(ctx, accountID)
+}
+
+func (m *mockTwoFactorService) Enrollment(ctx context.Context, accountID uint64) (service.Enrollment, error) {
+	if !m.enrolling {
+		return service.Enrollment{}, service.ErrTwoFactorNotEnrolling
+	}
+	return service.Enrollment{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/Admin:admin@example.com?secret=JBSWY3DPEHPK3PXP"}, nil
+}
+
+func (m *mockTwoFactorService) Enable(ctx context.Context, accountID uint64, code string) ([]string, error) {
+	if code != "123456" {
+		return nil, service.ErrTwoFactorInvalidCode
+	}
+	return []string{"abcde-fghjk"}, nil
+}
+
+func (m *mockTwoFactorService) Disable(ctx context.Context, accountID uint64, code string) error {
+	return nil
+}
+
+func (m *mockTwoFactorService) RegenerateRecoveryCodes(ctx context.Context, accountID uint64, code string) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockTwoFactorService) Verify(ctx context.Context, accountID uint64, code string) (repository.Account, bool, error) {
+	acc, ok := m.auth.accounts[accountID]
+	if !ok {
+		return repository.Account{}, false, service.ErrNotFound
+	}
+	switch code {
+	case "123456":
+		return acc, false, nil
+	case "abcde-fghjk":
+		return acc, true, nil
+	}
+	return repository.Account{}, false, service.ErrTwoFactorInvalidCode
+}
+
+// newTwoFactorEcho は main.go と同じ構成（ログイン → 2段階目、必須の役割の確認）を組み立てる
+// admin@example.com は2要素認証を有効にしている
+func newTwoFactorEcho() (*echo.Echo, *TwoFactorController, *mockAuthService) {
+	authSvc := newMockAuthService()
+	admin := authSvc.accounts[1]
+	admin.TotpEnabledAt = sql.NullTime{Time: time.Now(), Valid: true}
+	authSvc.accounts[1] = admin
+	auth := NewAuthController(authSvc)
+	svc := &mockTwoFactorService{auth: authSvc}
+	// アカウントごとに、1時間に7回まで間違えられる
+	ctrl := NewTwoFactorController(svc, ratelimit.New(7, time.Hour))
+
+	e := newInviteEcho()
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte("test-secret"))))
+	// フォームの送信用トークンはテストでは毎回発行して付ける
+	withToken := func(h echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			setFormToken(c, ctrl.IssueToken(c))
+			return h(c)
+		}
+	}
+	e.POST("/login", withToken(auth.Login))
+	e.GET("/login/2fa", ctrl.LoginPage)
+	e.POST("/login/2fa", withToken(ctrl.Login))
+
+	ok := func(c echo.Context) error { return c.String(http.StatusOK, "ok") }
+	account := e.Group("/account", auth.RequireLogin, Authorize(RoutePermissions))
+	account.GET("/2fa", ctrl.Show)
+	account.GET("/2fa/qr.png", ctrl.QRCode)
+	account.POST("/2fa/setup", withToken(ctrl.Setup))
+	account.POST("/2fa/enable", withToken(ctrl.Enable))
+	group := e.Group("", auth.RequireLogin, Authorize(RoutePermissions), RequireTwoFactor(svc))
+	group.GET("/users", ok)
+	group.GET("/api/v1/users", ok)
+	return e, ctrl, authSvc
+}
+
+// send は browser (csrf_test.go) でフォームを送る。form が nil なら本文なし
+func send(b *browser, method, path string, form url.Values) *httptest.ResponseRecorder {
+	if form == nil {
+		return b.do(httptest.NewRequest(method, path, nil))
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	return b.do(req)
+}
+
+// loginWithPassword はパスワードまで入れて、2段階目の画面に来たところまで進める
+func loginWithPassword(t *testing.T, e *echo.Echo) *browser {
+	t.Helper()
+	b := newBrowser(e)
+	rec := send(b, http.MethodPost, "/login", url.Values{
+		"email": {"admin@example.com"}, "password": {"correct-horse"}, "next": {"/users?page=2"},
+	})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login/2fa", rec.Header().Get(echo.HeaderLocation))
+	return b
+}
+
+func TestTwoFactorController_Login(t *testing.T) {
+	e, _, _ := newTwoFactorEcho()
+	b := loginWithPassword(t, e)
+
+	// コードを入れるまではログインしていない
+	assert.Equal(t, http.StatusSeeOther, send(b, http.MethodGet, "/users", nil).Code)
+	assert.Equal(t, http.StatusOK, send(b, http.MethodGet, "/login/2fa", nil).Code)
+
+	rec := send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"000000"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "code:")
+
+	rec = send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"123456"}})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users?page=2", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, http.StatusOK, send(b, http.MethodGet, "/users", nil).Code)
+}
+
+func TestTwoFactorController_Login_RecoveryCode(t *testing.T) {
+	e, _, _ := newTwoFactorEcho()
+	b := loginWithPassword(t, e)
+
+	rec := send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"abcde-fghjk"}})
+
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, http.StatusOK, send(b, http.MethodGet, "/users", nil).Code)
+}
+
+func TestTwoFactorController_Login_TooManyFailures(t *testing.T) {
+	e, _, _ := newTwoFactorEcho()
+	b := loginWithPassword(t, e)
+
+	for i := 1; i < maxTwoFactorFailures; i++ {
+		assert.Equal(t, http.StatusUnprocessableEntity, send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"000000"}}).Code)
+	}
+	// 上限まで間違えたら、パスワードから入れ直す（正しいコードでも通らない）
+	rec := send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"000000"}})
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	rec = send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"123456"}})
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, http.StatusSeeOther, send(b, http.MethodGet, "/users", nil).Code)
+}
+
+func TestTwoFactorController_Login_LockedPerAccount(t *testing.T) {
+	e, _, _ := newTwoFactorEcho()
+	wrong := url.Values{"code": {"000000"}}
+
+	// ログインし直して間違えた回数を数え直させても、アカウントごとの回数は続けて数える
+	b := loginWithPassword(t, e)
+	for i := 0; i < 4; i++ {
+		send(b, http.MethodPost, "/login/2fa", wrong)
+	}
+	b = loginWithPassword(t, e)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnprocessableEntity, send(b, http.MethodPost, "/login/2fa", wrong).Code)
+	}
+
+	// 上限に達したら、正しいコードでも受け付けない（別のブラウザから入れ直しても同じ）
+	rec := send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"123456"}})
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	b = loginWithPassword(t, e)
+	rec = send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"123456"}})
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, http.StatusSeeOther, send(b, http.MethodGet, "/users", nil).Code)
+}
+
+func TestTwoFactorController_Login_Expired(t *testing.T) {
+	e, ctrl, _ := newTwoFactorEcho()
+	b := loginWithPassword(t, e)
+
+	ctrl.now = func() time.Time { return time.Now().Add(twoFactorPendingTTL + time.Minute) }
+	rec := send(b, http.MethodPost, "/login/2fa", url.Values{"code": {"123456"}})
+
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestRequireTwoFactor(t *testing.T) {
+	e, _, authSvc := newTwoFactorEcho()
+	// admin は必須なのにまだ設定していない
+	admin := authSvc.accounts[1]
+	admin.TotpEnabledAt = sql.NullTime{}
+	authSvc.accounts[1] = admin
+
+	b := newBrowser(e)
+	assert.Equal(t, http.StatusSeeOther, send(b, http.MethodPost, "/login", url.Values{"email": {"admin@example.com"}, "password": {"correct-horse"}}).Code)
+
+	rec := send(b, http.MethodGet, "/users", nil)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/account/2fa", rec.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, http.StatusForbidden, send(b, http.MethodGet, "/api/v1/users", nil).Code)
+	// 設定画面は開ける
+	assert.Equal(t, http.StatusOK, send(b, http.MethodGet, "/account/2fa", nil).Code)
+
+	// 必須でない役割はそのまま使える
+	editor := newBrowser(e)
+	send(editor, http.MethodPost, "/login", url.Values{"email": {"editor@example.com"}, "password": {"correct-horse"}})
+	assert.Equal(t, http.StatusOK, send(editor, http.MethodGet, "/users", nil).Code)
+}
+
+func TestTwoFactorController_SetupAndQRCode(t *testing.T) {
+	e, _, _ := newTwoFactorEcho()
+	b := newBrowser(e)
+	send(b, http.MethodPost, "/login", url.Values{"email": {"editor@example.com"}, "password": {"correct-horse"}})
+
+	// 設定を始めるまで QR コードは無い
+	assert.Equal(t, http.StatusNotFound, send(b, http.MethodGet, "/account/2fa/qr.png", nil).Code)
+
+	rec := send(b, http.MethodPost, "/account/2fa/setup", url.Values{})
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+	rec = send(b, http.MethodGet, "/account/2fa/qr.png", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "\x89PNG"))
+
+	rec = send(b, http.MethodPost, "/account/2fa/enable", url.Values{"code": {"000000"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "code:")
+
+	rec = send(b, http.MethodPost, "/account/2fa/enable", url.Values{"code": {"123456"}})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+}
@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"errors"
+	"go-example/admin-example/internal/avatar"
 	"go-example/admin-example/internal/model"
 	"go-example/admin-example/internal/service"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -13,23 +16,37 @@ import (
 type UserController struct {
 	BaseController // ★これを書くだけで Base の機能がすべて使える
 	svc            service.UserService
+	avatars        *avatar.Store // アップロードされたアバター画像の保存先
 }
 
-func NewUserController(s service.UserService) *UserController {
-	return &UserController{svc: s}
+func NewUserController(s service.UserService, avatars *avatar.Store) *UserController {
+	return &UserController{svc: s, avatars: avatars}
 }
 
-// 一覧表示 (GET /users)
+// 一覧表示 (GET /users?page=&per_page=&sort=&q=)
 func (c *UserController) Index(ctx echo.Context) error {
-	users, err := c.svc.GetList(ctx.Request().Context())
+	q := new(model.UserListQuery)
+	if err := ctx.Bind(q); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "検索条件が正しくありません")
+	}
+	q.Normalize()
+
+	users, total, err := c.svc.Search(ctx.Request().Context(), *q)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		// 中身は画面に出さず、HTTPErrorHandler がログと相関IDで扱う
+		return err
 	}
+	// Service と同じく、範囲外のページは最後のページとして表示する
+	q.ClampPage(total)
 
 	// 2. テンプレートに渡すデータを map で定義
 	// テンプレート側の {{range .Users}} とキー名を合わせるのがポイント
 	data := map[string]interface{}{
 		"Users": users,
+		"Query": q,
+		"Pager": model.NewPager("/users", q.Page, q.PerPage, total, q.Params()),
+		// 状態で絞り込む選択肢
+		"Statuses": model.UserStatuses,
 	}
 
 	// 3. レンダリング
@@ -52,15 +69,12 @@ func (c *UserController) Update(ctx echo.Context) error {
 // New: 登録画面を表示するだけ
 func (u *UserController) New(c echo.Context) error {
 	// 新規画面表示時にトークンを発行
-	return c.Render(http.StatusOK, "users/new", map[string]interface{}{
-		"csrf":   u.IssueToken(c),
-		"Errors": map[string]string{},
-	})
+	return u.renderNew(c, http.StatusOK, map[string]string{}, &model.UserCreateForm{})
 }
 func (u *UserController) Create(c echo.Context) error {
 	// 1. 二重送信チェック (バックボタン対策)
 	if !u.IsValidAndDestroyToken(c) {
-		return c.String(http.StatusBadRequest, "二重送信エラーです。前の画面に戻ってやり直してください。")
+		return u.doubleSubmitted(c, "/users")
 	}
 
 	form := new(model.UserCreateForm)
@@ -70,35 +84,61 @@ func (u *UserController) Create(c echo.Context) error {
 
 	if err := c.Validate(form); err != nil {
 		// GetValidationErrors(複数版) を呼んでそのまま renderNew に渡す
-		return u.renderNew(c, u.GetValidationErrors(err, form), form.Name)
+		return u.renderNew(c, http.StatusUnprocessableEntity, u.GetValidationErrors(c, err, form), form)
+	}
+
+	// 画像は入力チェックが通ってから保存する
+	key, err := u.saveAvatar(c)
+	if err != nil {
+		if isAvatarError(err) {
+			return u.renderNew(c, http.StatusUnprocessableEntity, map[string]string{"avatar": err.Error()}, form)
+		}
+		return err
 	}
 
-	if err := u.svc.Register(c.Request().Context(), form.Name); err != nil {
-		return u.renderNew(c, map[string]string{"Main": "保存に失敗しました"}, form.Name)
+	in := createInput(form)
+	in.Avatar = key
+	if _, err := u.svc.Register(c.Request().Context(), in); err != nil {
+		// 登録できなかったので、保存した画像は要らない
+		u.removeAvatar(c, key)
+		// 名前・メールアドレスの重複 (409) などは、入力した値を残したまま項目の下にメッセージを出す
+		if isFieldError(err) {
+			vErrors, status := fieldErrors(err)
+			return u.renderNew(c, status, vErrors, form)
+		}
+		return err
 	}
 
-	return c.Redirect(http.StatusSeeOther, "/users")
+	return u.redirectWithFlash(c, FlashSuccess, "ユーザー「"+form.Name+"」を登録しました", "/users")
 }
 
 func (u *UserController) Edit(c echo.Context) error {
-	id, _ := strconv.ParseUint(c.Param("id"), 10, 64)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
 	user, err := u.svc.FindByID(c.Request().Context(), id)
 	if err != nil {
-		return c.Redirect(http.StatusSeeOther, "/users")
+		// 見つからない (service.ErrNotFound) なら 404、それ以外は 500 (HTTPErrorHandler)
+		return err
 	}
 
-	return c.Render(http.StatusOK, "users/edit", map[string]interface{}{
-		"ID":     user.ID,
-		"Name":   user.Name.String,
-		"csrf":   u.IssueToken(c), // 編集画面表示時もトークン発行
-		"Errors": map[string]string{},
-	})
+	form := &model.UserUpdateForm{
+		ID:          user.ID,
+		Name:        user.Name.String,
+		Email:       user.Email.String,
+		DisplayName: user.DisplayName,
+		Status:      user.Status,
+		Role:        user.Role,
+		Version:     user.Version, // 保存時に「開いた時から変わっていないか」を確認する
+	}
+	return u.renderEdit(c, http.StatusOK, form, map[string]string{})
 }
 
 func (u *UserController) Update(c echo.Context) error {
 	// 1. 二重送信チェック
 	if !u.IsValidAndDestroyToken(c) {
-		return c.String(http.StatusBadRequest, "二重送信エラーです。")
+		return u.doubleSubmitted(c, "/users")
 	}
 
 	form := new(model.UserUpdateForm)
@@ -108,32 +148,254 @@ func (u *UserController) Update(c echo.Context) error {
 
 	if err := c.Validate(form); err != nil {
 		// 共通バリデーションメッセージ関数を呼び出し
-		return u.renderEdit(c, form.ID, u.GetValidationErrors(err, form), form.Name)
+		return u.renderEdit(c, http.StatusUnprocessableEntity, form, u.GetValidationErrors(c, err, form))
+	}
+
+	key, err := u.saveAvatar(c)
+	if err != nil {
+		if isAvatarError(err) {
+			return u.renderEdit(c, http.StatusUnprocessableEntity, form, map[string]string{"avatar": err.Error()})
+		}
+		return err
+	}
+
+	in := updateInput(form)
+	in.Avatar = key
+	replaced, err := u.svc.UpdateProfile(c.Request().Context(), form.ID, in, form.Version)
+	if err != nil {
+		u.removeAvatar(c, key)
+	}
+	switch {
+	case errors.Is(err, service.ErrConflict):
+		// 他の人が先に保存していた。上書きせず、今の値を見せて選んでもらう
+		return u.renderConflict(c, form)
+	case isFieldError(err):
+		vErrors, status := fieldErrors(err)
+		return u.renderEdit(c, status, form, vErrors)
+	case err != nil:
+		// 編集中に削除された (service.ErrNotFound → 404) か、想定外のエラー (500)
+		return err
+	}
+
+	// 保存できたので、差し替える前の画像を消す
+	u.removeAvatar(c, replaced)
+	return u.redirectWithFlash(c, FlashSuccess, "ユーザー「"+form.Name+"」を更新しました", "/users")
+}
+
+// アバター画像 (GET /users/:id/avatar?size=thumb)
+// 画像が無いユーザーは 404。画面側で頭文字などを代わりに出す
+func (u *UserController) Avatar(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "画像が見つかりません")
+	}
+	user, err := u.svc.FindByID(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	path, err := u.avatars.Path(user.AvatarPath, c.QueryParam("size") == "thumb")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "画像が見つかりません")
+	}
+
+	// 差し替えると key (ファイル名) が変わるが、URL は同じなので長くはキャッシュさせない
+	c.Response().Header().Set(echo.HeaderCacheControl, "private, max-age=60")
+	return c.File(path)
+}
+
+// 削除 (DELETE /users/:id)
+// htmx の hx-swap="outerHTML" で行ごと差し替えるため、成功時は空のHTMLを 200 で返す
+// (204 だと htmx はスワップしないので注意)
+func (u *UserController) Delete(c echo.Context) error {
+	// 1. トークンチェック (一覧画面から何度も押されるので破棄はしない)
+	if !u.IsValidToken(c) {
+		return ErrInvalidCSRFToken
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+	}
+
+	if err := u.svc.DeleteUser(c.Request().Context(), id); err != nil {
+		// すでに削除済み、または存在しないID
+		if errors.Is(err, service.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "削除に失敗しました").SetInternal(err)
+	}
+
+	return c.HTML(http.StatusOK, "")
+}
+
+// ゴミ箱一覧 (GET /users/trash?page=&per_page=)
+func (u *UserController) Trash(c echo.Context) error {
+	return u.renderTrash(c, http.StatusOK, "")
+}
+
+// 復元 (POST /users/:id/restore)
+func (u *UserController) Restore(c echo.Context) error {
+	if !u.IsValidAndDestroyToken(c) {
+		return u.doubleSubmitted(c, "/users/trash")
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
 	}
 
-	if err := u.svc.UpdateName(c.Request().Context(), form.ID, form.Name); err != nil {
-		return u.renderEdit(c, form.ID, map[string]string{"Main": "保存に失敗しました"}, form.Name)
+	if err := u.svc.RestoreUser(c.Request().Context(), id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrDuplicateName):
+			// 削除後に同じ名前で登録し直されているケース
+			return u.renderTrash(c, http.StatusConflict, "同じ名前の有効なユーザーがいるため復元できません。先にそちらの名前を変更してください。")
+		case errors.Is(err, service.ErrDuplicateEmail):
+			return u.renderTrash(c, http.StatusConflict, "同じメールアドレスの有効なユーザーがいるため復元できません。先にそちらのメールアドレスを変更してください。")
+		case errors.Is(err, service.ErrNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "ユーザーが見つかりません")
+		default:
+			return err
+		}
 	}
 
-	return c.Redirect(http.StatusSeeOther, "/users")
+	return u.redirectWithFlash(c, FlashSuccess, "ユーザーを復元しました", "/users/trash")
 }
 
 // --- ヘルパーメソッド (エラー時に新しいトークンを付けて再表示) ---
 
+// createInput / updateInput はフォームの値を Service に渡す形にする（画面と API で共通）
+func createInput(form *model.UserCreateForm) service.UserInput {
+	return service.UserInput{
+		Name:        form.Name,
+		Email:       form.Email,
+		DisplayName: form.DisplayName,
+		Status:      model.UserStatus(form.Status),
+		Role:        model.Role(form.Role),
+	}
+}
+
+func updateInput(form *model.UserUpdateForm) service.UserInput {
+	return service.UserInput{
+		Name:         form.Name,
+		Email:        form.Email,
+		DisplayName:  form.DisplayName,
+		Status:       model.UserStatus(form.Status),
+		Role:         model.Role(form.Role),
+		RemoveAvatar: form.RemoveAvatar,
+	}
+}
+
+// saveAvatar はフォームの "avatar" の画像を保存して key を返す。画像が送られていなければ ""
+func (u *UserController) saveAvatar(c echo.Context) (string, error) {
+	fh, err := c.FormFile("avatar")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if fh.Size > avatar.MaxFileSize {
+		return "", avatar.ErrTooLarge
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return u.avatars.Save(f)
+}
+
+// removeAvatar は使わなくなった画像を消す。消せなくても画面の操作は成功にする（ログだけ残す）
+func (u *UserController) removeAvatar(c echo.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := u.avatars.Remove(key); err != nil {
+		slog.WarnContext(c.Request().Context(), "アバター画像を削除できませんでした", "key", key, "error", err.Error())
+	}
+}
+
+// isAvatarError はフォームの画像の項目に出せるエラー（形式が違う・大きすぎる）かを返す
+func isAvatarError(err error) bool {
+	return errors.Is(err, avatar.ErrUnsupported) || errors.Is(err, avatar.ErrTooLarge)
+}
+
 // msg (string) ではなく vErrors (map) を受け取るように変更
-func (u *UserController) renderNew(c echo.Context, vErrors map[string]string, name string) error {
-	return c.Render(http.StatusOK, "users/new", map[string]interface{}{
-		"Errors": vErrors, // mapごとテンプレートへ
-		"Name":   name,
-		"csrf":   u.IssueToken(c),
+// status は入力エラーなら 422、名前・メールアドレスの重複なら 409
+func (u *UserController) renderNew(c echo.Context, status int, vErrors map[string]string, form *model.UserCreateForm) error {
+	return c.Render(status, "users/new", map[string]interface{}{
+		"Errors":      vErrors, // mapごとテンプレートへ
+		"Name":        form.Name,
+		"Email":       form.Email,
+		"DisplayName": form.DisplayName,
+		"Status":      form.Status,
+		"Role":        form.Role,
+		"Statuses":    model.UserStatuses, // 選択肢
+		"Roles":       model.Roles,
 	})
 }
 
-func (u *UserController) renderEdit(c echo.Context, id uint64, vErrors map[string]string, name string) error {
-	return c.Render(http.StatusOK, "users/edit", map[string]interface{}{
-		"ID":     id,
-		"Errors": vErrors, // mapごとテンプレートへ
-		"Name":   name,
-		"csrf":   u.IssueToken(c), // 再表示のたびにトークンを更新
+func (u *UserController) renderEdit(c echo.Context, status int, form *model.UserUpdateForm, vErrors map[string]string) error {
+	return c.Render(status, "users/edit", u.editData(c, form, vErrors))
+}
+
+// editData は編集画面に渡すデータ。今の画像は保存されている値を出す（入力では変わらないため）
+func (u *UserController) editData(c echo.Context, form *model.UserUpdateForm, vErrors map[string]string) map[string]interface{} {
+	data := map[string]interface{}{
+		"ID":          form.ID,
+		"Errors":      vErrors, // mapごとテンプレートへ
+		"Name":        form.Name,
+		"Email":       form.Email,
+		"DisplayName": form.DisplayName,
+		"Status":      form.Status,
+		"Role":        form.Role,
+		"Version":     form.Version,
+		"Statuses":    model.UserStatuses,
+		"Roles":       model.Roles,
+		"HasAvatar":   false,
+	}
+	if current, err := u.svc.FindByID(c.Request().Context(), form.ID); err == nil {
+		data["HasAvatar"] = current.AvatarPath != ""
+	}
+	return data
+}
+
+// renderConflict は「他の人が先に更新した」ことを、今の値と一緒に 409 で表示する。
+// 入力した値はそのまま残し、version は今のものにするので、もう一度保存すれば上書きできる
+func (u *UserController) renderConflict(c echo.Context, form *model.UserUpdateForm) error {
+	current, err := u.svc.FindByID(c.Request().Context(), form.ID)
+	if err != nil {
+		// 競合の原因が削除だった
+		return u.renderEdit(c, http.StatusNotFound, form, map[string]string{"Main": "このユーザーは他の人が削除しました"})
+	}
+
+	data := u.editData(c, form, map[string]string{"Main": "他の人が先にこのユーザーを更新しました。現在の値を確認してから、もう一度保存してください"})
+	data["Version"] = current.Version
+	data["Current"] = current
+	return c.Render(http.StatusConflict, "users/edit", data)
+}
+
+// renderTrash はゴミ箱を1ページ分表示する (?page=&per_page=)
+func (u *UserController) renderTrash(c echo.Context, status int, errMsg string) error {
+	q := new(model.UserListQuery)
+	if err := c.Bind(q); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "検索条件が正しくありません")
+	}
+	// ゴミ箱には検索・並び替えが無いので、ページ番号と件数だけ使う
+	*q = model.UserListQuery{Page: q.Page, PerPage: q.PerPage}
+	q.Normalize()
+
+	users, total, err := u.svc.GetTrash(c.Request().Context(), *q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "ゴミ箱の取得に失敗しました").SetInternal(err)
+	}
+	// Service と同じく、範囲外のページは最後のページとして表示する
+	q.ClampPage(total)
+
+	return c.Render(status, "users/trash", map[string]interface{}{
+		"Users": users,
+		"Pager": model.NewPager("/users/trash", q.Page, q.PerPage, total, q.Params()),
+		"Error": errMsg,
 	})
 }
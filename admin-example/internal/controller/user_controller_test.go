@@ -1,16 +1,29 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"go-example/admin-example/internal/avatar"
+	"go-example/admin-example/internal/model"
 	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/validation"
+	"image"
+	"image/png"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // 1. Serviceの偽物（Mock）を定義
@@ -23,10 +36,34 @@ func (m *mockUserService) GetList(ctx context.Context) ([]repository.User, error
 	}, nil
 }
 
+func (m *mockUserService) Search(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	users, err := m.GetList(ctx)
+	return users, int64(len(users)), err
+}
+
 // 他のメソッドもインターフェースを満たすために定義
-func (m *mockUserService) Register(ctx context.Context, name string) error              { return nil }
-func (m *mockUserService) UpdateName(ctx context.Context, id uint64, name string) error { return nil }
-func (m *mockUserService) DeleteUser(ctx context.Context, id uint64) error              { return nil }
+func (m *mockUserService) Register(ctx context.Context, in service.UserInput) (uint64, error) {
+	return 1, nil
+}
+func (m *mockUserService) UpdateProfile(ctx context.Context, id uint64, in service.UserInput, version uint32) (string, error) {
+	return "", nil
+}
+func (m *mockUserService) DeleteUser(ctx context.Context, id uint64) error { return nil }
+func (m *mockUserService) FindByID(ctx context.Context, id uint64) (repository.User, error) {
+	return repository.User{ID: id, Name: sql.NullString{String: "コントローラーテスト", Valid: true}, Version: 1}, nil
+}
+func (m *mockUserService) GetTrash(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	return []repository.User{
+		{ID: 2, Name: sql.NullString{String: "削除済みユーザー", Valid: true}},
+	}, 1, nil
+}
+func (m *mockUserService) RestoreUser(ctx context.Context, id uint64) error { return nil }
+func (m *mockUserService) CheckImport(ctx context.Context, rows []service.ImportRow) ([]service.ImportProblem, error) {
+	return nil, nil
+}
+func (m *mockUserService) ImportUsers(ctx context.Context, rows []service.ImportRow) (int, error) {
+	return len(rows), nil
+}
 
 // 2. Rendererの偽物（Mock） ★ここがポイント
 type mockRenderer struct{}
@@ -38,6 +75,20 @@ func (m *mockRenderer) Render(w io.Writer, name string, data interface{}, c echo
 		if users, ok := d["Users"].([]repository.User); ok && len(users) > 0 {
 			w.Write([]byte(users[0].Name.String))
 		}
+		if msg, ok := d["Error"].(string); ok {
+			w.Write([]byte(msg))
+		}
+		if errs, ok := d["Errors"].(map[string]string); ok {
+			w.Write([]byte(errs["Main"]))
+			for _, field := range []string{"name", "email", "avatar"} {
+				if msg := errs[field]; msg != "" {
+					w.Write([]byte(field + ":" + msg))
+				}
+			}
+		}
+		if current, ok := d["Current"].(repository.User); ok {
+			w.Write([]byte(current.Name.String))
+		}
 	}
 	return nil
 }
@@ -60,7 +111,7 @@ func TestUserController_Index(t *testing.T) {
 
 	// 3. DI：MockServiceを注入してControllerを作成
 	mockSvc := &mockUserService{}
-	ctrl := NewUserController(mockSvc)
+	ctrl := NewUserController(mockSvc, nil)
 
 	// 4. 実行
 	// もしRendererの設定が難しい場合は、Index内で呼び出している
@@ -84,6 +135,76 @@ func (m *errorUserService) GetList(ctx context.Context) ([]repository.User, erro
 	return nil, sql.ErrConnDone // わざと「DB接続切れ」エラーを返す
 }
 
+func (m *errorUserService) Search(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	return nil, 0, sql.ErrConnDone
+}
+
+// 検索条件を受け取ったか確認するための偽物
+type recordingUserService struct {
+	mockUserService
+	query model.UserListQuery
+}
+
+func (m *recordingUserService) Search(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	m.query = q
+	return m.mockUserService.Search(ctx, q)
+}
+
+func (m *recordingUserService) GetTrash(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	m.query = q
+	return m.mockUserService.GetTrash(ctx, q)
+}
+
+// pagerRenderer は一覧に渡されたページャーを覚えておき、テンプレートと同じようにページ番号を作る
+type pagerRenderer struct {
+	pager model.Pager
+	pages []int
+}
+
+func (r *pagerRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	r.pager = data.(map[string]interface{})["Pager"].(model.Pager)
+	r.pages = r.pager.Pages()
+	return nil
+}
+
+func TestUserController_Index_PageOutOfRange(t *testing.T) {
+	e := echo.New()
+	renderer := &pagerRenderer{}
+	e.Renderer = renderer
+	// 削除で件数が減った後の古いリンクなど、最後のページより後を開いた
+	req := httptest.NewRequest(http.MethodGet, "/users?page=1000", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := ctrl.Index(c)
+
+	// 最後のページとして表示する
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, renderer.pager.Page)
+	assert.Equal(t, []int{1}, renderer.pages)
+}
+
+func TestUserController_Index_Query(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	// sort にホワイトリスト外の値、per_page に上限超えの値を渡す
+	req := httptest.NewRequest(http.MethodGet, "/users?page=2&per_page=1000&sort=password&q=%20taro%20", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	mockSvc := &recordingUserService{}
+	ctrl := NewUserController(mockSvc, nil)
+
+	err := ctrl.Index(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, mockSvc.query.Page)
+	assert.Equal(t, model.MaxPerPage, mockSvc.query.PerPage)
+	assert.Equal(t, model.DefaultUserSort, mockSvc.query.Sort)
+	assert.Equal(t, "taro", mockSvc.query.Q)
+}
+
 func TestUserController_Index_Error(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/users", nil)
@@ -92,17 +213,442 @@ func TestUserController_Index_Error(t *testing.T) {
 
 	// 2. エラーを出すServiceを注入
 	mockSvc := &errorUserService{}
-	ctrl := NewUserController(mockSvc)
+	ctrl := NewUserController(mockSvc, nil)
 
 	// 3. 実行
 	err := ctrl.Index(c)
 
 	// 4. 検証
 	// 通常、Echoのハンドラーがエラーを返すと、err に値が入ります
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, sql.ErrConnDone)
+
+	// HTTPErrorHandler を通すと 500 になり、DBのエラーの中身は画面に出ない
+	HTTPErrorHandler(err, c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), sql.ErrConnDone.Error())
+}
+
+// 削除済み（または存在しない）ユーザーを削除しようとした場合の偽物
+type notFoundUserService struct {
+	mockUserService
+}
+
+func (m *notFoundUserService) DeleteUser(ctx context.Context, id uint64) error {
+	return service.ErrNotFound
+}
+
+func (m *notFoundUserService) FindByID(ctx context.Context, id uint64) (repository.User, error) {
+	return repository.User{}, service.ErrNotFound
+}
+
+func (m *notFoundUserService) UpdateProfile(ctx context.Context, id uint64, in service.UserInput, version uint32) (string, error) {
+	return "", service.ErrNotFound
+}
+
+// withSession はセッションミドルウェアを通してハンドラーを実行する
+// (BaseController のトークン機能はセッションが前提のため)
+func withSession(h echo.HandlerFunc) echo.HandlerFunc {
+	return session.Middleware(sessions.NewCookieStore([]byte("test-secret")))(h)
+}
+
+// newDeleteContext は DELETE /users/:id のリクエストを組み立てる
+func newDeleteContext(e *echo.Echo, id string) (echo.Context, *http.Request, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+id, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/:id")
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, req, rec
+}
+
+func TestUserController_Delete(t *testing.T) {
+	e := echo.New()
+	c, req, rec := newDeleteContext(e, "1")
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		// 一覧画面でトークンが発行され、htmx がヘッダーで送ってくる想定
+		req.Header.Set("X-CSRF-Token", ctrl.IssueToken(c))
+		return ctrl.Delete(c)
+	})(c)
+
+	// htmx が行を消せるように、空のHTMLを 200 で返すこと
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestUserController_Delete_InvalidToken(t *testing.T) {
+	e := echo.New()
+	c, req, _ := newDeleteContext(e, "1")
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		ctrl.IssueToken(c)
+		req.Header.Set("X-CSRF-Token", "wrong-token")
+		return ctrl.Delete(c)
+	})(c)
+
+	if assert.Error(t, err) {
+		he, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusForbidden, he.Code)
+	}
+}
+
+func TestUserController_Delete_NotFound(t *testing.T) {
+	e := echo.New()
+	c, req, _ := newDeleteContext(e, "99")
+	ctrl := NewUserController(&notFoundUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		req.Header.Set("X-CSRF-Token", ctrl.IssueToken(c))
+		return ctrl.Delete(c)
+	})(c)
+
+	// 削除済みのIDは 404
+	if assert.Error(t, err) {
+		he, ok := err.(*echo.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, http.StatusNotFound, he.Code)
+	}
+}
+
+func TestUserController_Trash(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	req := httptest.NewRequest(http.MethodGet, "/users/trash", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := withSession(ctrl.Trash)(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "削除済みユーザー")
+}
+
+func TestUserController_Trash_Page(t *testing.T) {
+	e := echo.New()
+	renderer := &pagerRenderer{}
+	e.Renderer = renderer
+	// ゴミ箱には検索・並び替えが無いので、q や sort は無視する
+	req := httptest.NewRequest(http.MethodGet, "/users/trash?page=1000&per_page=1000&sort=name&q=taro", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	mockSvc := &recordingUserService{}
+	ctrl := NewUserController(mockSvc, nil)
+
+	err := withSession(ctrl.Trash)(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.UserListQuery{
+		Page: 1000, PerPage: model.MaxPerPage, Sort: model.DefaultUserSort, Match: "contains",
+	}, mockSvc.query)
+	// 1件しかないので、最後のページ (1ページ目) として表示する
+	assert.Equal(t, 1, renderer.pager.Page)
+	assert.Equal(t, "/users/trash?per_page=100", renderer.pager.URL(1))
+}
+
+// 同じ名前の有効なユーザーがいて復元できない場合の偽物
+type nameTakenUserService struct {
+	mockUserService
+}
+
+func (m *nameTakenUserService) RestoreUser(ctx context.Context, id uint64) error {
+	return service.ErrDuplicateName
+}
+
+// newRestoreContext は POST /users/:id/restore のリクエストを組み立てる
+func newRestoreContext(e *echo.Echo, id string) (echo.Context, *http.Request, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/users/"+id+"/restore", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/users/:id/restore")
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, req, rec
+}
+
+func TestUserController_Restore(t *testing.T) {
+	e := echo.New()
+	c, req, rec := newRestoreContext(e, "2")
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		req.Form = map[string][]string{"csrf": {ctrl.IssueToken(c)}}
+		return ctrl.Restore(c)
+	})(c)
+
+	// 復元できたらゴミ箱に戻る
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users/trash", rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestUserController_Restore_NameTaken(t *testing.T) {
+	e := echo.New()
+	e.Renderer = &mockRenderer{}
+	c, req, rec := newRestoreContext(e, "2")
+	ctrl := NewUserController(&nameTakenUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		req.Form = map[string][]string{"csrf": {ctrl.IssueToken(c)}}
+		return ctrl.Restore(c)
+	})(c)
+
+	// 復元せずにゴミ箱をエラー付きで再表示する
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "復元できません")
+}
+
+// 他の人が先に更新していた場合の偽物（今の値は version 2）
+type conflictUserService struct {
+	mockUserService
+}
+
+func (m *conflictUserService) UpdateProfile(ctx context.Context, id uint64, in service.UserInput, version uint32) (string, error) {
+	return "", service.ErrConflict
+}
+
+func (m *conflictUserService) FindByID(ctx context.Context, id uint64) (repository.User, error) {
+	return repository.User{ID: id, Name: sql.NullString{String: "他の人が付けた名前", Valid: true}, Version: 2}, nil
+}
+
+// newUpdateContext は POST /users/update のフォーム送信を組み立てる
+func newUpdateContext(e *echo.Echo, form url.Values) (echo.Context, *http.Request, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/users/update", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), req, rec
+}
+
+// updateWithToken はトークンを発行してからフォームに入れて Update を呼ぶ
+func updateWithToken(ctrl *UserController, req *http.Request, form url.Values) echo.HandlerFunc {
+	return withSession(func(c echo.Context) error {
+		form.Set("csrf", ctrl.IssueToken(c))
+		req.PostForm = form
+		req.Form = form
+		return ctrl.Update(c)
+	})
+}
+
+func TestUserController_Update(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	form := url.Values{"id": {"5"}, "name": {"変更後の名前"}, "version": {"1"}}
+	c, req, rec := newUpdateContext(e, form)
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := updateWithToken(ctrl, req, form)(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+}
+
+func TestUserController_Update_Conflict(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &mockRenderer{}
+	form := url.Values{"id": {"5"}, "name": {"自分の名前"}, "version": {"1"}}
+	c, req, rec := newUpdateContext(e, form)
+	ctrl := NewUserController(&conflictUserService{}, nil)
+
+	err := updateWithToken(ctrl, req, form)(c)
+
+	// 上書きせずに 409 で編集画面を出し、今の値を見せる
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "他の人が先にこのユーザーを更新しました")
+	assert.Contains(t, rec.Body.String(), "他の人が付けた名前")
+}
+
+// newCreateContext は POST /users のフォーム送信を組み立てる
+func newCreateContext(e *echo.Echo, form url.Values) (echo.Context, *http.Request, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), req, rec
+}
+
+func TestUserController_Create_DuplicateName(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &mockRenderer{}
+	form := url.Values{"name": {"山田太郎"}}
+	c, req, rec := newCreateContext(e, form)
+	ctrl := NewUserController(&duplicateNameUserService{}, nil)
+
+	err := withSession(func(c echo.Context) error {
+		form.Set("csrf", ctrl.IssueToken(c))
+		req.PostForm = form
+		req.Form = form
+		return ctrl.Create(c)
+	})(c)
+
+	// 「保存に失敗しました」ではなく、名前の項目に重複のメッセージを出す
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "name:"+service.ErrDuplicateName.Error())
+}
+
+// 登録・更新で受け取った値を覚えておく偽物
+type profileUserService struct {
+	mockUserService
+	input    service.UserInput
+	avatar   string // 今の画像 (FindByID で返す)
+	replaced string // UpdateProfile で返す、差し替え前の画像
+}
+
+func (m *profileUserService) Register(ctx context.Context, in service.UserInput) (uint64, error) {
+	m.input = in
+	return 1, nil
+}
+
+func (m *profileUserService) UpdateProfile(ctx context.Context, id uint64, in service.UserInput, version uint32) (string, error) {
+	m.input = in
+	return m.replaced, nil
+}
+
+func (m *profileUserService) FindByID(ctx context.Context, id uint64) (repository.User, error) {
+	u, _ := m.mockUserService.FindByID(ctx, id)
+	u.AvatarPath = m.avatar
+	return u, nil
+}
+
+// newMultipartRequest は画像付きのフォーム送信 (multipart/form-data) を組み立てる
+func newMultipartRequest(t *testing.T, target string, form url.Values, file []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, vs := range form {
+		for _, v := range vs {
+			require.NoError(t, w.WriteField(k, v))
+		}
+	}
+	fw, err := w.CreateFormFile("avatar", "avatar.png")
+	require.NoError(t, err)
+	_, err = fw.Write(file)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return req
+}
+
+func testPNG(t *testing.T) []byte {
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, image.NewRGBA(image.Rect(0, 0, 20, 10))))
+	return b.Bytes()
+}
+
+// createMultipart はトークンを発行してから、画像付きで Create を呼ぶ
+func createMultipart(t *testing.T, e *echo.Echo, ctrl *UserController, form url.Values, file []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	err := withSession(func(c echo.Context) error {
+		// セッションは ctx に入っているので、差し替えるリクエストにも引き継ぐ
+		form.Set("csrf", ctrl.IssueToken(c))
+		c.SetRequest(newMultipartRequest(t, "/users", form, file).WithContext(c.Request().Context()))
+		return ctrl.Create(c)
+	})(e.NewContext(httptest.NewRequest(http.MethodPost, "/users", nil), rec))
+	require.NoError(t, err)
+	return rec
+}
+
+func TestUserController_Create_Avatar(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	store, err := avatar.NewStore(t.TempDir())
+	require.NoError(t, err)
+	svc := &profileUserService{}
+	ctrl := NewUserController(svc, store)
+
+	form := url.Values{"name": {"山田太郎"}, "email": {"yamada@example.com"}, "status": {"invited"}, "role": {"editor"}}
+	rec := createMultipart(t, e, ctrl, form, testPNG(t))
+
+	// 画像を保存し、その key を他の項目と一緒に Service に渡す
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "yamada@example.com", svc.input.Email)
+	assert.Equal(t, model.UserStatusInvited, svc.input.Status)
+	assert.Equal(t, model.RoleEditor, svc.input.Role)
+	_, err = store.Path(svc.input.Avatar, true)
+	assert.NoError(t, err)
+}
+
+func TestUserController_Create_InvalidAvatar(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = &mockRenderer{}
+	store, err := avatar.NewStore(t.TempDir())
+	require.NoError(t, err)
+	svc := &profileUserService{}
+	ctrl := NewUserController(svc, store)
+
+	rec := createMultipart(t, e, ctrl, url.Values{"name": {"山田太郎"}}, []byte("画像ではない"))
+
+	// 登録せずに、画像の項目にメッセージを出す
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "avatar:"+avatar.ErrUnsupported.Error())
+	assert.Empty(t, svc.input.Name)
+}
+
+func TestUserController_Update_ReplacesAvatar(t *testing.T) {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	store, err := avatar.NewStore(t.TempDir())
+	require.NoError(t, err)
+	old, err := store.Save(bytes.NewReader(testPNG(t)))
+	require.NoError(t, err)
+	svc := &profileUserService{avatar: old, replaced: old}
+	ctrl := NewUserController(svc, store)
+
+	form := url.Values{"id": {"5"}, "name": {"山田太郎"}, "version": {"1"}}
+	rec := httptest.NewRecorder()
+	err = withSession(func(c echo.Context) error {
+		form.Set("csrf", ctrl.IssueToken(c))
+		c.SetRequest(newMultipartRequest(t, "/users/update", form, testPNG(t)).WithContext(c.Request().Context()))
+		return ctrl.Update(c)
+	})(e.NewContext(httptest.NewRequest(http.MethodPost, "/users/update", nil), rec))
+
+	// 新しい画像が残り、差し替える前の画像は消える
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	_, err = store.Path(svc.input.Avatar, false)
+	assert.NoError(t, err)
+	_, err = store.Path(old, false)
+	assert.ErrorIs(t, err, avatar.ErrNotFound)
+}
+
+func TestUserController_Avatar(t *testing.T) {
+	store, err := avatar.NewStore(t.TempDir())
+	require.NoError(t, err)
+	key, err := store.Save(bytes.NewReader(testPNG(t)))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"画像がある", key, http.StatusOK},
+		{"画像が無い", "", http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			ctrl := NewUserController(&profileUserService{avatar: tt.key}, store)
+			e.GET("/users/:id/avatar", ctrl.Avatar)
+			req := httptest.NewRequest(http.MethodGet, "/users/5/avatar?size=thumb", nil)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
 
-	// もしController内で HTTPError に変換しているなら、その中身をチェック
-	if he, ok := err.(*echo.HTTPError); ok {
-		assert.Equal(t, http.StatusInternalServerError, he.Code)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+			}
+		})
 	}
 }
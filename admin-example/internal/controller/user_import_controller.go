@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/usercsv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// 取り込める CSV ファイルの大きさの上限
+const maxImportFileSize = 1 << 20 // 1MB
+
+// importPreviewRow: 取り込み確認画面の1行
+type importPreviewRow struct {
+	Line   int
+	Name   string
+	Errors []string // 空なら登録できる
+}
+
+// CSV 出力 (GET /users/export?encoding=sjis)
+// 既定は BOM 付きの UTF-8。日本語版 Windows の古い Excel 向けに Shift_JIS も選べる
+func (u *UserController) Export(c echo.Context) error {
+	users, err := u.svc.GetList(c.Request().Context())
+	if err != nil {
+		return err
+	}
+
+	enc := usercsv.ParseEncoding(c.QueryParam("encoding"))
+	charset := "UTF-8"
+	if enc == usercsv.ShiftJIS {
+		charset = "Shift_JIS"
+	}
+	filename := "users-" + time.Now().Format("20060102") + ".csv"
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset="+charset)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	res.WriteHeader(http.StatusOK)
+	// ヘッダーを送った後なので、失敗してもエラー画面は出せない（HTTPErrorHandler がログに残す）
+	return usercsv.Write(res, users, enc)
+}
+
+// 取り込み画面 (GET /users/import)
+func (u *UserController) ImportForm(c echo.Context) error {
+	return u.renderImport(c, http.StatusOK, nil, "")
+}
+
+// 取り込みの確認 (POST /users/import)
+// アップロードされた CSV を1行ずつチェックして、登録はせずに結果を表示する
+func (u *UserController) ImportPreview(c echo.Context) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return u.renderImport(c, http.StatusUnprocessableEntity, nil, "CSV ファイルを選んでください")
+	}
+	if fh.Size > maxImportFileSize {
+		return u.renderImport(c, http.StatusUnprocessableEntity, nil, "ファイルが大きすぎます (1MB まで)")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := usercsv.Read(f)
+	if err != nil {
+		return u.renderImport(c, http.StatusUnprocessableEntity, nil, err.Error())
+	}
+	if len(records) == 0 {
+		return u.renderImport(c, http.StatusUnprocessableEntity, nil, "取り込むデータがありません")
+	}
+
+	preview, rows := u.validateImportRows(c, records)
+	problems, err := u.svc.CheckImport(c.Request().Context(), rows)
+	if err != nil {
+		return err
+	}
+	addImportProblems(preview, problems)
+	return u.renderImport(c, http.StatusOK, preview, "")
+}
+
+// 取り込みの実行 (POST /users/import/commit)
+// 確認画面の hidden の line / name を受け取り、全ての行を1つのトランザクションで登録する。
+// 1行でも登録できなければ1件も登録せず、確認画面を出し直す
+func (u *UserController) ImportCommit(c echo.Context) error {
+	if !u.IsValidAndDestroyToken(c) {
+		return u.doubleSubmitted(c, "/users")
+	}
+
+	form, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	lines, names := form["line"], form["name"]
+	if len(names) == 0 || len(lines) != len(names) || len(names) > usercsv.MaxRows {
+		return echo.NewHTTPError(http.StatusBadRequest, "取り込むデータが正しくありません")
+	}
+	records := make([]usercsv.Row, len(names))
+	for i := range names {
+		line, err := strconv.Atoi(lines[i])
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "取り込むデータが正しくありません")
+		}
+		records[i] = usercsv.Row{Line: line, Name: names[i]}
+	}
+
+	// 確認画面の後に書き換えられていないとは限らないので、もう一度チェックする
+	preview, rows := u.validateImportRows(c, records)
+	if len(rows) < len(preview) {
+		return u.renderImport(c, http.StatusUnprocessableEntity, preview, "")
+	}
+
+	n, err := u.svc.ImportUsers(c.Request().Context(), rows)
+	var importErr *service.ImportError
+	switch {
+	case errors.As(err, &importErr):
+		// 確認してから実行するまでの間に、他の人が同じ名前で登録した
+		addImportProblems(preview, importErr.Problems)
+		return u.renderImport(c, http.StatusUnprocessableEntity, preview, "登録できない行があったため、1件も取り込んでいません")
+	case err != nil:
+		return echo.NewHTTPError(http.StatusInternalServerError, "取り込みに失敗しました").SetInternal(err)
+	}
+
+	return u.redirectWithFlash(c, FlashSuccess, fmt.Sprintf("%d 件のユーザーを取り込みました", n), "/users")
+}
+
+// validateImportRows は各行を UserCreateForm と同じルールでチェックする。
+// 確認画面の行と、チェックを通った行 (Service に渡すもの) を返す
+func (u *UserController) validateImportRows(c echo.Context, records []usercsv.Row) ([]importPreviewRow, []service.ImportRow) {
+	preview := make([]importPreviewRow, len(records))
+	var rows []service.ImportRow
+	for i, r := range records {
+		preview[i] = importPreviewRow{Line: r.Line, Name: r.Name}
+		form := &model.UserImportRow{Name: r.Name}
+		if err := c.Validate(form); err != nil {
+			for _, msg := range u.GetValidationErrors(c, err, form) {
+				preview[i].Errors = append(preview[i].Errors, msg)
+			}
+			continue
+		}
+		rows = append(rows, service.ImportRow{Line: r.Line, Name: r.Name})
+	}
+	return preview, rows
+}
+
+// addImportProblems は Service が見つけた問題（名前の重複）を、同じ行番号の行に足す
+func addImportProblems(preview []importPreviewRow, problems []service.ImportProblem) {
+	for _, p := range problems {
+		for i := range preview {
+			if preview[i].Line == p.Line {
+				preview[i].Errors = append(preview[i].Errors, p.Message)
+				break
+			}
+		}
+	}
+}
+
+func (u *UserController) renderImport(c echo.Context, status int, preview []importPreviewRow, errMsg string) error {
+	errorCount := 0
+	for _, r := range preview {
+		if len(r.Errors) > 0 {
+			errorCount++
+		}
+	}
+	return c.Render(status, "users/import", map[string]interface{}{
+		"Rows":       preview,
+		"ErrorCount": errorCount,
+		"Error":      errMsg,
+		"MaxRows":    usercsv.MaxRows,
+	})
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-example/admin-example/internal/service"
+	"go-example/admin-example/internal/validation"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// importRenderer は users/import に渡された行を覚えておく Renderer
+type importRenderer struct {
+	rows []importPreviewRow
+}
+
+func (r *importRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
+	d := data.(map[string]interface{})
+	r.rows, _ = d["Rows"].([]importPreviewRow)
+	if msg, _ := d["Error"].(string); msg != "" {
+		w.Write([]byte(msg))
+	}
+	return nil
+}
+
+// 名前が重複している行を返す偽物
+type duplicateImportService struct {
+	mockUserService
+}
+
+func (m *duplicateImportService) CheckImport(ctx context.Context, rows []service.ImportRow) ([]service.ImportProblem, error) {
+	return []service.ImportProblem{{Line: 4, Name: "登録済みの人", Message: "同じ名前のユーザーがすでに存在します (uk_users_active_name)"}}, nil
+}
+
+func (m *duplicateImportService) ImportUsers(ctx context.Context, rows []service.ImportRow) (int, error) {
+	return 0, &service.ImportError{Problems: []service.ImportProblem{{Line: 2, Name: rows[0].Name, Message: "同じ名前のユーザーがすでに存在します (uk_users_active_name)"}}}
+}
+
+func newImportEcho(r echo.Renderer) *echo.Echo {
+	e := echo.New()
+	e.Validator = &testValidator{validator: validation.New()}
+	e.Renderer = r
+	return e
+}
+
+// newUploadContext は POST /users/import のファイルのアップロードを組み立てる
+func newUploadContext(e *echo.Echo, csv string) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "users.csv")
+	fw.Write([]byte(csv))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/import", &body)
+	req.Header.Set(echo.HeaderContentType, mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestUserController_ImportPreview(t *testing.T) {
+	r := &importRenderer{}
+	e := newImportEcho(r)
+	c, rec := newUploadContext(e, "name\n新人一号\nab\n登録済みの人\n")
+	ctrl := NewUserController(&duplicateImportService{}, nil)
+
+	err := ctrl.ImportPreview(c)
+
+	// 登録はせず、行ごとの結果を表示する
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.Len(t, r.rows, 3) {
+		assert.Empty(t, r.rows[0].Errors)
+		assert.Equal(t, []string{"名前は3文字以上で入力してください"}, r.rows[1].Errors)
+		assert.Equal(t, 4, r.rows[2].Line)
+		assert.Contains(t, r.rows[2].Errors[0], "uk_users_active_name")
+	}
+}
+
+func TestUserController_ImportPreview_NoNameColumn(t *testing.T) {
+	e := newImportEcho(&importRenderer{})
+	c, rec := newUploadContext(e, "id,email\n1,a@example.com\n")
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := ctrl.ImportPreview(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "name")
+}
+
+// commitWithToken はトークンを発行してからフォームに入れて ImportCommit を呼ぶ
+func commitWithToken(e *echo.Echo, ctrl *UserController, form url.Values) (error, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/users/import/commit", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	err := withSession(func(c echo.Context) error {
+		form.Set("csrf", ctrl.IssueToken(c))
+		req.PostForm = form
+		req.Form = form
+		return ctrl.ImportCommit(c)
+	})(e.NewContext(req, rec))
+	return err, rec
+}
+
+func TestUserController_ImportCommit(t *testing.T) {
+	e := newImportEcho(&importRenderer{})
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err, rec := commitWithToken(e, ctrl, url.Values{"line": {"2", "3"}, "name": {"新人一号", "新人二号"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/users", rec.Header().Get(echo.HeaderLocation))
+}
+
+func TestUserController_ImportCommit_Duplicate(t *testing.T) {
+	r := &importRenderer{}
+	e := newImportEcho(r)
+	ctrl := NewUserController(&duplicateImportService{}, nil)
+
+	err, rec := commitWithToken(e, ctrl, url.Values{"line": {"2"}, "name": {"登録済みの人"}})
+
+	// 確認の後に他の人が登録していた。1件も登録せず、確認画面を出し直す
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "1件も取り込んでいません")
+	if assert.Len(t, r.rows, 1) {
+		assert.Contains(t, r.rows[0].Errors[0], "uk_users_active_name")
+	}
+}
+
+func TestUserController_ImportCommit_Tampered(t *testing.T) {
+	r := &importRenderer{}
+	e := newImportEcho(r)
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	// 確認画面の hidden を書き換えて、チェックを通らない名前を送る
+	err, rec := commitWithToken(e, ctrl, url.Values{"line": {"2"}, "name": {"ab"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	if assert.Len(t, r.rows, 1) {
+		assert.NotEmpty(t, r.rows[0].Errors)
+	}
+}
+
+func TestUserController_Export(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/users/export?encoding=sjis", nil)
+	rec := httptest.NewRecorder()
+	ctrl := NewUserController(&mockUserService{}, nil)
+
+	err := ctrl.Export(e.NewContext(req, rec))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv; charset=Shift_JIS", rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "attachment;")
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "id,name,"))
+}
@@ -0,0 +1,50 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-example/admin-example/internal/config"
+)
+
+// OpenDB はDBに接続し、実際に疎通できるまで Ping をリトライする。
+// sql.Open は接続を確認しないので、ここで確認しないと最初のリクエストまで失敗に気付けない
+func OpenDB(ctx context.Context, cfg config.DBConfig) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("DSN が正しくありません: %w", err)
+	}
+
+	if err := pingWithRetry(ctx, db, cfg.PingRetries, cfg.PingInterval); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// pingWithRetry は最初の1回 + retries 回まで Ping する。待ち時間は失敗のたびに倍にする
+func pingWithRetry(ctx context.Context, db *sql.DB, retries int, interval time.Duration) error {
+	var err error
+	wait := interval
+	for attempt := 0; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			break
+		}
+
+		slog.WarnContext(ctx, "DBに接続できません。待ってからリトライします",
+			"retry", attempt+1, "retries", retries, "wait", wait.String(), "error", err.Error())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("DBへの接続を中断しました: %w", ctx.Err())
+		}
+		wait *= 2
+	}
+	return fmt.Errorf("DBに接続できません (%d回リトライしました): %w", retries, err)
+}
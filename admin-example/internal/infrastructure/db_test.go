@@ -0,0 +1,62 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/config"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenDB_Unreachable(t *testing.T) {
+	// 誰も待ち受けていないポートに接続させる
+	cfg := config.DBConfig{
+		DSN:          "user:pass@tcp(127.0.0.1:1)/none?timeout=100ms",
+		PingRetries:  2,
+		PingInterval: 10 * time.Millisecond,
+	}
+
+	start := time.Now()
+	db, err := OpenDB(context.Background(), cfg)
+
+	// リトライしたうえで、分かりやすいエラーで諦めること
+	assert.Nil(t, db)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "2回リトライしました")
+	}
+	// 10ms + 20ms は待っているはず
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
+
+func TestOpenDB_Canceled(t *testing.T) {
+	cfg := config.DBConfig{
+		DSN:          "user:pass@tcp(127.0.0.1:1)/none?timeout=100ms",
+		PingRetries:  100,
+		PingInterval: time.Hour,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := OpenDB(ctx, cfg)
+
+	// 待っている途中でも、キャンセルされたらすぐに戻ること
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestOpenDB_InvalidDSN(t *testing.T) {
+	_, err := OpenDB(context.Background(), config.DBConfig{DSN: "not a dsn"})
+
+	assert.Error(t, err)
+}
+
+func TestOpenRepositories_Memory(t *testing.T) {
+	repos, closeFn, err := OpenRepositories(context.Background(), config.DBConfig{Driver: config.DriverMemory})
+
+	// DBに接続しなくても、すぐに使える
+	assert.NoError(t, err)
+	assert.NoError(t, repos.Health.Ping(context.Background()))
+	assert.NoError(t, closeFn())
+}
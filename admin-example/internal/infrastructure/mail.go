@@ -0,0 +1,22 @@
+package infrastructure
+
+import (
+	"log/slog"
+
+	"go-example/admin-example/internal/config"
+	"go-example/admin-example/internal/mail"
+)
+
+// NewMailer は mail.driver に応じてメールの送り方を作る
+func NewMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	switch cfg.Driver {
+	case config.MailDriverSMTP:
+		return mail.NewSMTPMailer(cfg.SMTP.Addr, cfg.From, cfg.SMTP.Username, cfg.SMTP.Password), nil
+	case config.MailDriverMemory:
+		slog.Warn("mail.driver=memory: メールは送られず、どこにも残りません")
+		return mail.NewMemoryMailer(), nil
+	default:
+		slog.Info("mail.driver=file: メールは送らずにファイルに保存します", "dir", cfg.Dir)
+		return mail.NewFileMailer(cfg.Dir, cfg.From)
+	}
+}
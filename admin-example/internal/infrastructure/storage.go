@@ -0,0 +1,28 @@
+package infrastructure
+
+import (
+	"context"
+	"log/slog"
+
+	"go-example/admin-example/internal/config"
+	"go-example/admin-example/internal/repository"
+)
+
+// OpenRepositories は db.driver に応じてリポジトリ一式を作る。
+// 戻り値の close は終了時に呼ぶこと（MySQL なら接続を閉じる）
+// クエリのログは slog.Default() に書くので、先に slog.SetDefault しておくこと
+func OpenRepositories(ctx context.Context, cfg config.DBConfig) (repository.Repositories, func() error, error) {
+	if cfg.Driver == config.DriverMemory {
+		slog.Warn("db.driver=memory: データはメモリに保存され、停止すると消えます")
+		repos := repository.NewMemoryRepositories(repository.NewMemoryStore())
+		return repos, func() error { return nil }, nil
+	}
+
+	db, err := OpenDB(ctx, cfg)
+	if err != nil {
+		return repository.Repositories{}, nil, err
+	}
+	// SQL は debug で、遅いクエリは warn でログに書く (log.level と db.slow_query_threshold)
+	ql := repository.QueryLog{Logger: slog.Default(), SlowThreshold: cfg.SlowQueryThreshold}
+	return repository.NewMySQLRepositories(db, ql), db.Close, nil
+}
@@ -1,19 +1,220 @@
 package infrastructure
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
 	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"go-example/admin-example/internal/model"
 
 	"github.com/labstack/echo/v4"
 	"github.com/yosssi/ace"
 )
 
-type TemplateRenderer struct{}
+// 全ての画面で使うレイアウト
+const baseLayout = "layout/base"
+
+type TemplateRenderer struct {
+	// テンプレートの置き場所（空なら "views"）。FS が nil の時だけ使う
+	ViewsDir string
+	// go:embed したテンプレート (views.FS)。バイナリだけで動かす時に使う
+	FS fs.FS
+	// テンプレートから呼べる関数。TemplateFuncs に足したい時だけ指定する
+	Funcs template.FuncMap
+	// すべての画面に共通で渡す値（ログイン中のアカウントなど）を作る関数
+	// data が map の場合だけ、まだ入っていないキーを足す
+	Globals func(c echo.Context) map[string]interface{}
+
+	mu    sync.RWMutex
+	cache map[string]*cachedTemplate
+}
+
+// cachedTemplate: コンパイル済みのテンプレートと、読み込んだファイルの更新日時
+type cachedTemplate struct {
+	tpl   *template.Template
+	files map[string]time.Time // レイアウト・画面・include したファイル
+}
 
 func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c echo.Context) error {
-	// 複雑なことはせず、単にロードして実行するだけ
-	tpl, err := ace.Load("views/layout/base", "views/"+name, nil)
+	tpl, err := t.lookup(name)
 	if err != nil {
 		return err
 	}
+
+	if m, ok := data.(map[string]interface{}); ok && t.Globals != nil {
+		for k, v := range t.Globals(c) {
+			if _, exists := m[k]; !exists {
+				m[k] = v
+			}
+		}
+	}
 	return tpl.Execute(w, data)
 }
+
+// lookup はキャッシュからテンプレートを返す。無ければコンパイルしてキャッシュする
+// (html/template は実行中に書き換えないので、複数のリクエストから同時に使ってよい)
+func (t *TemplateRenderer) lookup(name string) (*template.Template, error) {
+	t.mu.RLock()
+	entry, ok := t.cache[name]
+	t.mu.RUnlock()
+	if ok {
+		return entry.tpl, nil
+	}
+
+	entry, err := t.compile(name)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	if t.cache == nil {
+		t.cache = map[string]*cachedTemplate{}
+	}
+	t.cache[name] = entry
+	t.mu.Unlock()
+	return entry.tpl, nil
+}
+
+// compile はレイアウトと画面 (name) を読み込んでコンパイルする
+func (t *TemplateRenderer) compile(name string) (*cachedTemplate, error) {
+	fsys := t.fsys()
+	files := map[string]time.Time{}
+
+	funcs := TemplateFuncs()
+	for k, v := range t.Funcs {
+		funcs[k] = v
+	}
+	tpl, err := ace.Load(baseLayout, name, &ace.Options{
+		// キャッシュはこちらで持つので、ace の（消せない）キャッシュは使わない
+		DynamicReload: true,
+		// BaseDir を指定しないので、include も fsys の直下 (views/) からの相対パスで書ける
+		Asset: func(path string) ([]byte, error) {
+			info, err := fs.Stat(fsys, path)
+			if err != nil {
+				return nil, err
+			}
+			files[path] = info.ModTime()
+			return fs.ReadFile(fsys, path)
+		},
+		FuncMap: funcs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("テンプレート %s を読み込めません: %w", name, err)
+	}
+	return &cachedTemplate{tpl: tpl, files: files}, nil
+}
+
+func (t *TemplateRenderer) fsys() fs.FS {
+	if t.FS != nil {
+		return t.FS
+	}
+	dir := t.ViewsDir
+	if dir == "" {
+		dir = "views"
+	}
+	return os.DirFS(dir)
+}
+
+// Watch は開発用。interval ごとにテンプレートのファイルを確認し、
+// 書き換えられたものをキャッシュから外す（次のリクエストで読み直す）。ctx が終わるまで戻らない
+func (t *TemplateRenderer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.invalidateChanged()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// invalidateChanged は読み込んだ後に更新・削除されたファイルを使っているテンプレートを捨てる
+func (t *TemplateRenderer) invalidateChanged() {
+	fsys := t.fsys()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, entry := range t.cache {
+		for path, modTime := range entry.files {
+			info, err := fs.Stat(fsys, path)
+			if err != nil || !info.ModTime().Equal(modTime) {
+				slog.Info("テンプレートが変更されたので読み直します", "template", name, "file", path)
+				delete(t.cache, name)
+				break
+			}
+		}
+	}
+}
+
+// TemplateFuncs はテンプレートから呼べる関数の一覧
+//
+//	{{date .UpdatedAt}}                      → 2006-01-02 15:04 （sql.NullTime が NULL なら空）
+//	{{date .CreatedAt "2006-01-02"}}         → 書式を指定する
+//	{{str .Name}}                            → sql.NullString の中身（NULL なら空）
+//	{{csrfField .csrf}}                      → CSRF・二重送信防止のトークンの hidden input
+//	{{statusLabel .Status}} / {{roleLabel .Role}} → ユーザーの状態・役割の表示名
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"date":        formatDate,
+		"str":         nullString,
+		"csrfField":   csrfField,
+		"statusLabel": func(s string) string { return model.UserStatus(s).Label() },
+		"roleLabel":   func(r string) string { return model.Role(r).Label() },
+	}
+}
+
+const defaultDateLayout = "2006-01-02 15:04"
+
+func formatDate(v interface{}, layout ...string) (string, error) {
+	l := defaultDateLayout
+	if len(layout) > 0 {
+		l = layout[0]
+	}
+	switch t := v.(type) {
+	case time.Time:
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(l), nil
+	case sql.NullTime:
+		if !t.Valid {
+			return "", nil
+		}
+		return t.Time.Format(l), nil
+	case *time.Time:
+		if t == nil {
+			return "", nil
+		}
+		return t.Format(l), nil
+	case nil:
+		return "", nil
+	}
+	return "", fmt.Errorf("date: 日時ではありません (%T)", v)
+}
+
+func nullString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case sql.NullString:
+		return s.String, nil // NULL なら String は空
+	case string:
+		return s, nil
+	case nil:
+		return "", nil
+	}
+	return "", errors.New("str: 文字列ではありません")
+}
+
+// token は文字列か、String() で初めて発行するもの (controller の formToken)
+func csrfField(token interface{}) template.HTML {
+	return template.HTML(`<input type="hidden" name="csrf" value="` + html.EscapeString(fmt.Sprint(token)) + `">`)
+}
@@ -0,0 +1,130 @@
+package infrastructure
+
+import (
+	"bytes"
+	"database/sql"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go-example/admin-example/views"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testViews() fstest.MapFS {
+	return fstest.MapFS{
+		"layout/base.ace":  {Data: []byte("html\n  body\n    = yield main\n"), ModTime: time.Unix(1, 0)},
+		"layout/parts.ace": {Data: []byte("span.parts {{.}}\n"), ModTime: time.Unix(1, 0)},
+		"users/show.ace": {Data: []byte("= content main\n  p {{str .Name}} {{date .At}}\n  = include layout/parts .Msg\n"),
+			ModTime: time.Unix(1, 0)},
+	}
+}
+
+func renderString(t *testing.T, r *TemplateRenderer, name string, data interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.Render(&buf, name, data, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func showData() map[string]interface{} {
+	return map[string]interface{}{
+		"Name": sql.NullString{String: "taro", Valid: true},
+		"At":   time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC),
+		"Msg":  "v1",
+	}
+}
+
+func TestTemplateRenderer_Render(t *testing.T) {
+	r := &TemplateRenderer{FS: testViews()}
+
+	out := renderString(t, r, "users/show", showData())
+
+	assert.Contains(t, out, "<p>taro 2026-01-02 03:04</p>")
+	assert.Contains(t, out, `<span class="parts">v1</span>`)
+}
+
+func TestTemplateRenderer_Cache(t *testing.T) {
+	fsys := testViews()
+	r := &TemplateRenderer{FS: fsys}
+	renderString(t, r, "users/show", showData())
+
+	// 2回目以降はキャッシュを使うので、ファイルを書き換えても変わらない
+	fsys["users/show.ace"].Data = []byte("= content main\n  p changed\n")
+	assert.NotContains(t, renderString(t, r, "users/show", showData()), "changed")
+
+	// 更新日時が変わったら捨てて読み直す (include したファイルでも同じ)
+	fsys["users/show.ace"].ModTime = time.Unix(2, 0)
+	r.invalidateChanged()
+	assert.Contains(t, renderString(t, r, "users/show", showData()), "changed")
+
+	fsys["layout/base.ace"].Data = []byte("div.v2\n  = yield main\n")
+	fsys["layout/base.ace"].ModTime = time.Unix(2, 0)
+	r.invalidateChanged()
+	assert.Contains(t, renderString(t, r, "users/show", showData()), `<div class="v2">`)
+}
+
+func TestTemplateRenderer_Concurrent(t *testing.T) {
+	r := &TemplateRenderer{FS: testViews()}
+
+	// 同時に呼ばれても壊れないこと (go test -race で確認する)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			assert.NoError(t, r.Render(&buf, "users/show", showData(), nil))
+			if i%5 == 0 {
+				r.invalidateChanged()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTemplateRenderer_NotFound(t *testing.T) {
+	r := &TemplateRenderer{FS: testViews()}
+
+	err := r.Render(&bytes.Buffer{}, "users/none", nil, nil)
+
+	assert.Error(t, err)
+}
+
+// 埋め込んだ本物のテンプレートが全てコンパイルできること
+func TestTemplateRenderer_EmbeddedViews(t *testing.T) {
+	r := &TemplateRenderer{FS: views.FS}
+	names, _ := fs.Glob(views.FS, "*/*.ace")
+
+	assert.NotEmpty(t, names)
+	for _, name := range names {
+		if strings.HasPrefix(name, "layout/") {
+			continue
+		}
+		_, err := r.compile(strings.TrimSuffix(name, ".ace"))
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s, err := formatDate(sql.NullTime{Time: at, Valid: true}, "2006-01-02")
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-01-02", s)
+	s, _ = formatDate(sql.NullTime{})
+	assert.Equal(t, "", s)
+	_, err = formatDate("2026-01-02")
+	assert.Error(t, err)
+
+	s, _ = nullString(sql.NullString{})
+	assert.Equal(t, "", s)
+
+	assert.Equal(t, `<input type="hidden" name="csrf" value="a&#34;b">`, string(csrfField(`a"b`)))
+}
@@ -0,0 +1,64 @@
+// Package logging はアプリのログ (log/slog の JSON) の設定を行う。
+//
+// ログを書く時に ctx を渡すと (slog.InfoContext など)、context に載っているリクエストID
+// (requestctx.WithRequestID) を request_id として自動で付ける。
+// Controller → Service → Repository のどこで書いたログでも、同じリクエストのものをまとめて探せる
+//
+//	{"time":"...","level":"INFO","msg":"リクエスト","request_id":"Xy3...","method":"POST","status":303}
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go-example/admin-example/internal/requestctx"
+)
+
+// New は JSON で書き出す Logger を作る。level より低いログは書かない
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(&contextHandler{Handler: h})
+}
+
+// ParseLevel は設定の log.level (debug / info / warn / error) を読む
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("ログのレベルは debug / info / warn / error のどれかにしてください (%q)", s)
+}
+
+// contextHandler は ctx のリクエストIDと、操作したアカウントをログに足す
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := requestctx.RequestIDFrom(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if actor := requestctx.ActorFrom(ctx); actor.ID != 0 {
+			r.AddAttrs(slog.Uint64("actor_id", actor.ID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go-example/admin-example/internal/requestctx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	return m
+}
+
+func TestNew_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo).With("component", "test")
+	ctx := requestctx.WithRequestID(context.Background(), "req-123")
+	ctx = requestctx.WithActor(ctx, requestctx.Actor{ID: 7, Email: "admin@example.com"})
+
+	logger.InfoContext(ctx, "保存しました", "user_id", 5)
+
+	m := decode(t, &buf)
+	assert.Equal(t, "保存しました", m["msg"])
+	assert.Equal(t, "req-123", m["request_id"])
+	assert.Equal(t, float64(7), m["actor_id"])
+	assert.Equal(t, "test", m["component"]) // With で足した値も残る
+	assert.Equal(t, float64(5), m["user_id"])
+}
+
+func TestNew_WithoutRequest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	// コマンドラインなど、リクエストの外では request_id を付けない
+	logger.Info("起動しました")
+
+	m := decode(t, &buf)
+	assert.NotContains(t, m, "request_id")
+	assert.NotContains(t, m, "actor_id")
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Debug("SQL")
+
+	assert.Empty(t, buf.String())
+}
+
+func TestParseLevel(t *testing.T) {
+	l, err := ParseLevel("DEBUG")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, l)
+
+	l, err = ParseLevel("")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, l)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer: 送らずに dir に .eml ファイルとして保存する（メールソフトでそのまま開ける）
+type FileMailer struct {
+	dir  string
+	from string
+	now  func() time.Time
+}
+
+// NewFileMailer は dir に保存する Mailer を作る（dir が無ければ作る）
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("メールの保存先 %s を作れません: %w", dir, err)
+	}
+	return &FileMailer{dir: dir, from: from, now: time.Now}, nil
+}
+
+func (f *FileMailer) Send(ctx context.Context, m Message) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	now := f.now()
+	// 送った順に並ぶよう、ファイル名は日時から始める
+	file, err := os.CreateTemp(f.dir, now.Format("20060102-150405.000")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(format(f.from, m, now)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// MemoryMailer: 送らずにメモリに溜める（テスト用）
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent はこれまでに送ったメールを古い順に返す
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
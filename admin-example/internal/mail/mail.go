@@ -0,0 +1,84 @@
+// Package mail はメールを送る。送り方は Mailer を差し替えて選ぶ (config の mail.driver)
+//
+//	smtp   … SMTP サーバーに送る (SMTPMailer)
+//	file   … 送らずに .eml ファイルとして保存する。開発中にメールの中身を確認する用 (FileMailer)
+//	memory … 送らずにメモリに溜める。テスト用 (MemoryMailer)
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message: 送るメール（本文はテキストのみ）
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer: メールの送り方
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// ErrInvalidHeader: 宛先や件名に改行が入っている（ヘッダーを書き足される恐れがある）か、宛先の形式が違う
+var ErrInvalidHeader = errors.New("メールの宛先か件名が正しくありません")
+
+// validate は宛先と件名を確かめる
+func (m Message) validate() error {
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+	if _, err := mail.ParseAddress(m.To); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	return nil
+}
+
+// format はメールを RFC 5322 の形式にする。
+// 件名は MIME でエンコードし、本文は UTF-8 を base64 にする（日本語が化けないように）
+func format(from string, m Message, now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.BEncoding.Encode("UTF-8", m.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="UTF-8"`)
+	header("Content-Transfer-Encoding", "base64")
+	b.WriteString("\r\n")
+
+	// 1行は76文字まで
+	body := base64.StdEncoding.EncodeToString([]byte(m.Body))
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return b.Bytes()
+}
+
+// messageID は <ランダムな値@送信元のドメイン> を作る
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+	r := make([]byte, 12)
+	rand.Read(r)
+	return "<" + hex.EncodeToString(r) + "@" + domain + ">"
+}
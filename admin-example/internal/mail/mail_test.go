@@ -0,0 +1,77 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	body := strings.Repeat("招待のお知らせです。", 10)
+
+	b := string(format("Admin <admin@example.com>", Message{To: "taro@example.com", Subject: "招待", Body: body}, now))
+
+	head, encoded, ok := strings.Cut(b, "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, head, "To: taro@example.com\r\n")
+	assert.Contains(t, head, "Subject: =?UTF-8?b?5oub5b6F?=\r\n") // 件名は MIME でエンコード
+	assert.Contains(t, head, "Message-ID: <")
+	assert.Contains(t, head, "@example.com>")
+
+	// 本文は76文字ごとに折り返した base64
+	for _, line := range strings.Split(strings.TrimSuffix(encoded, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 76)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestMessage_Validate(t *testing.T) {
+	for name, m := range map[string]Message{
+		"件名に改行":    {To: "taro@example.com", Subject: "件名\r\nBcc: evil@example.com"},
+		"宛先に改行":    {To: "taro@example.com\r\nBcc: evil@example.com", Subject: "件名"},
+		"宛先の形式が違う": {To: "taro", Subject: "件名"},
+	} {
+		err := NewMemoryMailer().Send(context.Background(), m)
+		assert.ErrorIs(t, err, ErrInvalidHeader, name)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "admin@example.com")
+	require.NoError(t, err)
+
+	err = m.Send(context.Background(), Message{To: "taro@example.com", Subject: "招待", Body: "本文"})
+
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		b, err := os.ReadFile(files[0])
+		require.NoError(t, err)
+		assert.Contains(t, string(b), "To: taro@example.com\r\n")
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "a@example.com", Subject: "1"}))
+	require.NoError(t, m.Send(context.Background(), Message{To: "b@example.com", Subject: "2"}))
+
+	sent := m.Sent()
+	if assert.Len(t, sent, 2) {
+		assert.Equal(t, "a@example.com", sent[0].To)
+		assert.Equal(t, "b@example.com", sent[1].To)
+	}
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// SMTPMailer: SMTP サーバーに送る
+// サーバーが STARTTLS に対応していれば暗号化して送る (net/smtp.SendMail)
+type SMTPMailer struct {
+	addr string // "smtp.example.com:587"
+	from string
+	auth smtp.Auth // nil なら認証しない（社内のリレーなど）
+}
+
+// NewSMTPMailer は addr の SMTP サーバーに送る Mailer を作る。username が空なら認証しない
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send はメールを送る。
+// net/smtp は ctx で止められないので、ctx が終わっていたら送らないことだけ確認する
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return err
+	}
+	to, _ := mail.ParseAddress(m.To) // validate で確認済み
+
+	return smtp.SendMail(s.addr, s.auth, from.Address, []string{to.Address}, format(s.from, m, time.Now()))
+}
@@ -0,0 +1,279 @@
+// Package migrate は番号付きのSQLファイル (migrations パッケージ) をDBに適用する。
+//
+// 適用済みのものは schema_migrations テーブルに番号とチェックサムを記録する。
+// 複数のサーバーが同時に起動しても二重に実行しないよう、MySQL の GET_LOCK で排他する
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ErrLocked: 他のプロセスがマイグレーション中で、ロックを取れなかった
+var ErrLocked = errors.New("他のプロセスがマイグレーションを実行中です")
+
+// 適用済みのマイグレーションを記録するテーブル (%s にテーブル名が入る)
+const createSchemaMigrations = `CREATE TABLE IF NOT EXISTS %s (
+  version bigint unsigned NOT NULL,
+  name varchar(255) NOT NULL,
+  checksum char(64) NOT NULL,
+  applied_at datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (version)
+)`
+
+// record: 記録用テーブルの1行
+type record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status: status コマンドで表示する1行分
+type Status struct {
+	Version   int64
+	ID        string
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // 適用した後にファイルが書き換えられている
+	Missing   bool // 適用済みなのにファイルが無い
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	Table       string        // 適用済みを記録するテーブル名（コードの中でだけ決める）
+	LockName    string        // GET_LOCK に使う名前
+	LockTimeout time.Duration // ロックが空くのを待つ時間
+	Out         io.Writer     // 進み具合の出力先
+}
+
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		Table:       "schema_migrations",
+		LockName:    "admin-example.migrate",
+		LockTimeout: 10 * time.Second,
+		Out:         io.Discard,
+	}
+}
+
+// Up は未適用のマイグレーションを番号順に全て適用する
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]record) error {
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+		todo := pending(m.migrations, applied)
+		if len(todo) == 0 {
+			fmt.Fprintln(m.Out, "適用するマイグレーションはありません")
+			return nil
+		}
+		for _, mig := range todo {
+			if err := m.apply(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down は最後に適用したものから steps 個を戻す
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]record) error {
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+		for _, mig := range latestApplied(m.migrations, applied, steps) {
+			if err := m.revert(ctx, conn, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redo は最後に適用したものを1つ戻してから、もう一度適用する
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]record) error {
+		if err := verify(m.migrations, applied); err != nil {
+			return err
+		}
+		last := latestApplied(m.migrations, applied, 1)
+		if len(last) == 0 {
+			return errors.New("やり直せるマイグレーションがありません")
+		}
+		if err := m.revert(ctx, conn, last[0]); err != nil {
+			return err
+		}
+		return m.apply(ctx, conn, last[0])
+	})
+}
+
+// Status は全てのマイグレーションの適用状況を返す
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := m.withLock(ctx, func(conn *sql.Conn, applied map[int64]record) error {
+		result = statuses(m.migrations, applied)
+		return nil
+	})
+	return result, err
+}
+
+// withLock はロックを取り、schema_migrations を用意してから fn を実行する。
+// GET_LOCK は接続ごとのロックなので、最後まで同じ接続 (conn) を使う
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]record) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.LockName, int(m.LockTimeout.Seconds())).Scan(&got); err != nil {
+		return fmt.Errorf("ロックを取れません: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer func() {
+		// ctx がキャンセルされていてもロックは返す
+		var released sql.NullInt64
+		conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.LockName).Scan(&released)
+	}()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(createSchemaMigrations, m.Table)); err != nil {
+		return fmt.Errorf("%s を作れません: %w", m.Table, err)
+	}
+	applied, err := m.loadApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+func (m *Migrator) loadApplied(ctx context.Context, conn *sql.Conn) (map[int64]record, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+m.Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]record{}
+	for rows.Next() {
+		var r record
+		if err := rows.Scan(&r.Version, &r.Name, &r.Checksum, &r.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[r.Version] = r
+	}
+	return applied, rows.Err()
+}
+
+// apply は up を実行して schema_migrations に記録する。
+// MySQL の DDL はトランザクションで巻き戻せないので、途中で失敗したら手で直してから再実行すること
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	for _, stmt := range splitStatements(mig.Up) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s の適用に失敗しました: %w", mig.ID(), err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx,
+		"INSERT INTO "+m.Table+" (version, name, checksum) VALUES (?, ?, ?)",
+		mig.Version, mig.Name, mig.Checksum,
+	); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.Out, "適用しました: %s\n", mig.ID())
+	return nil
+}
+
+// revert は down を実行して schema_migrations から消す
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if mig.Down == "" {
+		return fmt.Errorf("%s には down.sql が無いので戻せません", mig.ID())
+	}
+	for _, stmt := range splitStatements(mig.Down) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%s を戻せませんでした: %w", mig.ID(), err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "DELETE FROM "+m.Table+" WHERE version = ?", mig.Version); err != nil {
+		return err
+	}
+	fmt.Fprintf(m.Out, "戻しました: %s\n", mig.ID())
+	return nil
+}
+
+// verify は適用済みのマイグレーションが、手元のファイルと食い違っていないかを確認する
+func verify(migrations []Migration, applied map[int64]record) error {
+	known := map[int64]Migration{}
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+	for version, r := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("適用済みの %04d_%s のファイルがありません", version, r.Name)
+		}
+		if mig.Checksum != r.Checksum {
+			return fmt.Errorf("%s は適用した後に書き換えられています。変更は新しいマイグレーションとして追加してください", mig.ID())
+		}
+	}
+	return nil
+}
+
+// pending は未適用のものを番号順に返す
+func pending(migrations []Migration, applied map[int64]record) []Migration {
+	var todo []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			todo = append(todo, mig)
+		}
+	}
+	return todo
+}
+
+// latestApplied は適用済みのものを新しい順に最大 n 個返す
+func latestApplied(migrations []Migration, applied map[int64]record, n int) []Migration {
+	var result []Migration
+	for i := len(migrations) - 1; i >= 0 && len(result) < n; i-- {
+		if _, ok := applied[migrations[i].Version]; ok {
+			result = append(result, migrations[i])
+		}
+	}
+	return result
+}
+
+// statuses はファイルと schema_migrations を突き合わせる
+func statuses(migrations []Migration, applied map[int64]record) []Status {
+	var result []Status
+	known := map[int64]bool{}
+	for _, mig := range migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, ID: mig.ID()}
+		if r, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = r.AppliedAt
+			s.Modified = r.Checksum != mig.Checksum
+		}
+		result = append(result, s)
+	}
+	for version, r := range applied {
+		if !known[version] {
+			result = append(result, Status{
+				Version: version, ID: fmt.Sprintf("%04d_%s", version, r.Name),
+				Applied: true, AppliedAt: r.AppliedAt, Missing: true,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result
+}
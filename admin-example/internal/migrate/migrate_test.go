@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"0002_add_items.up.sql":      {Data: []byte("CREATE TABLE items (id int);\n")},
+		"0002_add_items.down.sql":    {Data: []byte("DROP TABLE items;\n")},
+		"0001_create_users.up.sql":   {Data: []byte("-- コメント\nCREATE TABLE users (id int);\nCREATE INDEX idx ON users (id);\n")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;\n")},
+		"README.md":                  {Data: []byte("sql 以外は無視する")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS())
+
+	// 番号順に並び、up/down が1組になる
+	assert.NoError(t, err)
+	if assert.Len(t, migrations, 2) {
+		assert.Equal(t, "0001_create_users", migrations[0].ID())
+		assert.Equal(t, "DROP TABLE users;\n", migrations[0].Down)
+		assert.Equal(t, "0002_add_items", migrations[1].ID())
+		assert.Len(t, migrations[0].Checksum, 64)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"ファイル名の形式": {"create_users.up.sql": {Data: []byte("x;")}},
+		"番号の重複": {
+			"0001_a.up.sql": {Data: []byte("x;")},
+			"0001_b.up.sql": {Data: []byte("y;")},
+		},
+		"upが無い": {"0001_a.down.sql": {Data: []byte("x;")}},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("-- 先頭のコメント\nCREATE TABLE a (\n  id int\n);\n\nINSERT INTO a VALUES (1);\n-- 最後のコメントだけ\n")
+
+	assert.Equal(t, []string{
+		"-- 先頭のコメント\nCREATE TABLE a (\n  id int\n);",
+		"INSERT INTO a VALUES (1);",
+	}, stmts)
+}
+
+func TestPlan(t *testing.T) {
+	migrations, _ := Load(testFS())
+	applied := map[int64]record{1: {Version: 1, Name: "create_users", Checksum: migrations[0].Checksum}}
+
+	assert.NoError(t, verify(migrations, applied))
+	if todo := pending(migrations, applied); assert.Len(t, todo, 1) {
+		assert.Equal(t, int64(2), todo[0].Version)
+	}
+	if last := latestApplied(migrations, applied, 5); assert.Len(t, last, 1) {
+		assert.Equal(t, int64(1), last[0].Version)
+	}
+}
+
+func TestVerify_Modified(t *testing.T) {
+	migrations, _ := Load(testFS())
+
+	// 適用後にファイルを書き換えた
+	modified := map[int64]record{1: {Version: 1, Name: "create_users", Checksum: "old"}}
+	assert.Error(t, verify(migrations, modified))
+
+	// 適用済みのファイルを消した
+	missing := map[int64]record{9: {Version: 9, Name: "gone", Checksum: "x"}}
+	assert.Error(t, verify(migrations, missing))
+
+	s := statuses(migrations, map[int64]record{
+		1: {Version: 1, Name: "create_users", Checksum: "old"},
+		9: {Version: 9, Name: "gone"},
+	})
+	if assert.Len(t, s, 3) {
+		assert.True(t, s[0].Modified)
+		assert.False(t, s[1].Applied)
+		assert.True(t, s[2].Missing)
+		assert.Equal(t, "0009_gone", s[2].ID)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	migrations, _ := Load(testFS())
+
+	schema := Schema(migrations)
+
+	assert.Contains(t, schema, "直接編集しないこと")
+	assert.Contains(t, schema, "-- 0001_create_users\n-- コメント\nCREATE TABLE users")
+	assert.Contains(t, schema, "-- 0002_add_items\nCREATE TABLE items (id int);\n")
+}
+
+// 本物の MySQL での up/down/redo。TEST_MYSQL_DSN が無ければスキップする
+// (テーブルを作ったり消したりするので、必ずテスト専用のDBを指定すること)
+func TestMigrator_MySQL(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN が未設定なのでスキップします")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"0001_migrate_test.up.sql":   {Data: []byte("CREATE TABLE migrate_test (id int);\n")},
+		"0001_migrate_test.down.sql": {Data: []byte("DROP TABLE migrate_test;\n")},
+	}
+	migrations, _ := Load(fsys)
+	m := New(db, migrations)
+	// 本物の schema_migrations には触らない
+	m.Table = "schema_migrations_test"
+	m.LockName = "admin-example.migrate_test"
+	m.LockTimeout = time.Second
+	db.Exec("DROP TABLE IF EXISTS migrate_test")
+	db.Exec("DROP TABLE IF EXISTS schema_migrations_test")
+
+	assert.NoError(t, m.Up(ctx))
+	assert.NoError(t, m.Up(ctx)) // 2回目は何もしない
+	assert.NoError(t, m.Redo(ctx))
+
+	s, err := m.Status(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, s, 1) {
+		assert.True(t, s[0].Applied)
+	}
+
+	// 他の接続がロックを持っている間は実行できない
+	conn, _ := db.Conn(ctx)
+	var got int
+	conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", m.LockName).Scan(&got)
+	assert.ErrorIs(t, m.Down(ctx, 1), ErrLocked)
+	conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", m.LockName).Scan(&got)
+	conn.Close()
+
+	assert.NoError(t, m.Down(ctx, 1))
+	s, _ = m.Status(ctx)
+	assert.False(t, s[0].Applied)
+}
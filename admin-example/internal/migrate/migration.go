@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration: 番号付きのSQLファイル1組 (<番号>_<名前>.up.sql / .down.sql)
+type Migration struct {
+	Version  int64
+	Name     string // 例) create_users
+	Up       string
+	Down     string // 無ければ戻せない
+	Checksum string // Up の SHA-256。適用後に書き換えられていないかの確認に使う
+}
+
+// ID は 0001_create_users のような表示用の名前を返す
+func (m Migration) ID() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load は fsys の直下にある *.sql を読み込み、番号順に並べて返す
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("マイグレーションのファイル名が正しくありません: %s (<番号>_<名前>.up.sql の形式にしてください)", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("マイグレーションの番号が正しくありません: %s", e.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		// 同じ番号で名前が違うのは、別々のブランチで番号がぶつかった時によく起きる
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("マイグレーションの番号 %d が重複しています (%s と %s)", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%s.up.sql がありません", m.ID())
+		}
+		m.Checksum = checksum(m.Up)
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitStatements はSQLファイルを1文ずつに分ける。
+// ドライバの multiStatements に頼らないよう、行末の ; を文の区切りとみなす
+// (文字列の中で行末に ; を書くと誤って分かれるので、マイグレーションでは避けること)
+func splitStatements(sql string) []string {
+	var stmts []string
+	var current []string
+	flush := func() {
+		stmt := strings.TrimSpace(strings.Join(current, "\n"))
+		current = nil
+		if stmt != "" && !commentOnly(stmt) {
+			stmts = append(stmts, stmt)
+		}
+	}
+	for _, line := range strings.Split(sql, "\n") {
+		current = append(current, line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			flush()
+		}
+	}
+	flush()
+	return stmts
+}
+
+// commentOnly は -- のコメントと空行しか無いかを返す
+func commentOnly(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaHeader: 生成した schema.sql の先頭に付ける注意書き
+const schemaHeader = `-- schema.sql
+-- このファイルは migrations/*.up.sql から生成しています。直接編集しないこと
+-- テーブルを変える時は migrations に次の番号のファイルを追加してから
+--   UPDATE_SCHEMA=1 go test ./migrations
+-- で作り直す (sqlc はこのファイルを読む)
+`
+
+// Schema は全ての up マイグレーションを順に並べた schema.sql の中身を返す
+func Schema(migrations []Migration) string {
+	var b strings.Builder
+	b.WriteString(schemaHeader)
+	for _, m := range migrations {
+		fmt.Fprintf(&b, "\n-- %s\n", m.ID())
+		b.WriteString(strings.TrimSpace(m.Up))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
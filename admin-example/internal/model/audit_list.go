@@ -0,0 +1,90 @@
+package model
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日付の入力形式 (<input type="date"> と同じ)
+const dateLayout = "2006-01-02"
+
+// AuditListQuery: 監査ログ画面 (GET /audit?actor=&target_id=&from=&to=&page=) の検索条件
+type AuditListQuery struct {
+	Page     int    `query:"page"`
+	PerPage  int    `query:"per_page"`
+	Actor    string `query:"actor"`     // 操作した人のメールアドレス
+	TargetID uint64 `query:"target_id"` // 対象のユーザーID
+	From     string `query:"from"`      // この日以降 (YYYY-MM-DD)
+	To       string `query:"to"`        // この日まで (YYYY-MM-DD、その日も含む)
+}
+
+// Normalize は不正な値やおかしな値をデフォルトに丸める（読めない日付は無視する）
+func (q *AuditListQuery) Normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	if q.PerPage > MaxPerPage {
+		q.PerPage = MaxPerPage
+	}
+	q.Actor = strings.ToLower(strings.TrimSpace(q.Actor))
+	if _, err := time.ParseInLocation(dateLayout, q.From, time.Local); err != nil {
+		q.From = ""
+	}
+	if _, err := time.ParseInLocation(dateLayout, q.To, time.Local); err != nil {
+		q.To = ""
+	}
+}
+
+// FromTime は From の日の 0:00（指定なしならゼロ値）
+func (q AuditListQuery) FromTime() time.Time {
+	t, err := time.ParseInLocation(dateLayout, q.From, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ToTime は To の翌日の 0:00（To の日を丸ごと含めるため。指定なしならゼロ値）
+func (q AuditListQuery) ToTime() time.Time {
+	t, err := time.ParseInLocation(dateLayout, q.To, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// ClampPage は件数 total を数えた後に、page を最後のページまでに収める
+func (q *AuditListQuery) ClampPage(total int64) {
+	q.Page = ClampPage(q.Page, q.PerPage, total)
+}
+
+// Offset は LIMIT/OFFSET の OFFSET
+func (q AuditListQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// Params はページを移動しても引き継ぐ検索条件
+func (q AuditListQuery) Params() url.Values {
+	params := url.Values{}
+	if q.Actor != "" {
+		params.Set("actor", q.Actor)
+	}
+	if q.TargetID != 0 {
+		params.Set("target_id", strconv.FormatUint(q.TargetID, 10))
+	}
+	if q.From != "" {
+		params.Set("from", q.From)
+	}
+	if q.To != "" {
+		params.Set("to", q.To)
+	}
+	if q.PerPage != DefaultPerPage {
+		params.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	return params
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditListQuery_DateRange(t *testing.T) {
+	q := AuditListQuery{From: "2026-04-01", To: "2026-04-30", Actor: " Admin@Example.com "}
+	q.Normalize()
+
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.Local), q.FromTime())
+	// To の日を丸ごと含めるため、翌日の 0:00 より前を対象にする
+	assert.Equal(t, time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local), q.ToTime())
+	assert.Equal(t, "admin@example.com", q.Actor)
+}
+
+func TestAuditListQuery_InvalidDate(t *testing.T) {
+	q := AuditListQuery{From: "昨日", To: "2026-13-01"}
+	q.Normalize()
+
+	// 読めない日付は無視する
+	assert.Empty(t, q.From)
+	assert.Empty(t, q.To)
+	assert.True(t, q.FromTime().IsZero())
+	assert.True(t, q.ToTime().IsZero())
+}
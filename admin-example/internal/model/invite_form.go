@@ -0,0 +1,17 @@
+package model
+
+// UserInviteForm: ユーザーの招待用。名前はメールアドレスになる（受諾した後に編集画面で変えられる）
+type UserInviteForm struct {
+	Email       string `form:"email" validate:"required,email,max=255" label:"メールアドレス" label_en:"Email"`
+	DisplayName string `form:"display_name" validate:"max=50" label:"表示名" label_en:"Display name"`
+	// 空なら閲覧者
+	Role string `form:"role" validate:"omitempty,oneof=admin editor viewer" label:"役割" label_en:"Role"`
+}
+
+// InvitationAcceptForm: 招待された人がパスワードを決める画面用
+// パスワードの決まり（長さなど）は Service (service/password_policy.go) で確認する
+type InvitationAcceptForm struct {
+	Token           string `form:"token" validate:"required" label:"招待のリンク" label_en:"Invitation link"`
+	Password        string `form:"password" validate:"required" label:"パスワード" label_en:"Password"`
+	PasswordConfirm string `form:"password_confirm" validate:"required,eqfield=Password" label:"パスワード（確認）" label_en:"Password (confirm)"`
+}
@@ -0,0 +1,7 @@
+package model
+
+// LoginForm: ログイン画面用
+type LoginForm struct {
+	Email    string `form:"email" validate:"required,email" label:"メールアドレス" label_en:"Email"`
+	Password string `form:"password" validate:"required" label:"パスワード" label_en:"Password"`
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// 前後に何ページ分のリンクを出すか
+const pagerWindow = 2
+
+// Pager: 一覧画面の下に出すページ送り (views/layout/pager.ace) 用のデータ
+type Pager struct {
+	Page    int
+	PerPage int
+	Total   int64
+
+	// リンク先のパスと、page 以外に引き継ぐクエリ（検索条件や並び順）
+	Path   string
+	Params url.Values
+}
+
+// NewPager はページャーを作る。params には page 以外に引き継ぎたい検索条件を渡す
+func NewPager(path string, page, perPage int, total int64, params url.Values) Pager {
+	if params == nil {
+		params = url.Values{}
+	}
+	return Pager{Page: page, PerPage: perPage, Total: total, Path: path, Params: params}
+}
+
+func (p Pager) TotalPages() int {
+	if p.PerPage < 1 || p.Total == 0 {
+		return 1
+	}
+	return int((p.Total + int64(p.PerPage) - 1) / int64(p.PerPage))
+}
+
+// ClampPage は page を 1〜最後のページに収める
+// 削除で件数が減った後の古いリンクや、大きすぎる page でも、最後のページを出す (OFFSET も溢れない)
+func ClampPage(page, perPage int, total int64) int {
+	return min(max(page, 1), Pager{PerPage: perPage, Total: total}.TotalPages())
+}
+
+func (p Pager) HasPrev() bool { return p.Page > 1 }
+func (p Pager) HasNext() bool { return p.Page < p.TotalPages() }
+func (p Pager) PrevPage() int { return p.Page - 1 }
+func (p Pager) NextPage() int { return p.Page + 1 }
+
+// Pages は現在ページの前後 pagerWindow ページ分の番号
+// (ページが範囲外なら空。ふつうは ClampPage で範囲内にしてから作る)
+func (p Pager) Pages() []int {
+	from := max(1, p.Page-pagerWindow)
+	to := min(p.TotalPages(), p.Page+pagerWindow)
+	if from > to {
+		return nil
+	}
+
+	pages := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		pages = append(pages, i)
+	}
+	return pages
+}
+
+// URL は検索条件を引き継いだまま、指定ページへのURLを返す
+func (p Pager) URL(page int) string {
+	params := url.Values{}
+	for k, v := range p.Params {
+		params[k] = v
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
+	}
+	if len(params) == 0 {
+		return p.Path
+	}
+	return p.Path + "?" + params.Encode()
+}
+
+// SortURL は並び順だけを変えたURLを返す（並び替えたら1ページ目に戻す）
+func (p Pager) SortURL(sort string) string {
+	params := url.Values{}
+	for k, v := range p.Params {
+		params[k] = v
+	}
+	params.Set("sort", sort)
+	return p.Path + "?" + params.Encode()
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserListQuery_Normalize(t *testing.T) {
+	q := UserListQuery{Page: -1, PerPage: 0, Sort: "password", Q: "  太郎 ", Match: "regex"}
+
+	q.Normalize()
+
+	assert.Equal(t, 1, q.Page)
+	assert.Equal(t, DefaultPerPage, q.PerPage)
+	assert.Equal(t, DefaultUserSort, q.Sort) // ホワイトリスト外はデフォルトに戻す
+	assert.Equal(t, "太郎", q.Q)
+	assert.Equal(t, "contains", q.Match)
+}
+
+func TestUserListQuery_NextSort(t *testing.T) {
+	q := UserListQuery{Sort: "name"}
+
+	assert.Equal(t, "-name", q.NextSort("name")) // 同じカラムなら昇順/降順を入れ替え
+	assert.Equal(t, "id", q.NextSort("id"))
+}
+
+func TestPager(t *testing.T) {
+	q := UserListQuery{Page: 5, PerPage: 20, Sort: "name", Q: "a"}
+	q.Normalize()
+
+	p := NewPager("/users", q.Page, q.PerPage, 101, q.Params())
+
+	assert.Equal(t, 6, p.TotalPages())
+	assert.True(t, p.HasPrev())
+	assert.True(t, p.HasNext())
+	assert.Equal(t, []int{3, 4, 5, 6}, p.Pages())
+	// 検索条件を引き継ぎ、1ページ目は page を付けない
+	assert.Equal(t, "/users?match=contains&q=a&sort=name", p.URL(1))
+	assert.Equal(t, "/users?match=contains&page=6&q=a&sort=name", p.URL(6))
+}
+
+func TestPager_Empty(t *testing.T) {
+	q := UserListQuery{Page: 1, PerPage: DefaultPerPage, Sort: DefaultUserSort}
+	p := NewPager("/users", q.Page, q.PerPage, 0, q.Params())
+
+	assert.Equal(t, 1, p.TotalPages())
+	assert.False(t, p.HasNext())
+	assert.Equal(t, "/users", p.URL(1))
+}
+
+func TestPager_OutOfRange(t *testing.T) {
+	// 最後のページより後でも壊れない（番号は出さない）
+	p := NewPager("/users", 1000, 20, 100, nil)
+	assert.Nil(t, p.Pages())
+	assert.False(t, p.HasNext())
+
+	// 件数を数えた後に、最後のページに収める
+	q := UserListQuery{Page: 1000, PerPage: 20}
+	q.ClampPage(100)
+	assert.Equal(t, 5, q.Page)
+	assert.Equal(t, 80, q.Offset())
+	q = UserListQuery{Page: 3, PerPage: 20}
+	q.ClampPage(0)
+	assert.Equal(t, 1, q.Page)
+	assert.Equal(t, []int{1}, NewPager("/users", q.Page, q.PerPage, 0, nil).Pages())
+}
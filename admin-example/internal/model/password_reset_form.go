@@ -0,0 +1,14 @@
+package model
+
+// PasswordForgotForm: パスワードを忘れた時の申し込み用
+type PasswordForgotForm struct {
+	Email string `form:"email" validate:"required,email,max=255" label:"メールアドレス" label_en:"Email"`
+}
+
+// PasswordResetForm: 再設定のリンクから新しいパスワードを決める画面用
+// パスワードの決まり（長さなど）は Service (service/password_policy.go) で確認する
+type PasswordResetForm struct {
+	Token           string `form:"token" validate:"required" label:"再設定のリンク" label_en:"Reset link"`
+	Password        string `form:"password" validate:"required" label:"新しいパスワード" label_en:"New password"`
+	PasswordConfirm string `form:"password_confirm" validate:"required,eqfield=Password" label:"新しいパスワード（確認）" label_en:"New password (confirm)"`
+}
@@ -0,0 +1,79 @@
+package model
+
+// Role: アカウントの役割
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // すべての操作ができる
+	RoleEditor Role = "editor" // ユーザーの閲覧・登録・編集ができる（削除はできない）
+	RoleViewer Role = "viewer" // 閲覧のみ
+)
+
+// Roles: 画面の選択肢に並べる順番
+var Roles = []Role{RoleAdmin, RoleEditor, RoleViewer}
+
+var roleLabels = map[Role]string{
+	RoleAdmin:  "管理者",
+	RoleEditor: "編集者",
+	RoleViewer: "閲覧者",
+}
+
+// Label は画面に表示する名前（知らない値はそのまま）
+func (r Role) Label() string {
+	if l, ok := roleLabels[r]; ok {
+		return l
+	}
+	return string(r)
+}
+
+// Permission: 画面・APIごとに必要な権限
+type Permission string
+
+const (
+	PermUserView   Permission = "user:view"
+	PermUserEdit   Permission = "user:edit"
+	PermUserDelete Permission = "user:delete" // 削除・復元
+	PermAuditView  Permission = "audit:view"  // 監査ログの閲覧
+	// ログイン中のセッションの一覧・強制終了
+	PermSessionManage Permission = "session:manage"
+	// 自分のアカウントの設定（2要素認証など）。どの役割でも使える
+	PermAccountSelf Permission = "account:self"
+)
+
+// 役割ごとに許可する権限の一覧
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:  {PermUserView, PermUserEdit, PermUserDelete, PermAuditView, PermSessionManage, PermAccountSelf},
+	RoleEditor: {PermUserView, PermUserEdit, PermAccountSelf},
+	RoleViewer: {PermUserView, PermAccountSelf},
+}
+
+// Valid は定義済みの役割かどうか
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can はその役割が権限を持っているかどうか
+// テンプレートからも {{if .CurrentRole.Can "user:delete"}} のように使える
+func (r Role) Can(p Permission) bool {
+	for _, allowed := range rolePermissions[r] {
+		if allowed == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanGrant はこの役割のアカウントが、ユーザーに role を付けてよいかどうか
+// 自分の持っていない権限を含む役割は付けられない。閲覧者より上の役割を付けられるのは管理者だけ
+func (r Role) CanGrant(role Role) bool {
+	if !role.Valid() {
+		return false
+	}
+	for _, p := range rolePermissions[role] {
+		if !r.Can(p) {
+			return false
+		}
+	}
+	return role == RoleViewer || r == RoleAdmin
+}
@@ -0,0 +1,7 @@
+package model
+
+// TwoFactorCodeForm: 認証アプリのコード（またはリカバリーコード）を入れる画面用
+// ログインの2段階目と、2要素認証の設定画面で使う
+type TwoFactorCodeForm struct {
+	Code string `form:"code" validate:"required,max=32" label:"確認コード" label_en:"Verification code"`
+}
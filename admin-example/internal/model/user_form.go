@@ -3,12 +3,31 @@ package model
 // UserCreateForm: 新規登録用
 type UserCreateForm struct {
 	// 修正箇所: max=20" (閉じ) + 半角スペース + label
-	Name  string `form:"name" validate:"required,min=3,max=20" label:"名前"`
-	Name1 string `form:"name1" validate:"required,min=3,max=20" label:"名前1"`
+	Name        string `form:"name" json:"name" validate:"required,min=3,max=20" label:"名前" label_en:"Name"`
+	Email       string `form:"email" json:"email" validate:"omitempty,email,max=255" label:"メールアドレス" label_en:"Email"`
+	DisplayName string `form:"display_name" json:"display_name" validate:"max=50" label:"表示名" label_en:"Display name"`
+	// 状態と役割は空なら初期値 (active / viewer)
+	Status string `form:"status" json:"status" validate:"omitempty,oneof=active suspended invited" label:"状態" label_en:"Status"`
+	Role   string `form:"role" json:"role" validate:"omitempty,oneof=admin editor viewer" label:"役割" label_en:"Role"`
+	// アバター画像はファイルなので、Bind ではなく c.FormFile("avatar") で受け取る
 }
 
 // UserUpdateForm: 更新用
 type UserUpdateForm struct {
-	ID   uint64 `form:"id" validate:"required" label:"ID"`
-	Name string `form:"name" validate:"required,min=3,max=20" label:"名前"`
+	ID          uint64 `form:"id" json:"id" validate:"required" label:"ID" label_en:"ID"`
+	Name        string `form:"name" json:"name" validate:"required,min=3,max=20" label:"名前" label_en:"Name"`
+	Email       string `form:"email" json:"email" validate:"omitempty,email,max=255" label:"メールアドレス" label_en:"Email"`
+	DisplayName string `form:"display_name" json:"display_name" validate:"max=50" label:"表示名" label_en:"Display name"`
+	// 状態と役割は空なら今のまま。画像は画面からだけ変更する (RemoveAvatar は API では受け取らない)
+	Status       string `form:"status" json:"status" validate:"omitempty,oneof=active suspended invited" label:"状態" label_en:"Status"`
+	Role         string `form:"role" json:"role" validate:"omitempty,oneof=admin editor viewer" label:"役割" label_en:"Role"`
+	RemoveAvatar bool   `form:"remove_avatar" json:"-" label:"画像を削除" label_en:"Remove image"`
+	// 編集画面を開いた時の version（楽観的ロック）。API でも GET で返した値を送ってもらう
+	Version uint32 `form:"version" json:"version" validate:"required" label:"バージョン" label_en:"Version"`
+}
+
+// UserImportRow: CSV の取り込みの1行。名前の入力チェックは UserCreateForm.Name と同じ
+// (タグを変える時は両方とも変えること。user_form_test.go で確認している)
+type UserImportRow struct {
+	Name string `form:"name" json:"name" validate:"required,min=3,max=20" label:"名前" label_en:"Name"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// CSV の取り込みと新規登録画面で、名前の入力チェックが食い違わないこと
+func TestUserImportRow_SameRulesAsCreateForm(t *testing.T) {
+	create, _ := reflect.TypeOf(UserCreateForm{}).FieldByName("Name")
+	row, _ := reflect.TypeOf(UserImportRow{}).FieldByName("Name")
+
+	assert.Equal(t, create.Tag, row.Tag)
+}
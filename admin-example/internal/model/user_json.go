@@ -0,0 +1,60 @@
+package model
+
+import (
+	"go-example/admin-example/internal/repository"
+	"time"
+)
+
+// UserJSON: JSON API (/api/v1/users) で返すユーザー
+// sql.NullString / sql.NullTime はそのままだと {"String": "...", "Valid": true} になってしまうので、
+// 普通の文字列と時刻（無ければ null）に平らにする
+type UserJSON struct {
+	ID          uint64     `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"` // 無ければ ""
+	DisplayName string     `json:"display_name"`
+	Status      string     `json:"status"`
+	Role        string     `json:"role"`
+	HasAvatar   bool       `json:"has_avatar"` // 画像は GET /users/:id/avatar（画面側）で配信する
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+	Version     uint32     `json:"version"` // 更新 (PUT) の時にそのまま送り返す
+}
+
+// UserListJSON: 一覧APIのレスポンス
+type UserListJSON struct {
+	Users   []UserJSON `json:"users"`
+	Total   int64      `json:"total"`
+	Page    int        `json:"page"`
+	PerPage int        `json:"per_page"`
+}
+
+// NewUserJSON は repository.User を API 用の形に変換する
+func NewUserJSON(u repository.User) UserJSON {
+	res := UserJSON{
+		ID:          u.ID,
+		Name:        u.Name.String,
+		Email:       u.Email.String,
+		DisplayName: u.DisplayName,
+		Status:      u.Status,
+		Role:        u.Role,
+		HasAvatar:   u.AvatarPath != "",
+		Version:     u.Version,
+	}
+	if u.CreatedAt.Valid {
+		res.CreatedAt = &u.CreatedAt.Time
+	}
+	if u.UpdatedAt.Valid {
+		res.UpdatedAt = &u.UpdatedAt.Time
+	}
+	return res
+}
+
+// NewUserListJSON は一覧APIのレスポンスを作る（0件でも users は [] にする）
+func NewUserListJSON(users []repository.User, total int64, q UserListQuery) UserListJSON {
+	list := make([]UserJSON, 0, len(users))
+	for _, u := range users {
+		list = append(list, NewUserJSON(u))
+	}
+	return UserListJSON{Users: list, Total: total, Page: q.Page, PerPage: q.PerPage}
+}
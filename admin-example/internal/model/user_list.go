@@ -0,0 +1,108 @@
+package model
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 一覧のページサイズ
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// DefaultUserSort: 何も指定されていない時の並び順（これまでと同じ「ID の新しい順」）
+const DefaultUserSort = "-id"
+
+// 並び替えに使ってよいカラム（ホワイトリスト）
+// 先頭に "-" を付けると降順になる（例: "-name"）
+var userSortable = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// UserListQuery: 一覧画面 (GET /users?page=&per_page=&sort=&q=&status=) の検索条件
+type UserListQuery struct {
+	Page    int    `query:"page"`
+	PerPage int    `query:"per_page"`
+	Sort    string `query:"sort"`
+	Q       string `query:"q"`
+	// "prefix" なら前方一致、それ以外は部分一致
+	Match string `query:"match"`
+	// 状態 (UserStatus) で絞り込む。空なら全て
+	Status string `query:"status"`
+}
+
+// Normalize は不正な値やおかしな値をデフォルトに丸める
+func (q *UserListQuery) Normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	if q.PerPage > MaxPerPage {
+		q.PerPage = MaxPerPage
+	}
+	if !userSortable[strings.TrimPrefix(q.Sort, "-")] {
+		q.Sort = DefaultUserSort
+	}
+	if q.Match != "prefix" {
+		q.Match = "contains"
+	}
+	q.Q = strings.TrimSpace(q.Q)
+	if !UserStatus(q.Status).Valid() {
+		q.Status = ""
+	}
+}
+
+// SortColumn は並び替えるカラム名（"-" を除いたもの）
+func (q UserListQuery) SortColumn() string {
+	return strings.TrimPrefix(q.Sort, "-")
+}
+
+// SortDesc は降順かどうか
+func (q UserListQuery) SortDesc() bool {
+	return strings.HasPrefix(q.Sort, "-")
+}
+
+// ClampPage は件数 total を数えた後に、page を最後のページまでに収める
+func (q *UserListQuery) ClampPage(total int64) {
+	q.Page = ClampPage(q.Page, q.PerPage, total)
+}
+
+// Offset は LIMIT/OFFSET の OFFSET
+func (q UserListQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// NextSort はカラム見出しをクリックした時の並び順。
+// すでにそのカラムで並んでいれば昇順/降順を入れ替える
+func (q UserListQuery) NextSort(column string) string {
+	if q.SortColumn() == column && !q.SortDesc() {
+		return "-" + column
+	}
+	return column
+}
+
+// Params はページを移動しても引き継ぐ検索条件（デフォルト値のものは省く）
+func (q UserListQuery) Params() url.Values {
+	params := url.Values{}
+	if q.Q != "" {
+		params.Set("q", q.Q)
+		params.Set("match", q.Match)
+	}
+	if q.Status != "" {
+		params.Set("status", q.Status)
+	}
+	if q.Sort != DefaultUserSort {
+		params.Set("sort", q.Sort)
+	}
+	if q.PerPage != DefaultPerPage {
+		params.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	return params
+}
@@ -0,0 +1,34 @@
+package model
+
+// UserStatus: ユーザーの状態
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"    // 利用中
+	UserStatusSuspended UserStatus = "suspended" // 利用停止
+	UserStatusInvited   UserStatus = "invited"   // 招待中（まだ使い始めていない）
+)
+
+// UserStatuses: 画面の選択肢に並べる順番
+var UserStatuses = []UserStatus{UserStatusActive, UserStatusSuspended, UserStatusInvited}
+
+var userStatusLabels = map[UserStatus]string{
+	UserStatusActive:    "利用中",
+	UserStatusSuspended: "利用停止",
+	UserStatusInvited:   "招待中",
+}
+
+// Valid は定義済みの状態かどうか
+func (s UserStatus) Valid() bool {
+	_, ok := userStatusLabels[s]
+	return ok
+}
+
+// Label は画面に表示する名前（知らない値はそのまま）
+// テンプレートからも {{.Label}} で使える
+func (s UserStatus) Label() string {
+	if l, ok := userStatusLabels[s]; ok {
+		return l
+	}
+	return string(s)
+}
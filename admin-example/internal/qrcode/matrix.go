@@ -0,0 +1,249 @@
+package qrcode
+
+// matrix: モジュールを並べている途中の QR コード
+type matrix struct {
+	size     int
+	modules  [][]bool // [y][x]。true が黒
+	function [][]bool // 位置検出パターンなど、データを置かない場所
+}
+
+func newGrid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+// drawFunctionPatterns は位置検出・タイミング・位置合わせパターンと型番情報を描き、形式情報の場所を空けておく
+func (m *matrix) drawFunctionPatterns(ver int, align []int) {
+	// タイミングパターン
+	for i := 0; i < m.size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	// 位置検出パターン (3つの角) と周りの白い分離パターン
+	for _, c := range [][2]int{{3, 3}, {m.size - 4, 3}, {3, m.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= m.size || y >= m.size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				m.setFunction(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// 位置合わせパターン (位置検出パターンと重なるところには置かない)
+	for i, cy := range align {
+		for j, cx := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == len(align)-1) || (i == len(align)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	m.drawFormatBits(0) // 場所を取っておくだけ。マスクを決めてから描き直す
+	m.drawVersionBits(ver)
+}
+
+// formatBits は誤り訂正レベル M とマスク番号の形式情報 (15ビット)
+func formatBits(mask int) int {
+	data := 0<<3 | mask // M は 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+func (m *matrix) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	// 左上
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+
+	// 右上と左下
+	for i := 0; i < 8; i++ {
+		m.setFunction(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, m.size-15+i, bit(i))
+	}
+	m.setFunction(8, m.size-8, true) // 常に黒のモジュール
+}
+
+// versionBits は型番情報 (18ビット)。型番 7 から入れる
+func versionBits(ver int) int {
+	rem := ver
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1f25
+	}
+	return ver<<12 | rem
+}
+
+func (m *matrix) drawVersionBits(ver int) {
+	if ver < 7 {
+		return
+	}
+	bits := versionBits(ver)
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 == 1
+		a, b := m.size-11+i%3, i/3
+		m.setFunction(a, b, dark)
+		m.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords は右下から2列ずつ、上下に折り返しながらコード語のビットを置く
+// 余ったモジュール (残余ビット) は白のまま
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 { // 縦のタイミングパターンの列は飛ばす
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.modules[y][x] = codewords[i>>3]>>uint(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// maskFuncs: マスクパターン 0〜7 (x が列、y が行)
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// applyMask はデータの場所だけ色を反転させる
+func (m *matrix) applyMask(mask int) {
+	f := maskFuncs[mask]
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if !m.function[y][x] && f(x, y) {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty は読み取りにくさの減点 (規格の N1〜N4)
+func (m *matrix) penalty() int {
+	p := 0
+	get := func(x, y int, vertical bool) bool {
+		if vertical {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < m.size; y++ {
+			// N1: 同じ色が5つ以上続く
+			run := 1
+			for x := 1; x < m.size; x++ {
+				if get(x, y, vertical) == get(x-1, y, vertical) {
+					run++
+					if run == 5 {
+						p += 3
+					} else if run > 5 {
+						p++
+					}
+				} else {
+					run = 1
+				}
+			}
+			// N3: 位置検出パターンに似た 1:1:3:1:1 の並びの片側に白が4つ
+			for x := 0; x+11 <= m.size; x++ {
+				if matches(get, x, y, vertical, finderLike1) || matches(get, x, y, vertical, finderLike2) {
+					p += 40
+				}
+			}
+		}
+	}
+
+	// N2: 2x2 が同じ色
+	for y := 0; y < m.size-1; y++ {
+		for x := 0; x < m.size-1; x++ {
+			c := m.modules[y][x]
+			if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+
+	// N4: 黒の割合が 50% から 5% 離れるごとに 10
+	dark := 0
+	for _, row := range m.modules {
+		for _, d := range row {
+			if d {
+				dark++
+			}
+		}
+	}
+	total := m.size * m.size
+	k := abs(dark*20-total*10) / total
+	p += k * 10
+	return p
+}
+
+var (
+	finderLike1 = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderLike2 = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func matches(get func(x, y int, vertical bool) bool, x, y int, vertical bool, pattern []bool) bool {
+	for i, want := range pattern {
+		if get(x+i, y, vertical) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,218 @@
+// Package qrcode は文字列を QR コード (誤り訂正レベル M、バイトモード) にして PNG で返す
+//
+// 2要素認証の登録で、otpauth:// の URI を認証アプリに読ませるためのもの。
+// 外部のサービスに秘密鍵を渡さないよう、画像はプロセスの中で作る。
+// 対応しているのは型番 1〜15（M で 412 バイトまで）
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong: 対応している一番大きい型番にも入らない
+var ErrTooLong = errors.New("QR コードにするには文字列が長すぎます")
+
+// 周りに空ける余白（モジュール数）。規格で4以上と決まっている
+const quietZone = 4
+
+// 型番ごとの誤り訂正レベル M のブロック構成
+type versionInfo struct {
+	ecPerBlock int      // ブロックごとの誤り訂正コード語の数
+	blocks     [][2]int // {ブロック数, ブロックごとのデータコード語の数}
+	align      []int    // 位置合わせパターンの中心
+}
+
+var versions = []versionInfo{
+	1:  {10, [][2]int{{1, 16}}, nil},
+	2:  {16, [][2]int{{1, 28}}, []int{6, 18}},
+	3:  {26, [][2]int{{1, 44}}, []int{6, 22}},
+	4:  {18, [][2]int{{2, 32}}, []int{6, 26}},
+	5:  {24, [][2]int{{2, 43}}, []int{6, 30}},
+	6:  {16, [][2]int{{4, 27}}, []int{6, 34}},
+	7:  {18, [][2]int{{4, 31}}, []int{6, 22, 38}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+	11: {30, [][2]int{{1, 50}, {4, 51}}, []int{6, 30, 54}},
+	12: {22, [][2]int{{6, 36}, {2, 37}}, []int{6, 32, 58}},
+	13: {22, [][2]int{{8, 37}, {1, 38}}, []int{6, 34, 62}},
+	14: {24, [][2]int{{4, 40}, {5, 41}}, []int{6, 26, 46, 66}},
+	15: {24, [][2]int{{5, 41}, {5, 42}}, []int{6, 26, 48, 70}},
+}
+
+func (v versionInfo) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b[0] * b[1]
+	}
+	return n
+}
+
+// Code: 作った QR コード。Dark で各モジュールの色を返す
+type Code struct {
+	version int
+	size    int
+	modules [][]bool // [y][x]。true が黒
+}
+
+// Size は1辺のモジュール数（余白は含まない）
+func (c *Code) Size() int { return c.size }
+
+// Dark は (x, y) のモジュールが黒かどうか
+func (c *Code) Dark(x, y int) bool { return c.modules[y][x] }
+
+// Encode は text を入る中で一番小さい型番の QR コードにする
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for ver := 1; ver < len(versions); ver++ {
+		if bits := 4 + countBits(ver) + len(data)*8; bits <= versions[ver].dataCodewords()*8 {
+			return build(ver, data), nil
+		}
+	}
+	return nil, ErrTooLong
+}
+
+// PNG は text を QR コードの PNG にする。scale は1モジュールのピクセル数
+func PNG(text string, scale int) ([]byte, error) {
+	code, err := Encode(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Image は余白を付けた白黒の画像にする
+func (c *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	n := (c.size + quietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for py := 0; py < n; py++ {
+		for px := 0; px < n; px++ {
+			x, y := px/scale-quietZone, py/scale-quietZone
+			v := color.Gray{Y: 0xff}
+			if x >= 0 && y >= 0 && x < c.size && y < c.size && c.modules[y][x] {
+				v = color.Gray{Y: 0}
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+	return img
+}
+
+// countBits は文字数を書くビット数（バイトモード）
+func countBits(ver int) int {
+	if ver <= 9 {
+		return 8
+	}
+	return 16
+}
+
+func build(ver int, data []byte) *Code {
+	v := versions[ver]
+	codewords := addErrorCorrection(v, encodeData(ver, data))
+
+	size := ver*4 + 17
+	m := &matrix{size: size}
+	m.modules = newGrid(size)
+	m.function = newGrid(size)
+	m.drawFunctionPatterns(ver, v.align)
+	m.drawCodewords(codewords)
+
+	// 一番読みやすい (減点の少ない) マスクを選ぶ
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if p := m.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask) // XOR なのでもう1回かけると元に戻る
+	}
+	m.applyMask(best)
+	m.drawFormatBits(best)
+
+	return &Code{version: ver, size: size, modules: m.modules}
+}
+
+// encodeData はモード・文字数・データを並べ、終端と埋め草を足してデータコード語にする
+func encodeData(ver int, data []byte) []byte {
+	capacity := versions[ver].dataCodewords()
+	var bb bitBuffer
+	bb.append(0x4, 4) // バイトモード
+	bb.append(len(data), countBits(ver))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	// 終端 (最大4ビットの0) を足して、8ビットの区切りまで0で埋める
+	if rest := capacity*8 - len(bb); rest > 4 {
+		bb.append(0, 4)
+	} else {
+		bb.append(0, rest)
+	}
+	for len(bb)%8 != 0 {
+		bb = append(bb, false)
+	}
+
+	out := make([]byte, 0, capacity)
+	for i := 0; i < len(bb); i += 8 {
+		var b byte
+		for _, bit := range bb[i : i+8] {
+			b <<= 1
+			if bit {
+				b |= 1
+			}
+		}
+		out = append(out, b)
+	}
+	for pad := byte(0xec); len(out) < capacity; pad ^= 0xec ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// addErrorCorrection はブロックに分けて誤り訂正コード語を付け、互い違いに並べる
+func addErrorCorrection(v versionInfo, data []byte) []byte {
+	var blocks, ecBlocks [][]byte
+	for _, g := range v.blocks {
+		for i := 0; i < g[0]; i++ {
+			block := data[:g[1]]
+			data = data[g[1]:]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, reedSolomon(block, v.ecPerBlock))
+		}
+	}
+
+	var out []byte
+	maxLen := v.blocks[len(v.blocks)-1][1]
+	for i := 0; i < maxLen; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, v>>uint(i)&1 == 1)
+	}
+}
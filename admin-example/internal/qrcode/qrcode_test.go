@@ -0,0 +1,200 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// 型番 1-M の "HELLO WORLD" (英数字モード) のデータコード語と誤り訂正コード語
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	assert.Equal(t, want, reedSolomon(data, 10))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	// 規格の表の値
+	assert.Equal(t, 0b101010000010010, formatBits(0))
+	assert.Equal(t, 0b100000011001110, formatBits(5))
+	assert.Equal(t, 0b100101010100000, formatBits(7))
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b001010010011010011, versionBits(10))
+}
+
+func TestEncode_Version(t *testing.T) {
+	for _, tt := range []struct {
+		n, version int
+	}{
+		{14, 1}, {15, 2}, {122, 7}, {123, 8}, {412, 15},
+	} {
+		code, err := Encode(strings.Repeat("a", tt.n))
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, code.version, "%d bytes", tt.n)
+		assert.Equal(t, tt.version*4+17, code.Size())
+	}
+
+	_, err := Encode(strings.Repeat("a", 413))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+// 作った QR コードを読み戻して、誤り訂正コードとデータが正しいことを確かめる
+func TestEncode_RoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"https://example.com/",
+		"otpauth://totp/%E7%AE%A1%E7%90%86%E7%94%BB%E9%9D%A2:admin%40example.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=%E7%AE%A1%E7%90%86%E7%94%BB%E9%9D%A2&algorithm=SHA1&digits=6&period=30",
+		strings.Repeat("あ", 100),
+	} {
+		code, err := Encode(text)
+		require.NoError(t, err)
+		assert.Equal(t, text, decode(t, code), "version %d", code.version)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	b, err := PNG("hello", 4)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	require.NoError(t, err)
+	// 型番 1 (21 モジュール) + 余白 4 x 2、1モジュール 4px
+	assert.Equal(t, (21+8)*4, img.Bounds().Dx())
+	// 余白は白、左上の位置検出パターンの角は黒
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	r, _, _, _ = img.At(4*4, 4*4).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
+
+// decode はテスト用の簡単な読み取り（誤りの訂正はしない）
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	ver := (c.size - 17) / 4
+	v := versions[ver]
+
+	// 形式情報 (左上) からマスクを読む
+	var bits int
+	read := func(x, y, i int) {
+		if c.modules[y][x] {
+			bits |= 1 << uint(i)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		read(8, i, i)
+	}
+	read(8, 7, 6)
+	read(8, 8, 7)
+	read(7, 8, 8)
+	for i := 9; i < 15; i++ {
+		read(14-i, 8, i)
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == bits {
+			mask = m
+		}
+	}
+	require.NotEqual(t, -1, mask, "形式情報が読めない")
+
+	// データの場所を同じ手順で求め、マスクを外してコード語を読む
+	m := &matrix{size: c.size, modules: newGrid(c.size), function: newGrid(c.size)}
+	m.drawFunctionPatterns(ver, v.align)
+	total := v.dataCodewords() + v.ecPerBlock*blockCount(v)
+	var stream []bool
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !m.function[y][x] {
+					stream = append(stream, c.modules[y][x] != maskFuncs[mask](x, y))
+				}
+			}
+		}
+	}
+	codewords := make([]byte, total)
+	for i := range codewords {
+		for _, b := range stream[i*8 : i*8+8] {
+			codewords[i] <<= 1
+			if b {
+				codewords[i] |= 1
+			}
+		}
+	}
+
+	// 互い違いの並びをブロックに戻し、誤り訂正コードが合っているか (シンドロームが 0 か) を確かめる
+	var sizes []int
+	for _, g := range v.blocks {
+		for i := 0; i < g[0]; i++ {
+			sizes = append(sizes, g[1])
+		}
+	}
+	blocks := make([][]byte, len(sizes))
+	k := 0
+	for i := 0; i < sizes[len(sizes)-1]; i++ {
+		for b, n := range sizes {
+			if i < n {
+				blocks[b] = append(blocks[b], codewords[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for _, b := range blocks {
+		data = append(data, b...)
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[k])
+			k++
+		}
+	}
+	for b, block := range blocks {
+		for i := 0; i < v.ecPerBlock; i++ {
+			var s byte
+			for _, cw := range block {
+				s = gfMul(s, gfExp[i]) ^ cw
+			}
+			require.Zero(t, s, fmt.Sprintf("ブロック %d のシンドローム %d", b, i))
+		}
+	}
+
+	// モード (バイト) と文字数を読んで、データを取り出す
+	pos := 0
+	next := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(data[pos/8]>>uint(7-pos%8)&1)
+			pos++
+		}
+		return v
+	}
+	require.Equal(t, 0x4, next(4))
+	n := next(countBits(ver))
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(next(8))
+	}
+	return string(out)
+}
+
+func blockCount(v versionInfo) int {
+	n := 0
+	for _, g := range v.blocks {
+		n += g[0]
+	}
+	return n
+}
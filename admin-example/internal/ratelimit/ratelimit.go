@@ -0,0 +1,103 @@
+// Package ratelimit は「同じ相手からの回数」を数えて、多すぎるものを断る
+// (パスワードの再設定の申し込みなど、メールを送る操作を連打させないため)
+//
+// 数はプロセスのメモリに持つので、複数台で動かすと台数分まで通る。再起動すると数え直しになる
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter: key ごとに、直近 window の間に limit 回まで許す（スライディングウィンドウ）
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	hits      map[string][]time.Time // key ごとの、window 内に許した時刻（古い順）
+	lastSweep time.Time
+
+	now func() time.Time // テストで時刻を進めるため差し替えられる
+}
+
+// New は window の間に limit 回まで許す Limiter を作る
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{limit: limit, window: window, hits: map[string][]time.Time{}, now: time.Now}
+}
+
+// Allow は key の回数を1つ数えて、limit 以内なら true を返す
+// 断った分は数えない（断られている間に送り続けても、待つ時間は延びない）
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	hits := l.recent(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// Reserve は Allow と同じく、確かめるのと数えるのを1回で行う（同時に呼ばれても limit 回を超えて通さない）
+// 返した cancel を呼ぶと、数えた1回を取り消す。試してみて失敗した時だけ数えたい時は、
+// 先に Reserve で枠を取っておき、成功したら cancel を呼ぶ
+func (l *Limiter) Reserve(key string) (cancel func(), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	hits := l.recent(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return func() {}, false
+	}
+	l.hits[key] = append(hits, now)
+
+	var once sync.Once
+	return func() { once.Do(func() { l.cancel(key, now) }) }, true
+}
+
+// cancel は Reserve で数えた時刻 at を1つ取り消す（window を過ぎて消えていれば何もしない）
+func (l *Limiter) cancel(key string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hits := l.hits[key]
+	for i := len(hits) - 1; i >= 0; i-- {
+		if hits[i].Equal(at) {
+			l.hits[key] = append(hits[:i:i], hits[i+1:]...)
+			return
+		}
+	}
+}
+
+// recent は key の window 内の時刻だけを返す
+func (l *Limiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(now.Add(-l.window)) {
+		i++
+	}
+	return hits[i:]
+}
+
+// sweep は window に1回、もう数えなくてよい key を消す（メモリが増え続けないように）
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key := range l.hits {
+		if len(l.recent(key, now)) == 0 {
+			delete(l.hits, key)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	l := New(2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.Allow("a"))
+	now = now.Add(10 * time.Minute)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a")) // 3回目は断る
+	assert.True(t, l.Allow("b"))  // key ごとに数える
+
+	// 1回目から1時間たつと、1回分また通る
+	now = now.Add(50 * time.Minute)
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"))
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+
+	// 期限の切れた key は消える
+	assert.Len(t, l.hits, 1)
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	l := New(2, time.Hour)
+	l.now = func() time.Time { return now }
+
+	// 取り消した分は数えない（何度呼んでも1回分だけ）
+	cancel, ok := l.Reserve("a")
+	assert.True(t, ok)
+	cancel()
+	cancel()
+	_, ok = l.Reserve("a")
+	assert.True(t, ok)
+	_, ok = l.Reserve("a")
+	assert.True(t, ok)
+	_, ok = l.Reserve("a")
+	assert.False(t, ok)
+
+	now = now.Add(time.Hour)
+	_, ok = l.Reserve("a")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Reserve_Concurrent(t *testing.T) {
+	l := New(5, time.Hour)
+
+	// 同時に何回呼ばれても、通るのは limit 回まで
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := l.Reserve("a"); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), allowed.Load())
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AccountRepository: 管理画面にログインするアカウント
+type AccountRepository interface {
+	Create(ctx context.Context, email, passwordHash, role string) (uint64, error)
+	FindByID(ctx context.Context, id uint64) (Account, error)
+	FindByEmail(ctx context.Context, email string) (Account, error)
+	// UpdatePassword はパスワードを変え、session_version を +1 する（今までのセッションはログアウト扱いになる）
+	UpdatePassword(ctx context.Context, id uint64, passwordHash string) error
+
+	// パスワードの再設定のリンク (password_reset_repository.go)
+	CreatePasswordReset(ctx context.Context, pr NewPasswordReset) (uint64, error)
+	FindPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error)
+	UsePasswordReset(ctx context.Context, id uint64) error
+	ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error)
+
+	// 2要素認証とリカバリーコード (two_factor_repository.go)
+	SetTOTPSecret(ctx context.Context, id uint64, sealed []byte) error
+	EnableTOTP(ctx context.Context, id uint64, step uint64) error
+	DisableTOTP(ctx context.Context, id uint64) error
+	UseTOTPStep(ctx context.Context, id uint64, step uint64) error
+	ReplaceRecoveryCodes(ctx context.Context, accountID uint64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, accountID uint64, codeHash string) error
+	CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error)
+}
+
+type accountRepository struct {
+	q *Queries
+}
+
+func NewAccountRepository(db DBTX) AccountRepository {
+	return &accountRepository{q: New(db)}
+}
+
+func (r *accountRepository) Create(ctx context.Context, email, passwordHash, role string) (uint64, error) {
+	res, err := r.q.CreateAccount(ctx, CreateAccountParams{
+		Email:        email,
+		PasswordHash: passwordHash,
+		Role:         role,
+	})
+	if err != nil {
+		return 0, translateError(err) // メールアドレスの重複 (uk_accounts_email) は ErrDuplicate
+	}
+	id, err := res.LastInsertId()
+	return uint64(id), err
+}
+
+func (r *accountRepository) FindByID(ctx context.Context, id uint64) (Account, error) {
+	return r.q.GetAccount(ctx, id)
+}
+
+func (r *accountRepository) FindByEmail(ctx context.Context, email string) (Account, error) {
+	return r.q.GetAccountByEmail(ctx, email)
+}
+
+// UpdatePassword はアカウントが無ければ sql.ErrNoRows を返す
+func (r *accountRepository) UpdatePassword(ctx context.Context, id uint64, passwordHash string) error {
+	n, err := r.q.UpdateAccountPassword(ctx, UpdateAccountPasswordParams{PasswordHash: passwordHash, ID: id})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"go-example/admin-example/internal/requestctx"
+)
+
+// AuditEntry: 監査ログ1件分
+// 操作した人とリクエストIDは context (requestctx) から自動で埋める
+type AuditEntry struct {
+	Action     string // 例) user.update
+	TargetType string // 例) user
+	TargetID   uint64
+	Before     interface{} // 変更前の値（JSON にして保存する。nil なら NULL）
+	After      interface{} // 変更後の値（同上）
+}
+
+// AuditRepository: 監査ログの検索
+// 書き込みは変更と同じトランザクションで行う（UserRepository.RecordAudit を参照）
+type AuditRepository interface {
+	Search(ctx context.Context, p AuditSearchParams) ([]AuditLog, error)
+	Count(ctx context.Context, p AuditSearchParams) (int64, error)
+}
+
+type auditRepository struct {
+	q *Queries
+}
+
+func NewAuditRepository(db DBTX) AuditRepository {
+	return &auditRepository{q: New(db)}
+}
+
+func (r *auditRepository) Search(ctx context.Context, p AuditSearchParams) ([]AuditLog, error) {
+	return r.q.SearchAuditLogs(ctx, p)
+}
+
+func (r *auditRepository) Count(ctx context.Context, p AuditSearchParams) (int64, error) {
+	return r.q.CountAuditLogs(ctx, p)
+}
+
+// recordAudit は監査ログを1件書き込む（q にはトランザクション中の Queries を渡す）
+func recordAudit(ctx context.Context, q *Queries, e AuditEntry) error {
+	arg, err := newAuditLogParams(ctx, e)
+	if err != nil {
+		return err
+	}
+	return q.CreateAuditLog(ctx, arg)
+}
+
+// newAuditLogParams は AuditEntry と context から書き込む値を組み立てる（MySQL/メモリ共通）
+func newAuditLogParams(ctx context.Context, e AuditEntry) (CreateAuditLogParams, error) {
+	before, err := marshalAuditValue(e.Before)
+	if err != nil {
+		return CreateAuditLogParams{}, err
+	}
+	after, err := marshalAuditValue(e.After)
+	if err != nil {
+		return CreateAuditLogParams{}, err
+	}
+
+	actor := requestctx.ActorFrom(ctx)
+	return CreateAuditLogParams{
+		ActorID:     actor.ID,
+		ActorEmail:  actor.Email,
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetID:    e.TargetID,
+		BeforeValue: before,
+		AfterValue:  after,
+		RequestID:   requestctx.RequestIDFrom(ctx),
+	}, nil
+}
+
+func marshalAuditValue(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
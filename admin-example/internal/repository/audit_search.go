@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// 監査ログの検索も条件によってSQLが変わるので手書きする (user_search.go と同じ方針)
+
+// AuditSearchParams: 監査ログ検索の条件（ゼロ値の項目は絞り込まない）
+type AuditSearchParams struct {
+	ActorEmail string    // 操作した人
+	TargetID   uint64    // 対象のユーザーID
+	From       time.Time // この日時以降
+	To         time.Time // この日時より前
+	Limit      int
+	Offset     int
+}
+
+const searchAuditLogsSelect = `SELECT id, actor_id, actor_email, action, target_type, target_id, before_value, after_value, request_id, created_at FROM audit_logs`
+
+const countAuditLogsSelect = `SELECT COUNT(*) FROM audit_logs`
+
+func (p AuditSearchParams) where() (string, []interface{}) {
+	clause := " WHERE 1 = 1"
+	var args []interface{}
+	if p.ActorEmail != "" {
+		clause += " AND actor_email = ?"
+		args = append(args, p.ActorEmail)
+	}
+	if p.TargetID != 0 {
+		clause += " AND target_type = 'user' AND target_id = ?"
+		args = append(args, p.TargetID)
+	}
+	if !p.From.IsZero() {
+		clause += " AND created_at >= ?"
+		args = append(args, p.From)
+	}
+	if !p.To.IsZero() {
+		clause += " AND created_at < ?"
+		args = append(args, p.To)
+	}
+	return clause, args
+}
+
+// SearchAuditLogs は条件に合う監査ログを新しい順に1ページ分返す
+func (q *Queries) SearchAuditLogs(ctx context.Context, arg AuditSearchParams) ([]AuditLog, error) {
+	where, args := arg.where()
+	query := searchAuditLogsSelect + where + " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, arg.Limit, arg.Offset)
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorID,
+			&i.ActorEmail,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.BeforeValue,
+			&i.AfterValue,
+			&i.RequestID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CountAuditLogs は条件に合う監査ログの総件数を返す
+func (q *Queries) CountAuditLogs(ctx context.Context, arg AuditSearchParams) (int64, error) {
+	where, args := arg.where()
+	row := q.db.QueryRowContext(ctx, countAuditLogsSelect+where, args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
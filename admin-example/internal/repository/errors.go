@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrDuplicate: ユニーク制約に違反した (MySQL のエラー 1062)。
+// どの制約かは DuplicateKeyError の Key で分かる
+var ErrDuplicate = errors.New("同じ値がすでに登録されています")
+
+// MySQL のエラー番号 (https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html)
+const mysqlErrDupEntry = 1062 // ER_DUP_ENTRY
+
+// DuplicateKeyError: ユニーク制約の違反。errors.Is(err, ErrDuplicate) で判定できる
+type DuplicateKeyError struct {
+	Key string // 制約の名前 (uk_users_active_name など)
+	Err error  // ドライバのエラー
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return ErrDuplicate.Error() + " (" + e.Key + ")"
+}
+
+func (e *DuplicateKeyError) Is(target error) bool { return target == ErrDuplicate }
+func (e *DuplicateKeyError) Unwrap() error        { return e.Err }
+
+// translateError はドライバのエラー (*mysql.MySQLError) を、このパッケージのエラーに変える。
+// Service は MySQL のエラー番号を知らなくてよい（メモリ版のリポジトリでも同じエラーになる）
+func translateError(err error) error {
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) {
+		return err
+	}
+	switch me.Number {
+	case mysqlErrDupEntry:
+		return &DuplicateKeyError{Key: duplicateKeyName(me.Message), Err: err}
+	}
+	return err
+}
+
+// duplicateKeyName はエラーメッセージから制約の名前を取り出す
+// "Duplicate entry 'foo-NULL' for key 'users.uk_users_active_name'" → "uk_users_active_name"
+func duplicateKeyName(msg string) string {
+	_, key, ok := strings.Cut(msg, " for key ")
+	if !ok {
+		return ""
+	}
+	key = strings.Trim(key, "'`")
+	// MySQL 8.0.19 からは "テーブル名.制約名"
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		key = key[i+1:]
+	}
+	return key
+}
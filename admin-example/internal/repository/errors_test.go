@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError_DuplicateEntry(t *testing.T) {
+	driverErr := &mysql.MySQLError{
+		Number:  1062,
+		Message: "Duplicate entry 'taro' for key 'users.uk_users_active_name'",
+	}
+
+	err := translateError(driverErr)
+
+	// Service は MySQL のエラー番号ではなく ErrDuplicate で判定できる
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.ErrorIs(t, err, driverErr)
+	var dup *DuplicateKeyError
+	if assert.ErrorAs(t, err, &dup) {
+		assert.Equal(t, "uk_users_active_name", dup.Key)
+	}
+}
+
+func TestTranslateError_Others(t *testing.T) {
+	// 1062 以外はそのまま
+	lockErr := &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	assert.Same(t, lockErr, translateError(lockErr))
+
+	other := errors.New("接続できません")
+	assert.Equal(t, other, translateError(other))
+	assert.Nil(t, translateError(nil))
+}
+
+func TestDuplicateKeyName(t *testing.T) {
+	// MySQL 8.0.19 より前はテーブル名が付かない
+	assert.Equal(t, "uk_accounts_email", duplicateKeyName("Duplicate entry 'a@example.com' for key 'uk_accounts_email'"))
+	assert.Equal(t, "", duplicateKeyName("Duplicate entry"))
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// HealthRepository: readiness チェック (/readyz) 用にDBの疎通を確認する
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+}
+
+type healthRepository struct {
+	db *sql.DB
+}
+
+func NewHealthRepository(db *sql.DB) HealthRepository {
+	return &healthRepository{db: db}
+}
+
+func (r *healthRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
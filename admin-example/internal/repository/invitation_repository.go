@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go-example/admin-example/internal/requestctx"
+)
+
+// NewInvitation: 作る招待
+// 招待したアカウント (created_by) は監査ログと同じく ctx から取る
+type NewInvitation struct {
+	UserID    uint64
+	Email     string
+	TokenHash string // token.Hash。トークンそのものは保存しない
+	ExpiresAt time.Time
+}
+
+func (r *userRepository) CreateInvitation(ctx context.Context, inv NewInvitation) (uint64, error) {
+	res, err := r.q.CreateUserInvitation(ctx, CreateUserInvitationParams{
+		UserID:    inv.UserID,
+		Email:     inv.Email,
+		TokenHash: inv.TokenHash,
+		ExpiresAt: inv.ExpiresAt,
+		CreatedBy: requestctx.ActorFrom(ctx).ID,
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	id, err := res.LastInsertId()
+	return uint64(id), err
+}
+
+// FindInvitationByTokenHash は見つからなければ sql.ErrNoRows を返す
+// (使用済み・取り消し済みのものも返すので、使えるかどうかは呼び出し側で確認する)
+func (r *userRepository) FindInvitationByTokenHash(ctx context.Context, tokenHash string) (UserInvitation, error) {
+	return r.q.GetUserInvitationByTokenHash(ctx, tokenHash)
+}
+
+// AcceptInvitation は招待を使用済みにする。
+// すでに使用済みか取り消されていたら ErrConflict（同時に受諾された場合も片方はこうなる）
+func (r *userRepository) AcceptInvitation(ctx context.Context, id uint64) error {
+	n, err := r.q.AcceptUserInvitation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// RevokeInvitations はユーザーの使われていない招待を全て取り消し、取り消した件数を返す
+func (r *userRepository) RevokeInvitations(ctx context.Context, userID uint64) (int64, error) {
+	return r.q.RevokeUserInvitations(ctx, userID)
+}
+
+func (r *userRepository) Accounts() AccountRepository {
+	return &accountRepository{q: r.q}
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore: MySQL の代わりに使う、プロセス内だけのデータ置き場 (db.driver: memory)
+// テストやDBの無い環境で動かすためのもので、プロセスが終わるとデータは消える。
+//
+// 振る舞いは schema.sql / query.sql と同じになるようにしている
+//   - 論理削除 (deleted_at)、datetime(3) の精度、ON UPDATE の updated_at
+//   - ロールバックしても AUTO_INCREMENT の番号は戻らない
+//   - 文字列の比較は照合順序 (_ci) と同じく大文字・小文字を区別しない
+type MemoryStore struct {
+	mu sync.Mutex
+
+	users       []User // id の昇順
+	accounts    []Account
+	audits      []AuditLog
+	invitations []UserInvitation
+	resets      []PasswordReset
+	recoveries  []RecoveryCode
+
+	// AUTO_INCREMENT の次の値
+	nextUserID, nextAccountID, nextAuditID, nextInvitationID, nextResetID, nextRecoveryID uint64
+
+	now func() time.Time // テストで時刻を固定するため差し替えられる
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		nextUserID:       1,
+		nextAccountID:    1,
+		nextAuditID:      1,
+		nextInvitationID: 1,
+		nextResetID:      1,
+		nextRecoveryID:   1,
+		now:              time.Now,
+	}
+}
+
+// timestamp は datetime(3) と同じくミリ秒に切り捨てた現在時刻を返す
+func (s *MemoryStore) timestamp() time.Time {
+	return s.now().Truncate(time.Millisecond)
+}
+
+// lock はトランザクションの外なら排他ロックを取る。
+// トランザクション中は RunInTx がロックを持ったままなので何もしない
+// (RunInTx の fn の中で、トランザクション外のリポジトリを使うと止まってしまうので注意)
+func (s *MemoryStore) lock(inTx bool) func() {
+	if inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// memorySnapshot: ロールバック用に、トランザクション開始時点のデータを覚えておく
+type memorySnapshot struct {
+	users       []User
+	accounts    []Account
+	audits      []AuditLog
+	invitations []UserInvitation
+	resets      []PasswordReset
+	recoveries  []RecoveryCode
+}
+
+func (s *MemoryStore) snapshot() memorySnapshot {
+	return memorySnapshot{
+		users:       append([]User(nil), s.users...),
+		accounts:    append([]Account(nil), s.accounts...),
+		audits:      append([]AuditLog(nil), s.audits...),
+		invitations: append([]UserInvitation(nil), s.invitations...),
+		resets:      append([]PasswordReset(nil), s.resets...),
+		recoveries:  append([]RecoveryCode(nil), s.recoveries...),
+	}
+}
+
+func (s *MemoryStore) restore(snap memorySnapshot) {
+	s.users = snap.users
+	s.accounts = snap.accounts
+	s.audits = snap.audits
+	s.invitations = snap.invitations
+	s.resets = snap.resets
+	s.recoveries = snap.recoveries
+}
+
+// memoryTxManager: MemoryStore 用の TxManager
+// トランザクション中はストア全体をロックする（同時に実行できるトランザクションは1つだけ）
+type memoryTxManager struct {
+	s *MemoryStore
+}
+
+func NewMemoryTxManager(s *MemoryStore) TxManager {
+	return &memoryTxManager{s: s}
+}
+
+func (m *memoryTxManager) RunInTx(ctx context.Context, fn func(ctx context.Context, repo UserRepository) error) error {
+	if InTx(ctx) {
+		return ErrNestedTx
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	snap := m.s.snapshot()
+
+	// panic した時も途中までの変更を残さない
+	defer func() {
+		if p := recover(); p != nil {
+			m.s.restore(snap)
+			panic(p)
+		}
+	}()
+
+	repo := &memoryUserRepository{s: m.s, inTx: true}
+	if err := fn(context.WithValue(ctx, txKey{}, true), repo); err != nil {
+		m.s.restore(snap)
+		return err
+	}
+	return nil
+}
+
+// memoryHealthRepository: メモリなので常に「つながっている」
+type memoryHealthRepository struct{}
+
+func NewMemoryHealthRepository() HealthRepository {
+	return memoryHealthRepository{}
+}
+
+func (memoryHealthRepository) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// memoryResult: CreateUser (:execresult) が返す sql.Result の代わり
+type memoryResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r memoryResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r memoryResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
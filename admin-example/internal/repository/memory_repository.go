@@ -0,0 +1,715 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-example/admin-example/internal/requestctx"
+)
+
+// MemoryStore を使ったリポジトリの実装
+// どれも MySQL 版と同じ結果になること (user_repository_test.go の共通テスト) を確認している
+
+// --- users ---
+
+type memoryUserRepository struct {
+	s    *MemoryStore
+	inTx bool // memoryTxManager.RunInTx から渡されたもの
+}
+
+func NewMemoryUserRepository(s *MemoryStore) UserRepository {
+	return &memoryUserRepository{s: s}
+}
+
+// find は id のユーザーの位置を返す（見つからなければ -1）
+func (r *memoryUserRepository) find(id uint64) int {
+	for i := range r.s.users {
+		if r.s.users[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, p UserProfile) (sql.Result, error) {
+	defer r.s.lock(r.inTx)()
+
+	// name は NOT NULL なので、空文字 (NULL で渡る) は MySQL でもエラーになる
+	if p.Name == "" {
+		return nil, errors.New("users.name は空にできません")
+	}
+	if err := r.checkUnique(0, p.Name, p.Email); err != nil {
+		return nil, err
+	}
+
+	now := r.s.timestamp()
+	id := r.s.nextUserID
+	r.s.nextUserID++
+	u := User{
+		ID:        id,
+		CreatedAt: sql.NullTime{Time: now, Valid: true},
+		UpdatedAt: sql.NullTime{Time: now, Valid: true},
+		Version:   1,
+	}
+	setProfile(&u, p)
+	r.s.users = append(r.s.users, u)
+	return memoryResult{lastInsertID: int64(id), rowsAffected: 1}, nil
+}
+
+// checkUnique は users のユニーク制約 (uk_users_active_name / uk_users_active_email) を再現する。
+// 削除済みのユーザーと、更新する本人 (id) は比べない。MySQL の照合順序と同じく大文字・小文字は区別しない
+func (r *memoryUserRepository) checkUnique(id uint64, name, email string) error {
+	for _, u := range r.s.users {
+		if u.ID == id || u.DeletedAt.Valid {
+			continue
+		}
+		if strings.EqualFold(u.Name.String, name) {
+			return &DuplicateKeyError{Key: "uk_users_active_name", Err: fmt.Errorf("Duplicate entry '%s' for key 'users.uk_users_active_name'", name)}
+		}
+		// メール無し (NULL) 同士は重複にならない
+		if email != "" && u.Email.Valid && strings.EqualFold(u.Email.String, email) {
+			return &DuplicateKeyError{Key: "uk_users_active_email", Err: fmt.Errorf("Duplicate entry '%s' for key 'users.uk_users_active_email'", email)}
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id uint64) (User, error) {
+	defer r.s.lock(r.inTx)()
+
+	i := r.find(id)
+	if i < 0 || r.s.users[i].DeletedAt.Valid {
+		return User{}, sql.ErrNoRows
+	}
+	return r.s.users[i], nil
+}
+
+// Update は UpdateUser と同じく、version が一致する時だけ更新する
+func (r *memoryUserRepository) Update(ctx context.Context, id uint64, p UserProfile, version uint32) error {
+	defer r.s.lock(r.inTx)()
+
+	if p.Name == "" {
+		return errors.New("users.name は空にできません")
+	}
+	i := r.find(id)
+	if i < 0 || r.s.users[i].DeletedAt.Valid || r.s.users[i].Version != version {
+		return ErrConflict
+	}
+	if err := r.checkUnique(id, p.Name, p.Email); err != nil {
+		return err
+	}
+	u := &r.s.users[i]
+	u.Version++
+	// version は必ず変わるので、ON UPDATE CURRENT_TIMESTAMP の updated_at も更新される
+	setProfile(u, p)
+	u.UpdatedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+	return nil
+}
+
+// setProfile は CreateUser / UpdateUser と同じく、p の値を全て書き込む
+func setProfile(u *User, p UserProfile) {
+	u.Name = nullString(p.Name)
+	u.Email = nullString(p.Email)
+	u.DisplayName = p.DisplayName
+	u.Status = p.Status
+	u.Role = p.Role
+	u.AvatarPath = p.AvatarPath
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	i := r.find(id)
+	if i < 0 || r.s.users[i].DeletedAt.Valid {
+		return sql.ErrNoRows
+	}
+	now := sql.NullTime{Time: r.s.timestamp(), Valid: true}
+	r.s.users[i].DeletedAt = now
+	r.s.users[i].UpdatedAt = now
+	return nil
+}
+
+// List は ListUsers と同じく、有効なユーザーを ID の新しい順に返す
+func (r *memoryUserRepository) List(ctx context.Context) ([]User, error) {
+	defer r.s.lock(r.inTx)()
+
+	var items []User
+	for i := len(r.s.users) - 1; i >= 0; i-- {
+		if !r.s.users[i].DeletedAt.Valid {
+			items = append(items, r.s.users[i])
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryUserRepository) Search(ctx context.Context, p UserSearchParams) ([]User, error) {
+	defer r.s.lock(r.inTx)()
+
+	items := r.match(p)
+	sortUsers(items, p)
+
+	// LIMIT / OFFSET
+	if p.Offset >= len(items) {
+		return nil, nil
+	}
+	items = items[p.Offset:]
+	if p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items, nil
+}
+
+func (r *memoryUserRepository) Count(ctx context.Context, p UserSearchParams) (int64, error) {
+	defer r.s.lock(r.inTx)()
+
+	return int64(len(r.match(p))), nil
+}
+
+// match は UserSearchParams.where と同じ条件で絞り込む
+func (r *memoryUserRepository) match(p UserSearchParams) []User {
+	name := strings.ToLower(p.Name)
+	var items []User
+	for _, u := range r.s.users {
+		if u.DeletedAt.Valid {
+			continue
+		}
+		if name != "" && !matchText(name, p.Prefix, u.Name.String, u.DisplayName, u.Email.String) {
+			continue
+		}
+		if p.Status != "" && u.Status != p.Status {
+			continue
+		}
+		items = append(items, u)
+	}
+	return items
+}
+
+// matchText は LIKE と同じく、values のどれかが検索文字列 (小文字にしたもの) を含むかを返す
+func matchText(lower string, prefix bool, values ...string) bool {
+	for _, v := range values {
+		v = strings.ToLower(v)
+		if prefix && strings.HasPrefix(v, lower) || !prefix && strings.Contains(v, lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortUsers は UserSearchParams.orderBy と同じ順に並べる
+func sortUsers(items []User, p UserSearchParams) {
+	column, ok := userSortColumns[p.SortColumn]
+	desc := p.Desc
+	if !ok {
+		column, desc = "id", true
+	}
+
+	// a が b より前に来るか（昇順で比べる。同じ値なら id を第2キーにする）
+	less := func(a, b User) bool {
+		switch column {
+		case "name":
+			if x, y := strings.ToLower(a.Name.String), strings.ToLower(b.Name.String); x != y {
+				return x < y
+			}
+		case "created_at":
+			if !a.CreatedAt.Time.Equal(b.CreatedAt.Time) {
+				return a.CreatedAt.Time.Before(b.CreatedAt.Time)
+			}
+		case "updated_at":
+			if !a.UpdatedAt.Time.Equal(b.UpdatedAt.Time) {
+				return a.UpdatedAt.Time.Before(b.UpdatedAt.Time)
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if desc {
+			return less(items[j], items[i])
+		}
+		return less(items[i], items[j])
+	})
+}
+
+// ListDeleted は ListDeletedUsers と同じく、削除した日時の新しい順に返す
+func (r *memoryUserRepository) ListDeleted(ctx context.Context, limit, offset int) ([]User, error) {
+	defer r.s.lock(r.inTx)()
+
+	items := r.deleted()
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.DeletedAt.Time.Equal(b.DeletedAt.Time) {
+			return a.DeletedAt.Time.After(b.DeletedAt.Time)
+		}
+		return a.ID > b.ID
+	})
+
+	// LIMIT / OFFSET
+	if offset >= len(items) {
+		return nil, nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (r *memoryUserRepository) CountDeleted(ctx context.Context) (int64, error) {
+	defer r.s.lock(r.inTx)()
+
+	return int64(len(r.deleted())), nil
+}
+
+// deleted は論理削除済みのユーザーを登録順に返す
+func (r *memoryUserRepository) deleted() []User {
+	var items []User
+	for _, u := range r.s.users {
+		if u.DeletedAt.Valid {
+			items = append(items, u)
+		}
+	}
+	return items
+}
+
+func (r *memoryUserRepository) FindDeletedByID(ctx context.Context, id uint64) (User, error) {
+	defer r.s.lock(r.inTx)()
+
+	i := r.find(id)
+	if i < 0 || !r.s.users[i].DeletedAt.Valid {
+		return User{}, sql.ErrNoRows
+	}
+	return r.s.users[i], nil
+}
+
+func (r *memoryUserRepository) ExistsActiveName(ctx context.Context, name string) (bool, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, u := range r.s.users {
+		if !u.DeletedAt.Valid && strings.EqualFold(u.Name.String, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) ExistsActiveEmail(ctx context.Context, email string) (bool, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, u := range r.s.users {
+		if !u.DeletedAt.Valid && u.Email.Valid && strings.EqualFold(u.Email.String, email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	i := r.find(id)
+	if i < 0 || !r.s.users[i].DeletedAt.Valid {
+		return sql.ErrNoRows
+	}
+	if err := r.checkUnique(id, r.s.users[i].Name.String, r.s.users[i].Email.String); err != nil {
+		return err
+	}
+	r.s.users[i].DeletedAt = sql.NullTime{}
+	r.s.users[i].UpdatedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+	return nil
+}
+
+func (r *memoryUserRepository) RecordAudit(ctx context.Context, e AuditEntry) error {
+	arg, err := newAuditLogParams(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	defer r.s.lock(r.inTx)()
+	r.s.audits = append(r.s.audits, AuditLog{
+		ID:          r.s.nextAuditID,
+		ActorID:     arg.ActorID,
+		ActorEmail:  arg.ActorEmail,
+		Action:      arg.Action,
+		TargetType:  arg.TargetType,
+		TargetID:    arg.TargetID,
+		BeforeValue: arg.BeforeValue,
+		AfterValue:  arg.AfterValue,
+		RequestID:   arg.RequestID,
+		CreatedAt:   r.s.timestamp(),
+	})
+	r.s.nextAuditID++
+	return nil
+}
+
+// --- user_invitations ---
+
+func (r *memoryUserRepository) CreateInvitation(ctx context.Context, inv NewInvitation) (uint64, error) {
+	defer r.s.lock(r.inTx)()
+
+	// uk_user_invitations_token_hash
+	for _, i := range r.s.invitations {
+		if i.TokenHash == inv.TokenHash {
+			return 0, &DuplicateKeyError{Key: "uk_user_invitations_token_hash", Err: errors.New("同じトークンの招待があります")}
+		}
+	}
+
+	id := r.s.nextInvitationID
+	r.s.nextInvitationID++
+	r.s.invitations = append(r.s.invitations, UserInvitation{
+		ID:        id,
+		UserID:    inv.UserID,
+		Email:     inv.Email,
+		TokenHash: inv.TokenHash,
+		ExpiresAt: inv.ExpiresAt.Truncate(time.Millisecond),
+		CreatedBy: requestctx.ActorFrom(ctx).ID,
+		CreatedAt: r.s.timestamp(),
+	})
+	return id, nil
+}
+
+func (r *memoryUserRepository) FindInvitationByTokenHash(ctx context.Context, tokenHash string) (UserInvitation, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, i := range r.s.invitations {
+		if i.TokenHash == tokenHash {
+			return i, nil
+		}
+	}
+	return UserInvitation{}, sql.ErrNoRows
+}
+
+func (r *memoryUserRepository) AcceptInvitation(ctx context.Context, id uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	for i := range r.s.invitations {
+		inv := &r.s.invitations[i]
+		if inv.ID == id && !inv.AcceptedAt.Valid && !inv.RevokedAt.Valid {
+			inv.AcceptedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (r *memoryUserRepository) RevokeInvitations(ctx context.Context, userID uint64) (int64, error) {
+	defer r.s.lock(r.inTx)()
+
+	var n int64
+	for i := range r.s.invitations {
+		inv := &r.s.invitations[i]
+		if inv.UserID == userID && !inv.AcceptedAt.Valid && !inv.RevokedAt.Valid {
+			inv.RevokedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryUserRepository) Accounts() AccountRepository {
+	return &memoryAccountRepository{s: r.s, inTx: r.inTx}
+}
+
+// --- accounts ---
+
+type memoryAccountRepository struct {
+	s    *MemoryStore
+	inTx bool // memoryUserRepository.Accounts から作られたもの
+}
+
+func NewMemoryAccountRepository(s *MemoryStore) AccountRepository {
+	return &memoryAccountRepository{s: s}
+}
+
+func (r *memoryAccountRepository) Create(ctx context.Context, email, passwordHash, role string) (uint64, error) {
+	defer r.s.lock(r.inTx)()
+
+	// uk_accounts_email と同じく、メールアドレスの重複は弾く
+	for _, a := range r.s.accounts {
+		if strings.EqualFold(a.Email, email) {
+			return 0, &DuplicateKeyError{
+				Key: "uk_accounts_email",
+				Err: fmt.Errorf("メールアドレス %s は既に登録されています", email),
+			}
+		}
+	}
+
+	now := r.s.timestamp()
+	id := r.s.nextAccountID
+	r.s.nextAccountID++
+	r.s.accounts = append(r.s.accounts, Account{
+		ID:             id,
+		Email:          email,
+		PasswordHash:   passwordHash,
+		Role:           role,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		SessionVersion: 1,
+	})
+	return id, nil
+}
+
+func (r *memoryAccountRepository) FindByID(ctx context.Context, id uint64) (Account, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, a := range r.s.accounts {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return Account{}, sql.ErrNoRows
+}
+
+func (r *memoryAccountRepository) FindByEmail(ctx context.Context, email string) (Account, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, a := range r.s.accounts {
+		if strings.EqualFold(a.Email, email) {
+			return a, nil
+		}
+	}
+	return Account{}, sql.ErrNoRows
+}
+
+func (r *memoryAccountRepository) UpdatePassword(ctx context.Context, id uint64, passwordHash string) error {
+	defer r.s.lock(r.inTx)()
+
+	for i := range r.s.accounts {
+		a := &r.s.accounts[i]
+		if a.ID == id {
+			a.PasswordHash = passwordHash
+			a.SessionVersion++
+			a.UpdatedAt = r.s.timestamp()
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// --- password_resets ---
+
+func (r *memoryAccountRepository) CreatePasswordReset(ctx context.Context, pr NewPasswordReset) (uint64, error) {
+	defer r.s.lock(r.inTx)()
+
+	// uk_password_resets_token_hash
+	for _, p := range r.s.resets {
+		if p.TokenHash == pr.TokenHash {
+			return 0, &DuplicateKeyError{Key: "uk_password_resets_token_hash", Err: errors.New("同じトークンのリンクがあります")}
+		}
+	}
+
+	id := r.s.nextResetID
+	r.s.nextResetID++
+	r.s.resets = append(r.s.resets, PasswordReset{
+		ID:          id,
+		AccountID:   pr.AccountID,
+		TokenHash:   pr.TokenHash,
+		ExpiresAt:   pr.ExpiresAt.Truncate(time.Millisecond),
+		RequestedIp: pr.RequestedIP,
+		CreatedAt:   r.s.timestamp(),
+	})
+	return id, nil
+}
+
+func (r *memoryAccountRepository) FindPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	defer r.s.lock(r.inTx)()
+
+	for _, p := range r.s.resets {
+		if p.TokenHash == tokenHash {
+			return p, nil
+		}
+	}
+	return PasswordReset{}, sql.ErrNoRows
+}
+
+func (r *memoryAccountRepository) UsePasswordReset(ctx context.Context, id uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	for i := range r.s.resets {
+		p := &r.s.resets[i]
+		if p.ID == id && !p.UsedAt.Valid {
+			p.UsedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+func (r *memoryAccountRepository) ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error) {
+	defer r.s.lock(r.inTx)()
+
+	var n int64
+	for i := range r.s.resets {
+		p := &r.s.resets[i]
+		if p.AccountID == accountID && !p.UsedAt.Valid {
+			p.UsedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// --- 2要素認証 (accounts の totp_* と recovery_codes) ---
+
+// account はアカウントを探す（ロックは呼び出し側で取る）
+func (r *memoryAccountRepository) account(id uint64) *Account {
+	for i := range r.s.accounts {
+		if r.s.accounts[i].ID == id {
+			return &r.s.accounts[i]
+		}
+	}
+	return nil
+}
+
+func (r *memoryAccountRepository) SetTOTPSecret(ctx context.Context, id uint64, sealed []byte) error {
+	defer r.s.lock(r.inTx)()
+
+	a := r.account(id)
+	if a == nil || a.TotpEnabledAt.Valid {
+		return ErrConflict
+	}
+	a.TotpSecret = append([]byte(nil), sealed...)
+	a.TotpLastStep = 0
+	a.UpdatedAt = r.s.timestamp()
+	return nil
+}
+
+func (r *memoryAccountRepository) EnableTOTP(ctx context.Context, id uint64, step uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	a := r.account(id)
+	if a == nil || a.TotpSecret == nil || a.TotpEnabledAt.Valid {
+		return ErrConflict
+	}
+	a.TotpEnabledAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+	a.TotpLastStep = step
+	a.UpdatedAt = r.s.timestamp()
+	return nil
+}
+
+func (r *memoryAccountRepository) DisableTOTP(ctx context.Context, id uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	if a := r.account(id); a != nil {
+		a.TotpSecret = nil
+		a.TotpEnabledAt = sql.NullTime{}
+		a.TotpLastStep = 0
+		a.UpdatedAt = r.s.timestamp()
+	}
+	return nil
+}
+
+func (r *memoryAccountRepository) UseTOTPStep(ctx context.Context, id uint64, step uint64) error {
+	defer r.s.lock(r.inTx)()
+
+	a := r.account(id)
+	if a == nil || !a.TotpEnabledAt.Valid || a.TotpLastStep >= step {
+		return ErrConflict
+	}
+	a.TotpLastStep = step
+	a.UpdatedAt = r.s.timestamp()
+	return nil
+}
+
+func (r *memoryAccountRepository) ReplaceRecoveryCodes(ctx context.Context, accountID uint64, codeHashes []string) error {
+	defer r.s.lock(r.inTx)()
+
+	kept := r.s.recoveries[:0:0]
+	for _, c := range r.s.recoveries {
+		if c.AccountID != accountID {
+			kept = append(kept, c)
+		}
+	}
+	for _, h := range codeHashes {
+		kept = append(kept, RecoveryCode{
+			ID:        r.s.nextRecoveryID,
+			AccountID: accountID,
+			CodeHash:  h,
+			CreatedAt: r.s.timestamp(),
+		})
+		r.s.nextRecoveryID++
+	}
+	r.s.recoveries = kept
+	return nil
+}
+
+func (r *memoryAccountRepository) UseRecoveryCode(ctx context.Context, accountID uint64, codeHash string) error {
+	defer r.s.lock(r.inTx)()
+
+	for i := range r.s.recoveries {
+		c := &r.s.recoveries[i]
+		if c.AccountID == accountID && c.CodeHash == codeHash && !c.UsedAt.Valid {
+			c.UsedAt = sql.NullTime{Time: r.s.timestamp(), Valid: true}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (r *memoryAccountRepository) CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error) {
+	defer r.s.lock(r.inTx)()
+
+	var n int64
+	for _, c := range r.s.recoveries {
+		if c.AccountID == accountID && !c.UsedAt.Valid {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// --- audit_logs ---
+
+type memoryAuditRepository struct {
+	s *MemoryStore
+}
+
+func NewMemoryAuditRepository(s *MemoryStore) AuditRepository {
+	return &memoryAuditRepository{s: s}
+}
+
+// Search は SearchAuditLogs と同じく、新しい順に1ページ分返す
+func (r *memoryAuditRepository) Search(ctx context.Context, p AuditSearchParams) ([]AuditLog, error) {
+	defer r.s.lock(false)()
+
+	items := r.match(p)
+	if p.Offset >= len(items) {
+		return nil, nil
+	}
+	items = items[p.Offset:]
+	if p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items, nil
+}
+
+func (r *memoryAuditRepository) Count(ctx context.Context, p AuditSearchParams) (int64, error) {
+	defer r.s.lock(false)()
+
+	return int64(len(r.match(p))), nil
+}
+
+// match は AuditSearchParams.where と同じ条件で絞り込み、id の新しい順に返す
+func (r *memoryAuditRepository) match(p AuditSearchParams) []AuditLog {
+	var items []AuditLog
+	for i := len(r.s.audits) - 1; i >= 0; i-- {
+		l := r.s.audits[i]
+		if p.ActorEmail != "" && !strings.EqualFold(l.ActorEmail, p.ActorEmail) {
+			continue
+		}
+		if p.TargetID != 0 && (l.TargetType != "user" || l.TargetID != p.TargetID) {
+			continue
+		}
+		if !p.From.IsZero() && l.CreatedAt.Before(p.From) {
+			continue
+		}
+		if !p.To.IsZero() && !l.CreatedAt.Before(p.To) {
+			continue
+		}
+		items = append(items, l)
+	}
+	return items
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 共通の振る舞いは user_repository_test.go で確認している。ここではメモリ版だけの細かい所を見る
+
+func TestMemoryTxManager_RollbackOnPanic(t *testing.T) {
+	s := NewMemoryStore()
+	tm := NewMemoryTxManager(s)
+
+	assert.Panics(t, func() {
+		tm.RunInTx(context.Background(), func(ctx context.Context, repo UserRepository) error {
+			repo.Create(ctx, UserProfile{Name: "panic"})
+			panic("boom")
+		})
+	})
+
+	// 変更は残らず、ロックも解放されている
+	users, err := NewMemoryUserRepository(s).List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestMemoryUserRepository_AutoIncrement(t *testing.T) {
+	s := NewMemoryStore()
+	repo := NewMemoryUserRepository(s)
+	ctx := context.Background()
+
+	mustCreate(t, repo, "first")
+	NewMemoryTxManager(s).RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+		mustCreate(t, repo, "rolled back")
+		return assert.AnError
+	})
+
+	// AUTO_INCREMENT と同じく、ロールバックしても番号は戻らない
+	assert.Equal(t, uint64(3), mustCreate(t, repo, "third"))
+}
+
+func TestMemoryUserRepository_Timestamps(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	s.now = func() time.Time { return now }
+	repo := NewMemoryUserRepository(s)
+	ctx := context.Background()
+
+	id := mustCreate(t, repo, "taro")
+	user, _ := repo.FindByID(ctx, id)
+	// datetime(3) と同じくミリ秒まで
+	assert.Equal(t, now.Truncate(time.Millisecond), user.CreatedAt.Time)
+
+	// 更新すると version が上がるので、updated_at も必ず変わる (ON UPDATE と同じ)
+	now = now.Add(time.Minute)
+	assert.NoError(t, repo.Update(ctx, id, ProfileOf(user), user.Version))
+	user, _ = repo.FindByID(ctx, id)
+	assert.Equal(t, uint32(2), user.Version)
+	assert.True(t, user.UpdatedAt.Time.After(user.CreatedAt.Time))
+}
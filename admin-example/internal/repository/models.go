@@ -6,12 +6,87 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+type Account struct {
+	ID             uint64       `json:"id"`
+	Email          string       `json:"email"`
+	PasswordHash   string       `json:"password_hash"`
+	Role           string       `json:"role"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	SessionVersion uint32       `json:"session_version"`
+	TotpSecret     []byte       `json:"totp_secret"`
+	TotpEnabledAt  sql.NullTime `json:"totp_enabled_at"`
+	TotpLastStep   uint64       `json:"totp_last_step"`
+}
+
+type AuditLog struct {
+	ID          uint64          `json:"id"`
+	ActorID     uint64          `json:"actor_id"`
+	ActorEmail  string          `json:"actor_email"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    uint64          `json:"target_id"`
+	BeforeValue json.RawMessage `json:"before_value"`
+	AfterValue  json.RawMessage `json:"after_value"`
+	RequestID   string          `json:"request_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type PasswordReset struct {
+	ID          uint64       `json:"id"`
+	AccountID   uint64       `json:"account_id"`
+	TokenHash   string       `json:"token_hash"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+	UsedAt      sql.NullTime `json:"used_at"`
+	RequestedIp string       `json:"requested_ip"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type RecoveryCode struct {
+	ID        uint64       `json:"id"`
+	AccountID uint64       `json:"account_id"`
+	CodeHash  string       `json:"code_hash"`
+	UsedAt    sql.NullTime `json:"used_at"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type Session struct {
+	ID        string    `json:"id"`
+	AccountID uint64    `json:"account_id"`
+	Data      []byte    `json:"data"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 type User struct {
-	ID        uint64         `json:"id"`
-	Name      sql.NullString `json:"name"`
-	CreatedAt sql.NullTime   `json:"created_at"`
-	UpdatedAt sql.NullTime   `json:"updated_at"`
-	DeletedAt sql.NullTime   `json:"deleted_at"`
+	ID          uint64         `json:"id"`
+	Name        sql.NullString `json:"name"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+	UpdatedAt   sql.NullTime   `json:"updated_at"`
+	DeletedAt   sql.NullTime   `json:"deleted_at"`
+	Version     uint32         `json:"version"`
+	Email       sql.NullString `json:"email"`
+	DisplayName string         `json:"display_name"`
+	Status      string         `json:"status"`
+	Role        string         `json:"role"`
+	AvatarPath  string         `json:"avatar_path"`
+}
+
+type UserInvitation struct {
+	ID         uint64       `json:"id"`
+	UserID     uint64       `json:"user_id"`
+	Email      string       `json:"email"`
+	TokenHash  string       `json:"token_hash"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	AcceptedAt sql.NullTime `json:"accepted_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+	CreatedBy  uint64       `json:"created_by"`
+	CreatedAt  time.Time    `json:"created_at"`
 }
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// NewPasswordReset: 作るパスワードの再設定のリンク
+type NewPasswordReset struct {
+	AccountID   uint64
+	TokenHash   string // token.Hash。トークンそのものは保存しない
+	ExpiresAt   time.Time
+	RequestedIP string // 申し込んだ IP アドレス（調査用）
+}
+
+func (r *accountRepository) CreatePasswordReset(ctx context.Context, pr NewPasswordReset) (uint64, error) {
+	res, err := r.q.CreatePasswordReset(ctx, CreatePasswordResetParams{
+		AccountID:   pr.AccountID,
+		TokenHash:   pr.TokenHash,
+		ExpiresAt:   pr.ExpiresAt,
+		RequestedIp: pr.RequestedIP,
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	id, err := res.LastInsertId()
+	return uint64(id), err
+}
+
+// FindPasswordResetByTokenHash は見つからなければ sql.ErrNoRows を返す
+// (使用済みのものも返すので、使えるかどうかは呼び出し側で確認する)
+func (r *accountRepository) FindPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	return r.q.GetPasswordResetByTokenHash(ctx, tokenHash)
+}
+
+// UsePasswordReset はリンクを使用済みにする。
+// すでに使用済みなら ErrConflict（同時に使われた場合も片方はこうなる）
+func (r *accountRepository) UsePasswordReset(ctx context.Context, id uint64) error {
+	n, err := r.q.UsePasswordReset(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// ExpirePasswordResets はアカウントの使われていないリンクを全て使えなくし、その件数を返す
+func (r *accountRepository) ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error) {
+	return r.q.ExpirePasswordResets(ctx, accountID)
+}
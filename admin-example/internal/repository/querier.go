@@ -7,15 +7,74 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Querier interface {
-	CreateUser(ctx context.Context, name sql.NullString) (sql.Result, error)
+	// 招待を使用済みにする。未使用で取り消されていないものだけが対象
+	// 同時に2回受諾されても、影響行数が 1 になるのは片方だけ
+	AcceptUserInvitation(ctx context.Context, id uint64) (int64, error)
+	// メールアドレスも名前と同じく、有効なユーザーの中で重複させない (uk_users_active_email)
+	CountActiveUsersByEmail(ctx context.Context, email sql.NullString) (int64, error)
+	// 復元前に「同じ名前の有効なユーザー」がいないか確認する
+	CountActiveUsersByName(ctx context.Context, name sql.NullString) (int64, error)
+	// ゴミ箱のページ送り用の総件数
+	CountDeletedUsers(ctx context.Context) (int64, error)
+	// 残っている（使われていない）リカバリーコードの数
+	CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error)
+	CreateAccount(ctx context.Context, arg CreateAccountParams) (sql.Result, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (sql.Result, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	// 新しく振った ID のセッションを作る
+	CreateSession(ctx context.Context, arg CreateSessionParams) error
+	CreateUser(ctx context.Context, arg CreateUserParams) (sql.Result, error)
+	CreateUserInvitation(ctx context.Context, arg CreateUserInvitationParams) (sql.Result, error)
+	// アカウントのセッションを全て消す（パスワードの再設定で使う）
+	DeleteAccountSessions(ctx context.Context, accountID uint64) (int64, error)
+	DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, accountID uint64) error
+	DeleteSession(ctx context.Context, id string) (int64, error)
 	// 物理削除ではなく、現在時刻を入れて「論理削除」にする
-	DeleteUser(ctx context.Context, id uint64) error
+	// 削除済みの行は対象外にし、影響行数で「見つからない」を判定できるようにする
+	DeleteUser(ctx context.Context, id uint64) (int64, error)
+	DisableAccountTOTP(ctx context.Context, id uint64) error
+	// 確認に使ったコードの区切りを totp_last_step に入れ、同じコードでログインできないようにする
+	EnableAccountTOTP(ctx context.Context, arg EnableAccountTOTPParams) (int64, error)
+	// アカウントの使われていないリンクを全て使えなくする（新しく申し込まれた時と、再設定した後に呼ぶ）
+	ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error)
+	GetAccount(ctx context.Context, id uint64) (Account, error)
+	GetAccountByEmail(ctx context.Context, email string) (Account, error)
+	GetDeletedUser(ctx context.Context, id uint64) (User, error)
+	GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error)
+	GetSession(ctx context.Context, arg GetSessionParams) (Session, error)
 	GetUser(ctx context.Context, id uint64) (User, error)
+	GetUserInvitationByTokenHash(ctx context.Context, tokenHash string) (UserInvitation, error)
+	// ログイン中のセッションの一覧（中身は読まない）
+	ListAuthenticatedSessions(ctx context.Context, expiresAt time.Time) ([]ListAuthenticatedSessionsRow, error)
+	// ゴミ箱（論理削除済み）の一覧を1ページ分。新しく削除したものから順に並べる
+	ListDeletedUsers(ctx context.Context, arg ListDeletedUsersParams) ([]User, error)
 	ListUsers(ctx context.Context) ([]User, error)
-	UpdateUser(ctx context.Context, arg UpdateUserParams) error
+	// deleted_at を NULL に戻して論理削除を取り消す
+	RestoreUser(ctx context.Context, id uint64) (int64, error)
+	// ユーザーの使われていない招待を全て取り消す（再送の前にも呼ぶ）
+	RevokeUserInvitations(ctx context.Context, userID uint64) (int64, error)
+	// 2要素認証の登録を始める（秘密鍵は暗号化したもの）。有効にした後は上書きできない
+	SetAccountTOTPSecret(ctx context.Context, arg SetAccountTOTPSecretParams) (int64, error)
+	// パスワードを変えると session_version も +1 し、今までのログイン中のセッションを使えなくする
+	UpdateAccountPassword(ctx context.Context, arg UpdateAccountPasswordParams) (int64, error)
+	// 中身と期限を上書きする（created_at は最初の値のまま）
+	// ログアウト・強制終了で消えた行は作り直さない（影響行数が 0 になる）
+	UpdateSession(ctx context.Context, arg UpdateSessionParams) (int64, error)
+	// 楽観的ロック: 画面を開いた時の version と一致する時だけ更新し、version を +1 する
+	// 他の人が先に更新していたら（または削除済みなら）影響行数が 0 になる
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (int64, error)
+	// コードを使ったことにする。同じか前の区切りのコードなら影響行数が 0 になる（使い回しを防ぐ）
+	UseAccountTOTPStep(ctx context.Context, arg UseAccountTOTPStepParams) (int64, error)
+	// 再設定のリンクを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+	UsePasswordReset(ctx context.Context, id uint64) (int64, error)
+	// リカバリーコードを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
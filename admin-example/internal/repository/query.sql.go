@@ -8,29 +8,455 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 )
 
+const acceptUserInvitation = `-- name: AcceptUserInvitation :execrows
+UPDATE user_invitations SET accepted_at = NOW(3)
+WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL
+`
+
+// 招待を使用済みにする。未使用で取り消されていないものだけが対象
+// 同時に2回受諾されても、影響行数が 1 になるのは片方だけ
+func (q *Queries) AcceptUserInvitation(ctx context.Context, id uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, acceptUserInvitation, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countActiveUsersByEmail = `-- name: CountActiveUsersByEmail :one
+SELECT COUNT(*) FROM users 
+WHERE email = ? AND deleted_at IS NULL
+`
+
+// メールアドレスも名前と同じく、有効なユーザーの中で重複させない (uk_users_active_email)
+func (q *Queries) CountActiveUsersByEmail(ctx context.Context, email sql.NullString) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveUsersByEmail, email)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countActiveUsersByName = `-- name: CountActiveUsersByName :one
+SELECT COUNT(*) FROM users 
+WHERE name = ? AND deleted_at IS NULL
+`
+
+// 復元前に「同じ名前の有効なユーザー」がいないか確認する
+func (q *Queries) CountActiveUsersByName(ctx context.Context, name sql.NullString) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveUsersByName, name)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countDeletedUsers = `-- name: CountDeletedUsers :one
+SELECT COUNT(*) FROM users 
+WHERE deleted_at IS NOT NULL
+`
+
+// ゴミ箱のページ送り用の総件数
+func (q *Queries) CountDeletedUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countDeletedUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRecoveryCodes = `-- name: CountRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE account_id = ? AND used_at IS NULL
+`
+
+// 残っている（使われていない）リカバリーコードの数
+func (q *Queries) CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecoveryCodes, accountID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAccount = `-- name: CreateAccount :execresult
+INSERT INTO accounts (email, password_hash, role) VALUES (?, ?, ?)
+`
+
+type CreateAccountParams struct {
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createAccount, arg.Email, arg.PasswordHash, arg.Role)
+}
+
+const createAuditLog = `-- name: CreateAuditLog :exec
+INSERT INTO audit_logs (
+  actor_id, actor_email, action, target_type, target_id, before_value, after_value, request_id
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateAuditLogParams struct {
+	ActorID     uint64          `json:"actor_id"`
+	ActorEmail  string          `json:"actor_email"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    uint64          `json:"target_id"`
+	BeforeValue json.RawMessage `json:"before_value"`
+	AfterValue  json.RawMessage `json:"after_value"`
+	RequestID   string          `json:"request_id"`
+}
+
+func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditLog,
+		arg.ActorID,
+		arg.ActorEmail,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.BeforeValue,
+		arg.AfterValue,
+		arg.RequestID,
+	)
+	return err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :execresult
+INSERT INTO password_resets (account_id, token_hash, expires_at, requested_ip) VALUES (?, ?, ?, ?)
+`
+
+type CreatePasswordResetParams struct {
+	AccountID   uint64    `json:"account_id"`
+	TokenHash   string    `json:"token_hash"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	RequestedIp string    `json:"requested_ip"`
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createPasswordReset,
+		arg.AccountID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.RequestedIp,
+	)
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (account_id, code_hash) VALUES (?, ?)
+`
+
+type CreateRecoveryCodeParams struct {
+	AccountID uint64 `json:"account_id"`
+	CodeHash  string `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createRecoveryCode, arg.AccountID, arg.CodeHash)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (id, account_id, data, ip, user_agent, created_at, updated_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateSessionParams struct {
+	ID        string    `json:"id"`
+	AccountID uint64    `json:"account_id"`
+	Data      []byte    `json:"data"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 新しく振った ID のセッションを作る
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession,
+		arg.ID,
+		arg.AccountID,
+		arg.Data,
+		arg.Ip,
+		arg.UserAgent,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const createUser = `-- name: CreateUser :execresult
-INSERT INTO users (name) VALUES (?)
+INSERT INTO users (name, email, display_name, status, role, avatar_path) VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateUserParams struct {
+	Name        sql.NullString `json:"name"`
+	Email       sql.NullString `json:"email"`
+	DisplayName string         `json:"display_name"`
+	Status      string         `json:"status"`
+	Role        string         `json:"role"`
+	AvatarPath  string         `json:"avatar_path"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createUser,
+		arg.Name,
+		arg.Email,
+		arg.DisplayName,
+		arg.Status,
+		arg.Role,
+		arg.AvatarPath,
+	)
+}
+
+const createUserInvitation = `-- name: CreateUserInvitation :execresult
+INSERT INTO user_invitations (user_id, email, token_hash, expires_at, created_by) VALUES (?, ?, ?, ?, ?)
 `
 
-func (q *Queries) CreateUser(ctx context.Context, name sql.NullString) (sql.Result, error) {
-	return q.db.ExecContext(ctx, createUser, name)
+type CreateUserInvitationParams struct {
+	UserID    uint64    `json:"user_id"`
+	Email     string    `json:"email"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedBy uint64    `json:"created_by"`
 }
 
-const deleteUser = `-- name: DeleteUser :exec
+func (q *Queries) CreateUserInvitation(ctx context.Context, arg CreateUserInvitationParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createUserInvitation,
+		arg.UserID,
+		arg.Email,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.CreatedBy,
+	)
+}
+
+const deleteAccountSessions = `-- name: DeleteAccountSessions :execrows
+DELETE FROM sessions WHERE account_id = ?
+`
+
+// アカウントのセッションを全て消す（パスワードの再設定で使う）
+func (q *Queries) DeleteAccountSessions(ctx context.Context, accountID uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAccountSessions, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE account_id = ?
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, accountID uint64) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, accountID)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :execrows
+DELETE FROM sessions WHERE id = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSession, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
 UPDATE users SET deleted_at = NOW(3) 
-WHERE id = ?
+WHERE id = ? AND deleted_at IS NULL
 `
 
 // 物理削除ではなく、現在時刻を入れて「論理削除」にする
-func (q *Queries) DeleteUser(ctx context.Context, id uint64) error {
-	_, err := q.db.ExecContext(ctx, deleteUser, id)
+// 削除済みの行は対象外にし、影響行数で「見つからない」を判定できるようにする
+func (q *Queries) DeleteUser(ctx context.Context, id uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const disableAccountTOTP = `-- name: DisableAccountTOTP :exec
+UPDATE accounts SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+WHERE id = ?
+`
+
+func (q *Queries) DisableAccountTOTP(ctx context.Context, id uint64) error {
+	_, err := q.db.ExecContext(ctx, disableAccountTOTP, id)
 	return err
 }
 
+const enableAccountTOTP = `-- name: EnableAccountTOTP :execrows
+UPDATE accounts SET totp_enabled_at = NOW(3), totp_last_step = ?
+WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+`
+
+type EnableAccountTOTPParams struct {
+	TotpLastStep uint64 `json:"totp_last_step"`
+	ID           uint64 `json:"id"`
+}
+
+// 確認に使ったコードの区切りを totp_last_step に入れ、同じコードでログインできないようにする
+func (q *Queries) EnableAccountTOTP(ctx context.Context, arg EnableAccountTOTPParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableAccountTOTP, arg.TotpLastStep, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expirePasswordResets = `-- name: ExpirePasswordResets :execrows
+UPDATE password_resets SET used_at = NOW(3)
+WHERE account_id = ? AND used_at IS NULL
+`
+
+// アカウントの使われていないリンクを全て使えなくする（新しく申し込まれた時と、再設定した後に呼ぶ）
+func (q *Queries) ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expirePasswordResets, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAccount = `-- name: GetAccount :one
+SELECT id, email, password_hash, role, created_at, updated_at, session_version, totp_secret, totp_enabled_at, totp_last_step FROM accounts 
+WHERE id = ? LIMIT 1
+`
+
+func (q *Queries) GetAccount(ctx context.Context, id uint64) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getAccountByEmail = `-- name: GetAccountByEmail :one
+SELECT id, email, password_hash, role, created_at, updated_at, session_version, totp_secret, totp_enabled_at, totp_last_step FROM accounts 
+WHERE email = ? LIMIT 1
+`
+
+func (q *Queries) GetAccountByEmail(ctx context.Context, email string) (Account, error) {
+	row := q.db.QueryRowContext(ctx, getAccountByEmail, email)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SessionVersion,
+		&i.TotpSecret,
+		&i.TotpEnabledAt,
+		&i.TotpLastStep,
+	)
+	return i, err
+}
+
+const getDeletedUser = `-- name: GetDeletedUser :one
+SELECT id, name, created_at, updated_at, deleted_at, version, email, display_name, status, role, avatar_path FROM users 
+WHERE id = ? AND deleted_at IS NOT NULL LIMIT 1
+`
+
+func (q *Queries) GetDeletedUser(ctx context.Context, id uint64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Version,
+		&i.Email,
+		&i.DisplayName,
+		&i.Status,
+		&i.Role,
+		&i.AvatarPath,
+	)
+	return i, err
+}
+
+const getPasswordResetByTokenHash = `-- name: GetPasswordResetByTokenHash :one
+SELECT id, account_id, token_hash, expires_at, used_at, requested_ip, created_at FROM password_resets
+WHERE token_hash = ? LIMIT 1
+`
+
+func (q *Queries) GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRowContext(ctx, getPasswordResetByTokenHash, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.RequestedIp,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, account_id, data, ip, user_agent, created_at, updated_at, expires_at FROM sessions
+WHERE id = ? AND expires_at > ? LIMIT 1
+`
+
+type GetSessionParams struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) GetSession(ctx context.Context, arg GetSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, arg.ID, arg.ExpiresAt)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Data,
+		&i.Ip,
+		&i.UserAgent,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, name, created_at, updated_at, deleted_at FROM users 
+SELECT id, name, created_at, updated_at, deleted_at, version, email, display_name, status, role, avatar_path FROM users 
 WHERE id = ? AND deleted_at IS NULL LIMIT 1
 `
 
@@ -43,12 +469,136 @@ func (q *Queries) GetUser(ctx context.Context, id uint64) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Email,
+		&i.DisplayName,
+		&i.Status,
+		&i.Role,
+		&i.AvatarPath,
+	)
+	return i, err
+}
+
+const getUserInvitationByTokenHash = `-- name: GetUserInvitationByTokenHash :one
+SELECT id, user_id, email, token_hash, expires_at, accepted_at, revoked_at, created_by, created_at FROM user_invitations
+WHERE token_hash = ? LIMIT 1
+`
+
+func (q *Queries) GetUserInvitationByTokenHash(ctx context.Context, tokenHash string) (UserInvitation, error) {
+	row := q.db.QueryRowContext(ctx, getUserInvitationByTokenHash, tokenHash)
+	var i UserInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.AcceptedAt,
+		&i.RevokedAt,
+		&i.CreatedBy,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
+const listAuthenticatedSessions = `-- name: ListAuthenticatedSessions :many
+SELECT id, account_id, ip, user_agent, created_at, updated_at, expires_at FROM sessions
+WHERE account_id <> 0 AND expires_at > ?
+ORDER BY updated_at DESC, id
+`
+
+type ListAuthenticatedSessionsRow struct {
+	ID        string    `json:"id"`
+	AccountID uint64    `json:"account_id"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ログイン中のセッションの一覧（中身は読まない）
+func (q *Queries) ListAuthenticatedSessions(ctx context.Context, expiresAt time.Time) ([]ListAuthenticatedSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAuthenticatedSessions, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAuthenticatedSessionsRow
+	for rows.Next() {
+		var i ListAuthenticatedSessionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedUsers = `-- name: ListDeletedUsers :many
+SELECT id, name, created_at, updated_at, deleted_at, version, email, display_name, status, role, avatar_path FROM users 
+WHERE deleted_at IS NOT NULL 
+ORDER BY deleted_at DESC, id DESC 
+LIMIT ? OFFSET ?
+`
+
+type ListDeletedUsersParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+// ゴミ箱（論理削除済み）の一覧を1ページ分。新しく削除したものから順に並べる
+func (q *Queries) ListDeletedUsers(ctx context.Context, arg ListDeletedUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listDeletedUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Version,
+			&i.Email,
+			&i.DisplayName,
+			&i.Status,
+			&i.Role,
+			&i.AvatarPath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, name, created_at, updated_at, deleted_at FROM users 
+SELECT id, name, created_at, updated_at, deleted_at, version, email, display_name, status, role, avatar_path FROM users 
 WHERE deleted_at IS NULL 
 ORDER BY id DESC
 `
@@ -68,6 +618,12 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Version,
+			&i.Email,
+			&i.DisplayName,
+			&i.Status,
+			&i.Role,
+			&i.AvatarPath,
 		); err != nil {
 			return nil, err
 		}
@@ -82,17 +638,189 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const updateUser = `-- name: UpdateUser :exec
-UPDATE users SET name = ? 
-WHERE id = ? AND deleted_at IS NULL
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users SET deleted_at = NULL 
+WHERE id = ? AND deleted_at IS NOT NULL
+`
+
+// deleted_at を NULL に戻して論理削除を取り消す
+func (q *Queries) RestoreUser(ctx context.Context, id uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserInvitations = `-- name: RevokeUserInvitations :execrows
+UPDATE user_invitations SET revoked_at = NOW(3)
+WHERE user_id = ? AND accepted_at IS NULL AND revoked_at IS NULL
+`
+
+// ユーザーの使われていない招待を全て取り消す（再送の前にも呼ぶ）
+func (q *Queries) RevokeUserInvitations(ctx context.Context, userID uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserInvitations, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setAccountTOTPSecret = `-- name: SetAccountTOTPSecret :execrows
+UPDATE accounts SET totp_secret = ?, totp_last_step = 0
+WHERE id = ? AND totp_enabled_at IS NULL
+`
+
+type SetAccountTOTPSecretParams struct {
+	TotpSecret []byte `json:"totp_secret"`
+	ID         uint64 `json:"id"`
+}
+
+// 2要素認証の登録を始める（秘密鍵は暗号化したもの）。有効にした後は上書きできない
+func (q *Queries) SetAccountTOTPSecret(ctx context.Context, arg SetAccountTOTPSecretParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setAccountTOTPSecret, arg.TotpSecret, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateAccountPassword = `-- name: UpdateAccountPassword :execrows
+UPDATE accounts SET password_hash = ?, session_version = session_version + 1
+WHERE id = ?
+`
+
+type UpdateAccountPasswordParams struct {
+	PasswordHash string `json:"password_hash"`
+	ID           uint64 `json:"id"`
+}
+
+// パスワードを変えると session_version も +1 し、今までのログイン中のセッションを使えなくする
+func (q *Queries) UpdateAccountPassword(ctx context.Context, arg UpdateAccountPasswordParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateAccountPassword, arg.PasswordHash, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSession = `-- name: UpdateSession :execrows
+UPDATE sessions SET account_id = ?, data = ?, ip = ?, user_agent = ?, updated_at = ?, expires_at = ?
+WHERE id = ?
+`
+
+type UpdateSessionParams struct {
+	AccountID uint64    `json:"account_id"`
+	Data      []byte    `json:"data"`
+	Ip        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ID        string    `json:"id"`
+}
+
+// 中身と期限を上書きする（created_at は最初の値のまま）
+// ログアウト・強制終了で消えた行は作り直さない（影響行数が 0 になる）
+func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSession,
+		arg.AccountID,
+		arg.Data,
+		arg.Ip,
+		arg.UserAgent,
+		arg.UpdatedAt,
+		arg.ExpiresAt,
+		arg.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :execrows
+UPDATE users SET name = ?, email = ?, display_name = ?, status = ?, role = ?, avatar_path = ?, version = version + 1 
+WHERE id = ? AND version = ? AND deleted_at IS NULL
 `
 
 type UpdateUserParams struct {
-	Name sql.NullString `json:"name"`
-	ID   uint64         `json:"id"`
+	Name        sql.NullString `json:"name"`
+	Email       sql.NullString `json:"email"`
+	DisplayName string         `json:"display_name"`
+	Status      string         `json:"status"`
+	Role        string         `json:"role"`
+	AvatarPath  string         `json:"avatar_path"`
+	ID          uint64         `json:"id"`
+	Version     uint32         `json:"version"`
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.ExecContext(ctx, updateUser, arg.Name, arg.ID)
-	return err
+// 楽観的ロック: 画面を開いた時の version と一致する時だけ更新し、version を +1 する
+// 他の人が先に更新していたら（または削除済みなら）影響行数が 0 になる
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUser,
+		arg.Name,
+		arg.Email,
+		arg.DisplayName,
+		arg.Status,
+		arg.Role,
+		arg.AvatarPath,
+		arg.ID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useAccountTOTPStep = `-- name: UseAccountTOTPStep :execrows
+UPDATE accounts SET totp_last_step = ?
+WHERE id = ? AND totp_enabled_at IS NOT NULL AND totp_last_step < ?
+`
+
+type UseAccountTOTPStepParams struct {
+	TotpLastStep   uint64 `json:"totp_last_step"`
+	ID             uint64 `json:"id"`
+	TotpLastStep_2 uint64 `json:"totp_last_step_2"`
+}
+
+// コードを使ったことにする。同じか前の区切りのコードなら影響行数が 0 になる（使い回しを防ぐ）
+func (q *Queries) UseAccountTOTPStep(ctx context.Context, arg UseAccountTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useAccountTOTPStep, arg.TotpLastStep, arg.ID, arg.TotpLastStep_2)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const usePasswordReset = `-- name: UsePasswordReset :execrows
+UPDATE password_resets SET used_at = NOW(3)
+WHERE id = ? AND used_at IS NULL
+`
+
+// 再設定のリンクを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+func (q *Queries) UsePasswordReset(ctx context.Context, id uint64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, usePasswordReset, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = NOW(3)
+WHERE account_id = ? AND code_hash = ? AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	AccountID uint64 `json:"account_id"`
+	CodeHash  string `json:"code_hash"`
+}
+
+// リカバリーコードを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRecoveryCode, arg.AccountID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// QueryLog: SQL のログの設定
+// sqlc の Queries に渡す DBTX を包んで (Wrap)、全てのクエリの実行時間を測る
+//
+//	debug: 全てのクエリ（クエリ名・SQL・かかった時間）
+//	warn:  SlowThreshold より時間がかかったクエリ
+//
+// ログは ctx 付きで書くので、リクエストID (request_id) も付く (internal/logging)
+type QueryLog struct {
+	Logger *slog.Logger // nil ならログを書かない
+	// これより時間がかかったクエリを warn で書く。0 なら書かない
+	SlowThreshold time.Duration
+}
+
+// Wrap は db のクエリをログに書く DBTX を返す。Logger が無ければ db をそのまま返す
+func (l QueryLog) Wrap(db DBTX) DBTX {
+	if l.Logger == nil {
+		return db
+	}
+	return &loggingDB{db: db, log: l}
+}
+
+// loggingDB: クエリの実行時間を測ってログに書く DBTX
+type loggingDB struct {
+	db  DBTX
+	log QueryLog
+}
+
+func (d *loggingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := d.db.ExecContext(ctx, query, args...)
+	d.log.record(ctx, query, len(args), time.Since(start), err)
+	return res, err
+}
+
+func (d *loggingDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	// 準備だけで実行はしないので、時間は測らない
+	return d.db.PrepareContext(ctx, query)
+}
+
+func (d *loggingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	d.log.record(ctx, query, len(args), time.Since(start), err)
+	return rows, err
+}
+
+func (d *loggingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.db.QueryRowContext(ctx, query, args...)
+	// エラーは Scan の時に分かるので、ここでは時間だけ
+	d.log.record(ctx, query, len(args), time.Since(start), row.Err())
+	return row
+}
+
+// record はクエリのログを書く。
+// 引数の値（パスワードのハッシュなど）は書かず、個数だけにする
+func (l QueryLog) record(ctx context.Context, query string, args int, elapsed time.Duration, err error) {
+	slow := l.SlowThreshold > 0 && elapsed >= l.SlowThreshold
+	level := slog.LevelDebug
+	msg := "SQL"
+	if slow {
+		level = slog.LevelWarn
+		msg = "遅いクエリ"
+	}
+	if !l.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	name, sqlText := splitQueryName(query)
+	attrs := []slog.Attr{
+		slog.String("query", name),
+		slog.String("sql", sqlText),
+		slog.Int("args", args),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if slow {
+		attrs = append(attrs, slog.Float64("threshold_ms", float64(l.SlowThreshold.Microseconds())/1000))
+	}
+	if err != nil && err != sql.ErrNoRows {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// splitQueryName は sqlc のクエリの先頭のコメント ("-- name: GetUser :one") からクエリ名を取り出し、
+// SQL は改行や連続した空白を1つにまとめて1行にする（手書きのクエリは名前が空）
+func splitQueryName(query string) (string, string) {
+	name := ""
+	if rest, ok := strings.CutPrefix(query, "-- name: "); ok {
+		line, body, _ := strings.Cut(rest, "\n")
+		name, _, _ = strings.Cut(line, " ")
+		query = body
+	}
+	return name, strings.Join(strings.Fields(query), " ")
+}
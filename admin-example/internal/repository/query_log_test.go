@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowDB は指定した時間だけ待ってから返す DBTX（使わないメソッドは呼ぶと panic）
+type slowDB struct {
+	DBTX
+	wait time.Duration
+	err  error
+}
+
+func (d *slowDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	time.Sleep(d.wait)
+	return nil, d.err
+}
+
+func newQueryLog(level slog.Level, threshold time.Duration) (QueryLog, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	return QueryLog{Logger: logger, SlowThreshold: threshold}, &buf
+}
+
+func decodeLog(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	return m
+}
+
+func TestQueryLog_Debug(t *testing.T) {
+	ql, buf := newQueryLog(slog.LevelDebug, time.Second)
+	db := ql.Wrap(&slowDB{})
+
+	err := New(db).CreateAuditLog(context.Background(), CreateAuditLogParams{ActorEmail: "secret@example.com", Action: "user.create"})
+
+	assert.NoError(t, err)
+	m := decodeLog(t, buf)
+	assert.Equal(t, "DEBUG", m["level"])
+	assert.Equal(t, "CreateAuditLog", m["query"]) // sqlc のクエリ名
+	assert.Equal(t, "INSERT INTO audit_logs ( actor_id, actor_email, action, target_type, target_id, before_value, after_value, request_id ) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", m["sql"])
+	assert.Equal(t, float64(8), m["args"])
+	assert.Contains(t, m, "duration_ms")
+	// 引数の値は書かない
+	assert.NotContains(t, buf.String(), "secret@example.com")
+}
+
+func TestQueryLog_Slow(t *testing.T) {
+	ql, buf := newQueryLog(slog.LevelInfo, time.Millisecond)
+	db := ql.Wrap(&slowDB{wait: 5 * time.Millisecond, err: errors.New("Lock wait timeout exceeded")})
+
+	_, err := db.ExecContext(context.Background(), "UPDATE users\n    SET name = ?\n    WHERE id = ?", "a", 1)
+
+	// エラーはそのまま返し、遅いクエリは info でも warn で書く
+	assert.Error(t, err)
+	m := decodeLog(t, buf)
+	assert.Equal(t, "WARN", m["level"])
+	assert.Equal(t, "", m["query"]) // 手書きのクエリは名前が無い
+	assert.Equal(t, "UPDATE users SET name = ? WHERE id = ?", m["sql"])
+	assert.Equal(t, float64(1), m["threshold_ms"])
+	assert.Equal(t, "Lock wait timeout exceeded", m["error"])
+}
+
+func TestQueryLog_InfoSkipsFastQueries(t *testing.T) {
+	ql, buf := newQueryLog(slog.LevelInfo, time.Second)
+
+	_, err := ql.Wrap(&slowDB{}).ExecContext(context.Background(), "SELECT 1")
+
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestQueryLog_WrapWithoutLogger(t *testing.T) {
+	db := &slowDB{}
+
+	// Logger が無ければ包まない
+	assert.Same(t, db, QueryLog{}.Wrap(db))
+}
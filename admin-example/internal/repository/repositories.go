@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"database/sql"
+
+	"go-example/admin-example/internal/sessionstore"
+)
+
+// Repositories: アプリが使うリポジトリ一式
+// 保存先 (config の db.driver) によって、MySQL 版とメモリ版を丸ごと差し替える
+type Repositories struct {
+	Users    UserRepository
+	Tx       TxManager
+	Accounts AccountRepository
+	Audit    AuditRepository
+	Health   HealthRepository
+	// セッションの保存先。メモリ版は Redis の代わりの sessionstore.NewMemoryBackend
+	Sessions sessionstore.Backend
+}
+
+// NewMySQLRepositories は MySQL (sqlc) を使うリポジトリ一式を作る。
+// ql.Logger を渡すと、全てのクエリの実行時間をログに書く
+func NewMySQLRepositories(db *sql.DB, ql QueryLog) Repositories {
+	logged := ql.Wrap(db)
+	return Repositories{
+		Users:    NewUserRepository(logged),
+		Tx:       &txManager{db: db, queryLog: ql},
+		Accounts: NewAccountRepository(logged),
+		Audit:    NewAuditRepository(logged),
+		Health:   NewHealthRepository(db),
+		Sessions: NewSessionRepository(logged),
+	}
+}
+
+// NewMemoryRepositories は MemoryStore を使うリポジトリ一式を作る
+func NewMemoryRepositories(s *MemoryStore) Repositories {
+	return Repositories{
+		Users:    NewMemoryUserRepository(s),
+		Tx:       NewMemoryTxManager(s),
+		Accounts: NewMemoryAccountRepository(s),
+		Audit:    NewMemoryAuditRepository(s),
+		Health:   NewMemoryHealthRepository(),
+		Sessions: sessionstore.NewMemoryBackend(),
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-example/admin-example/internal/sessionstore"
+)
+
+// sessionRepository: セッションを sessions テーブルに保存する sessionstore.Backend
+type sessionRepository struct {
+	q *Queries
+}
+
+func NewSessionRepository(db DBTX) sessionstore.Backend {
+	return &sessionRepository{q: New(db)}
+}
+
+func (r *sessionRepository) Load(ctx context.Context, id string, now time.Time) (sessionstore.Record, error) {
+	s, err := r.q.GetSession(ctx, GetSessionParams{ID: id, ExpiresAt: now})
+	if errors.Is(err, sql.ErrNoRows) {
+		return sessionstore.Record{}, sessionstore.ErrNotFound
+	}
+	if err != nil {
+		return sessionstore.Record{}, err
+	}
+	return sessionstore.Record{
+		ID:        s.ID,
+		AccountID: s.AccountID,
+		Data:      s.Data,
+		IP:        s.Ip,
+		UserAgent: s.UserAgent,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}, nil
+}
+
+func (r *sessionRepository) Create(ctx context.Context, rec sessionstore.Record) error {
+	return r.q.CreateSession(ctx, CreateSessionParams{
+		ID:        rec.ID,
+		AccountID: rec.AccountID,
+		Data:      rec.Data,
+		Ip:        rec.IP,
+		UserAgent: rec.UserAgent,
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+		ExpiresAt: rec.ExpiresAt,
+	})
+}
+
+func (r *sessionRepository) Update(ctx context.Context, rec sessionstore.Record) error {
+	n, err := r.q.UpdateSession(ctx, UpdateSessionParams{
+		AccountID: rec.AccountID,
+		Data:      rec.Data,
+		Ip:        rec.IP,
+		UserAgent: rec.UserAgent,
+		UpdatedAt: rec.UpdatedAt,
+		ExpiresAt: rec.ExpiresAt,
+		ID:        rec.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	// MySQL は値が変わらなかった行を影響行数に数えないので、行が残っているかを確かめる
+	_, err = r.q.GetSession(ctx, GetSessionParams{ID: rec.ID, ExpiresAt: rec.UpdatedAt})
+	if errors.Is(err, sql.ErrNoRows) {
+		return sessionstore.ErrNotFound
+	}
+	return err
+}
+
+func (r *sessionRepository) Delete(ctx context.Context, id string) error {
+	n, err := r.q.DeleteSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sessionstore.ErrNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) DeleteByAccount(ctx context.Context, accountID uint64) (int64, error) {
+	return r.q.DeleteAccountSessions(ctx, accountID)
+}
+
+func (r *sessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	return r.q.DeleteExpiredSessions(ctx, now)
+}
+
+func (r *sessionRepository) ListAuthenticated(ctx context.Context, now time.Time) ([]sessionstore.Record, error) {
+	rows, err := r.q.ListAuthenticatedSessions(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]sessionstore.Record, 0, len(rows))
+	for _, s := range rows {
+		list = append(list, sessionstore.Record{
+			ID:        s.ID,
+			AccountID: s.AccountID,
+			IP:        s.Ip,
+			UserAgent: s.UserAgent,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+			ExpiresAt: s.ExpiresAt,
+		})
+	}
+	return list, nil
+}
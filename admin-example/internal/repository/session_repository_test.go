@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/sessionstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MySQL 版とメモリ版 (Redis の代わり) のセッションの保存先が同じ振る舞いをすること
+func TestSessionBackend(t *testing.T) {
+	for name, newRepos := range repositoryFactories {
+		t.Run(name, func(t *testing.T) {
+			b := newRepos(t).Sessions
+			ctx := context.Background()
+			// MySQL の datetime(3) に合わせてミリ秒で切る
+			now := time.Now().UTC().Truncate(time.Millisecond)
+
+			rec := sessionstore.Record{
+				ID: sessionstore.RecordID("a"), Data: []byte("data"), IP: "192.0.2.1", UserAgent: "agent",
+				CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour),
+			}
+			require.NoError(t, b.Create(ctx, rec))
+			got, err := b.Load(ctx, rec.ID, now)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("data"), got.Data)
+
+			// 上書きしても作った時刻は変わらない
+			later := now.Add(time.Minute)
+			rec.AccountID, rec.Data, rec.CreatedAt, rec.UpdatedAt = 1, []byte("login"), later, later
+			require.NoError(t, b.Update(ctx, rec))
+			// 中身が同じでも（MySQL の影響行数が 0 でも）上書きできる
+			require.NoError(t, b.Update(ctx, rec))
+			got, err = b.Load(ctx, rec.ID, later)
+			require.NoError(t, err)
+			assert.Equal(t, []byte("login"), got.Data)
+			assert.True(t, got.CreatedAt.Equal(now))
+
+			// 一覧はログイン中のものだけ、新しく保存した順
+			anon := sessionstore.Record{ID: sessionstore.RecordID("b"), Data: []byte{}, CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			other := sessionstore.Record{ID: sessionstore.RecordID("c"), AccountID: 2, Data: []byte{}, CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			require.NoError(t, b.Create(ctx, anon))
+			require.NoError(t, b.Create(ctx, other))
+			list, err := b.ListAuthenticated(ctx, later)
+			require.NoError(t, err)
+			if assert.Len(t, list, 2) {
+				assert.Equal(t, rec.ID, list[0].ID)
+				assert.Equal(t, other.ID, list[1].ID)
+			}
+
+			// 期限切れは読めず、DeleteExpired で消える
+			_, err = b.Load(ctx, rec.ID, now.Add(2*time.Hour))
+			assert.ErrorIs(t, err, sessionstore.ErrNotFound)
+			n, err := b.DeleteExpired(ctx, now.Add(2*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), n)
+
+			require.NoError(t, b.Create(ctx, anon))
+			require.NoError(t, b.Delete(ctx, anon.ID))
+			assert.ErrorIs(t, b.Delete(ctx, anon.ID), sessionstore.ErrNotFound)
+			// 消した行は上書きで作り直さない
+			assert.ErrorIs(t, b.Update(ctx, anon), sessionstore.ErrNotFound)
+			_, err = b.Load(ctx, anon.ID, now)
+			assert.ErrorIs(t, err, sessionstore.ErrNotFound)
+
+			// DeleteByAccount はそのアカウントのものだけ消す
+			require.NoError(t, b.Create(ctx, rec))
+			require.NoError(t, b.Create(ctx, other))
+			n, err = b.DeleteByAccount(ctx, rec.AccountID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+			_, err = b.Load(ctx, rec.ID, now)
+			assert.ErrorIs(t, err, sessionstore.ErrNotFound)
+			_, err = b.Load(ctx, other.ID, now)
+			assert.NoError(t, err)
+		})
+	}
+}
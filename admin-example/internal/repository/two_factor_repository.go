@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetTOTPSecret は登録の途中の秘密鍵（secretbox で暗号化したもの）を保存する。
+// 2要素認証がすでに有効（またはアカウントが無い）なら ErrConflict
+func (r *accountRepository) SetTOTPSecret(ctx context.Context, id uint64, sealed []byte) error {
+	n, err := r.q.SetAccountTOTPSecret(ctx, SetAccountTOTPSecretParams{TotpSecret: sealed, ID: id})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// EnableTOTP は2要素認証を有効にする。step は確認に使ったコードの区切り。
+// 登録を始めていない、またはすでに有効なら ErrConflict
+func (r *accountRepository) EnableTOTP(ctx context.Context, id uint64, step uint64) error {
+	n, err := r.q.EnableAccountTOTP(ctx, EnableAccountTOTPParams{TotpLastStep: step, ID: id})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// DisableTOTP は秘密鍵を消して2要素認証を無効にする（リカバリーコードは ReplaceRecoveryCodes で消す）
+func (r *accountRepository) DisableTOTP(ctx context.Context, id uint64) error {
+	return r.q.DisableAccountTOTP(ctx, id)
+}
+
+// UseTOTPStep はコードを使ったことにする。
+// step が前に使ったもの以下なら ErrConflict（同じコードを2回使えないようにする）
+func (r *accountRepository) UseTOTPStep(ctx context.Context, id uint64, step uint64) error {
+	n, err := r.q.UseAccountTOTPStep(ctx, UseAccountTOTPStepParams{TotpLastStep: step, ID: id, TotpLastStep_2: step})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes はアカウントのリカバリーコードを全て消し、codeHashes を入れ直す
+// (途中で失敗しても古いコードが消えないよう、RunInTx の中で呼ぶ)
+func (r *accountRepository) ReplaceRecoveryCodes(ctx context.Context, accountID uint64, codeHashes []string) error {
+	if err := r.q.DeleteRecoveryCodes(ctx, accountID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if err := r.q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{AccountID: accountID, CodeHash: h}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode はリカバリーコードを使用済みにする。
+// 使えるものが無ければ（違う・使用済み）sql.ErrNoRows
+func (r *accountRepository) UseRecoveryCode(ctx context.Context, accountID uint64, codeHash string) error {
+	n, err := r.q.UseRecoveryCode(ctx, UseRecoveryCodeParams{AccountID: accountID, CodeHash: codeHash})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *accountRepository) CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error) {
+	return r.q.CountRecoveryCodes(ctx, accountID)
+}
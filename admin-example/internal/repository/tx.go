@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNestedTx: トランザクションの中で、さらに RunInTx を呼ぼうとした
+// (内側だけコミット/ロールバックされるような事故を防ぐため、入れ子は禁止にしている)
+var ErrNestedTx = errors.New("トランザクションの中で RunInTx を呼ぶことはできません")
+
+// TxManager: 複数のリポジトリ操作を1つのトランザクションで実行する (Unit of Work)
+//
+//	err := tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+//		if err := repo.Update(ctx, id, profile, version); err != nil {
+//			return err // ロールバックされる
+//		}
+//		return repo.RecordAudit(ctx, entry)
+//	})
+//
+// fn がエラーを返すか panic したらロールバックし、正常に終わればコミットする。
+// fn に渡される ctx は「トランザクション中」の印が付いたもので、
+// その ctx のまま RunInTx を呼ぶと ErrNestedTx になる
+type TxManager interface {
+	RunInTx(ctx context.Context, fn func(ctx context.Context, repo UserRepository) error) error
+}
+
+type txKey struct{}
+
+// InTx は ctx がトランザクション中 (RunInTx の中) かどうかを返す
+func InTx(ctx context.Context) bool {
+	v, _ := ctx.Value(txKey{}).(bool)
+	return v
+}
+
+type txManager struct {
+	db       *sql.DB
+	queryLog QueryLog // トランザクションの中のクエリもログに書く
+}
+
+func NewTxManager(db *sql.DB) TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) RunInTx(ctx context.Context, fn func(ctx context.Context, repo UserRepository) error) error {
+	if InTx(ctx) {
+		return ErrNestedTx
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// panic しても接続をトランザクション中のまま放置しない
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// 同じトランザクションを使う Queries を作る（sqlc の WithTx と同じことを、ログ付きの tx で行う）
+	repo := &userRepository{q: New(m.queryLog.Wrap(tx))}
+	if err := fn(context.WithValue(ctx, txKey{}, true), repo); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (ロールバックにも失敗しました: %v)", err, rbErr)
+		}
+		if m.queryLog.Logger != nil {
+			m.queryLog.Logger.DebugContext(ctx, "ロールバックしました", "error", err.Error())
+		}
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// --- MySQL が無くても動かせるよう、Begin/Commit/Rollback を数えるだけの偽ドライバ ---
+
+type txCounter struct {
+	mu                       sync.Mutex
+	begins, commits, rollbks int
+}
+
+type fakeDriver struct{ c *txCounter }
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{c: d.c}, nil }
+
+type fakeConn struct{ c *txCounter }
+
+func (f *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake driver: Prepare は未対応")
+}
+func (f *fakeConn) Close() error { return nil }
+func (f *fakeConn) Begin() (driver.Tx, error) {
+	f.c.mu.Lock()
+	defer f.c.mu.Unlock()
+	f.c.begins++
+	return &fakeTx{c: f.c}, nil
+}
+
+type fakeTx struct{ c *txCounter }
+
+func (t *fakeTx) Commit() error {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.c.commits++
+	return nil
+}
+func (t *fakeTx) Rollback() error {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	t.c.rollbks++
+	return nil
+}
+
+var registerOnce sync.Once
+var fakeCounter = &txCounter{}
+
+// newFakeDB は偽ドライバの *sql.DB を返す。カウンタはテストごとにリセットする
+func newFakeDB(t *testing.T) (*sql.DB, *txCounter) {
+	registerOnce.Do(func() { sql.Register("fake-tx", fakeDriver{c: fakeCounter}) })
+
+	fakeCounter.mu.Lock()
+	fakeCounter.begins, fakeCounter.commits, fakeCounter.rollbks = 0, 0, 0
+	fakeCounter.mu.Unlock()
+
+	db, err := sql.Open("fake-tx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, fakeCounter
+}
+
+func TestTxManager_RunInTx_Commit(t *testing.T) {
+	db, c := newFakeDB(t)
+	tm := NewTxManager(db)
+
+	err := tm.RunInTx(context.Background(), func(ctx context.Context, repo UserRepository) error {
+		// fn の中ではトランザクション中の印が付いている
+		assert.True(t, InTx(ctx))
+		assert.NotNil(t, repo)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.begins)
+	assert.Equal(t, 1, c.commits)
+	assert.Equal(t, 0, c.rollbks)
+}
+
+func TestTxManager_RunInTx_RollbackOnError(t *testing.T) {
+	db, c := newFakeDB(t)
+	tm := NewTxManager(db)
+	want := errors.New("保存に失敗")
+
+	err := tm.RunInTx(context.Background(), func(ctx context.Context, repo UserRepository) error {
+		return want
+	})
+
+	// fn のエラーがそのまま返り、コミットされない
+	assert.ErrorIs(t, err, want)
+	assert.Equal(t, 0, c.commits)
+	assert.Equal(t, 1, c.rollbks)
+}
+
+func TestTxManager_RunInTx_RollbackOnPanic(t *testing.T) {
+	db, c := newFakeDB(t)
+	tm := NewTxManager(db)
+
+	// panic はロールバックした上で呼び出し元に伝わる
+	assert.PanicsWithValue(t, "boom", func() {
+		tm.RunInTx(context.Background(), func(ctx context.Context, repo UserRepository) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, 0, c.commits)
+	assert.Equal(t, 1, c.rollbks)
+}
+
+func TestTxManager_RunInTx_Nested(t *testing.T) {
+	db, c := newFakeDB(t)
+	tm := NewTxManager(db)
+
+	var inner error
+	err := tm.RunInTx(context.Background(), func(ctx context.Context, repo UserRepository) error {
+		inner = tm.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+			return nil
+		})
+		return inner
+	})
+
+	// 内側は始まらず、外側もエラーでロールバックされる
+	assert.ErrorIs(t, inner, ErrNestedTx)
+	assert.ErrorIs(t, err, ErrNestedTx)
+	assert.Equal(t, 1, c.begins)
+	assert.Equal(t, 1, c.rollbks)
+}
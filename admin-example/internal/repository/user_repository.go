@@ -3,52 +3,201 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 )
 
+// ErrConflict: 読み込んだ後に他の人が更新（または削除）していたので、更新しなかった
+var ErrConflict = errors.New("他の人が先に更新しています")
+
 // 1. インターフェース定義（テストでMockに差し替えるための「契約」）
 type UserRepository interface {
-	Create(ctx context.Context, name string) (sql.Result, error)
+	Create(ctx context.Context, p UserProfile) (sql.Result, error)
 	FindByID(ctx context.Context, id uint64) (User, error)
-	Update(ctx context.Context, id uint64, name string) error
+	// version には画面を開いた時の値を渡す。食い違っていたら ErrConflict
+	Update(ctx context.Context, id uint64, p UserProfile, version uint32) error
 	Delete(ctx context.Context, id uint64) error
 	List(ctx context.Context) ([]User, error)
+
+	// 一覧画面用（検索・並び替え・ページング）
+	Search(ctx context.Context, p UserSearchParams) ([]User, error)
+	Count(ctx context.Context, p UserSearchParams) (int64, error)
+
+	// ゴミ箱（論理削除済みユーザー）用
+	// limit / offset は LIMIT / OFFSET
+	ListDeleted(ctx context.Context, limit, offset int) ([]User, error)
+	CountDeleted(ctx context.Context) (int64, error)
+	FindDeletedByID(ctx context.Context, id uint64) (User, error)
+	ExistsActiveName(ctx context.Context, name string) (bool, error)
+	ExistsActiveEmail(ctx context.Context, email string) (bool, error)
+	Restore(ctx context.Context, id uint64) error
+
+	// 監査ログを書き込む。変更と同じトランザクションで書けるよう、
+	// TxManager.RunInTx から渡される UserRepository で呼ぶこと
+	RecordAudit(ctx context.Context, e AuditEntry) error
+
+	// 招待 (user_invitations, invitation_repository.go)
+	// ユーザーの登録や有効化と同じトランザクションで書けるよう、ここに置いている
+	CreateInvitation(ctx context.Context, inv NewInvitation) (uint64, error)
+	FindInvitationByTokenHash(ctx context.Context, tokenHash string) (UserInvitation, error)
+	AcceptInvitation(ctx context.Context, id uint64) error
+	RevokeInvitations(ctx context.Context, userID uint64) (int64, error)
+
+	// Accounts は同じ接続（トランザクション）を使う AccountRepository を返す
+	// (招待の受諾で、ユーザーの有効化とアカウントの作成を一緒に行うため)
+	Accounts() AccountRepository
+}
+
+// UserProfile: 登録・更新で書き込むユーザーの項目（id・日時・version 以外の全部）
+// Update は全ての項目を書き換えるので、変えない項目も今の値を入れて渡すこと
+type UserProfile struct {
+	Name        string
+	Email       string // 空なら NULL（メールアドレス無し）
+	DisplayName string
+	Status      string // model.UserStatus
+	Role        string // model.Role
+	AvatarPath  string // アバター画像のキー。空なら画像なし
+}
+
+// ProfileOf は今のユーザーの値から UserProfile を作る（一部だけ変えて Update する時に使う）
+func ProfileOf(u User) UserProfile {
+	return UserProfile{
+		Name:        u.Name.String,
+		Email:       u.Email.String,
+		DisplayName: u.DisplayName,
+		Status:      u.Status,
+		Role:        u.Role,
+		AvatarPath:  u.AvatarPath,
+	}
+}
+
+// nullString は空文字を NULL にする
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 // 2. 実体となる構造体
 type userRepository struct {
-	q  *Queries
-	db *sql.DB
+	q *Queries
 }
 
 // 3. コンストラクタ（sqlcの生成物をラップして返す）
-func NewUserRepository(db *sql.DB) UserRepository {
+// db には *sql.DB か、ログを書くように包んだもの (QueryLog.Wrap) を渡す
+func NewUserRepository(db DBTX) UserRepository {
 	return &userRepository{
-		q:  New(db), // Newはsqlcが生成した関数
-		db: db,
+		q: New(db), // Newはsqlcが生成した関数
 	}
 }
 
 // --- 以下、インターフェースを満たすための実装 ---
 
-func (r *userRepository) Create(ctx context.Context, name string) (sql.Result, error) {
-	return r.q.CreateUser(ctx, sql.NullString{String: name, Valid: name != ""})
+// Create はユーザーを登録する。ユニーク制約に違反したら *DuplicateKeyError (ErrDuplicate)
+func (r *userRepository) Create(ctx context.Context, p UserProfile) (sql.Result, error) {
+	res, err := r.q.CreateUser(ctx, CreateUserParams{
+		Name:        nullString(p.Name),
+		Email:       nullString(p.Email),
+		DisplayName: p.DisplayName,
+		Status:      p.Status,
+		Role:        p.Role,
+		AvatarPath:  p.AvatarPath,
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return res, nil
 }
 
 func (r *userRepository) FindByID(ctx context.Context, id uint64) (User, error) {
 	return r.q.GetUser(ctx, id)
 }
 
-func (r *userRepository) Update(ctx context.Context, id uint64, name string) error {
-	return r.q.UpdateUser(ctx, UpdateUserParams{
-		ID:   id,
-		Name: sql.NullString{String: name, Valid: name != ""},
+// Update は version が一致する時だけ更新する。0件なら ErrConflict を返す
+// (対象が無い場合も 0件になるので、見つからないかどうかは呼び出し側で先に確認する)
+func (r *userRepository) Update(ctx context.Context, id uint64, p UserProfile, version uint32) error {
+	n, err := r.q.UpdateUser(ctx, UpdateUserParams{
+		ID:          id,
+		Name:        nullString(p.Name),
+		Email:       nullString(p.Email),
+		DisplayName: p.DisplayName,
+		Status:      p.Status,
+		Role:        p.Role,
+		AvatarPath:  p.AvatarPath,
+		Version:     version,
 	})
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
 }
 
+// Delete は論理削除を行う。対象が存在しない（または削除済み）の場合は sql.ErrNoRows を返す
 func (r *userRepository) Delete(ctx context.Context, id uint64) error {
-	return r.q.DeleteUser(ctx, id)
+	n, err := r.q.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 func (r *userRepository) List(ctx context.Context) ([]User, error) {
 	return r.q.ListUsers(ctx)
 }
+
+func (r *userRepository) Search(ctx context.Context, p UserSearchParams) ([]User, error) {
+	return r.q.SearchUsers(ctx, p)
+}
+
+func (r *userRepository) Count(ctx context.Context, p UserSearchParams) (int64, error) {
+	return r.q.CountUsers(ctx, p)
+}
+
+func (r *userRepository) ListDeleted(ctx context.Context, limit, offset int) ([]User, error) {
+	return r.q.ListDeletedUsers(ctx, ListDeletedUsersParams{Limit: int32(limit), Offset: int32(offset)})
+}
+
+func (r *userRepository) CountDeleted(ctx context.Context) (int64, error) {
+	return r.q.CountDeletedUsers(ctx)
+}
+
+func (r *userRepository) FindDeletedByID(ctx context.Context, id uint64) (User, error) {
+	return r.q.GetDeletedUser(ctx, id)
+}
+
+// ExistsActiveName は同じ名前の有効な（削除されていない）ユーザーがいるかを返す
+func (r *userRepository) ExistsActiveName(ctx context.Context, name string) (bool, error) {
+	n, err := r.q.CountActiveUsersByName(ctx, nullString(name))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ExistsActiveEmail は同じメールアドレスの有効なユーザーがいるかを返す
+func (r *userRepository) ExistsActiveEmail(ctx context.Context, email string) (bool, error) {
+	n, err := r.q.CountActiveUsersByEmail(ctx, nullString(email))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Restore は論理削除を取り消す。対象が削除済みでない場合は sql.ErrNoRows を返す
+func (r *userRepository) Restore(ctx context.Context, id uint64) error {
+	n, err := r.q.RestoreUser(ctx, id)
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *userRepository) RecordAudit(ctx context.Context, e AuditEntry) error {
+	return recordAudit(ctx, r.q, e)
+}
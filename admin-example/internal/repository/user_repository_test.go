@@ -3,7 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"go-example/admin-example/internal/requestctx"
+	"os"
 	"testing"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" // ドライバをインポート
 	"github.com/stretchr/testify/assert"
@@ -38,55 +42,533 @@ func TestUserRepository_Create(t *testing.T) {
 }
 */
 
-func TestUserRepository_Create(t *testing.T) {
-	// 1. 本物のDB接続
-	db, _ := sql.Open("mysql", "user:pass@tcp(localhost:3306)/test_db?parseTime=true")
-	defer db.Close()
+// --- 共通テスト (コントラクトテスト) ---
+// MySQL 版とメモリ版の UserRepository が同じ振る舞いをすることを、同じテストで確認する。
+// MySQL 版は TEST_MYSQL_DSN（省略時は下の testMySQLDSN）につながらなければスキップする
 
-	// 2. ★ここが最重要：あなたが作った「NewUserRepository」を呼ぶ
-	// これで repo は UserRepository インターフェース（実体は *userRepository）になる
-	repo := NewUserRepository(db)
-	ctx := context.Background()
+const testMySQLDSN = "user:pass@tcp(localhost:3306)/test_db?parseTime=true"
 
-	// 3. インターフェースで定義した「Create」を呼ぶ
-	// 引数は string で渡せる（内部で sql.NullString に変換されるのをテストできる）
-	res, err := repo.Create(ctx, "テストユーザー")
+// openTestMySQL はテスト用DBに接続し、テーブルを空にする
+func openTestMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		dsn = testMySQLDSN
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
 
-	// 4. 検証
-	assert.NoError(t, err)
-	lastID, _ := res.LastInsertId()
-	assert.True(t, lastID > 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Skipf("テスト用の MySQL につながらないのでスキップします: %v", err)
+	}
 
-	// 後片付け
-	db.Exec("DELETE FROM users WHERE id = ?", lastID)
+	// 本番用とは別のDBを用意すること！（中身を消してから始める）
+	for _, table := range []string{"users", "audit_logs", "user_invitations", "password_resets", "recovery_codes", "accounts", "sessions"} {
+		if _, err := db.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// 実装ごとに、空の状態のリポジトリ一式を作る関数
+var repositoryFactories = map[string]func(t *testing.T) Repositories{
+	"mysql": func(t *testing.T) Repositories {
+		return NewMySQLRepositories(openTestMySQL(t), QueryLog{})
+	},
+	"memory": func(t *testing.T) Repositories {
+		return NewMemoryRepositories(NewMemoryStore())
+	},
 }
 
 func TestUserRepository(t *testing.T) {
-	// 1. テストDB準備
-	db, _ := sql.Open("mysql", "user:pass@tcp(localhost:3306)/test_db?parseTime=true")
-	defer db.Close()
+	for name, newRepos := range repositoryFactories {
+		t.Run(name, func(t *testing.T) {
+			testUserRepositoryContract(t, newRepos)
+		})
+	}
+}
 
-	// 2. 「あなたの作った」ラッパーを生成
-	repo := NewUserRepository(db)
+// mustCreate はユーザーを作って ID を返す
+func mustCreate(t *testing.T, repo UserRepository, name string) uint64 {
+	t.Helper()
+	res, err := repo.Create(context.Background(), UserProfile{Name: name, Status: "active", Role: "viewer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint64(id)
+}
+
+func userNames(users []User) []string {
+	names := []string{}
+	for _, u := range users {
+		names = append(names, u.Name.String)
+	}
+	return names
+}
+
+func testUserRepositoryContract(t *testing.T, newRepos func(t *testing.T) Repositories) {
 	ctx := context.Background()
 
 	t.Run("Createのテスト", func(t *testing.T) {
-		// ここではインターフェースのメソッド名「Create」を呼ぶ
-		// 引数も sql.NullString ではなく、ただの string でOK（ラッパーが変換してくれるから）
-		res, err := repo.Create(ctx, "テスト太郎")
+		repo := newRepos(t).Users
+
+		// 引数は sql.NullString ではなく、ただの string でOK（ラッパーが変換してくれるから）
+		res, err := repo.Create(ctx, UserProfile{
+			Name:        "テスト太郎",
+			Email:       "taro@example.com",
+			DisplayName: "太郎",
+			Status:      "invited",
+			Role:        "editor",
+			AvatarPath:  "0123abcd",
+		})
 
 		assert.NoError(t, err)
 		lastID, _ := res.LastInsertId()
 		assert.True(t, lastID > 0)
 
-		// 後片付け
-		db.Exec("DELETE FROM users WHERE id = ?", lastID)
+		user, err := repo.FindByID(ctx, uint64(lastID))
+		assert.NoError(t, err)
+		assert.Equal(t, "テスト太郎", user.Name.String)
+		assert.Equal(t, "taro@example.com", user.Email.String)
+		assert.Equal(t, "太郎", user.DisplayName)
+		assert.Equal(t, "invited", user.Status)
+		assert.Equal(t, "editor", user.Role)
+		assert.Equal(t, "0123abcd", user.AvatarPath)
+		assert.True(t, user.CreatedAt.Valid)
+		assert.False(t, user.DeletedAt.Valid)
+
+		// メールアドレスが空なら NULL
+		id := mustCreate(t, repo, "メール無し")
+		user, _ = repo.FindByID(ctx, id)
+		assert.False(t, user.Email.Valid)
 	})
 
 	t.Run("Listのテスト", func(t *testing.T) {
+		repo := newRepos(t).Users
+		mustCreate(t, repo, "a")
+		deleted := mustCreate(t, repo, "b")
+		mustCreate(t, repo, "c")
+		assert.NoError(t, repo.Delete(ctx, deleted))
+
 		users, err := repo.List(ctx)
+
+		// 削除済みは含まず、ID の新しい順
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c", "a"}, userNames(users))
+	})
+
+	t.Run("Updateのテスト", func(t *testing.T) {
+		repo := newRepos(t).Users
+		id := mustCreate(t, repo, "before")
+		user, _ := repo.FindByID(ctx, id)
+		assert.Equal(t, uint32(1), user.Version)
+
+		p := ProfileOf(user)
+		p.Name, p.Email, p.Status = "after", "after@example.com", "suspended"
+		assert.NoError(t, repo.Update(ctx, id, p, user.Version))
+
+		user, err := repo.FindByID(ctx, id)
 		assert.NoError(t, err)
-		assert.NotNil(t, users)
+		assert.Equal(t, "after", user.Name.String)
+		assert.Equal(t, "after@example.com", user.Email.String)
+		assert.Equal(t, "suspended", user.Status)
+		assert.Equal(t, "viewer", user.Role) // 渡した今の値のまま
+		assert.Equal(t, uint32(2), user.Version)
+	})
+
+	t.Run("Updateは古いversionならErrConflict", func(t *testing.T) {
+		repo := newRepos(t).Users
+		id := mustCreate(t, repo, "first")
+
+		// 2人が同じ version=1 の画面を開き、先に1人目が保存した
+		assert.NoError(t, repo.Update(ctx, id, UserProfile{Name: "by-alice"}, 1))
+		assert.ErrorIs(t, repo.Update(ctx, id, UserProfile{Name: "by-bob"}, 1), ErrConflict)
+
+		user, _ := repo.FindByID(ctx, id)
+		assert.Equal(t, "by-alice", user.Name.String)
+
+		// 削除済みも更新しない
+		assert.NoError(t, repo.Delete(ctx, id))
+		assert.ErrorIs(t, repo.Update(ctx, id, UserProfile{Name: "deleted"}, user.Version), ErrConflict)
+	})
+
+	t.Run("Deleteは論理削除で、2回目はErrNoRows", func(t *testing.T) {
+		repo := newRepos(t).Users
+		id := mustCreate(t, repo, "deleted")
+
+		assert.NoError(t, repo.Delete(ctx, id))
+		assert.ErrorIs(t, repo.Delete(ctx, id), sql.ErrNoRows)
+		assert.ErrorIs(t, repo.Delete(ctx, id+1000), sql.ErrNoRows)
+
+		// 通常の取得からは見えなくなり、ゴミ箱から見える
+		_, err := repo.FindByID(ctx, id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		user, err := repo.FindDeletedByID(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, user.DeletedAt.Valid)
+
+		trash, err := repo.ListDeleted(ctx, 20, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"deleted"}, userNames(trash))
+	})
+
+	t.Run("ListDeletedは削除の新しい順にページを区切る", func(t *testing.T) {
+		repo := newRepos(t).Users
+		for _, name := range []string{"first", "second", "third"} {
+			id := mustCreate(t, repo, name)
+			assert.NoError(t, repo.Delete(ctx, id))
+		}
+		// 削除されていないものは数えない
+		mustCreate(t, repo, "active")
+
+		total, err := repo.CountDeleted(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+
+		page1, err := repo.ListDeleted(ctx, 2, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"third", "second"}, userNames(page1))
+		page2, err := repo.ListDeleted(ctx, 2, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first"}, userNames(page2))
+	})
+
+	t.Run("Restoreのテスト", func(t *testing.T) {
+		repo := newRepos(t).Users
+		id := mustCreate(t, repo, "restored")
+		assert.NoError(t, repo.Delete(ctx, id))
+
+		assert.NoError(t, repo.Restore(ctx, id))
+		// 削除されていないものは復元できない
+		assert.ErrorIs(t, repo.Restore(ctx, id), sql.ErrNoRows)
+
+		user, err := repo.FindByID(ctx, id)
+		assert.NoError(t, err)
+		assert.False(t, user.DeletedAt.Valid)
+		_, err = repo.FindDeletedByID(ctx, id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("ExistsActiveNameは削除済みを数えない", func(t *testing.T) {
+		repo := newRepos(t).Users
+		id := mustCreate(t, repo, "taken")
+
+		exists, err := repo.ExistsActiveName(ctx, "taken")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		assert.NoError(t, repo.Delete(ctx, id))
+		exists, err = repo.ExistsActiveName(ctx, "taken")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		// 削除済みなら同じ名前で登録し直せる (uk_users_active_name)
+		mustCreate(t, repo, "taken")
+	})
+
+	t.Run("有効なユーザー同士の同じ名前はDBで弾かれる", func(t *testing.T) {
+		repo := newRepos(t).Users
+		first := mustCreate(t, repo, "taken")
+		other := mustCreate(t, repo, "other")
+
+		// 確認 (ExistsActiveName) をすり抜けて同時に登録された場合も、書き込みで止まる
+		var dup *DuplicateKeyError
+		_, err := repo.Create(ctx, UserProfile{Name: "TAKEN", Status: "active", Role: "viewer"})
+		if assert.ErrorAs(t, err, &dup) {
+			assert.Equal(t, "uk_users_active_name", dup.Key)
+		}
+		u, err := repo.FindByID(ctx, other)
+		assert.NoError(t, err)
+		err = repo.Update(ctx, other, UserProfile{Name: "taken", Status: "active", Role: "viewer"}, u.Version)
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		// 削除した後に同じ名前が使われたら、元に戻せない
+		assert.NoError(t, repo.Delete(ctx, first))
+		mustCreate(t, repo, "taken")
+		assert.ErrorIs(t, repo.Restore(ctx, first), ErrDuplicate)
+	})
+
+	t.Run("ExistsActiveEmailは削除済みとメール無しを数えない", func(t *testing.T) {
+		repo := newRepos(t).Users
+		mustCreate(t, repo, "メール無し")
+		res, err := repo.Create(ctx, UserProfile{Name: "hanako", Email: "hanako@example.com", Status: "active", Role: "viewer"})
+		assert.NoError(t, err)
+		id, _ := res.LastInsertId()
+
+		exists, err := repo.ExistsActiveEmail(ctx, "hanako@example.com")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		assert.NoError(t, repo.Delete(ctx, uint64(id)))
+		exists, err = repo.ExistsActiveEmail(ctx, "hanako@example.com")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("有効なユーザー同士の同じメールアドレスはDBで弾かれる", func(t *testing.T) {
+		repo := newRepos(t).Users
+		// メール無し同士は重複にならない
+		mustCreate(t, repo, "メール無し1")
+		mustCreate(t, repo, "メール無し2")
+		res, err := repo.Create(ctx, UserProfile{Name: "hanako", Email: "hanako@example.com", Status: "active", Role: "viewer"})
+		assert.NoError(t, err)
+		id, _ := res.LastInsertId()
+
+		var dup *DuplicateKeyError
+		_, err = repo.Create(ctx, UserProfile{Name: "hanako2", Email: "HANAKO@example.com", Status: "active", Role: "viewer"})
+		if assert.ErrorAs(t, err, &dup) {
+			assert.Equal(t, "uk_users_active_email", dup.Key)
+		}
+
+		// 削除済みなら同じメールアドレスで登録し直せる
+		assert.NoError(t, repo.Delete(ctx, uint64(id)))
+		_, err = repo.Create(ctx, UserProfile{Name: "hanako2", Email: "hanako@example.com", Status: "active", Role: "viewer"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Searchは表示名・メールアドレスと状態でも絞り込める", func(t *testing.T) {
+		repo := newRepos(t).Users
+		for _, p := range []UserProfile{
+			{Name: "user1", DisplayName: "山田", Status: "active", Role: "viewer"},
+			{Name: "user2", Email: "yamada@example.com", Status: "suspended", Role: "viewer"},
+			{Name: "user3", Status: "active", Role: "viewer"},
+		} {
+			_, err := repo.Create(ctx, p)
+			assert.NoError(t, err)
+		}
+
+		users, err := repo.Search(ctx, UserSearchParams{Name: "yamada", SortColumn: "id", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"user2"}, userNames(users))
+
+		users, err = repo.Search(ctx, UserSearchParams{Name: "山田", SortColumn: "id", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"user1"}, userNames(users))
+
+		total, err := repo.Count(ctx, UserSearchParams{Status: "active"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+	})
+
+	t.Run("Searchの絞り込み・並び替え・ページング", func(t *testing.T) {
+		repo := newRepos(t).Users
+		for _, name := range []string{"alice", "bob", "alicia", "100%", "1000", "carol"} {
+			mustCreate(t, repo, name)
+		}
+		deleted := mustCreate(t, repo, "alien")
+		assert.NoError(t, repo.Delete(ctx, deleted))
+
+		// 前方一致 + 名前の昇順
+		users, err := repo.Search(ctx, UserSearchParams{Name: "ali", Prefix: true, SortColumn: "name", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice", "alicia"}, userNames(users))
+
+		// 部分一致。% は文字として扱う
+		users, err = repo.Search(ctx, UserSearchParams{Name: "0%", SortColumn: "id", Limit: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"100%"}, userNames(users))
+
+		// 不明な並び順は ID の新しい順。件数は LIMIT/OFFSET に関係なく数える
+		params := UserSearchParams{SortColumn: "password", Limit: 2, Offset: 1}
+		users, err = repo.Search(ctx, params)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1000", "100%"}, userNames(users))
+
+		total, err := repo.Count(ctx, params)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6), total)
+	})
+
+	t.Run("RunInTxはエラーならロールバックする", func(t *testing.T) {
+		repos := newRepos(t)
+		want := errors.New("途中で失敗")
+
+		err := repos.Tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+			mustCreate(t, repo, "rollback")
+			if err := repo.RecordAudit(ctx, AuditEntry{Action: "user.create", TargetType: "user", TargetID: 1}); err != nil {
+				return err
+			}
+			return want
+		})
+
+		assert.ErrorIs(t, err, want)
+		users, _ := repos.Users.List(ctx)
+		assert.Empty(t, users)
+		n, _ := repos.Audit.Count(ctx, AuditSearchParams{})
+		assert.Equal(t, int64(0), n)
+	})
+
+	t.Run("RunInTxは成功ならコミットし、監査ログも残る", func(t *testing.T) {
+		repos := newRepos(t)
+		ctx := requestctx.WithActor(ctx, requestctx.Actor{ID: 7, Email: "admin@example.com"})
+
+		var id uint64
+		err := repos.Tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+			id = mustCreate(t, repo, "commit")
+			return repo.RecordAudit(ctx, AuditEntry{Action: "user.create", TargetType: "user", TargetID: id, After: map[string]string{"name": "commit"}})
+		})
+		assert.NoError(t, err)
+
+		user, err := repos.Users.FindByID(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "commit", user.Name.String)
+
+		logs, err := repos.Audit.Search(ctx, AuditSearchParams{TargetID: id, Limit: 10})
+		assert.NoError(t, err)
+		if assert.Len(t, logs, 1) {
+			assert.Equal(t, "admin@example.com", logs[0].ActorEmail)
+			assert.Equal(t, uint64(7), logs[0].ActorID)
+			assert.JSONEq(t, `{"name":"commit"}`, string(logs[0].AfterValue))
+		}
+	})
+
+	t.Run("招待は1回だけ受諾でき、取り消したものは使えない", func(t *testing.T) {
+		repo := newRepos(t).Users
+		ctx := requestctx.WithActor(ctx, requestctx.Actor{ID: 7, Email: "admin@example.com"})
+		userID := mustCreate(t, repo, "invited")
+		expires := time.Date(2026, 4, 1, 9, 0, 0, 123000000, time.UTC)
+
+		id, err := repo.CreateInvitation(ctx, NewInvitation{UserID: userID, Email: "taro@example.com", TokenHash: "hash-1", ExpiresAt: expires})
+		assert.NoError(t, err)
+		_, err = repo.CreateInvitation(ctx, NewInvitation{UserID: userID, Email: "taro@example.com", TokenHash: "hash-1", ExpiresAt: expires})
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		inv, err := repo.FindInvitationByTokenHash(ctx, "hash-1")
+		assert.NoError(t, err)
+		assert.Equal(t, id, inv.ID)
+		assert.Equal(t, userID, inv.UserID)
+		assert.Equal(t, uint64(7), inv.CreatedBy)
+		assert.True(t, expires.Equal(inv.ExpiresAt))
+		assert.False(t, inv.AcceptedAt.Valid)
+
+		assert.NoError(t, repo.AcceptInvitation(ctx, id))
+		assert.ErrorIs(t, repo.AcceptInvitation(ctx, id), ErrConflict)
+
+		// 取り消しは使われていないものだけ
+		id2, err := repo.CreateInvitation(ctx, NewInvitation{UserID: userID, Email: "taro@example.com", TokenHash: "hash-2", ExpiresAt: expires})
+		assert.NoError(t, err)
+		n, err := repo.RevokeInvitations(ctx, userID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.ErrorIs(t, repo.AcceptInvitation(ctx, id2), ErrConflict)
+
+		_, err = repo.FindInvitationByTokenHash(ctx, "no-such-hash")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("再設定のリンクは1回だけ使え、パスワードを変えるとsession_versionが増える", func(t *testing.T) {
+		accounts := newRepos(t).Accounts
+		accountID, err := accounts.Create(ctx, "taro@example.com", "old-hash", "viewer")
+		assert.NoError(t, err)
+		expires := time.Date(2026, 4, 1, 9, 0, 0, 123000000, time.UTC)
+
+		id, err := accounts.CreatePasswordReset(ctx, NewPasswordReset{AccountID: accountID, TokenHash: "hash-1", ExpiresAt: expires, RequestedIP: "192.0.2.1"})
+		assert.NoError(t, err)
+		pr, err := accounts.FindPasswordResetByTokenHash(ctx, "hash-1")
+		assert.NoError(t, err)
+		assert.Equal(t, id, pr.ID)
+		assert.Equal(t, "192.0.2.1", pr.RequestedIp)
+		assert.True(t, expires.Equal(pr.ExpiresAt))
+
+		assert.NoError(t, accounts.UsePasswordReset(ctx, id))
+		assert.ErrorIs(t, accounts.UsePasswordReset(ctx, id), ErrConflict)
+
+		// 使われていないものだけを使えなくする
+		id2, err := accounts.CreatePasswordReset(ctx, NewPasswordReset{AccountID: accountID, TokenHash: "hash-2", ExpiresAt: expires})
+		assert.NoError(t, err)
+		n, err := accounts.ExpirePasswordResets(ctx, accountID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.ErrorIs(t, accounts.UsePasswordReset(ctx, id2), ErrConflict)
+
+		before, _ := accounts.FindByID(ctx, accountID)
+		assert.NoError(t, accounts.UpdatePassword(ctx, accountID, "new-hash"))
+		after, _ := accounts.FindByID(ctx, accountID)
+		assert.Equal(t, "new-hash", after.PasswordHash)
+		assert.Equal(t, before.SessionVersion+1, after.SessionVersion)
+		assert.ErrorIs(t, accounts.UpdatePassword(ctx, accountID+100, "hash"), sql.ErrNoRows)
+	})
+
+	t.Run("2要素認証は登録してから有効にし、同じ区切りのコードとリカバリーコードは2回使えない", func(t *testing.T) {
+		accounts := newRepos(t).Accounts
+		accountID, err := accounts.Create(ctx, "taro@example.com", "hash", "admin")
+		assert.NoError(t, err)
+
+		// 登録を始めていなければ有効にできない
+		assert.ErrorIs(t, accounts.EnableTOTP(ctx, accountID, 100), ErrConflict)
+		assert.NoError(t, accounts.SetTOTPSecret(ctx, accountID, []byte("sealed-1")))
+		assert.NoError(t, accounts.SetTOTPSecret(ctx, accountID, []byte("sealed-2"))) // 登録中ならやり直せる
+		// 有効になるまではコードを使えない
+		assert.ErrorIs(t, accounts.UseTOTPStep(ctx, accountID, 100), ErrConflict)
+		assert.NoError(t, accounts.EnableTOTP(ctx, accountID, 100))
+		assert.ErrorIs(t, accounts.SetTOTPSecret(ctx, accountID, []byte("sealed-3")), ErrConflict)
+
+		a, err := accounts.FindByID(ctx, accountID)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("sealed-2"), a.TotpSecret)
+		assert.True(t, a.TotpEnabledAt.Valid)
+		assert.Equal(t, uint64(100), a.TotpLastStep)
+
+		assert.ErrorIs(t, accounts.UseTOTPStep(ctx, accountID, 100), ErrConflict)
+		assert.NoError(t, accounts.UseTOTPStep(ctx, accountID, 101))
+		assert.ErrorIs(t, accounts.UseTOTPStep(ctx, accountID, 99), ErrConflict)
+
+		assert.NoError(t, accounts.ReplaceRecoveryCodes(ctx, accountID, []string{"code-1", "code-2"}))
+		assert.NoError(t, accounts.UseRecoveryCode(ctx, accountID, "code-1"))
+		assert.ErrorIs(t, accounts.UseRecoveryCode(ctx, accountID, "code-1"), sql.ErrNoRows)
+		assert.ErrorIs(t, accounts.UseRecoveryCode(ctx, accountID+100, "code-2"), sql.ErrNoRows)
+		n, err := accounts.CountRecoveryCodes(ctx, accountID)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		// 作り直すと古いコードは使えない
+		assert.NoError(t, accounts.ReplaceRecoveryCodes(ctx, accountID, []string{"code-3"}))
+		assert.ErrorIs(t, accounts.UseRecoveryCode(ctx, accountID, "code-2"), sql.ErrNoRows)
+
+		assert.NoError(t, accounts.DisableTOTP(ctx, accountID))
+		a, _ = accounts.FindByID(ctx, accountID)
+		assert.Nil(t, a.TotpSecret)
+		assert.False(t, a.TotpEnabledAt.Valid)
+		assert.Equal(t, uint64(0), a.TotpLastStep)
+	})
+
+	t.Run("Accountsはトランザクションと一緒にロールバックされる", func(t *testing.T) {
+		repos := newRepos(t)
+		want := errors.New("途中で失敗")
+
+		err := repos.Tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+			if _, err := repo.Accounts().Create(ctx, "taro@example.com", "hash", "viewer"); err != nil {
+				return err
+			}
+			return want
+		})
+
+		assert.ErrorIs(t, err, want)
+		_, err = repos.Accounts.FindByEmail(ctx, "taro@example.com")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("RunInTxの入れ子はErrNestedTx", func(t *testing.T) {
+		repos := newRepos(t)
+
+		err := repos.Tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+			return repos.Tx.RunInTx(ctx, func(ctx context.Context, repo UserRepository) error {
+				return nil
+			})
+		})
+
+		assert.ErrorIs(t, err, ErrNestedTx)
 	})
 }
 
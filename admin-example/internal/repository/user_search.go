@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"strings"
+)
+
+// 一覧の検索・並び替え・ページングは条件によってSQLが変わるため、
+// sqlc では生成できない。ここだけ手書きでクエリを組み立てる。
+
+// 並び替えに使ってよいカラム（ホワイトリスト）
+// 画面から来た文字列をそのままSQLに入れないよう、必ずここを通す
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// UserSearchParams: 一覧検索の条件
+type UserSearchParams struct {
+	Name       string // 名前・表示名・メールアドレスの検索文字列（空なら絞り込まない）
+	Prefix     bool   // true なら前方一致、false なら部分一致
+	Status     string // 状態で絞り込む（空なら絞り込まない）
+	SortColumn string // userSortColumns のキー
+	Desc       bool
+	Limit      int
+	Offset     int
+}
+
+const searchUsersSelect = `SELECT id, name, created_at, updated_at, deleted_at, version, email, display_name, status, role, avatar_path FROM users`
+
+const countUsersSelect = `SELECT COUNT(*) FROM users`
+
+// where は検索条件から WHERE 句とパラメータを組み立てる
+func (p UserSearchParams) where() (string, []interface{}) {
+	clause := " WHERE deleted_at IS NULL"
+	var args []interface{}
+	if p.Name != "" {
+		pattern := escapeLike(p.Name) + "%"
+		if !p.Prefix {
+			pattern = "%" + pattern
+		}
+		clause += " AND (name LIKE ? OR display_name LIKE ? OR email LIKE ?)"
+		args = append(args, pattern, pattern, pattern)
+	}
+	if p.Status != "" {
+		clause += " AND status = ?"
+		args = append(args, p.Status)
+	}
+	return clause, args
+}
+
+// orderBy はホワイトリストにあるカラムだけで ORDER BY 句を作る
+func (p UserSearchParams) orderBy() string {
+	column, ok := userSortColumns[p.SortColumn]
+	if !ok {
+		// 不明なカラムは従来通り ID の新しい順
+		return " ORDER BY id DESC"
+	}
+	dir := " ASC"
+	if p.Desc {
+		dir = " DESC"
+	}
+	// 同じ値が並んだ時にページをまたいで順番がぶれないよう、id を第2キーにする
+	if column == "id" {
+		return " ORDER BY id" + dir
+	}
+	return " ORDER BY " + column + dir + ", id" + dir
+}
+
+// escapeLike は LIKE の特殊文字（% と _）をエスケープする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SearchUsers は条件に合うユーザーを1ページ分だけ返す
+func (q *Queries) SearchUsers(ctx context.Context, arg UserSearchParams) ([]User, error) {
+	where, args := arg.where()
+	query := searchUsersSelect + where + arg.orderBy() + " LIMIT ? OFFSET ?"
+	args = append(args, arg.Limit, arg.Offset)
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Version,
+			&i.Email,
+			&i.DisplayName,
+			&i.Status,
+			&i.Role,
+			&i.AvatarPath,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// CountUsers は条件に合うユーザーの総件数を返す（LIMIT/OFFSET は無視する）
+func (q *Queries) CountUsers(ctx context.Context, arg UserSearchParams) (int64, error) {
+	where, args := arg.where()
+	row := q.db.QueryRowContext(ctx, countUsersSelect+where, args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
@@ -0,0 +1,39 @@
+// Package requestctx は「誰の・どのリクエストか」を context.Context に載せて
+// Controller から Service / Repository まで運ぶためのヘルパー
+package requestctx
+
+import "context"
+
+// Actor: 操作した人（ログイン中のアカウント）
+// コマンドラインなど、ログインを伴わない操作では ID が 0 になる
+type Actor struct {
+	ID    uint64
+	Email string
+	Role  string // アカウントの役割 (model.Role)。権限の確認に使う
+}
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+// WithActor は操作した人を context に載せる
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFrom は context から操作した人を取り出す（無ければゼロ値）
+func ActorFrom(ctx context.Context) Actor {
+	a, _ := ctx.Value(actorKey{}).(Actor)
+	return a
+}
+
+// WithRequestID はリクエストIDを context に載せる
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom は context からリクエストIDを取り出す（無ければ空文字）
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
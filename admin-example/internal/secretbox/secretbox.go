@@ -0,0 +1,54 @@
+// Package secretbox は DB に保存する秘密の値（2要素認証の秘密鍵など）を AES-256-GCM で暗号化する
+//
+// 暗号文は <nonce (12バイト)><暗号化した値と認証タグ> 。鍵を替えると前の暗号文は読めなくなる
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// ErrDecrypt: 壊れている・別の鍵で暗号化されている
+var ErrDecrypt = errors.New("暗号化された値を復号できません")
+
+// Box: 1つの鍵で暗号化・復号する
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New は secret から鍵 (SHA-256) を作る
+func New(secret string) *Box {
+	key := sha256.Sum256([]byte("secretbox:" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // 32バイトの鍵なので起きない
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Box{aead: aead}
+}
+
+func (b *Box) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (b *Box) Open(ciphertext []byte) ([]byte, error) {
+	n := b.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, ErrDecrypt
+	}
+	plain, err := b.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
@@ -0,0 +1,33 @@
+package secretbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBox(t *testing.T) {
+	box := New("secret")
+
+	sealed, err := box.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "JBSWY3DPEHPK3PXP")
+
+	plain, err := box.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", string(plain))
+
+	// 同じ値でも毎回違う暗号文になる
+	again, _ := box.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	assert.NotEqual(t, sealed, again)
+
+	// 別の鍵や、書き換えられた暗号文は読めない
+	_, err = New("other").Open(sealed)
+	assert.ErrorIs(t, err, ErrDecrypt)
+	sealed[len(sealed)-1] ^= 1
+	_, err = box.Open(sealed)
+	assert.ErrorIs(t, err, ErrDecrypt)
+	_, err = box.Open([]byte("short"))
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+)
+
+// 監査ログの操作名
+const (
+	AuditActionUserCreate  = "user.create"
+	AuditActionUserUpdate  = "user.update"
+	AuditActionUserDelete  = "user.delete"
+	AuditActionUserRestore = "user.restore"
+	AuditActionUserImport  = "user.import" // CSV の取り込みで登録した（1行ごとに1件）
+)
+
+// 監査ログの対象の種類
+const (
+	auditTargetUser    = "user"
+	auditTargetAccount = "account" // ログインするアカウント (accounts)
+)
+
+// userSnapshot: 監査ログに残すユーザーの値（空の項目は省く）
+type userSnapshot struct {
+	Name        string `json:"name"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Role        string `json:"role,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+}
+
+func snapshotOf(u repository.User) userSnapshot {
+	return snapshotOfProfile(repository.ProfileOf(u))
+}
+
+func snapshotOfProfile(p repository.UserProfile) userSnapshot {
+	return userSnapshot{
+		Name:        p.Name,
+		Email:       p.Email,
+		DisplayName: p.DisplayName,
+		Status:      p.Status,
+		Role:        p.Role,
+		Avatar:      p.AvatarPath,
+	}
+}
+
+// userAuditEntry はユーザーに対する操作の監査ログを作る
+// before / after は無ければ nil を渡す
+func userAuditEntry(action string, id uint64, before, after interface{}) repository.AuditEntry {
+	return repository.AuditEntry{
+		Action:     action,
+		TargetType: auditTargetUser,
+		TargetID:   id,
+		Before:     before,
+		After:      after,
+	}
+}
+
+// AuditService: 監査ログの閲覧
+type AuditService interface {
+	Search(ctx context.Context, q model.AuditListQuery) ([]repository.AuditLog, int64, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(r repository.AuditRepository) AuditService {
+	return &auditService{repo: r}
+}
+
+func (s *auditService) Search(ctx context.Context, q model.AuditListQuery) ([]repository.AuditLog, int64, error) {
+	p := repository.AuditSearchParams{
+		ActorEmail: q.Actor,
+		TargetID:   q.TargetID,
+		From:       q.FromTime(),
+		To:         q.ToTime(),
+	}
+
+	total, err := s.repo.Count(ctx, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []repository.AuditLog{}, 0, nil
+	}
+	// 最後のページより後を指定されたら、最後のページを返す（呼び出し側も q.ClampPage(total) で合わせる）
+	q.ClampPage(total)
+	p.Limit = q.PerPage
+	p.Offset = q.Offset()
+
+	logs, err := s.repo.Search(ctx, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials: メールアドレスかパスワードが違う（どちらが違うかは教えない）
+	ErrInvalidCredentials = errors.New("メールアドレスまたはパスワードが違います")
+	// ErrWeakPassword: パスワードが短すぎる (ErrValidation)
+	ErrWeakPassword = newValidationError("password", "パスワードは8文字以上にしてください")
+	// ErrInvalidRole: 定義されていない役割 (ErrValidation)
+	ErrInvalidRole = newValidationError("role", "役割は admin / editor / viewer のどれかにしてください")
+	// ErrEmailAlreadyUsed: 同じメールアドレスのアカウントがある (uk_accounts_email, ErrValidation)
+	ErrEmailAlreadyUsed = newValidationError("email", "このメールアドレスはすでに登録されています")
+)
+
+// AuthService: ログインとアカウント作成
+type AuthService interface {
+	Authenticate(ctx context.Context, email, password string) (repository.Account, error)
+	FindAccount(ctx context.Context, id uint64) (repository.Account, error)
+	CreateAccount(ctx context.Context, email, password string, role model.Role) (uint64, error)
+}
+
+type authService struct {
+	repo repository.AccountRepository
+	// アカウントが存在しない時にも bcrypt の比較を1回行うためのダミー
+	// (存在するかどうかを応答時間で見抜かれないようにする)
+	dummyHash []byte
+}
+
+func NewAuthService(r repository.AccountRepository) AuthService {
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	return &authService{repo: r, dummyHash: dummy}
+}
+
+// Authenticate はメールアドレスとパスワードを確認し、正しければアカウントを返す
+func (s *authService) Authenticate(ctx context.Context, email, password string) (repository.Account, error) {
+	acc, err := s.repo.FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return repository.Account{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return repository.Account{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(acc.PasswordHash), []byte(password)); err != nil {
+		return repository.Account{}, ErrInvalidCredentials
+	}
+	return acc, nil
+}
+
+func (s *authService) FindAccount(ctx context.Context, id uint64) (repository.Account, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// CreateAccount はパスワードを bcrypt でハッシュ化してアカウントを作る
+func (s *authService) CreateAccount(ctx context.Context, email, password string, role model.Role) (uint64, error) {
+	if !role.Valid() {
+		return 0, ErrInvalidRole
+	}
+	if err := checkPassword(password, email); err != nil {
+		return 0, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	id, err := s.repo.Create(ctx, normalizeEmail(email), string(hash), string(role))
+	if errors.Is(err, repository.ErrDuplicate) {
+		return 0, ErrEmailAlreadyUsed
+	}
+	return id, err
+}
+
+// メールアドレスは大文字小文字を区別せずに扱う
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
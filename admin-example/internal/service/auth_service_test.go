@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// AccountRepository の偽物（メモリ上に保存する）
+type mockAccountRepository struct {
+	accounts map[string]repository.Account
+}
+
+func newMockAccountRepository() *mockAccountRepository {
+	return &mockAccountRepository{accounts: map[string]repository.Account{}}
+}
+
+func (m *mockAccountRepository) Create(ctx context.Context, email, passwordHash, role string) (uint64, error) {
+	id := uint64(len(m.accounts) + 1)
+	m.accounts[email] = repository.Account{ID: id, Email: email, PasswordHash: passwordHash, Role: role}
+	return id, nil
+}
+
+func (m *mockAccountRepository) FindByID(ctx context.Context, id uint64) (repository.Account, error) {
+	for _, a := range m.accounts {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return repository.Account{}, sql.ErrNoRows
+}
+
+func (m *mockAccountRepository) FindByEmail(ctx context.Context, email string) (repository.Account, error) {
+	a, ok := m.accounts[email]
+	if !ok {
+		return repository.Account{}, sql.ErrNoRows
+	}
+	return a, nil
+}
+
+func (m *mockAccountRepository) UpdatePassword(ctx context.Context, id uint64, passwordHash string) error {
+	for email, a := range m.accounts {
+		if a.ID == id {
+			a.PasswordHash = passwordHash
+			a.SessionVersion++
+			m.accounts[email] = a
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// パスワードの再設定のリンクは password_reset_service_test.go でメモリの Repository を使って確かめる
+func (m *mockAccountRepository) CreatePasswordReset(ctx context.Context, pr repository.NewPasswordReset) (uint64, error) {
+	return 1, nil
+}
+
+func (m *mockAccountRepository) FindPasswordResetByTokenHash(ctx context.Context, tokenHash string) (repository.PasswordReset, error) {
+	return repository.PasswordReset{}, sql.ErrNoRows
+}
+
+func (m *mockAccountRepository) UsePasswordReset(ctx context.Context, id uint64) error {
+	return nil
+}
+
+func (m *mockAccountRepository) ExpirePasswordResets(ctx context.Context, accountID uint64) (int64, error) {
+	return 0, nil
+}
+
+// 2要素認証は two_factor_service_test.go でメモリの Repository を使って確かめる
+func (m *mockAccountRepository) SetTOTPSecret(ctx context.Context, id uint64, sealed []byte) error {
+	return nil
+}
+
+func (m *mockAccountRepository) EnableTOTP(ctx context.Context, id uint64, step uint64) error {
+	return nil
+}
+
+func (m *mockAccountRepository) DisableTOTP(ctx context.Context, id uint64) error {
+	return nil
+}
+
+func (m *mockAccountRepository) UseTOTPStep(ctx context.Context, id uint64, step uint64) error {
+	return nil
+}
+
+func (m *mockAccountRepository) ReplaceRecoveryCodes(ctx context.Context, accountID uint64, codeHashes []string) error {
+	return nil
+}
+
+func (m *mockAccountRepository) UseRecoveryCode(ctx context.Context, accountID uint64, codeHash string) error {
+	return sql.ErrNoRows
+}
+
+func (m *mockAccountRepository) CountRecoveryCodes(ctx context.Context, accountID uint64) (int64, error) {
+	return 0, nil
+}
+
+func TestAuthService_CreateAndAuthenticate(t *testing.T) {
+	repo := newMockAccountRepository()
+	svc := NewAuthService(repo)
+	ctx := context.Background()
+
+	_, err := svc.CreateAccount(ctx, " Admin@Example.com ", "correct-horse", model.RoleAdmin)
+	assert.NoError(t, err)
+
+	// 平文ではなくハッシュが保存されていること
+	saved := repo.accounts["admin@example.com"]
+	assert.NotEqual(t, "correct-horse", saved.PasswordHash)
+
+	// メールアドレスの大文字小文字は区別しない
+	acc, err := svc.Authenticate(ctx, "ADMIN@example.com", "correct-horse")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", acc.Role)
+}
+
+func TestAuthService_Authenticate_Failures(t *testing.T) {
+	svc := NewAuthService(newMockAccountRepository())
+	ctx := context.Background()
+	_, err := svc.CreateAccount(ctx, "editor@example.com", "correct-horse", model.RoleEditor)
+	assert.NoError(t, err)
+
+	// パスワード違いも、存在しないアカウントも同じエラー
+	_, err = svc.Authenticate(ctx, "editor@example.com", "wrong-password")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = svc.Authenticate(ctx, "nobody@example.com", "correct-horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthService_CreateAccount_Invalid(t *testing.T) {
+	svc := NewAuthService(newMockAccountRepository())
+	ctx := context.Background()
+
+	_, err := svc.CreateAccount(ctx, "a@example.com", "short", model.RoleViewer)
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	assert.ErrorIs(t, err, ErrValidation)
+
+	_, err = svc.CreateAccount(ctx, "a@example.com", "long-enough", model.Role("root"))
+	assert.ErrorIs(t, err, ErrInvalidRole)
+}
+
+func TestAuthService_CreateAccount_DuplicateEmail(t *testing.T) {
+	svc := NewAuthService(repository.NewMemoryAccountRepository(repository.NewMemoryStore()))
+	ctx := context.Background()
+	_, err := svc.CreateAccount(ctx, "a@example.com", "long-enough", model.RoleViewer)
+	assert.NoError(t, err)
+
+	// uk_accounts_email の違反は、どの項目のエラーか分かる形で返す
+	_, err = svc.CreateAccount(ctx, "A@example.com", "long-enough", model.RoleViewer)
+	assert.ErrorIs(t, err, ErrEmailAlreadyUsed)
+	var ve *ValidationError
+	if assert.ErrorAs(t, err, &ve) {
+		assert.Equal(t, "email", ve.Field)
+	}
+}
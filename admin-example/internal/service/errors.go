@@ -0,0 +1,67 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-example/admin-example/internal/repository"
+)
+
+// Service が返すエラー（ドメインのエラー）
+// Controller は errors.Is でこれらを見て、ステータスと画面のメッセージを決める。
+// DB やドライバのエラー (sql.ErrNoRows / MySQL の 1062 など) は domainError で変換するので、
+// Controller がリポジトリやドライバのエラーを直接見る必要はない
+var (
+	// ErrNotFound: 対象が見つからない（削除済みを含む）
+	ErrNotFound = errors.New("ユーザーが見つかりません")
+	// ErrDuplicateName: 同じ名前の有効なユーザーがいる (uk_users_active_name)
+	ErrDuplicateName = errors.New("同じ名前のユーザーがすでに存在します")
+	// ErrDuplicateEmail: 同じメールアドレスの有効なユーザーがいる (uk_users_active_email)
+	ErrDuplicateEmail = errors.New("このメールアドレスのユーザーがすでに存在します")
+	// ErrConflict: 読み込んだ後に他の人が更新した（楽観的ロック）
+	ErrConflict = errors.New("他の人が先に更新しています")
+	// ErrValidation: 入力がルールに合わない。どの項目かは *ValidationError で分かる
+	ErrValidation = errors.New("入力内容に誤りがあります")
+	// ErrRoleNotGrantable: 自分では付けられない役割を付けよう（または外そう）とした (ErrValidation)
+	ErrRoleNotGrantable = newValidationError("role", "この役割を付ける権限がありません")
+)
+
+// ValidationError: 項目ごとの入力エラー。errors.Is(err, ErrValidation) で判定できる
+// Field はフォームの name (form タグ) と同じにしておくと、そのまま画面の項目の下に出せる
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string        { return e.Message }
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+func newValidationError(field, msg string) error {
+	return &ValidationError{Field: field, Message: msg}
+}
+
+// domainError はリポジトリのエラーをドメインのエラーに変える。
+// 元のエラーも %w で残すので、ログには DB のエラーがそのまま出る
+func domainError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.Is(err, repository.ErrConflict):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case errors.Is(err, repository.ErrDuplicate):
+		// どのユニーク制約に引っかかったかで分ける
+		var dup *repository.DuplicateKeyError
+		if errors.As(err, &dup) {
+			switch dup.Key {
+			case "uk_users_active_name":
+				return fmt.Errorf("%w: %w", ErrDuplicateName, err)
+			case "uk_users_active_email":
+				return fmt.Errorf("%w: %w", ErrDuplicateEmail, err)
+			}
+		}
+	}
+	return err
+}
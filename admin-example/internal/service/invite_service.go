@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-example/admin-example/internal/mail"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"go-example/admin-example/internal/token"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvitationInvalid: リンクが正しくない・使用済み・取り消された（どれかは教えない）
+	ErrInvitationInvalid = errors.New("招待のリンクが正しくないか、すでに使われています")
+	// ErrInvitationExpired: リンクの有効期限が切れている
+	ErrInvitationExpired = errors.New("招待のリンクの有効期限が切れています。管理者に招待の再送を依頼してください")
+	// ErrNotInvited: 招待中 (status = invited) ではないユーザーの招待を再送・取り消ししようとした
+	ErrNotInvited = errors.New("このユーザーは招待中ではありません")
+	// ErrInviteMailFailed: 招待は保存できたが、メールを送れなかった（一覧から再送できる）
+	ErrInviteMailFailed = errors.New("招待メールを送れませんでした。時間をおいて一覧から再送してください")
+)
+
+// InviteInput: 招待する人
+// 名前 (users.name) はメールアドレスにする。受諾した後に編集画面で変えられる
+type InviteInput struct {
+	Email       string
+	DisplayName string
+	Role        model.Role // 空なら viewer
+}
+
+// InviteOptions: 招待の設定 (config の token.invite_ttl / server.base_url)
+type InviteOptions struct {
+	TTL     time.Duration // リンクの有効期間
+	BaseURL string        // メールに書くリンクの先頭 (例: https://admin.example.com)
+}
+
+// InviteService: 管理ユーザーの招待
+//
+//  1. 管理者がメールアドレスを入れて招待する (Invite)
+//     → ユーザーを status = invited で登録し、署名付きのリンクをメールで送る
+//  2. 招待された人がリンクを開いてパスワードを決める (Accept)
+//     → ログイン用のアカウントを作り、ユーザーを active にする
+//
+// リンクは1回しか使えず、期限 (InviteOptions.TTL) を過ぎるか、取り消す (Revoke) と使えなくなる
+type InviteService interface {
+	// 登録したユーザーの ID を返す。メールだけ送れなかった時は ID と ErrInviteMailFailed
+	Invite(ctx context.Context, in InviteInput) (uint64, error)
+	// 前のリンクを取り消して、新しいリンクを送り直す
+	Resend(ctx context.Context, userID uint64) error
+	// 使われていないリンクを取り消す（ユーザーは招待中のまま残る）
+	Revoke(ctx context.Context, userID uint64) error
+
+	// 受諾画面を出す前に、リンクが使えるかを確かめる
+	CheckInvitation(ctx context.Context, tok string) (repository.UserInvitation, error)
+	Accept(ctx context.Context, tok, password string) error
+}
+
+type inviteService struct {
+	repo   repository.UserRepository
+	tx     repository.TxManager
+	mailer mail.Mailer
+	signer *token.Signer
+	opts   InviteOptions
+}
+
+func NewInviteService(r repository.UserRepository, tx repository.TxManager, m mail.Mailer, s *token.Signer, opts InviteOptions) InviteService {
+	return &inviteService{repo: r, tx: tx, mailer: m, signer: s, opts: opts}
+}
+
+// 監査ログの操作名（招待）
+const (
+	AuditActionUserInvite       = "user.invite"
+	AuditActionUserInviteResend = "user.invite_resend"
+	AuditActionUserInviteRevoke = "user.invite_revoke"
+	AuditActionUserInviteAccept = "user.invite_accept" // 操作したのは招待された本人
+)
+
+func (s *inviteService) Invite(ctx context.Context, in InviteInput) (uint64, error) {
+	email := normalizeEmail(in.Email)
+	if email == "" {
+		return 0, newValidationError("email", "メールアドレスを入力してください")
+	}
+	p := repository.UserProfile{
+		Name:        email,
+		Email:       email,
+		DisplayName: strings.TrimSpace(in.DisplayName),
+		Status:      string(model.UserStatusInvited),
+		Role:        string(model.RoleViewer),
+	}
+	if in.Role != "" {
+		p.Role = string(in.Role)
+	}
+	if err := validateProfile(p); err != nil {
+		return 0, err
+	}
+	// 受諾すると、この役割でログインできるアカウントになる
+	if err := checkRoleGrantable(ctx, model.RoleViewer, model.Role(p.Role)); err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	var tok string
+	var expires time.Time
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		if err := checkNameAvailable(ctx, repo, p.Name); err != nil {
+			return err
+		}
+		if err := checkEmailAvailable(ctx, repo, p.Email); err != nil {
+			return err
+		}
+		// 同じメールアドレスでログインできるアカウントがすでにある
+		if _, err := repo.Accounts().FindByEmail(ctx, email); err == nil {
+			return ErrEmailAlreadyUsed
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		res, err := repo.Create(ctx, p)
+		if err != nil {
+			return domainError(err)
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = uint64(lastID)
+
+		if tok, expires, err = s.createInvitation(ctx, repo, id, email); err != nil {
+			return err
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserInvite, id, nil, snapshotOfProfile(p)))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// メールはコミットしてから送る（送れなくても招待は残り、一覧から再送できる）
+	if err := s.send(ctx, email, p.DisplayName, tok, expires); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (s *inviteService) Resend(ctx context.Context, userID uint64) error {
+	var email, displayName, tok string
+	var expires time.Time
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		user, err := findInvitedUser(ctx, repo, userID)
+		if err != nil {
+			return err
+		}
+		email, displayName = user.Email.String, user.DisplayName
+
+		// 前に送ったリンクは使えなくする
+		if _, err := repo.RevokeInvitations(ctx, userID); err != nil {
+			return err
+		}
+		if tok, expires, err = s.createInvitation(ctx, repo, userID, email); err != nil {
+			return err
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserInviteResend, userID, nil, snapshotOf(user)))
+	})
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, email, displayName, tok, expires)
+}
+
+func (s *inviteService) Revoke(ctx context.Context, userID uint64) error {
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		user, err := findInvitedUser(ctx, repo, userID)
+		if err != nil {
+			return err
+		}
+		n, err := repo.RevokeInvitations(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil // 取り消すものが無い（取り消し済み）
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserInviteRevoke, userID, snapshotOf(user), nil))
+	})
+}
+
+func (s *inviteService) CheckInvitation(ctx context.Context, tok string) (repository.UserInvitation, error) {
+	if _, err := s.signer.Verify(token.PurposeInvite, tok); err != nil {
+		return repository.UserInvitation{}, invitationTokenError(err)
+	}
+	inv, _, err := findUsableInvitation(ctx, s.repo, tok)
+	return inv, err
+}
+
+func (s *inviteService) Accept(ctx context.Context, tok, password string) error {
+	// パスワードの決まりはメールアドレスも見るので、先に招待を確かめる
+	// (トランザクションの中でもう一度確かめる)
+	inv, err := s.CheckInvitation(ctx, tok)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(password, inv.Email); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// アカウントの作成・招待の使用済み・ユーザーの有効化を1つのトランザクションで行う
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		inv, user, err := findUsableInvitation(ctx, repo, tok)
+		if err != nil {
+			return err
+		}
+
+		accountID, err := repo.Accounts().Create(ctx, inv.Email, string(hash), user.Role)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrEmailAlreadyUsed
+		}
+		if err != nil {
+			return err
+		}
+		// 同時に受諾された時は、後の方がここで失敗する（アカウントの作成もロールバックされる）
+		if err := repo.AcceptInvitation(ctx, inv.ID); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return ErrInvitationInvalid
+			}
+			return err
+		}
+
+		p := repository.ProfileOf(user)
+		p.Status = string(model.UserStatusActive)
+		if err := repo.Update(ctx, user.ID, p, user.Version); err != nil {
+			return domainError(err)
+		}
+		// ログインしていないので、監査ログの操作者は作ったアカウント（招待された本人）にする
+		ctx = requestctx.WithActor(ctx, requestctx.Actor{ID: accountID, Email: inv.Email})
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserInviteAccept, user.ID, snapshotOf(user), snapshotOfProfile(p)))
+	})
+}
+
+// createInvitation はトークンを作り、そのハッシュを招待として保存する
+func (s *inviteService) createInvitation(ctx context.Context, repo repository.UserRepository, userID uint64, email string) (string, time.Time, error) {
+	tok, expires, err := s.signer.Issue(token.PurposeInvite, s.opts.TTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	_, err = repo.CreateInvitation(ctx, repository.NewInvitation{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: token.Hash(tok),
+		ExpiresAt: expires,
+	})
+	return tok, expires, err
+}
+
+// send は招待メールを送る。送れなければ ErrInviteMailFailed
+func (s *inviteService) send(ctx context.Context, email, displayName, tok string, expires time.Time) error {
+	link := strings.TrimRight(s.opts.BaseURL, "/") + "/invitations/accept?token=" + url.QueryEscape(tok)
+	name := displayName
+	if name == "" {
+		name = email
+	}
+
+	err := s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "管理画面への招待",
+		Body: name + " 様\n\n" +
+			"管理画面に招待されました。\n" +
+			"次のリンクを開いて、パスワードを設定してください。\n\n" +
+			link + "\n\n" +
+			"このリンクは " + expires.Local().Format("2006-01-02 15:04") + " まで、1回だけ使えます。\n" +
+			"心当たりが無い場合は、このメールを破棄してください。\n",
+	})
+	if err != nil {
+		return fmt.Errorf("%w (%v)", ErrInviteMailFailed, err)
+	}
+	return nil
+}
+
+// findInvitedUser は招待中のユーザーを返す。他の状態なら ErrNotInvited
+func findInvitedUser(ctx context.Context, repo repository.UserRepository, id uint64) (repository.User, error) {
+	user, err := repo.FindByID(ctx, id)
+	if err != nil {
+		return repository.User{}, domainError(err)
+	}
+	if user.Status != string(model.UserStatusInvited) {
+		return repository.User{}, ErrNotInvited
+	}
+	return user, nil
+}
+
+// findUsableInvitation はトークンの招待と、招待されたユーザーを返す。
+// 使用済み・取り消し済み・ユーザーが削除されたか招待中でなくなった時は ErrInvitationInvalid
+// (署名と期限は呼び出し側で確認しておくこと)
+func findUsableInvitation(ctx context.Context, repo repository.UserRepository, tok string) (repository.UserInvitation, repository.User, error) {
+	inv, err := repo.FindInvitationByTokenHash(ctx, token.Hash(tok))
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.UserInvitation{}, repository.User{}, ErrInvitationInvalid
+	}
+	if err != nil {
+		return repository.UserInvitation{}, repository.User{}, err
+	}
+	if inv.AcceptedAt.Valid || inv.RevokedAt.Valid {
+		return repository.UserInvitation{}, repository.User{}, ErrInvitationInvalid
+	}
+
+	user, err := findInvitedUser(ctx, repo, inv.UserID)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotInvited) {
+		return repository.UserInvitation{}, repository.User{}, ErrInvitationInvalid
+	}
+	if err != nil {
+		return repository.UserInvitation{}, repository.User{}, err
+	}
+	return inv, user, nil
+}
+
+// invitationTokenError は token の確認のエラーを招待のエラーにする
+func invitationTokenError(err error) error {
+	if errors.Is(err, token.ErrExpired) {
+		return ErrInvitationExpired
+	}
+	return ErrInvitationInvalid
+}
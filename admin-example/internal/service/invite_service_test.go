@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/mail"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type inviteFixture struct {
+	svc    InviteService
+	repo   repository.UserRepository
+	audits repository.AuditRepository
+	mailer *mail.MemoryMailer
+}
+
+func newInviteFixture(ttl time.Duration) inviteFixture {
+	s := repository.NewMemoryStore()
+	repo := repository.NewMemoryUserRepository(s)
+	mailer := mail.NewMemoryMailer()
+	svc := NewInviteService(repo, repository.NewMemoryTxManager(s), mailer,
+		token.NewSigner([]byte("test-secret")), InviteOptions{TTL: ttl, BaseURL: "https://admin.example.com/"})
+	return inviteFixture{svc: svc, repo: repo, audits: repository.NewMemoryAuditRepository(s), mailer: mailer}
+}
+
+var inviteLinkPattern = regexp.MustCompile(`https://admin\.example\.com/invitations/accept\?token=(\S+)`)
+
+// lastInviteToken は最後に送ったメールのリンクからトークンを取り出す
+func (f inviteFixture) lastInviteToken(t *testing.T) string {
+	t.Helper()
+	sent := f.mailer.Sent()
+	require.NotEmpty(t, sent)
+	m := inviteLinkPattern.FindStringSubmatch(sent[len(sent)-1].Body)
+	require.NotNil(t, m, "メールにリンクが無い")
+	tok, err := url.QueryUnescape(m[1])
+	require.NoError(t, err)
+	return tok
+}
+
+func TestInviteService_InviteAndAccept(t *testing.T) {
+	f := newInviteFixture(time.Hour)
+	ctx := actorContext(model.RoleAdmin)
+
+	id, err := f.svc.Invite(ctx, InviteInput{Email: " Taro@Example.com ", DisplayName: "太郎", Role: model.RoleEditor})
+	require.NoError(t, err)
+
+	// 招待中のユーザーとして登録され、メールが届く
+	user, err := f.repo.FindByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "invited", user.Status)
+	assert.Equal(t, "taro@example.com", user.Email.String)
+	if sent := f.mailer.Sent(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "taro@example.com", sent[0].To)
+		assert.Contains(t, sent[0].Body, "太郎 様")
+	}
+	tok := f.lastInviteToken(t)
+
+	inv, err := f.svc.CheckInvitation(ctx, tok)
+	require.NoError(t, err)
+	assert.Equal(t, id, inv.UserID)
+
+	// パスワードを決めると、ログインできるアカウントができてユーザーが有効になる
+	require.NoError(t, f.svc.Accept(ctx, tok, "correct-horse"))
+
+	user, _ = f.repo.FindByID(ctx, id)
+	assert.Equal(t, "active", user.Status)
+	acc, err := f.repo.Accounts().FindByEmail(ctx, "taro@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "editor", acc.Role)
+	assert.NotEqual(t, "correct-horse", acc.PasswordHash)
+
+	// 受諾は本人の操作として監査ログに残る
+	logs, err := f.audits.Search(ctx, repository.AuditSearchParams{ActorEmail: "taro@example.com", Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, AuditActionUserInviteAccept, logs[0].Action)
+		assert.Equal(t, acc.ID, logs[0].ActorID)
+	}
+
+	// 同じリンクは2回使えない
+	assert.ErrorIs(t, f.svc.Accept(ctx, tok, "correct-horse"), ErrInvitationInvalid)
+}
+
+func TestInviteService_Invite_Validation(t *testing.T) {
+	f := newInviteFixture(time.Hour)
+	ctx := context.Background()
+
+	_, err := f.svc.Invite(ctx, InviteInput{Email: ""})
+	var ve *ValidationError
+	if assert.True(t, errors.As(err, &ve)) {
+		assert.Equal(t, "email", ve.Field)
+	}
+
+	// 招待中のユーザーと同じメールアドレスは招待できない
+	_, err = f.svc.Invite(ctx, InviteInput{Email: "taro@example.com"})
+	require.NoError(t, err)
+	_, err = f.svc.Invite(ctx, InviteInput{Email: "TARO@example.com"})
+	assert.Error(t, err)
+	assert.Len(t, f.mailer.Sent(), 1)
+}
+
+func TestInviteService_Accept_WeakPasswordOrBadToken(t *testing.T) {
+	f := newInviteFixture(time.Hour)
+	ctx := context.Background()
+	id, err := f.svc.Invite(ctx, InviteInput{Email: "taro@example.com"})
+	require.NoError(t, err)
+	tok := f.lastInviteToken(t)
+
+	assert.ErrorIs(t, f.svc.Accept(ctx, tok, "short"), ErrWeakPassword)
+	assert.ErrorIs(t, f.svc.Accept(ctx, tok+"x", "correct-horse"), ErrInvitationInvalid)
+
+	// 失敗しても何も変わらない
+	user, _ := f.repo.FindByID(ctx, id)
+	assert.Equal(t, "invited", user.Status)
+}
+
+func TestInviteService_Accept_Expired(t *testing.T) {
+	f := newInviteFixture(-time.Minute) // 作った時にはもう期限切れ
+	ctx := context.Background()
+	_, err := f.svc.Invite(ctx, InviteInput{Email: "taro@example.com"})
+	require.NoError(t, err)
+	tok := f.lastInviteToken(t)
+
+	_, err = f.svc.CheckInvitation(ctx, tok)
+	assert.ErrorIs(t, err, ErrInvitationExpired)
+	assert.ErrorIs(t, f.svc.Accept(ctx, tok, "correct-horse"), ErrInvitationExpired)
+}
+
+func TestInviteService_ResendAndRevoke(t *testing.T) {
+	f := newInviteFixture(time.Hour)
+	ctx := context.Background()
+	id, err := f.svc.Invite(ctx, InviteInput{Email: "taro@example.com"})
+	require.NoError(t, err)
+	first := f.lastInviteToken(t)
+
+	// 再送すると前のリンクは使えなくなる
+	require.NoError(t, f.svc.Resend(ctx, id))
+	second := f.lastInviteToken(t)
+	assert.NotEqual(t, first, second)
+	_, err = f.svc.CheckInvitation(ctx, first)
+	assert.ErrorIs(t, err, ErrInvitationInvalid)
+	_, err = f.svc.CheckInvitation(ctx, second)
+	assert.NoError(t, err)
+
+	// 取り消すと新しいリンクも使えない
+	require.NoError(t, f.svc.Revoke(ctx, id))
+	assert.ErrorIs(t, f.svc.Accept(ctx, second, "correct-horse"), ErrInvitationInvalid)
+
+	// 受諾したユーザーは招待中ではないので再送できない
+	require.NoError(t, f.svc.Resend(ctx, id))
+	require.NoError(t, f.svc.Accept(ctx, f.lastInviteToken(t), "correct-horse"))
+	assert.ErrorIs(t, f.svc.Resend(ctx, id), ErrNotInvited)
+	assert.ErrorIs(t, f.svc.Revoke(ctx, id), ErrNotInvited)
+}
+
+// failingMailer: いつも送信に失敗する Mailer
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, m mail.Message) error {
+	return errors.New("connection refused")
+}
+
+func TestInviteService_Invite_MailFailed(t *testing.T) {
+	s := repository.NewMemoryStore()
+	repo := repository.NewMemoryUserRepository(s)
+	svc := NewInviteService(repo, repository.NewMemoryTxManager(s), failingMailer{},
+		token.NewSigner([]byte("test-secret")), InviteOptions{TTL: time.Hour})
+
+	id, err := svc.Invite(context.Background(), InviteInput{Email: "taro@example.com"})
+
+	// 招待は残るので、一覧から再送できる
+	assert.ErrorIs(t, err, ErrInviteMailFailed)
+	assert.NotZero(t, id)
+	user, err := repo.FindByID(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, "invited", user.Status)
+}
+
+func TestInviteService_Invite_RoleNotGrantable(t *testing.T) {
+	f := newInviteFixture(time.Hour)
+
+	// 編集者は管理者・編集者として招待できない（受諾すればその役割でログインできてしまう）
+	for _, role := range []model.Role{model.RoleAdmin, model.RoleEditor} {
+		_, err := f.svc.Invite(actorContext(model.RoleEditor), InviteInput{Email: "me@example.com", Role: role})
+		assert.ErrorIs(t, err, ErrRoleNotGrantable)
+	}
+	assert.Empty(t, f.mailer.Sent())
+
+	_, err := f.svc.Invite(actorContext(model.RoleEditor), InviteInput{Email: "me@example.com", Role: model.RoleViewer})
+	assert.NoError(t, err)
+}
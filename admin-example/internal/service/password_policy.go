@@ -0,0 +1,50 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// パスワードの決まり（アカウントの作成・招待の受諾・パスワードの再設定で共通）
+//   - MinPasswordLength 文字以上、MaxPasswordBytes バイト以内
+//   - 同じ文字だけの繰り返し、メールアドレスを含むもの、よく使われているものは使えない
+//
+// 記号や数字を必ず入れる、といった決まりは「覚えにくいだけで強くならない」ので入れていない
+const (
+	// パスワードの最低文字数
+	MinPasswordLength = 8
+	// bcrypt は72バイトより後ろを無視するので、それより長いものは受け付けない
+	MaxPasswordBytes = 72
+)
+
+// よく使われているパスワード（小文字にして比べる）
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "passw0rd": true,
+	"12345678": true, "123456789": true, "1234567890": true, "11111111": true,
+	"qwertyui": true, "qwerty123": true, "qwertyuiop": true, "1q2w3e4r": true,
+	"abc12345": true, "iloveyou": true, "welcome1": true, "letmein1": true,
+	"admin123": true, "administrator": true, "changeme": true, "sunshine": true,
+}
+
+// checkPassword はパスワードが決まりに合っているかを確認する。合っていなければ "password" の ValidationError
+// email はそのアカウントのメールアドレス（含まれていたら弾く）。分からなければ空でよい
+func checkPassword(password, email string) error {
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	if len(password) > MaxPasswordBytes {
+		return newValidationError("password", "パスワードは72バイト（半角72文字）以内にしてください")
+	}
+
+	lower := strings.ToLower(password)
+	if first, _ := utf8.DecodeRuneInString(lower); strings.Trim(lower, string(first)) == "" {
+		return newValidationError("password", "同じ文字の繰り返しはパスワードに使えません")
+	}
+	if local, _, _ := strings.Cut(normalizeEmail(email), "@"); len(local) >= 3 && strings.Contains(lower, local) {
+		return newValidationError("password", "メールアドレスを含むパスワードは使えません")
+	}
+	if commonPasswords[lower] {
+		return newValidationError("password", "よく使われているパスワードは使えません。推測されにくいものにしてください")
+	}
+	return nil
+}
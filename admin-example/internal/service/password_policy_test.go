@@ -0,0 +1,28 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPassword(t *testing.T) {
+	assert.NoError(t, checkPassword("correct-horse", "taro@example.com"))
+	assert.NoError(t, checkPassword("正しい馬のバッテリー", "taro@example.com")) // 文字数で数える
+
+	assert.ErrorIs(t, checkPassword("short", ""), ErrWeakPassword)
+	for name, pw := range map[string]string{
+		"長すぎる":      strings.Repeat("a1", 37),
+		"同じ文字だけ":    "aaaaaaaaaa",
+		"メールアドレス入り": "Taro-2026!",
+		"よくあるもの":    "Password1",
+	} {
+		err := checkPassword(pw, "taro@example.com")
+		var ve *ValidationError
+		if assert.True(t, errors.As(err, &ve), name) {
+			assert.Equal(t, "password", ve.Field, name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-example/admin-example/internal/mail"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"go-example/admin-example/internal/sessionstore"
+	"go-example/admin-example/internal/token"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrResetInvalid: リンクが正しくない・使用済み（どちらかは教えない）
+	ErrResetInvalid = errors.New("再設定のリンクが正しくないか、すでに使われています")
+	// ErrResetExpired: リンクの有効期限が切れている
+	ErrResetExpired = errors.New("再設定のリンクの有効期限が切れています。もう一度申し込んでください")
+	// ErrResetMailFailed: リンクは作れたが、メールを送れなかった
+	ErrResetMailFailed = errors.New("再設定のメールを送れませんでした")
+)
+
+// 監査ログの操作名（アカウント）
+const AuditActionAccountPasswordReset = "account.password_reset" // 操作したのは本人
+
+// PasswordResetOptions: パスワードの再設定の設定 (config の token.password_reset_ttl / server.base_url)
+type PasswordResetOptions struct {
+	TTL     time.Duration // リンクの有効期間
+	BaseURL string        // メールに書くリンクの先頭
+}
+
+// PasswordResetService: パスワードを忘れた時の再設定
+//
+//  1. ログイン画面からメールアドレスを入れて申し込む (Request)
+//     → アカウントがあれば、署名付きのリンクをメールで送る
+//  2. リンクを開いて新しいパスワードを決める (Reset)
+//     → パスワードを変え、ログイン中のセッションを全てログアウトさせる
+//
+// リンクは1回しか使えず、新しく申し込むと前のリンクは使えなくなる
+// 申し込みの回数の制限は Controller で行う (internal/ratelimit)
+type PasswordResetService interface {
+	// アカウントが無くてもエラーにしない（登録されているメールアドレスかを教えない）
+	// メールを送れなかった時は ErrResetMailFailed
+	Request(ctx context.Context, email, ip string) error
+	// 再設定画面を出す前に、リンクが使えるかを確かめてアカウントを返す
+	CheckReset(ctx context.Context, tok string) (repository.Account, error)
+	Reset(ctx context.Context, tok, password string) error
+}
+
+type passwordResetService struct {
+	accounts repository.AccountRepository
+	tx       repository.TxManager
+	sessions sessionstore.Backend // 再設定したら、そのアカウントのセッションを消す
+	mailer   mail.Mailer
+	signer   *token.Signer
+	opts     PasswordResetOptions
+}
+
+func NewPasswordResetService(a repository.AccountRepository, tx repository.TxManager, b sessionstore.Backend, m mail.Mailer, s *token.Signer, opts PasswordResetOptions) PasswordResetService {
+	return &passwordResetService{accounts: a, tx: tx, sessions: b, mailer: m, signer: s, opts: opts}
+}
+
+func (s *passwordResetService) Request(ctx context.Context, email, ip string) error {
+	acc, err := s.accounts.FindByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tok, expires, err := s.signer.Issue(token.PurposePasswordReset, s.opts.TTL)
+	if err != nil {
+		return err
+	}
+	err = s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		// 使えるのは最後に送ったリンクだけにする
+		if _, err := repo.Accounts().ExpirePasswordResets(ctx, acc.ID); err != nil {
+			return err
+		}
+		_, err := repo.Accounts().CreatePasswordReset(ctx, repository.NewPasswordReset{
+			AccountID:   acc.ID,
+			TokenHash:   token.Hash(tok),
+			ExpiresAt:   expires,
+			RequestedIP: ip,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.opts.BaseURL, "/") + "/password/reset?token=" + url.QueryEscape(tok)
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      acc.Email,
+		Subject: "パスワードの再設定",
+		Body: "パスワードの再設定が申し込まれました。\n" +
+			"次のリンクを開いて、新しいパスワードを設定してください。\n\n" +
+			link + "\n\n" +
+			"このリンクは " + expires.Local().Format("2006-01-02 15:04") + " まで、1回だけ使えます。\n" +
+			"申し込んだ覚えが無い場合は、このメールを破棄してください（パスワードは変わりません）。\n",
+	})
+	if err != nil {
+		return fmt.Errorf("%w (%v)", ErrResetMailFailed, err)
+	}
+	return nil
+}
+
+func (s *passwordResetService) CheckReset(ctx context.Context, tok string) (repository.Account, error) {
+	if _, err := s.signer.Verify(token.PurposePasswordReset, tok); err != nil {
+		if errors.Is(err, token.ErrExpired) {
+			return repository.Account{}, ErrResetExpired
+		}
+		return repository.Account{}, ErrResetInvalid
+	}
+	_, acc, err := findUsableReset(ctx, s.accounts, tok)
+	return acc, err
+}
+
+func (s *passwordResetService) Reset(ctx context.Context, tok, password string) error {
+	// パスワードの決まりはメールアドレスも見るので、先にリンクを確かめる
+	acc, err := s.CheckReset(ctx, tok)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(password, acc.Email); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// リンクの使用済み・パスワードの変更・監査ログを1つのトランザクションで行う
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		accounts := repo.Accounts()
+		pr, acc, err := findUsableReset(ctx, accounts, tok)
+		if err != nil {
+			return err
+		}
+		// 同時に使われた時は、後の方がここで失敗する
+		if err := accounts.UsePasswordReset(ctx, pr.ID); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return ErrResetInvalid
+			}
+			return err
+		}
+		// session_version も +1 されるので、ログイン中のセッションは全てログアウトになる
+		if err := accounts.UpdatePassword(ctx, acc.ID, string(hash)); err != nil {
+			return err
+		}
+		// もう使えないセッションがセッションの一覧画面に残らないよう、保存先からも消す
+		// (保存先はトランザクションの外なので、失敗したらパスワードも変えずにやり直してもらう)
+		if _, err := s.sessions.DeleteByAccount(ctx, acc.ID); err != nil {
+			return err
+		}
+		if _, err := accounts.ExpirePasswordResets(ctx, acc.ID); err != nil {
+			return err
+		}
+
+		// ログインしていないので、監査ログの操作者は本人にする（パスワードは残さない）
+		ctx = requestctx.WithActor(ctx, requestctx.Actor{ID: acc.ID, Email: acc.Email})
+		return repo.RecordAudit(ctx, repository.AuditEntry{
+			Action:     AuditActionAccountPasswordReset,
+			TargetType: auditTargetAccount,
+			TargetID:   acc.ID,
+			After:      map[string]string{"requested_ip": pr.RequestedIp},
+		})
+	})
+}
+
+// findUsableReset はトークンのリンクとアカウントを返す。使用済み・アカウントが無い時は ErrResetInvalid
+// (署名と期限は呼び出し側で確認しておくこと)
+func findUsableReset(ctx context.Context, accounts repository.AccountRepository, tok string) (repository.PasswordReset, repository.Account, error) {
+	pr, err := accounts.FindPasswordResetByTokenHash(ctx, token.Hash(tok))
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.PasswordReset{}, repository.Account{}, ErrResetInvalid
+	}
+	if err != nil {
+		return repository.PasswordReset{}, repository.Account{}, err
+	}
+	if pr.UsedAt.Valid {
+		return repository.PasswordReset{}, repository.Account{}, ErrResetInvalid
+	}
+
+	acc, err := accounts.FindByID(ctx, pr.AccountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.PasswordReset{}, repository.Account{}, ErrResetInvalid
+	}
+	if err != nil {
+		return repository.PasswordReset{}, repository.Account{}, err
+	}
+	return pr, acc, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/mail"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/sessionstore"
+	"go-example/admin-example/internal/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type resetFixture struct {
+	svc      PasswordResetService
+	auth     AuthService
+	accounts repository.AccountRepository
+	sessions sessionstore.Backend
+	mailer   *mail.MemoryMailer
+}
+
+// newResetFixture は taro@example.com のアカウントがある状態を作る
+func newResetFixture(t *testing.T, ttl time.Duration) resetFixture {
+	t.Helper()
+	s := repository.NewMemoryStore()
+	accounts := repository.NewMemoryAccountRepository(s)
+	sessions := sessionstore.NewMemoryBackend()
+	mailer := mail.NewMemoryMailer()
+	f := resetFixture{
+		svc: NewPasswordResetService(accounts, repository.NewMemoryTxManager(s), sessions, mailer,
+			token.NewSigner([]byte("test-secret")), PasswordResetOptions{TTL: ttl, BaseURL: "https://admin.example.com"}),
+		auth:     NewAuthService(accounts),
+		accounts: accounts,
+		sessions: sessions,
+		mailer:   mailer,
+	}
+	_, err := f.auth.CreateAccount(context.Background(), "taro@example.com", "correct-horse", model.RoleEditor)
+	require.NoError(t, err)
+	return f
+}
+
+var resetLinkPattern = regexp.MustCompile(`https://admin\.example\.com/password/reset\?token=(\S+)`)
+
+// lastResetToken は最後に送ったメールのリンクからトークンを取り出す
+func (f resetFixture) lastResetToken(t *testing.T) string {
+	t.Helper()
+	sent := f.mailer.Sent()
+	require.NotEmpty(t, sent)
+	m := resetLinkPattern.FindStringSubmatch(sent[len(sent)-1].Body)
+	require.NotNil(t, m, "メールにリンクが無い")
+	tok, err := url.QueryUnescape(m[1])
+	require.NoError(t, err)
+	return tok
+}
+
+func TestPasswordResetService_RequestAndReset(t *testing.T) {
+	f := newResetFixture(t, time.Hour)
+	ctx := context.Background()
+	before, _ := f.accounts.FindByEmail(ctx, "taro@example.com")
+	// 本人のログイン中のセッションと、他の人のセッション
+	now := time.Now()
+	own := sessionstore.Record{ID: "own", AccountID: before.ID, CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	other := sessionstore.Record{ID: "other", AccountID: before.ID + 1, CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	require.NoError(t, f.sessions.Create(ctx, own))
+	require.NoError(t, f.sessions.Create(ctx, other))
+
+	require.NoError(t, f.svc.Request(ctx, " TARO@example.com ", "192.0.2.1"))
+	if sent := f.mailer.Sent(); assert.Len(t, sent, 1) {
+		assert.Equal(t, "taro@example.com", sent[0].To)
+	}
+	tok := f.lastResetToken(t)
+
+	acc, err := f.svc.CheckReset(ctx, tok)
+	require.NoError(t, err)
+	assert.Equal(t, before.ID, acc.ID)
+
+	require.NoError(t, f.svc.Reset(ctx, tok, "battery-staple"))
+
+	// 新しいパスワードでだけログインでき、今までのセッションは使えなくなる
+	_, err = f.auth.Authenticate(ctx, "taro@example.com", "battery-staple")
+	assert.NoError(t, err)
+	_, err = f.auth.Authenticate(ctx, "taro@example.com", "correct-horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	after, _ := f.accounts.FindByEmail(ctx, "taro@example.com")
+	assert.Equal(t, before.SessionVersion+1, after.SessionVersion)
+	// セッションの保存先からも本人の分だけ消える（一覧画面に残らない）
+	_, err = f.sessions.Load(ctx, own.ID, now)
+	assert.ErrorIs(t, err, sessionstore.ErrNotFound)
+	_, err = f.sessions.Load(ctx, other.ID, now)
+	assert.NoError(t, err)
+
+	// 同じリンクは2回使えない
+	assert.ErrorIs(t, f.svc.Reset(ctx, tok, "another-secret"), ErrResetInvalid)
+}
+
+func TestPasswordResetService_Request_UnknownEmail(t *testing.T) {
+	f := newResetFixture(t, time.Hour)
+
+	// 登録されているかは教えない（エラーにせず、メールも送らない）
+	assert.NoError(t, f.svc.Request(context.Background(), "nobody@example.com", "192.0.2.1"))
+	assert.Empty(t, f.mailer.Sent())
+}
+
+func TestPasswordResetService_OnlyLatestLinkWorks(t *testing.T) {
+	f := newResetFixture(t, time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, f.svc.Request(ctx, "taro@example.com", "192.0.2.1"))
+	first := f.lastResetToken(t)
+	require.NoError(t, f.svc.Request(ctx, "taro@example.com", "192.0.2.1"))
+	second := f.lastResetToken(t)
+
+	_, err := f.svc.CheckReset(ctx, first)
+	assert.ErrorIs(t, err, ErrResetInvalid)
+	_, err = f.svc.CheckReset(ctx, second)
+	assert.NoError(t, err)
+}
+
+func TestPasswordResetService_Reset_Errors(t *testing.T) {
+	f := newResetFixture(t, time.Hour)
+	ctx := context.Background()
+	require.NoError(t, f.svc.Request(ctx, "taro@example.com", "192.0.2.1"))
+	tok := f.lastResetToken(t)
+
+	// パスワードの決まりに合わなければ変えない（リンクもまだ使える）
+	assert.ErrorIs(t, f.svc.Reset(ctx, tok, "short"), ErrWeakPassword)
+	assert.ErrorIs(t, f.svc.Reset(ctx, tok, "taro-no-password"), ErrValidation) // メールアドレスを含む
+	assert.ErrorIs(t, f.svc.Reset(ctx, tok+"x", "battery-staple"), ErrResetInvalid)
+	_, err := f.svc.CheckReset(ctx, tok)
+	assert.NoError(t, err)
+
+	// 期限切れ
+	f = newResetFixture(t, -time.Minute)
+	require.NoError(t, f.svc.Request(ctx, "taro@example.com", "192.0.2.1"))
+	assert.ErrorIs(t, f.svc.Reset(ctx, f.lastResetToken(t), "battery-staple"), ErrResetExpired)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/sessionstore"
+	"time"
+)
+
+// ErrSessionNotFound: もう終わっている（ログアウト・期限切れ・強制終了済み）
+var ErrSessionNotFound = errors.New("このセッションはすでに終了しています")
+
+// 監査ログの操作名（セッション）
+const AuditActionAccountSessionRevoke = "account.session_revoke" // 管理者がログイン中のセッションを終了させた
+
+// SessionInfo: 一覧画面に出すログイン中のセッション
+type SessionInfo struct {
+	ID        string // sessionstore.Record.ID（Cookie の ID ではない）
+	AccountID uint64
+	Email     string // アカウントが消されていれば空
+	Role      string
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// SessionService: ログイン中のセッションの一覧と強制終了（管理者用）
+type SessionService interface {
+	List(ctx context.Context) ([]SessionInfo, error)
+	// 終了させたセッションの人は、次の操作でログイン画面に戻される
+	Revoke(ctx context.Context, id string) error
+}
+
+type sessionService struct {
+	sessions sessionstore.Backend
+	accounts repository.AccountRepository
+	users    repository.UserRepository // 監査ログを書くため
+	now      func() time.Time
+}
+
+func NewSessionService(b sessionstore.Backend, a repository.AccountRepository, u repository.UserRepository) SessionService {
+	return &sessionService{sessions: b, accounts: a, users: u, now: time.Now}
+}
+
+func (s *sessionService) List(ctx context.Context) ([]SessionInfo, error) {
+	records, err := s.sessions.ListAuthenticated(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	// 同じアカウントのセッションは何件もあるので、アカウントは1回ずつ読む
+	accounts := map[uint64]repository.Account{}
+	list := make([]SessionInfo, 0, len(records))
+	for _, r := range records {
+		acc, ok := accounts[r.AccountID]
+		if !ok {
+			acc, err = s.accounts.FindByID(ctx, r.AccountID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			accounts[r.AccountID] = acc
+		}
+		list = append(list, SessionInfo{
+			ID:        r.ID,
+			AccountID: r.AccountID,
+			Email:     acc.Email,
+			Role:      acc.Role,
+			IP:        r.IP,
+			UserAgent: r.UserAgent,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+			ExpiresAt: r.ExpiresAt,
+		})
+	}
+	return list, nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, id string) error {
+	// 監査ログに誰のどの端末かを残すため、消す前に読む
+	rec, err := s.sessions.Load(ctx, id, s.now())
+	if err == nil {
+		err = s.sessions.Delete(ctx, id)
+	}
+	if errors.Is(err, sessionstore.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.users.RecordAudit(ctx, repository.AuditEntry{
+		Action:     AuditActionAccountSessionRevoke,
+		TargetType: auditTargetAccount,
+		TargetID:   rec.AccountID,
+		Before: map[string]string{
+			"ip":         rec.IP,
+			"user_agent": rec.UserAgent,
+			"created_at": rec.CreatedAt.Format(time.RFC3339),
+		},
+	})
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"go-example/admin-example/internal/sessionstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionService_ListAndRevoke(t *testing.T) {
+	s := repository.NewMemoryStore()
+	repos := repository.NewMemoryRepositories(s)
+	svc := NewSessionService(repos.Sessions, repos.Accounts, repos.Users)
+	ctx := context.Background()
+
+	adminID, err := repos.Accounts.Create(ctx, "admin@example.com", "hash", "admin")
+	require.NoError(t, err)
+	now := time.Now()
+	for id, accountID := range map[string]uint64{"a": adminID, "b": adminID, "gone": 999} {
+		require.NoError(t, repos.Sessions.Create(ctx, sessionstore.Record{
+			ID: id, AccountID: accountID, IP: "192.0.2.1", CreatedAt: now, UpdatedAt: now, ExpiresAt: now.Add(time.Hour),
+		}))
+	}
+
+	// 消されたアカウントのセッションも、メールアドレスを空にして出す
+	list, err := svc.List(ctx)
+	require.NoError(t, err)
+	emails := map[string]string{}
+	for _, info := range list {
+		emails[info.ID] = info.Email
+	}
+	assert.Equal(t, map[string]string{"a": "admin@example.com", "b": "admin@example.com", "gone": ""}, emails)
+
+	// 強制終了は監査ログに残る
+	actx := requestctx.WithActor(ctx, requestctx.Actor{ID: adminID, Email: "admin@example.com"})
+	require.NoError(t, svc.Revoke(actx, "a"))
+	assert.ErrorIs(t, svc.Revoke(actx, "a"), ErrSessionNotFound)
+
+	list, err = svc.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	logs, err := repos.Audit.Search(ctx, repository.AuditSearchParams{ActorEmail: "admin@example.com", Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, AuditActionAccountSessionRevoke, logs[0].Action)
+		assert.Equal(t, adminID, logs[0].TargetID)
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"go-example/admin-example/internal/secretbox"
+	"go-example/admin-example/internal/token"
+	"go-example/admin-example/internal/totp"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrTwoFactorInvalidCode: 認証アプリのコードもリカバリーコードも合わない（使用済みを含む, ErrValidation）
+	ErrTwoFactorInvalidCode = newValidationError("code", "確認コードが正しくありません")
+	// ErrTwoFactorNotEnrolling: 設定を始めていない（QR コードを出していない）
+	ErrTwoFactorNotEnrolling = errors.New("2要素認証の設定を始めてください")
+	// ErrTwoFactorAlreadyEnabled: すでに有効になっている
+	ErrTwoFactorAlreadyEnabled = errors.New("2要素認証はすでに有効です")
+	// ErrTwoFactorNotEnabled: 有効になっていない
+	ErrTwoFactorNotEnabled = errors.New("2要素認証は有効になっていません")
+)
+
+// 監査ログの操作名（2要素認証。操作したのはどれも本人）
+const (
+	AuditActionAccountTwoFactorEnable         = "account.2fa_enable"
+	AuditActionAccountTwoFactorDisable        = "account.2fa_disable"
+	AuditActionAccountRecoveryCodesRegenerate = "account.recovery_codes_regenerate"
+	AuditActionAccountRecoveryCodeUse         = "account.recovery_code_use" // ログインでリカバリーコードを使った
+)
+
+// RecoveryCodeCount: 一度に作るリカバリーコードの数
+const RecoveryCodeCount = 10
+
+// リカバリーコードに使う文字（0/o, 1/l/i のような読み間違えやすいものは除く）
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// TwoFactorOptions: 2要素認証の設定 (config の mfa)
+type TwoFactorOptions struct {
+	Issuer        string       // 認証アプリに表示する名前
+	RequiredRoles []model.Role // 2要素認証を必ず使う役割
+}
+
+// TwoFactorStatus: 設定画面に出す、アカウントの2要素認証の状態
+type TwoFactorStatus struct {
+	Enabled           bool
+	Enrolling         bool // 秘密鍵は作ったが、まだ確認コードを入れていない
+	Required          bool // 役割で必須になっている
+	RecoveryCodesLeft int64
+}
+
+// Enrollment: 認証アプリに登録する秘密鍵
+type Enrollment struct {
+	Secret string // 手で入れる時の文字列 (base32)
+	URI    string // QR コードにする otpauth:// の URI
+}
+
+// TwoFactorService: 認証アプリ (TOTP) による2要素認証
+//
+//  1. 設定画面で QR コードを出す (BeginEnrollment)
+//     → 秘密鍵を暗号化して保存する（この時点ではまだ有効ではない）
+//  2. 認証アプリのコードを入れて有効にする (Enable)
+//     → リカバリーコードを作って1回だけ見せる
+//  3. ログインの時、パスワードの後にコードを入れる (Verify)
+//
+// 同じコードは2回使えない（最後に使ったコードの区切りを覚えておく）
+type TwoFactorService interface {
+	// Required はその役割で2要素認証が必須かどうか
+	Required(role model.Role) bool
+	Status(ctx context.Context, accountID uint64) (TwoFactorStatus, error)
+	// BeginEnrollment は新しい秘密鍵を作る（設定の途中なら作り直す）
+	BeginEnrollment(ctx context.Context, accountID uint64) (Enrollment, error)
+	// Enrollment は設定の途中の秘密鍵を返す（QR コードを出すため）
+	Enrollment(ctx context.Context, accountID uint64) (Enrollment, error)
+	// Enable は認証アプリのコードを確かめて有効にし、リカバリーコードを返す
+	Enable(ctx context.Context, accountID uint64, code string) ([]string, error)
+	// Disable と RegenerateRecoveryCodes は、認証アプリのコードかリカバリーコードで本人か確かめる
+	// (認証アプリを無くした時に設定し直せるよう、必須の役割でも無効にはできる。
+	// その場合は設定し直すまで管理画面を使えない)
+	Disable(ctx context.Context, accountID uint64, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, accountID uint64, code string) ([]string, error)
+	// Verify はログインの2段階目。リカバリーコードを使った時は recovery が true
+	Verify(ctx context.Context, accountID uint64, code string) (acc repository.Account, recovery bool, err error)
+}
+
+type twoFactorService struct {
+	accounts repository.AccountRepository
+	tx       repository.TxManager
+	box      *secretbox.Box
+	opts     TwoFactorOptions
+	now      func() time.Time
+}
+
+func NewTwoFactorService(a repository.AccountRepository, tx repository.TxManager, box *secretbox.Box, opts TwoFactorOptions) TwoFactorService {
+	return &twoFactorService{accounts: a, tx: tx, box: box, opts: opts, now: time.Now}
+}
+
+func (s *twoFactorService) Required(role model.Role) bool {
+	for _, r := range s.opts.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *twoFactorService) Status(ctx context.Context, accountID uint64) (TwoFactorStatus, error) {
+	acc, err := s.accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return TwoFactorStatus{}, err
+	}
+	st := TwoFactorStatus{
+		Enabled:   acc.TotpEnabledAt.Valid,
+		Enrolling: !acc.TotpEnabledAt.Valid && acc.TotpSecret != nil,
+		Required:  s.Required(model.Role(acc.Role)),
+	}
+	if st.Enabled {
+		if st.RecoveryCodesLeft, err = s.accounts.CountRecoveryCodes(ctx, accountID); err != nil {
+			return TwoFactorStatus{}, err
+		}
+	}
+	return st, nil
+}
+
+func (s *twoFactorService) BeginEnrollment(ctx context.Context, accountID uint64) (Enrollment, error) {
+	acc, err := s.accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if acc.TotpEnabledAt.Valid {
+		return Enrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+	sealed, err := s.box.Seal([]byte(secret))
+	if err != nil {
+		return Enrollment{}, err
+	}
+	// 画面を開いている間に別のタブで有効にされていたら ErrConflict になる
+	if err := s.accounts.SetTOTPSecret(ctx, accountID, sealed); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return Enrollment{}, ErrTwoFactorAlreadyEnabled
+		}
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: totp.URI(s.opts.Issuer, acc.Email, secret)}, nil
+}
+
+func (s *twoFactorService) Enrollment(ctx context.Context, accountID uint64) (Enrollment, error) {
+	acc, err := s.accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	if acc.TotpEnabledAt.Valid || acc.TotpSecret == nil {
+		return Enrollment{}, ErrTwoFactorNotEnrolling
+	}
+	secret, err := s.openSecret(acc)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	return Enrollment{Secret: secret, URI: totp.URI(s.opts.Issuer, acc.Email, secret)}, nil
+}
+
+func (s *twoFactorService) Enable(ctx context.Context, accountID uint64, code string) ([]string, error) {
+	acc, err := s.accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if acc.TotpEnabledAt.Valid {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if acc.TotpSecret == nil {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+	secret, err := s.openSecret(acc)
+	if err != nil {
+		return nil, err
+	}
+	// 認証アプリに正しく登録できたことを、コードで確かめてから有効にする
+	step, ok := totp.Validate(secret, normalizeTOTPCode(code), s.now())
+	if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		accounts := repo.Accounts()
+		if err := accounts.EnableTOTP(ctx, accountID, step); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return ErrTwoFactorAlreadyEnabled
+			}
+			return err
+		}
+		if err := accounts.ReplaceRecoveryCodes(ctx, accountID, hashes); err != nil {
+			return err
+		}
+		return repo.RecordAudit(ctx, repository.AuditEntry{
+			Action:     AuditActionAccountTwoFactorEnable,
+			TargetType: auditTargetAccount,
+			TargetID:   accountID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(ctx context.Context, accountID uint64, code string) error {
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		accounts := repo.Accounts()
+		if _, err := s.useCode(ctx, accounts, accountID, code); err != nil {
+			return err
+		}
+		if err := accounts.DisableTOTP(ctx, accountID); err != nil {
+			return err
+		}
+		if err := accounts.ReplaceRecoveryCodes(ctx, accountID, nil); err != nil {
+			return err
+		}
+		return repo.RecordAudit(ctx, repository.AuditEntry{
+			Action:     AuditActionAccountTwoFactorDisable,
+			TargetType: auditTargetAccount,
+			TargetID:   accountID,
+		})
+	})
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, accountID uint64, code string) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		accounts := repo.Accounts()
+		if _, err := s.useCode(ctx, accounts, accountID, code); err != nil {
+			return err
+		}
+		// 古いコードは（残っていても）全て使えなくなる
+		if err := accounts.ReplaceRecoveryCodes(ctx, accountID, hashes); err != nil {
+			return err
+		}
+		return repo.RecordAudit(ctx, repository.AuditEntry{
+			Action:     AuditActionAccountRecoveryCodesRegenerate,
+			TargetType: auditTargetAccount,
+			TargetID:   accountID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *twoFactorService) Verify(ctx context.Context, accountID uint64, code string) (repository.Account, bool, error) {
+	var (
+		acc      repository.Account
+		recovery bool
+	)
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		accounts := repo.Accounts()
+		var err error
+		if recovery, err = s.useCode(ctx, accounts, accountID, code); err != nil {
+			return err
+		}
+		if acc, err = accounts.FindByID(ctx, accountID); err != nil {
+			return err
+		}
+		if !recovery {
+			return nil
+		}
+		// リカバリーコードを使った時は、後で調べられるよう監査ログに残す（操作者は本人）
+		ctx = requestctx.WithActor(ctx, requestctx.Actor{ID: acc.ID, Email: acc.Email})
+		return repo.RecordAudit(ctx, repository.AuditEntry{
+			Action:     AuditActionAccountRecoveryCodeUse,
+			TargetType: auditTargetAccount,
+			TargetID:   acc.ID,
+		})
+	})
+	if err != nil {
+		return repository.Account{}, false, domainError(err)
+	}
+	return acc, recovery, nil
+}
+
+// useCode は認証アプリのコードかリカバリーコードを確かめて、使用済みにする。
+// リカバリーコードだった時は true を返す。2要素認証が有効でなければ ErrTwoFactorNotEnabled
+func (s *twoFactorService) useCode(ctx context.Context, accounts repository.AccountRepository, accountID uint64, code string) (bool, error) {
+	acc, err := accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return false, err
+	}
+	if !acc.TotpEnabledAt.Valid {
+		return false, ErrTwoFactorNotEnabled
+	}
+
+	// 6桁の数字なら認証アプリのコード、それ以外はリカバリーコードとして扱う
+	if c := normalizeTOTPCode(code); len(c) == totp.Digits && strings.Trim(c, "0123456789") == "" {
+		secret, err := s.openSecret(acc)
+		if err != nil {
+			return false, err
+		}
+		step, ok := totp.Validate(secret, c, s.now())
+		if !ok {
+			return false, ErrTwoFactorInvalidCode
+		}
+		// 一度使ったコード（とそれより前のコード）は使えない
+		if err := accounts.UseTOTPStep(ctx, accountID, step); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return false, ErrTwoFactorInvalidCode
+			}
+			return false, err
+		}
+		return false, nil
+	}
+
+	err = accounts.UseRecoveryCode(ctx, accountID, token.Hash(normalizeRecoveryCode(code)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTwoFactorInvalidCode
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// openSecret は保存してある秘密鍵を復号する。
+// mfa.encryption_key を替えると復号できなくなる（2要素認証を設定し直してもらうしかない）
+func (s *twoFactorService) openSecret(acc repository.Account) (string, error) {
+	b, err := s.box.Open(acc.TotpSecret)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// newRecoveryCodes はリカバリーコード ("xxxxx-xxxxx") と、保存するハッシュを作る
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		for j := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			b[j] = recoveryCodeAlphabet[n.Int64()]
+		}
+		code := string(b[:5]) + "-" + string(b[5:])
+		codes = append(codes, code)
+		hashes = append(hashes, token.Hash(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeTOTPCode は認証アプリの表示どおり "123 456" のように入れられても受け付ける
+func normalizeTOTPCode(code string) string {
+	return strings.Join(strings.Fields(code), "")
+}
+
+// normalizeRecoveryCode は区切りの "-" と空白を除き、小文字にそろえる
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(normalizeTOTPCode(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/secretbox"
+	"go-example/admin-example/internal/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type twoFactorFixture struct {
+	svc       *twoFactorService
+	accounts  repository.AccountRepository
+	audits    repository.AuditRepository
+	accountID uint64
+	now       time.Time
+}
+
+// newTwoFactorFixture は admin@example.com (admin) のアカウントがある状態を作る。時刻は固定する
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+	s := repository.NewMemoryStore()
+	accounts := repository.NewMemoryAccountRepository(s)
+	f := &twoFactorFixture{
+		accounts: accounts,
+		audits:   repository.NewMemoryAuditRepository(s),
+		now:      time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC),
+	}
+	f.svc = NewTwoFactorService(accounts, repository.NewMemoryTxManager(s), secretbox.New("test-key"),
+		TwoFactorOptions{Issuer: "Admin", RequiredRoles: []model.Role{model.RoleAdmin}}).(*twoFactorService)
+	f.svc.now = func() time.Time { return f.now }
+
+	id, err := NewAuthService(accounts).CreateAccount(context.Background(), "admin@example.com", "correct-horse", model.RoleAdmin)
+	require.NoError(t, err)
+	f.accountID = id
+	return f
+}
+
+// code は今の時刻の認証アプリのコード
+func (f *twoFactorFixture) code(t *testing.T, secret string) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(f.now))
+	require.NoError(t, err)
+	return c
+}
+
+// enable は2要素認証を有効にして、秘密鍵とリカバリーコードを返す
+func (f *twoFactorFixture) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	e, err := f.svc.BeginEnrollment(ctx, f.accountID)
+	require.NoError(t, err)
+	codes, err := f.svc.Enable(ctx, f.accountID, f.code(t, e.Secret))
+	require.NoError(t, err)
+	return e.Secret, codes
+}
+
+func TestTwoFactorService_Enroll(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+
+	_, err := f.svc.Enable(ctx, f.accountID, "123456")
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnrolling)
+
+	e, err := f.svc.BeginEnrollment(ctx, f.accountID)
+	require.NoError(t, err)
+	assert.Contains(t, e.URI, "otpauth://totp/Admin:admin@example.com?")
+	assert.Contains(t, e.URI, "secret="+e.Secret)
+
+	// 秘密鍵は暗号化して保存する
+	acc, _ := f.accounts.FindByID(ctx, f.accountID)
+	assert.NotContains(t, string(acc.TotpSecret), e.Secret)
+	again, err := f.svc.Enrollment(ctx, f.accountID)
+	require.NoError(t, err)
+	assert.Equal(t, e, again)
+
+	st, err := f.svc.Status(ctx, f.accountID)
+	require.NoError(t, err)
+	assert.Equal(t, TwoFactorStatus{Enrolling: true, Required: true}, st)
+
+	_, err = f.svc.Enable(ctx, f.accountID, "000000")
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	codes, err := f.svc.Enable(ctx, f.accountID, f.code(t, e.Secret))
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	for _, c := range codes {
+		assert.Regexp(t, regexp.MustCompile(`^[2-9a-hjkmnp-z]{5}-[2-9a-hjkmnp-z]{5}$`), c)
+	}
+
+	st, _ = f.svc.Status(ctx, f.accountID)
+	assert.Equal(t, TwoFactorStatus{Enabled: true, Required: true, RecoveryCodesLeft: RecoveryCodeCount}, st)
+	_, err = f.svc.BeginEnrollment(ctx, f.accountID)
+	assert.ErrorIs(t, err, ErrTwoFactorAlreadyEnabled)
+
+	// 有効にする時に使ったコードではログインできない
+	_, _, err = f.svc.Verify(ctx, f.accountID, f.code(t, e.Secret))
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	logs, err := f.audits.Search(ctx, repository.AuditSearchParams{Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, logs, 1) {
+		assert.Equal(t, AuditActionAccountTwoFactorEnable, logs[0].Action)
+	}
+}
+
+func TestTwoFactorService_Verify(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	secret, codes := f.enable(t)
+
+	// 30秒後の新しいコードは1回だけ使える
+	f.now = f.now.Add(totp.Period)
+	code := f.code(t, secret)
+	acc, recovery, err := f.svc.Verify(ctx, f.accountID, code[:3]+" "+code[3:])
+	require.NoError(t, err)
+	assert.Equal(t, "admin@example.com", acc.Email)
+	assert.False(t, recovery)
+	_, _, err = f.svc.Verify(ctx, f.accountID, code)
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	// リカバリーコードは大文字や区切り無しでもよく、1回だけ使える
+	_, recovery, err = f.svc.Verify(ctx, f.accountID, " "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))+" ")
+	require.NoError(t, err)
+	assert.True(t, recovery)
+	_, _, err = f.svc.Verify(ctx, f.accountID, codes[0])
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+	_, _, err = f.svc.Verify(ctx, f.accountID, "aaaaa-aaaaa")
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	st, _ := f.svc.Status(ctx, f.accountID)
+	assert.Equal(t, int64(RecoveryCodeCount-1), st.RecoveryCodesLeft)
+
+	logs, _ := f.audits.Search(ctx, repository.AuditSearchParams{ActorEmail: "admin@example.com", Limit: 10})
+	if assert.NotEmpty(t, logs) {
+		assert.Equal(t, AuditActionAccountRecoveryCodeUse, logs[0].Action)
+	}
+}
+
+func TestTwoFactorService_RegenerateAndDisable(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	secret, old := f.enable(t)
+
+	_, err := f.svc.RegenerateRecoveryCodes(ctx, f.accountID, "000000")
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	// 本人の確認にはリカバリーコードも使える。作り直すと古いコードは使えない
+	codes, err := f.svc.RegenerateRecoveryCodes(ctx, f.accountID, old[0])
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+	_, _, err = f.svc.Verify(ctx, f.accountID, old[1])
+	assert.ErrorIs(t, err, ErrTwoFactorInvalidCode)
+
+	f.now = f.now.Add(totp.Period)
+	require.NoError(t, f.svc.Disable(ctx, f.accountID, f.code(t, secret)))
+	st, _ := f.svc.Status(ctx, f.accountID)
+	assert.Equal(t, TwoFactorStatus{Required: true}, st)
+	_, _, err = f.svc.Verify(ctx, f.accountID, codes[0])
+	assert.ErrorIs(t, err, ErrTwoFactorNotEnabled)
+}
+
+func TestTwoFactorService_Required(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	assert.True(t, f.svc.Required(model.RoleAdmin))
+	assert.False(t, f.svc.Required(model.RoleEditor))
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go-example/admin-example/internal/model"
+	"go-example/admin-example/internal/repository"
+)
+
+// ImportRow: CSV から読み込んだ1行（入力チェック済みのもの）
+type ImportRow struct {
+	Line int // CSV の行番号（エラーの表示用）
+	Name string
+}
+
+// ImportProblem: 登録できない行と、その理由
+type ImportProblem struct {
+	Line    int
+	Name    string
+	Message string
+}
+
+// ImportError: 登録できない行があったので、1件も登録しなかった。errors.Is(err, ErrValidation) でも判定できる
+type ImportError struct {
+	Problems []ImportProblem
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("取り込めない行が %d 件あります", len(e.Problems))
+}
+
+func (e *ImportError) Is(target error) bool { return target == ErrValidation }
+
+// 重複の行に付けるメッセージ。どの制約に当たるのかも書いておく
+var duplicateNameMessage = ErrDuplicateName.Error() + " (uk_users_active_name)"
+
+// CheckImport は登録前の確認（プレビュー）。名前が重複している行を返す
+// 登録はしないので、確認から登録までの間に他の人が同じ名前で登録することはある (ImportUsers でもう一度確認する)
+func (s *userService) CheckImport(ctx context.Context, rows []ImportRow) ([]ImportProblem, error) {
+	return checkImport(ctx, s.repo, rows)
+}
+
+// ImportUsers は rows を全て登録する。1行でも登録できない行があれば *ImportError を返し、1件も登録しない
+func (s *userService) ImportUsers(ctx context.Context, rows []ImportRow) (int, error) {
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		problems, err := checkImport(ctx, repo, rows)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return &ImportError{Problems: problems}
+		}
+
+		for _, row := range rows {
+			// CSV には名前しか無いので、他の項目は画面で登録した時と同じ初期値にする
+			res, err := repo.Create(ctx, repository.UserProfile{
+				Name:   row.Name,
+				Status: string(model.UserStatusActive),
+				Role:   string(model.RoleViewer),
+			})
+			if errors.Is(err, repository.ErrDuplicate) {
+				// 確認した後に、他の人が同じ名前で登録した。途中で止めずに、その行の問題として返す
+				return &ImportError{Problems: []ImportProblem{{Line: row.Line, Name: row.Name, Message: duplicateNameMessage}}}
+			}
+			if err != nil {
+				return fmt.Errorf("%d行目を登録できません: %w", row.Line, err)
+			}
+			lastID, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			id := uint64(lastID)
+			if err := repo.RecordAudit(ctx, userAuditEntry(AuditActionUserImport, id, nil, userSnapshot{Name: row.Name, Status: string(model.UserStatusActive), Role: string(model.RoleViewer)})); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var importErr *ImportError
+	if errors.As(err, &importErr) {
+		slog.InfoContext(ctx, "CSV の取り込みを中止しました", "rows", len(rows), "problems", len(importErr.Problems))
+	}
+	if err != nil {
+		return 0, err
+	}
+	slog.InfoContext(ctx, "CSV からユーザーを取り込みました", "rows", len(rows))
+	return len(rows), nil
+}
+
+// checkImport は各行の名前が登録できるか (validateName) と、名前の重複を確かめる。
+// 重複は users の uk_users_active_name でも弾かれるが、最初の1件で止まってしまうので、
+// 登録前に行ごとに確かめてまとめて報告する
+// (MySQL の照合順序と同じく、大文字・小文字は区別しない)
+func checkImport(ctx context.Context, repo repository.UserRepository, rows []ImportRow) ([]ImportProblem, error) {
+	var problems []ImportProblem
+	firstLine := map[string]int{} // 名前 → ファイルの中で最初に出てきた行
+	for _, row := range rows {
+		if err := validateName(row.Name); err != nil {
+			problems = append(problems, ImportProblem{Line: row.Line, Name: row.Name, Message: err.Error()})
+			continue
+		}
+		key := strings.ToLower(row.Name)
+		if line, ok := firstLine[key]; ok {
+			problems = append(problems, ImportProblem{
+				Line:    row.Line,
+				Name:    row.Name,
+				Message: fmt.Sprintf("%d行目と同じ名前です (uk_users_active_name)", line),
+			})
+			continue
+		}
+		firstLine[key] = row.Line
+
+		exists, err := repo.ExistsActiveName(ctx, row.Name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			problems = append(problems, ImportProblem{
+				Line:    row.Line,
+				Name:    row.Name,
+				Message: duplicateNameMessage,
+			})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"go-example/admin-example/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMemoryUserService は本物と同じくトランザクションでロールバックされる、メモリ上の Service を作る
+func newMemoryUserService() (UserService, repository.UserRepository) {
+	s := repository.NewMemoryStore()
+	repo := repository.NewMemoryUserRepository(s)
+	return NewUserService(repo, repository.NewMemoryTxManager(s)), repo
+}
+
+func TestUserService_CheckImport_Duplicates(t *testing.T) {
+	svc, _ := newMemoryUserService()
+	_, err := svc.Register(context.Background(), UserInput{Name: "既存ユーザー"})
+	assert.NoError(t, err)
+
+	problems, err := svc.CheckImport(context.Background(), []ImportRow{
+		{Line: 2, Name: "新人一号"},
+		{Line: 3, Name: "既存ユーザー"}, // 登録済み
+		{Line: 4, Name: "Alice"},
+		{Line: 5, Name: "alice"}, // ファイルの中で重複（大文字・小文字は区別しない）
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, problems, 2) {
+		assert.Equal(t, 3, problems[0].Line)
+		assert.Contains(t, problems[0].Message, "uk_users_active_name")
+		assert.Equal(t, 5, problems[1].Line)
+		assert.Contains(t, problems[1].Message, "4行目")
+	}
+}
+
+func TestUserService_ImportUsers(t *testing.T) {
+	svc, repo := newMemoryUserService()
+
+	n, err := svc.ImportUsers(context.Background(), []ImportRow{
+		{Line: 2, Name: "新人一号"},
+		{Line: 3, Name: "新人二号"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	users, _ := repo.List(context.Background())
+	assert.Len(t, users, 2)
+}
+
+func TestUserService_ImportUsers_Duplicate(t *testing.T) {
+	svc, repo := newMemoryUserService()
+	_, err := svc.Register(context.Background(), UserInput{Name: "既存ユーザー"})
+	assert.NoError(t, err)
+
+	_, err = svc.ImportUsers(context.Background(), []ImportRow{
+		{Line: 2, Name: "新人一号"},
+		{Line: 3, Name: "既存ユーザー"},
+	})
+
+	// 重複を行ごとに返し、問題の無い行も登録しない
+	var importErr *ImportError
+	if assert.ErrorAs(t, err, &importErr) {
+		assert.Len(t, importErr.Problems, 1)
+		assert.Equal(t, 3, importErr.Problems[0].Line)
+	}
+	users, _ := repo.List(context.Background())
+	assert.Len(t, users, 1)
+}
+
+// failingTxManager は、本物と同じくロールバックされるトランザクションの中で、
+// 指定した名前の登録だけを失敗させる
+type failingTxManager struct {
+	repository.TxManager
+	failOn string
+}
+
+type failingCreateRepository struct {
+	repository.UserRepository
+	failOn string
+}
+
+func (r *failingCreateRepository) Create(ctx context.Context, p repository.UserProfile) (sql.Result, error) {
+	if p.Name == r.failOn {
+		return nil, errors.New("接続が切れました")
+	}
+	return r.UserRepository.Create(ctx, p)
+}
+
+func (m *failingTxManager) RunInTx(ctx context.Context, fn func(ctx context.Context, repo repository.UserRepository) error) error {
+	return m.TxManager.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		return fn(ctx, &failingCreateRepository{UserRepository: repo, failOn: m.failOn})
+	})
+}
+
+func TestUserService_ImportUsers_RollsBackOnFailure(t *testing.T) {
+	s := repository.NewMemoryStore()
+	repo := repository.NewMemoryUserRepository(s)
+	svc := NewUserService(repo, &failingTxManager{TxManager: repository.NewMemoryTxManager(s), failOn: "新人二号"})
+
+	// 2行目の登録で失敗する
+	_, err := svc.ImportUsers(context.Background(), []ImportRow{
+		{Line: 2, Name: "新人一号"},
+		{Line: 3, Name: "新人二号"},
+	})
+
+	// 途中まで登録した行も残らない
+	assert.Error(t, err)
+	users, _ := repo.List(context.Background())
+	assert.Empty(t, users)
+}
@@ -2,48 +2,346 @@ package service
 
 import (
 	"context"
+	"go-example/admin-example/internal/model"
 	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
 )
 
+// users.name / email / display_name の長さの上限 (varchar(255))
+const maxNameLength = 255
+
+// UserInput: 登録・更新で受け取るユーザーの項目
+type UserInput struct {
+	Name        string
+	Email       string // 空ならメールアドレス無し。小文字にして保存する
+	DisplayName string
+	Status      model.UserStatus // 空なら、登録では active・更新では今のまま
+	Role        model.Role       // 空なら、登録では viewer・更新では今のまま
+	// アバター画像 (avatar.Store の key)。空なら、登録では画像なし・更新では今の画像のまま
+	Avatar       string
+	RemoveAvatar bool // 更新で、今の画像を外す
+}
+
 // サービス側のインターフェース（Controllerがこれを使う）
 type UserService interface {
-	Register(ctx context.Context, name string) error
+	Register(ctx context.Context, in UserInput) (uint64, error) // 登録したユーザーのIDを返す
 	GetList(ctx context.Context) ([]repository.User, error)
-	UpdateName(ctx context.Context, id uint64, name string) error
+	// 一覧画面用。1ページ分のユーザーと、条件に合う総件数を返す
+	Search(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error)
+	// エラーは errors.go のドメインのエラー (ErrNotFound / ErrDuplicateName / ErrDuplicateEmail / ErrConflict / ErrValidation)
+	// version は編集画面を開いた時の値。他の人が先に更新していたら ErrConflict
+	// 画像を差し替えた（外した）時は、前の画像の key を返す。ファイルは呼び出し側で消すこと
+	UpdateProfile(ctx context.Context, id uint64, in UserInput, version uint32) (replacedAvatar string, err error)
 	DeleteUser(ctx context.Context, id uint64) error
 	FindByID(ctx context.Context, id uint64) (repository.User, error) // これを追加
+
+	// ゴミ箱（論理削除済みユーザー）
+	// 一覧画面と同じく、1ページ分と総件数を返す。q は Page / PerPage だけ使う
+	GetTrash(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error)
+	RestoreUser(ctx context.Context, id uint64) error
+
+	// CSV の取り込み (user_import.go)
+	CheckImport(ctx context.Context, rows []ImportRow) ([]ImportProblem, error)
+	ImportUsers(ctx context.Context, rows []ImportRow) (int, error) // 登録した件数を返す
 }
 
 type userService struct {
 	repo repository.UserRepository // Repositoryの「インターフェース」を持つ
+	tx   repository.TxManager      // 複数の操作をまとめて1つのトランザクションにする
 }
 
 // コンストラクタ（ここでRepositoryの実体を注入する）
-func NewUserService(r repository.UserRepository) UserService {
-	return &userService{repo: r}
+func NewUserService(r repository.UserRepository, tx repository.TxManager) UserService {
+	return &userService{repo: r, tx: tx}
 }
 
 // --- 実装 ---
 
-func (s *userService) Register(ctx context.Context, name string) error {
-	// 必要ならここに「名前の重複チェック」などのバリデーションロジックを書く
-	_, err := s.repo.Create(ctx, name)
-	return err
+// 変更系のメソッドは、変更と監査ログを同じトランザクションで書き込む (s.tx.RunInTx)
+// トランザクションの中では、引数の repo と ctx を使うこと
+
+func (s *userService) Register(ctx context.Context, in UserInput) (uint64, error) {
+	p := repository.UserProfile{
+		Name:        in.Name,
+		Email:       normalizeEmail(in.Email),
+		DisplayName: strings.TrimSpace(in.DisplayName),
+		Status:      string(model.UserStatusActive),
+		Role:        string(model.RoleViewer),
+		AvatarPath:  in.Avatar,
+	}
+	if in.Status != "" {
+		p.Status = string(in.Status)
+	}
+	if in.Role != "" {
+		p.Role = string(in.Role)
+	}
+	if err := validateProfile(p); err != nil {
+		return 0, err
+	}
+	if err := checkRoleGrantable(ctx, model.RoleViewer, model.Role(p.Role)); err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		if err := checkNameAvailable(ctx, repo, p.Name); err != nil {
+			return err
+		}
+		if err := checkEmailAvailable(ctx, repo, p.Email); err != nil {
+			return err
+		}
+		res, err := repo.Create(ctx, p)
+		if err != nil {
+			return domainError(err)
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		id = uint64(lastID)
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserCreate, id, nil, snapshotOfProfile(p)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
 func (s *userService) GetList(ctx context.Context) ([]repository.User, error) {
 	return s.repo.List(ctx)
 }
 
-func (s *userService) UpdateName(ctx context.Context, id uint64, name string) error {
-	return s.repo.Update(ctx, id, name)
+func (s *userService) Search(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	p := repository.UserSearchParams{
+		Name:       q.Q,
+		Prefix:     q.Match == "prefix",
+		Status:     q.Status,
+		SortColumn: q.SortColumn(),
+		Desc:       q.SortDesc(),
+	}
+
+	total, err := s.repo.Count(ctx, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 0件ならわざわざ一覧を取りに行かない
+	if total == 0 {
+		return []repository.User{}, 0, nil
+	}
+	// 最後のページより後を指定されたら、最後のページを返す（呼び出し側も q.ClampPage(total) で合わせる）
+	q.ClampPage(total)
+	p.Limit = q.PerPage
+	p.Offset = q.Offset()
+
+	users, err := s.repo.Search(ctx, p)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, id uint64, in UserInput, version uint32) (string, error) {
+	var replaced string
+	err := s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		// 変更前の値も監査ログに残す（同じトランザクションで読むので、変更と食い違わない）
+		before, err := repo.FindByID(ctx, id)
+		if err != nil {
+			return domainError(err)
+		}
+
+		// 指定が無い項目は今の値のまま
+		p := repository.ProfileOf(before)
+		p.Name = in.Name
+		p.Email = normalizeEmail(in.Email)
+		p.DisplayName = strings.TrimSpace(in.DisplayName)
+		if in.Status != "" {
+			p.Status = string(in.Status)
+		}
+		if in.Role != "" {
+			p.Role = string(in.Role)
+		}
+		switch {
+		case in.Avatar != "":
+			p.AvatarPath = in.Avatar
+		case in.RemoveAvatar:
+			p.AvatarPath = ""
+		}
+		if err := validateProfile(p); err != nil {
+			return err
+		}
+		if err := checkRoleGrantable(ctx, model.Role(before.Role), model.Role(p.Role)); err != nil {
+			return err
+		}
+
+		// 大文字・小文字だけの変更は自分自身と重なるので確認しない
+		if !strings.EqualFold(before.Name.String, p.Name) {
+			if err := checkNameAvailable(ctx, repo, p.Name); err != nil {
+				return err
+			}
+		}
+		if !strings.EqualFold(before.Email.String, p.Email) {
+			if err := checkEmailAvailable(ctx, repo, p.Email); err != nil {
+				return err
+			}
+		}
+		if err := repo.Update(ctx, id, p, version); err != nil {
+			return domainError(err)
+		}
+		if before.AvatarPath != p.AvatarPath {
+			replaced = before.AvatarPath
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserUpdate, id, snapshotOf(before), snapshotOfProfile(p)))
+	})
+	if err != nil {
+		return "", err
+	}
+	return replaced, nil
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id uint64) error {
-	return s.repo.Delete(ctx, id)
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		before, err := repo.FindByID(ctx, id)
+		if err != nil {
+			return domainError(err)
+		}
+		if err := repo.Delete(ctx, id); err != nil {
+			return domainError(err)
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserDelete, id, snapshotOf(before), nil))
+	})
 }
 
 func (s *userService) FindByID(ctx context.Context, id uint64) (repository.User, error) {
-	// Repositoryの FindByID を呼び出すだけ
-	return s.repo.FindByID(ctx, id)
+	// Repositoryの FindByID を呼び出すだけ（見つからなければ ErrNotFound）
+	user, err := s.repo.FindByID(ctx, id)
+	return user, domainError(err)
+}
+
+func (s *userService) GetTrash(ctx context.Context, q model.UserListQuery) ([]repository.User, int64, error) {
+	total, err := s.repo.CountDeleted(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []repository.User{}, 0, nil
+	}
+	// Search と同じく、最後のページより後は最後のページにする
+	q.ClampPage(total)
+
+	users, err := s.repo.ListDeleted(ctx, q.PerPage, q.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// RestoreUser は論理削除を取り消す。
+// 削除後に同じ名前（メールアドレス）で再登録されている場合は、重複してしまうので復元しない
+func (s *userService) RestoreUser(ctx context.Context, id uint64) error {
+	// 確認から復元までを1つのトランザクションで行う
+	return s.tx.RunInTx(ctx, func(ctx context.Context, repo repository.UserRepository) error {
+		user, err := repo.FindDeletedByID(ctx, id)
+		if err != nil {
+			return domainError(err)
+		}
+		if err := checkNameAvailable(ctx, repo, user.Name.String); err != nil {
+			return err
+		}
+		if err := checkEmailAvailable(ctx, repo, user.Email.String); err != nil {
+			return err
+		}
+
+		if err := repo.Restore(ctx, id); err != nil {
+			return domainError(err)
+		}
+		return repo.RecordAudit(ctx, userAuditEntry(AuditActionUserRestore, id, nil, snapshotOf(user)))
+	})
+}
+
+// validateName は DB に入れられない名前を ErrValidation にする。
+// 文字数などの画面のルールはフォームの validate タグで確認済みなので、ここでは DB の制約だけを見る
+// (API や CSV の取り込みなど、どこから呼ばれても同じ結果になるように)
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return newValidationError("name", "名前を入力してください")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return newValidationError("name", "名前が長すぎます")
+	}
+	return nil
+}
+
+// validateProfile は名前 (validateName) と、メールアドレス・表示名・状態・役割を確かめる
+func validateProfile(p repository.UserProfile) error {
+	if err := validateName(p.Name); err != nil {
+		return err
+	}
+	if p.Email != "" {
+		// "山田 <a@example.com>" のような形は受け付けず、アドレスだけを許す
+		addr, err := mail.ParseAddress(p.Email)
+		if err != nil || addr.Address != p.Email {
+			return newValidationError("email", "メールアドレスの形式が正しくありません")
+		}
+		if len(p.Email) > maxNameLength {
+			return newValidationError("email", "メールアドレスが長すぎます")
+		}
+	}
+	if utf8.RuneCountInString(p.DisplayName) > maxNameLength {
+		return newValidationError("display_name", "表示名が長すぎます")
+	}
+	if !model.UserStatus(p.Status).Valid() {
+		return newValidationError("status", "状態が正しくありません")
+	}
+	if !model.Role(p.Role).Valid() {
+		return newValidationError("role", "役割が正しくありません")
+	}
+	return nil
+}
+
+// checkNameAvailable は同じ名前の有効なユーザーがいれば ErrDuplicateName を返す。
+// 重複は uk_users_active_name でも弾かれるが、先に確かめておくと分かりやすいメッセージを返せる
+// (同時に登録された時は、書き込みの 1062 を domainError が ErrDuplicateName にする)
+func checkNameAvailable(ctx context.Context, repo repository.UserRepository, name string) error {
+	exists, err := repo.ExistsActiveName(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateName
+	}
+	return nil
+}
+
+// checkRoleGrantable は操作している人 (requestctx の Actor) が、ユーザーの役割を from から to に変えてよいかを確かめる。
+// 新しく登録する時は from を閲覧者（初期値）にする。変えない時は確認しない
+// 役割の付け外しは両方とも model.Role.CanGrant で許された時だけ
+// (編集者が自分のアドレスを管理者として招待し、受諾すれば管理者になれてしまうため)
+func checkRoleGrantable(ctx context.Context, from, to model.Role) error {
+	if from == to {
+		return nil
+	}
+	actor := model.Role(requestctx.ActorFrom(ctx).Role)
+	if !actor.CanGrant(from) || !actor.CanGrant(to) {
+		return ErrRoleNotGrantable
+	}
+	return nil
+}
+
+// checkEmailAvailable は同じメールアドレスの有効なユーザーがいれば ErrDuplicateEmail を返す。空なら確認しない
+// (名前と同じく、重複は uk_users_active_email でも弾かれる。ここでは分かりやすいメッセージのために先に確かめる)
+func checkEmailAvailable(ctx context.Context, repo repository.UserRepository, email string) error {
+	if email == "" {
+		return nil
+	}
+	exists, err := repo.ExistsActiveEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateEmail
+	}
+	return nil
 }
@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"testing"
 
+	"go-example/admin-example/internal/model"
 	"go-example/admin-example/internal/repository"
+	"go-example/admin-example/internal/requestctx"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,6 +22,9 @@ import (
 type mockUserRepository struct {
 	// 埋め込みなどはせず、必要なメソッドだけ定義してもOKですが、
 	// 全てのメソッドを定義する必要があります。
+
+	// RecordAudit で書き込まれた監査ログ
+	audits []repository.AuditEntry
 }
 
 /*
@@ -40,11 +45,17 @@ func (m *mockUserRepository) List(ctx context.Context) ([]repository.User, error
 // --- 以下、インターフェースを満たすためのダミー実装 ---
 // sqlcの生成するインターフェースに合わせて戻り値を (sql.Result, error) に統一します
 
-func (m *mockUserRepository) Create(ctx context.Context, name string) (sql.Result, error) {
-	return nil, nil
+// Create で返す sql.Result の偽物
+type fakeResult struct{ id int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+func (m *mockUserRepository) Create(ctx context.Context, p repository.UserProfile) (sql.Result, error) {
+	return fakeResult{id: 10}, nil
 }
 
-func (m *mockUserRepository) Update(ctx context.Context, id uint64, name string) error {
+func (m *mockUserRepository) Update(ctx context.Context, id uint64, p repository.UserProfile, version uint32) error {
 	return nil
 }
 
@@ -57,16 +68,94 @@ func (m *mockUserRepository) GetUser(ctx context.Context, id uint64) (repository
 	return repository.User{}, nil
 }
 
-// FindByID を追加（中身は空でOK）
+// FindByID を追加
 func (m *mockUserRepository) FindByID(ctx context.Context, id uint64) (repository.User, error) {
-	return repository.User{}, nil
+	return repository.User{ID: id, Name: sql.NullString{String: "変更前の名前", Valid: true}, Status: "active", Role: "viewer"}, nil
+}
+
+func (m *mockUserRepository) RecordAudit(ctx context.Context, e repository.AuditEntry) error {
+	m.audits = append(m.audits, e)
+	return nil
+}
+
+// TxManager の偽物。トランザクションは張らずに、渡された repo でそのまま fn を実行する
+type mockTxManager struct {
+	repo repository.UserRepository
+	runs int // RunInTx が呼ばれた回数
+}
+
+func (m *mockTxManager) RunInTx(ctx context.Context, fn func(ctx context.Context, repo repository.UserRepository) error) error {
+	m.runs++
+	return fn(ctx, m.repo)
+}
+
+// newTestUserService はテスト用の Repository と TxManager で Service を作る
+func newTestUserService(repo repository.UserRepository) UserService {
+	return NewUserService(repo, &mockTxManager{repo: repo})
+}
+
+// 一覧検索用のダミー実装
+func (m *mockUserRepository) Search(ctx context.Context, p repository.UserSearchParams) ([]repository.User, error) {
+	return m.List(ctx)
+}
+
+func (m *mockUserRepository) Count(ctx context.Context, p repository.UserSearchParams) (int64, error) {
+	return 2, nil
+}
+
+// ゴミ箱用のダミー実装
+func (m *mockUserRepository) ListDeleted(ctx context.Context, limit, offset int) ([]repository.User, error) {
+	return []repository.User{
+		{ID: 3, Name: sql.NullString{String: "削除太郎", Valid: true}},
+	}, nil
+}
+
+func (m *mockUserRepository) CountDeleted(ctx context.Context) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockUserRepository) FindDeletedByID(ctx context.Context, id uint64) (repository.User, error) {
+	return repository.User{ID: id, Name: sql.NullString{String: "削除太郎", Valid: true}}, nil
+}
+
+func (m *mockUserRepository) ExistsActiveName(ctx context.Context, name string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockUserRepository) ExistsActiveEmail(ctx context.Context, email string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockUserRepository) Restore(ctx context.Context, id uint64) error {
+	return nil
+}
+
+// 招待用のダミー実装（招待のテストは invite_service_test.go でメモリの Repository を使う）
+func (m *mockUserRepository) CreateInvitation(ctx context.Context, inv repository.NewInvitation) (uint64, error) {
+	return 1, nil
+}
+
+func (m *mockUserRepository) FindInvitationByTokenHash(ctx context.Context, hash string) (repository.UserInvitation, error) {
+	return repository.UserInvitation{}, sql.ErrNoRows
+}
+
+func (m *mockUserRepository) AcceptInvitation(ctx context.Context, id uint64) error {
+	return nil
+}
+
+func (m *mockUserRepository) RevokeInvitations(ctx context.Context, userID uint64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockUserRepository) Accounts() repository.AccountRepository {
+	return newMockAccountRepository()
 }
 
 // 2. 実際のテスト関数
 func TestUserService_GetList(t *testing.T) {
 	// A. 準備: 偽物のRepoを作り、Serviceに注入(DI)する
 	mockRepo := &mockUserRepository{}
-	svc := NewUserService(mockRepo)
+	svc := newTestUserService(mockRepo)
 
 	// B. 実行: Serviceのメソッドを呼ぶ
 	users, err := svc.GetList(context.Background())
@@ -76,3 +165,310 @@ func TestUserService_GetList(t *testing.T) {
 	assert.Len(t, users, 2)                        // 2件取得できていること
 	assert.Equal(t, "テスト太郎", users[0].Name.String) // 1件目の名前が正しいこと
 }
+
+// 検索条件がRepositoryにどう渡るか確認するための偽物
+type searchRecordingRepository struct {
+	mockUserRepository
+	params repository.UserSearchParams
+}
+
+func (m *searchRecordingRepository) Search(ctx context.Context, p repository.UserSearchParams) ([]repository.User, error) {
+	m.params = p
+	return m.mockUserRepository.Search(ctx, p)
+}
+
+// 件数は25件（10件ずつなら3ページ）として返す
+func (m *searchRecordingRepository) Count(ctx context.Context, p repository.UserSearchParams) (int64, error) {
+	return 25, nil
+}
+
+func TestUserService_Search(t *testing.T) {
+	repo := &searchRecordingRepository{}
+	svc := newTestUserService(repo)
+	q := model.UserListQuery{Page: 3, PerPage: 10, Sort: "-name", Q: "太郎", Match: "prefix"}
+
+	users, total, err := svc.Search(context.Background(), q)
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(25), total)
+	// 3ページ目なので 20件読み飛ばす
+	assert.Equal(t, repository.UserSearchParams{
+		Name: "太郎", Prefix: true, SortColumn: "name", Desc: true, Limit: 10, Offset: 20,
+	}, repo.params)
+
+	// 最後のページより後は、最後のページを返す（大きすぎる page でも OFFSET が溢れない）
+	q.Page = 1 << 60
+	_, _, err = svc.Search(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, repo.params.Offset)
+}
+
+func TestUserService_RestoreUser(t *testing.T) {
+	svc := newTestUserService(&mockUserRepository{})
+
+	err := svc.RestoreUser(context.Background(), 3)
+
+	assert.NoError(t, err)
+}
+
+// 同じ名前の有効なユーザーがいる状態を再現する偽物
+type nameTakenUserRepository struct {
+	mockUserRepository
+	restored bool
+}
+
+func (m *nameTakenUserRepository) ExistsActiveName(ctx context.Context, name string) (bool, error) {
+	return true, nil
+}
+
+func (m *nameTakenUserRepository) Restore(ctx context.Context, id uint64) error {
+	m.restored = true
+	return nil
+}
+
+func TestUserService_RestoreUser_NameTaken(t *testing.T) {
+	repo := &nameTakenUserRepository{}
+	svc := newTestUserService(repo)
+
+	err := svc.RestoreUser(context.Background(), 3)
+
+	// 名前が重複するので復元されないこと
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	assert.False(t, repo.restored)
+}
+
+func TestUserService_Register_Audit(t *testing.T) {
+	repo := &mockUserRepository{}
+	svc := newTestUserService(repo)
+
+	id, err := svc.Register(context.Background(), UserInput{Name: "新人"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), id)
+	if assert.Len(t, repo.audits, 1) {
+		assert.Equal(t, AuditActionUserCreate, repo.audits[0].Action)
+		assert.Equal(t, uint64(10), repo.audits[0].TargetID)
+		assert.Nil(t, repo.audits[0].Before)
+		// 状態と役割は指定が無ければ初期値
+		assert.Equal(t, userSnapshot{Name: "新人", Status: "active", Role: "viewer"}, repo.audits[0].After)
+	}
+}
+
+func TestUserService_UpdateProfile_Audit(t *testing.T) {
+	repo := &mockUserRepository{}
+	svc := newTestUserService(repo)
+
+	_, err := svc.UpdateProfile(actorContext(model.RoleAdmin), 5, UserInput{Name: "変更後の名前", Role: model.RoleEditor}, 1)
+
+	// 変更前と変更後の両方が残ること。指定しなかった状態は今の値のまま
+	assert.NoError(t, err)
+	if assert.Len(t, repo.audits, 1) {
+		assert.Equal(t, AuditActionUserUpdate, repo.audits[0].Action)
+		assert.Equal(t, userSnapshot{Name: "変更前の名前", Status: "active", Role: "viewer"}, repo.audits[0].Before)
+		assert.Equal(t, userSnapshot{Name: "変更後の名前", Status: "active", Role: "editor"}, repo.audits[0].After)
+	}
+}
+
+// actorContext は role のアカウントでログインしている時の ctx (RequireLogin と同じく Actor を載せる)
+func actorContext(role model.Role) context.Context {
+	return requestctx.WithActor(context.Background(), requestctx.Actor{ID: 1, Email: string(role) + "@example.com", Role: string(role)})
+}
+
+func TestUserService_RoleNotGrantable(t *testing.T) {
+	repo := &mockUserRepository{}
+	svc := newTestUserService(repo)
+	editor := actorContext(model.RoleEditor)
+
+	// 編集者は閲覧者より上の役割を付けられない
+	_, err := svc.Register(editor, UserInput{Name: "新人", Role: model.RoleAdmin})
+	assert.ErrorIs(t, err, ErrRoleNotGrantable)
+	_, err = svc.Register(editor, UserInput{Name: "新人", Role: model.RoleEditor})
+	assert.ErrorIs(t, err, ErrRoleNotGrantable)
+	_, err = svc.UpdateProfile(editor, 5, UserInput{Name: "変更後の名前", Role: model.RoleAdmin}, 1)
+	assert.ErrorIs(t, err, ErrRoleNotGrantable)
+	// ログインしていない操作も同じ
+	_, err = svc.Register(context.Background(), UserInput{Name: "新人", Role: model.RoleAdmin})
+	assert.ErrorIs(t, err, ErrRoleNotGrantable)
+	var ve *ValidationError
+	if assert.ErrorAs(t, err, &ve) {
+		assert.Equal(t, "role", ve.Field)
+	}
+	assert.Empty(t, repo.audits)
+
+	// 役割を変えない更新と、閲覧者での登録はできる
+	_, err = svc.UpdateProfile(editor, 5, UserInput{Name: "変更後の名前"}, 1)
+	assert.NoError(t, err)
+	_, err = svc.Register(editor, UserInput{Name: "新人"})
+	assert.NoError(t, err)
+}
+
+// 他の人が先に更新していた状態を再現する偽物
+type conflictRepository struct {
+	mockUserRepository
+}
+
+func (m *conflictRepository) Update(ctx context.Context, id uint64, p repository.UserProfile, version uint32) error {
+	return repository.ErrConflict
+}
+
+func TestUserService_UpdateProfile_Conflict(t *testing.T) {
+	repo := &conflictRepository{}
+	svc := newTestUserService(repo)
+
+	_, err := svc.UpdateProfile(context.Background(), 5, UserInput{Name: "変更後の名前"}, 1)
+
+	// 競合は ErrConflict にして返し、監査ログは残さない
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Empty(t, repo.audits)
+}
+
+// 削除に失敗する偽物
+type deleteFailsRepository struct {
+	mockUserRepository
+}
+
+func (m *deleteFailsRepository) Delete(ctx context.Context, id uint64) error {
+	return sql.ErrNoRows
+}
+
+func TestUserService_DeleteUser_FailureNotAudited(t *testing.T) {
+	repo := &deleteFailsRepository{}
+	svc := newTestUserService(repo)
+
+	err := svc.DeleteUser(context.Background(), 5)
+
+	// 失敗した操作は監査ログを書かずにエラーを返す（本物ではロールバックされる）
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, repo.audits)
+}
+
+func TestUserService_Register_DuplicateName(t *testing.T) {
+	svc, _ := newMemoryUserService()
+	_, err := svc.Register(context.Background(), UserInput{Name: "山田太郎"})
+	assert.NoError(t, err)
+
+	_, err = svc.Register(context.Background(), UserInput{Name: "山田太郎"})
+
+	assert.ErrorIs(t, err, ErrDuplicateName)
+}
+
+func TestUserService_Register_DuplicateEmail(t *testing.T) {
+	svc, _ := newMemoryUserService()
+	_, err := svc.Register(context.Background(), UserInput{Name: "山田太郎", Email: "yamada@example.com"})
+	assert.NoError(t, err)
+
+	// 大文字・小文字や前後の空白が違っても同じメールアドレス
+	_, err = svc.Register(context.Background(), UserInput{Name: "山田花子", Email: " Yamada@Example.com "})
+
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+}
+
+func TestUserService_Register_Validation(t *testing.T) {
+	repo := &mockUserRepository{}
+	svc := newTestUserService(repo)
+
+	_, err := svc.Register(context.Background(), UserInput{Name: "   "})
+
+	// DB に入れる前に、どの項目のエラーか分かる形で返す
+	assert.ErrorIs(t, err, ErrValidation)
+	var ve *ValidationError
+	if assert.ErrorAs(t, err, &ve) {
+		assert.Equal(t, "name", ve.Field)
+	}
+	assert.Empty(t, repo.audits)
+}
+
+// DB のユニーク制約に当たった (MySQL の 1062) 状態を再現する偽物
+type duplicateKeyRepository struct {
+	mockUserRepository
+}
+
+func (m *duplicateKeyRepository) Create(ctx context.Context, p repository.UserProfile) (sql.Result, error) {
+	key := "uk_users_active_name"
+	if p.Email != "" {
+		key = "uk_users_active_email"
+	}
+	return nil, &repository.DuplicateKeyError{Key: key}
+}
+
+func TestUserService_Register_DuplicateKey(t *testing.T) {
+	svc := newTestUserService(&duplicateKeyRepository{})
+
+	_, err := svc.Register(context.Background(), UserInput{Name: "山田太郎"})
+
+	// ドライバのエラーではなく ErrDuplicateName になる
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	assert.ErrorIs(t, err, repository.ErrDuplicate)
+
+	// どの制約に当たったかで分ける
+	_, err = svc.Register(context.Background(), UserInput{Name: "山田太郎", Email: "yamada@example.com"})
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+}
+
+func TestUserService_Register_InvalidProfile(t *testing.T) {
+	svc := newTestUserService(&mockUserRepository{})
+
+	for field, in := range map[string]UserInput{
+		"email":  {Name: "山田太郎", Email: "Yamada <yamada@example.com>"},
+		"status": {Name: "山田太郎", Status: "deleted"},
+		"role":   {Name: "山田太郎", Role: "root"},
+	} {
+		_, err := svc.Register(context.Background(), in)
+
+		var ve *ValidationError
+		if assert.ErrorAs(t, err, &ve, field) {
+			assert.Equal(t, field, ve.Field)
+		}
+	}
+}
+
+func TestUserService_UpdateProfile_DuplicateName(t *testing.T) {
+	svc, _ := newMemoryUserService()
+	ctx := context.Background()
+	_, err := svc.Register(ctx, UserInput{Name: "山田太郎", Email: "yamada@example.com"})
+	assert.NoError(t, err)
+	id, err := svc.Register(ctx, UserInput{Name: "Suzuki", Email: "suzuki@example.com"})
+	assert.NoError(t, err)
+
+	// 他のユーザーと同じ名前・メールアドレスにはできない
+	_, err = svc.UpdateProfile(ctx, id, UserInput{Name: "山田太郎", Email: "suzuki@example.com"}, 1)
+	assert.ErrorIs(t, err, ErrDuplicateName)
+	_, err = svc.UpdateProfile(ctx, id, UserInput{Name: "Suzuki", Email: "yamada@example.com"}, 1)
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+	// 自分の名前の大文字・小文字を変えるだけならよい
+	_, err = svc.UpdateProfile(ctx, id, UserInput{Name: "SUZUKI", Email: "SUZUKI@example.com"}, 1)
+	assert.NoError(t, err)
+}
+
+func TestUserService_UpdateProfile_Avatar(t *testing.T) {
+	svc, _ := newMemoryUserService()
+	ctx := context.Background()
+	id, err := svc.Register(ctx, UserInput{Name: "山田太郎", Avatar: "old-avatar"})
+	assert.NoError(t, err)
+
+	// 画像を指定しなければそのまま
+	replaced, err := svc.UpdateProfile(ctx, id, UserInput{Name: "山田太郎"}, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, replaced)
+
+	// 差し替えたら前の画像を返す（ファイルは呼び出し側で消す）
+	replaced, err = svc.UpdateProfile(ctx, id, UserInput{Name: "山田太郎", Avatar: "new-avatar"}, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "old-avatar", replaced)
+
+	replaced, err = svc.UpdateProfile(ctx, id, UserInput{Name: "山田太郎", RemoveAvatar: true}, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-avatar", replaced)
+	user, _ := svc.FindByID(ctx, id)
+	assert.Empty(t, user.AvatarPath)
+}
+
+func TestUserService_FindByID_NotFound(t *testing.T) {
+	svc, _ := newMemoryUserService()
+
+	_, err := svc.FindByID(context.Background(), 99)
+
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,45 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound: セッションが無い（期限切れ・ログアウト済み・強制終了された）
+var ErrNotFound = errors.New("セッションが見つかりません")
+
+// Record: 保存先に置くセッション1件
+type Record struct {
+	// RecordID(Cookie の ID)。Cookie の ID そのものは保存しない
+	// (保存先が漏れても、そのままセッションを乗っ取れないようにする)
+	ID        string
+	AccountID uint64 // ログインしていなければ 0
+	Data      []byte // session.Values を gob にしたもの（一覧では空）
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	UpdatedAt time.Time // 最後に保存した時刻
+	ExpiresAt time.Time
+}
+
+// Backend: セッションの保存先
+// MySQL の sessions テーブル (repository.NewSessionRepository) とメモリ (NewMemoryBackend) がある。
+// Redis などに置く時も、これを実装すれば Store はそのまま使える
+type Backend interface {
+	// 期限内のものだけを返す。無ければ ErrNotFound
+	Load(ctx context.Context, id string, now time.Time) (Record, error)
+	// 新しく振った ID で作る
+	Create(ctx context.Context, r Record) error
+	// 中身と期限を上書きする（CreatedAt は最初の値のまま）
+	// 無ければ（ログアウト・強制終了で消えていれば）作り直さずに ErrNotFound
+	Update(ctx context.Context, r Record) error
+	// 無ければ ErrNotFound
+	Delete(ctx context.Context, id string) error
+	// アカウントのセッションを全て消して、消した件数を返す（期限切れも消してよい）
+	DeleteByAccount(ctx context.Context, accountID uint64) (int64, error)
+	// 期限切れを消して、消した件数を返す（自分で期限切れを消す Redis などは 0 を返せばよい）
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	// ログイン中（AccountID が 0 でない）で期限内のものを、新しく保存した順に返す。Data は空でよい
+	ListAuthenticated(ctx context.Context, now time.Time) ([]Record, error)
+}
@@ -0,0 +1,107 @@
+package sessionstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryBackend: プロセスのメモリに置く Backend
+// Redis の代わりの開発・テスト用（キーごとに期限を持ち、期限を過ぎたら無いものとして扱う）。
+// 停止すると消え、複数台で動かすと共有されない
+type memoryBackend struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryBackend() Backend {
+	return &memoryBackend{records: map[string]Record{}}
+}
+
+func (m *memoryBackend) Load(ctx context.Context, id string, now time.Time) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[id]
+	if !ok || !r.ExpiresAt.After(now) {
+		return Record{}, ErrNotFound
+	}
+	r.Data = append([]byte(nil), r.Data...) // 呼び出し元が書き換えても影響しないように
+	return r, nil
+}
+
+func (m *memoryBackend) Create(ctx context.Context, r Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.Data = append([]byte(nil), r.Data...)
+	m.records[r.ID] = r
+	return nil
+}
+
+func (m *memoryBackend) Update(ctx context.Context, r Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.records[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	r.CreatedAt = old.CreatedAt
+	r.Data = append([]byte(nil), r.Data...)
+	m.records[r.ID] = r
+	return nil
+}
+
+func (m *memoryBackend) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.records, id)
+	return nil
+}
+
+func (m *memoryBackend) DeleteByAccount(ctx context.Context, accountID uint64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, r := range m.records {
+		if r.AccountID == accountID {
+			delete(m.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryBackend) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for id, r := range m.records {
+		if !r.ExpiresAt.After(now) {
+			delete(m.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memoryBackend) ListAuthenticated(ctx context.Context, now time.Time) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Record
+	for _, r := range m.records {
+		if r.AccountID != 0 && r.ExpiresAt.After(now) {
+			r.Data = nil
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].UpdatedAt.Equal(list[j].UpdatedAt) {
+			return list[i].UpdatedAt.After(list[j].UpdatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
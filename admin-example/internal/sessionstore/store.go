@@ -0,0 +1,242 @@
+// Package sessionstore はセッションの中身をサーバー側に保存する gorilla/sessions の Store
+//
+// Cookie にはランダムな ID だけを（署名・暗号化して）入れ、中身は Backend に置く。
+// サーバー側で消せば、そのセッションはすぐに使えなくなる（強制ログアウト）
+package sessionstore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// AccountIDKey: session.Values にログイン中のアカウントID (uint64) を入れるキー
+// 一覧画面に出すため、保存する時にここを見て Record.AccountID に入れる
+const AccountIDKey = "account_id"
+
+// MaxAge が 0（ブラウザを閉じるまで）の時に、サーバー側で持っておく期間
+const defaultLifetime = 24 * time.Hour
+
+// 読み込んだ時のアカウントIDを session.Values に覚えておくキー（保存はしない）
+type loadedAccountKey struct{}
+
+// Options: Store の設定
+type Options struct {
+	// Cookie の署名・暗号化の鍵の元。先頭が今の鍵で、2つ目以降は替える前の古い鍵（読むだけ）
+	// 古い鍵の Cookie も読めるので、鍵を替えてもログイン中の人はログアウトされない
+	// (次に保存した時に今の鍵で作り直す)
+	Secrets []string
+	Cookie  sessions.Options
+	// 利用者の IP アドレスの取り方 (echo の e.IPExtractor)。nil なら RemoteAddr
+	IPExtractor func(*http.Request) string
+}
+
+// Store: sessions.Store の実装
+type Store struct {
+	backend Backend
+	codecs  []securecookie.Codec
+	opts    Options
+	now     func() time.Time // テストで差し替える
+}
+
+func NewStore(b Backend, opts Options) *Store {
+	return &Store{
+		backend: b,
+		codecs:  codecsFor(opts.Secrets, opts.Cookie.MaxAge),
+		opts:    opts,
+		now:     time.Now,
+	}
+}
+
+// codecsFor は鍵ごとに、署名用と暗号化用 (AES-256) の鍵を作る
+func codecsFor(secrets []string, maxAge int) []securecookie.Codec {
+	var pairs [][]byte
+	for _, secret := range secrets {
+		hashKey := sha256.Sum256([]byte("session-hash:" + secret))
+		blockKey := sha256.Sum256([]byte("session-block:" + secret))
+		pairs = append(pairs, hashKey[:], blockKey[:])
+	}
+	codecs := securecookie.CodecsFromPairs(pairs...)
+	if maxAge > 0 {
+		for _, c := range codecs {
+			c.(*securecookie.SecureCookie).MaxAge(maxAge)
+		}
+	}
+	return codecs
+}
+
+// RecordID は Cookie の ID から、保存先で使う ID (SHA-256 の16進) を作る
+func RecordID(cookieID string) string {
+	sum := sha256.Sum256([]byte(cookieID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get はリクエストの中で1回だけ読み込む (2回目からは同じ *sessions.Session を返す)
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New は Cookie の ID でセッションを読み込む
+// Cookie が壊れている・鍵が合わない・サーバー側に無い時は、エラーにせず空のセッションを返す
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	sess := sessions.NewSession(s, name)
+	opts := s.opts.Cookie
+	sess.Options = &opts
+	sess.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return sess, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, c.Value, &id, s.codecs...); err != nil {
+		return sess, nil
+	}
+	rec, err := s.backend.Load(r.Context(), RecordID(id), s.now())
+	if errors.Is(err, ErrNotFound) {
+		return sess, nil
+	}
+	if err != nil {
+		return sess, err
+	}
+
+	values := map[interface{}]interface{}{}
+	if err := (securecookie.GobEncoder{}).Deserialize(rec.Data, &values); err != nil {
+		return sess, nil
+	}
+	sess.Values = values
+	sess.Values[loadedAccountKey{}] = rec.AccountID
+	sess.ID = id
+	sess.IsNew = false
+	return sess, nil
+}
+
+// Save はセッションを保存して Cookie を書く。MaxAge が負（ログアウト）なら保存先からも消す
+//
+// 保存先に行を作るのは ID を新しく振った時だけで、読み込んだセッションは上書きしかしない。
+// 読み込んだ後にログアウト・強制終了で消えていたら、作り直さずに中身を空にして Cookie を消す
+// (同じリクエストの中で後から保存しても、消される前の値は戻らない)。
+// 中身が空の新しいセッションは保存しない（何も覚えていない人のために行を作らない）
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, sess *sessions.Session) error {
+	ctx := r.Context()
+	if sess.Options.MaxAge < 0 {
+		if sess.ID != "" {
+			if err := s.backend.Delete(ctx, RecordID(sess.ID)); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(sess.Name(), "", sess.Options))
+		return nil
+	}
+
+	// ログインなどでアカウントが変わったら ID を振り直す（ログイン前に知られた ID を使わせない）
+	accountID, _ := sess.Values[AccountIDKey].(uint64)
+	loaded, _ := sess.Values[loadedAccountKey{}].(uint64)
+	if sess.ID != "" && accountID != loaded {
+		if err := s.backend.Delete(ctx, RecordID(sess.ID)); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		sess.ID = ""
+	}
+
+	values := make(map[interface{}]interface{}, len(sess.Values))
+	for k, v := range sess.Values {
+		if _, skip := k.(loadedAccountKey); !skip {
+			values[k] = v
+		}
+	}
+	if sess.ID == "" && len(values) == 0 {
+		return nil
+	}
+	data, err := (securecookie.GobEncoder{}).Serialize(values)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	lifetime := time.Duration(sess.Options.MaxAge) * time.Second
+	if lifetime == 0 {
+		lifetime = defaultLifetime
+	}
+	rec := Record{
+		AccountID: accountID,
+		Data:      data,
+		IP:        s.clientIP(r),
+		UserAgent: truncate(r.UserAgent(), 255),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+	if sess.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		rec.ID = RecordID(id)
+		if err := s.backend.Create(ctx, rec); err != nil {
+			return err
+		}
+		sess.ID = id
+	} else {
+		rec.ID = RecordID(sess.ID)
+		err := s.backend.Update(ctx, rec)
+		if errors.Is(err, ErrNotFound) {
+			clear(sess.Values)
+			sess.ID = ""
+			sess.IsNew = true
+			expired := *sess.Options
+			expired.MaxAge = -1
+			http.SetCookie(w, sessions.NewCookie(sess.Name(), "", &expired))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(sess.Name(), sess.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(sess.Name(), encoded, sess.Options))
+	sess.Values[loadedAccountKey{}] = accountID
+	sess.IsNew = false
+	return nil
+}
+
+func (s *Store) clientIP(r *http.Request) string {
+	if s.opts.IPExtractor != nil {
+		return s.opts.IPExtractor(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newID は推測できないセッションID (256bit) を作る
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// truncate は s を max 文字までにする（DB の varchar に入るように）
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max])
+}
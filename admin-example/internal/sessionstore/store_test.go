@@ -0,0 +1,223 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(b Backend, secrets ...string) *Store {
+	return NewStore(b, Options{
+		Secrets: secrets,
+		Cookie:  sessions.Options{Path: "/", MaxAge: 3600, HttpOnly: true},
+	})
+}
+
+// load は cookies を付けたリクエストでセッションを読み込む
+func load(t *testing.T, s *Store, cookies []*http.Cookie) *sessions.Session {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	sess, err := s.New(req, "session")
+	require.NoError(t, err)
+	return sess
+}
+
+// save は保存して、書かれた Cookie を返す
+func save(t *testing.T, s *Store, sess *sessions.Session) []*http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	require.NoError(t, s.Save(req, rec, sess))
+	return rec.Result().Cookies()
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+
+	sess := load(t, s, nil)
+	assert.True(t, sess.IsNew)
+	sess.Values["locale"] = "en"
+	cookies := save(t, s, sess)
+
+	// Cookie には ID しか入っていない
+	require.Len(t, cookies, 1)
+	assert.NotContains(t, cookies[0].Value, "en")
+
+	again := load(t, s, cookies)
+	assert.False(t, again.IsNew)
+	assert.Equal(t, "en", again.Values["locale"])
+
+	// 保存先の ID は Cookie の ID のハッシュ
+	rec, err := b.Load(context.Background(), RecordID(sess.ID), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "test-agent", rec.UserAgent)
+	assert.Equal(t, "192.0.2.1", rec.IP)
+}
+
+func TestStore_BrokenOrUnknownCookie(t *testing.T) {
+	s := newTestStore(NewMemoryBackend(), "secret")
+
+	// 改ざんされた Cookie や、別の鍵で作られた Cookie はエラーにせず新しいセッションにする
+	other := newTestStore(NewMemoryBackend(), "other-secret")
+	sess := load(t, other, nil)
+	sess.Values["x"] = "y"
+	cookies := save(t, other, sess)
+
+	for _, c := range [][]*http.Cookie{
+		cookies,
+		{{Name: "session", Value: "garbage"}},
+	} {
+		sess := load(t, s, c)
+		assert.True(t, sess.IsNew)
+		assert.Empty(t, sess.Values)
+	}
+}
+
+func TestStore_KeyRotation(t *testing.T) {
+	b := NewMemoryBackend()
+	old := newTestStore(b, "old-secret")
+	sess := load(t, old, nil)
+	sess.Values[AccountIDKey] = uint64(1)
+	cookies := save(t, old, sess)
+
+	// 新しい鍵を先頭に、古い鍵を後ろに並べると、古い Cookie も読める
+	rotated := newTestStore(b, "new-secret", "old-secret")
+	sess = load(t, rotated, cookies)
+	assert.Equal(t, uint64(1), sess.Values[AccountIDKey])
+
+	// 保存し直した Cookie は新しい鍵だけで読める
+	cookies = save(t, rotated, sess)
+	sess = load(t, newTestStore(b, "new-secret"), cookies)
+	assert.Equal(t, uint64(1), sess.Values[AccountIDKey])
+}
+
+func TestStore_RenewIDOnLogin(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+	sess := load(t, s, nil)
+	sess.Values["locale"] = "en"
+	save(t, s, sess)
+	before := sess.ID
+
+	// ログインしたら ID を振り直し、ログイン前の ID は使えなくする
+	sess.Values[AccountIDKey] = uint64(1)
+	save(t, s, sess)
+	assert.NotEqual(t, before, sess.ID)
+	_, err := b.Load(context.Background(), RecordID(before), time.Now())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// ログインしたままの保存では振り直さない
+	after := sess.ID
+	sess.Values["locale"] = "en"
+	save(t, s, sess)
+	assert.Equal(t, after, sess.ID)
+
+	list, err := b.ListAuthenticated(context.Background(), time.Now())
+	require.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, uint64(1), list[0].AccountID)
+	}
+}
+
+func TestStore_LogoutAndRevoke(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+	sess := load(t, s, nil)
+	sess.Values[AccountIDKey] = uint64(1)
+	cookies := save(t, s, sess)
+
+	// サーバー側で消されたら、Cookie があっても空のセッションになる
+	require.NoError(t, b.Delete(context.Background(), RecordID(sess.ID)))
+	assert.True(t, load(t, s, cookies).IsNew)
+
+	// ログアウト (MaxAge < 0) は保存先から消して、Cookie も消す
+	sess = load(t, s, nil)
+	sess.Values[AccountIDKey] = uint64(2)
+	cookies = save(t, s, sess)
+	sess = load(t, s, cookies)
+	sess.Options.MaxAge = -1
+	cookies = save(t, s, sess)
+	assert.Equal(t, -1, cookies[0].MaxAge)
+	_, err := b.Load(context.Background(), RecordID(sess.ID), time.Now())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_RevokedWhileLoaded(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+	sess := load(t, s, nil)
+	sess.Values[AccountIDKey] = uint64(1)
+	cookies := save(t, s, sess)
+
+	// リクエストの途中（読み込んだ後）に、管理者が強制終了した
+	stale := load(t, s, cookies)
+	require.NoError(t, b.Delete(context.Background(), RecordID(stale.ID)))
+
+	// その後の保存 (フラッシュやトークンの発行など) で作り直さず、Cookie を消す
+	stale.Values["flash"] = "saved"
+	cookies = save(t, s, stale)
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, -1, cookies[0].MaxAge)
+	}
+	assert.Empty(t, stale.Values)
+	list, err := b.ListAuthenticated(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// 同じリクエストで続けて保存しても、ログインは戻らない
+	stale.Values["locale"] = "en"
+	cookies = save(t, s, stale)
+	again := load(t, s, cookies)
+	assert.Nil(t, again.Values[AccountIDKey])
+	assert.Equal(t, "en", again.Values["locale"])
+}
+
+func TestStore_EmptySessionNotSaved(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+
+	// 何も入っていない新しいセッションは、保存先に行を作らず Cookie も書かない
+	sess := load(t, s, nil)
+	assert.Empty(t, save(t, s, sess))
+	assert.Empty(t, sess.ID)
+	n, err := b.DeleteExpired(context.Background(), time.Now().Add(48*time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestStore_Sweep(t *testing.T) {
+	b := NewMemoryBackend()
+	s := newTestStore(b, "secret")
+	sess := load(t, s, nil)
+	sess.Values["locale"] = "en"
+	save(t, s, sess)
+
+	// 期限を過ぎた時刻にして掃除させる
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Sweep(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	// 今の時刻ではまだ期限内なので、消えていれば Sweep が消したもの
+	assert.Eventually(t, func() bool {
+		_, err := b.Load(context.Background(), RecordID(sess.ID), time.Now())
+		return errors.Is(err, ErrNotFound)
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,28 @@
+package sessionstore
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Sweep は interval ごとに期限切れのセッションを消す。ctx が終わるまで戻らないので go で呼ぶ
+func (s *Store) Sweep(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		n, err := s.backend.DeleteExpired(ctx, s.now())
+		if err != nil {
+			slog.WarnContext(ctx, "期限切れのセッションを消せませんでした", "error", err.Error())
+			continue
+		}
+		if n > 0 {
+			slog.InfoContext(ctx, "期限切れのセッションを消しました", "count", n)
+		}
+	}
+}
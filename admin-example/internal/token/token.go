@@ -0,0 +1,104 @@
+// Package token はメールで送るリンク（招待など）に入れる、署名付きで期限のあるトークンを作る。
+//
+//	<payload>.<署名>
+//	payload = 有効期限 (Unix ミリ秒, 8バイト) + ランダムな値 (16バイト) を base64url にしたもの
+//	署名    = HMAC-SHA256(鍵, 用途 + "\x00" + payload) を base64url にしたもの
+//
+// 署名に用途 (Purpose) を含めるので、招待のトークンを別の用途に使い回すことはできない。
+// 署名と期限は DB を見ずに確認できる。1回しか使えないようにする・取り消すといった管理は、
+// トークンのハッシュ (Hash) を DB に保存して行う（トークンそのものは保存しない）
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 用途。署名に含める
+const (
+	PurposeInvite        = "invite"         // 招待の受諾 (/invitations/accept)
+	PurposePasswordReset = "password_reset" // パスワードの再設定 (/password/reset)
+)
+
+var (
+	// ErrInvalid: 形式が違う・署名が合わない・用途が違う（改ざんされたか、別の鍵で作られた）
+	ErrInvalid = errors.New("トークンが正しくありません")
+	// ErrExpired: 署名は正しいが、有効期限を過ぎている
+	ErrExpired = errors.New("トークンの有効期限が切れています")
+)
+
+const (
+	nonceSize   = 16
+	payloadSize = 8 + nonceSize
+)
+
+var encoding = base64.RawURLEncoding // URL にそのまま入れられる
+
+// Signer: トークンを作って確かめる
+type Signer struct {
+	key []byte
+	now func() time.Time // テストで時刻を固定するため差し替えられる
+}
+
+// NewSigner は secret を鍵にする Signer を作る
+func NewSigner(secret []byte) *Signer {
+	return &Signer{key: secret, now: time.Now}
+}
+
+// Issue は用途 purpose の、ttl だけ有効なトークンを作る。有効期限も返す
+func (s *Signer) Issue(purpose string, ttl time.Duration) (string, time.Time, error) {
+	// DB の datetime(3) と同じく、ミリ秒に切り捨てておく
+	expires := s.now().Add(ttl).Truncate(time.Millisecond)
+
+	payload := make([]byte, payloadSize)
+	binary.BigEndian.PutUint64(payload, uint64(expires.UnixMilli()))
+	if _, err := rand.Read(payload[8:]); err != nil {
+		return "", time.Time{}, err
+	}
+	p := encoding.EncodeToString(payload)
+	return p + "." + encoding.EncodeToString(s.sign(purpose, p)), expires, nil
+}
+
+// Verify は token が purpose 用に作られたもので、期限内かを確かめて有効期限を返す
+func (s *Signer) Verify(purpose, token string) (time.Time, error) {
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, ErrInvalid
+	}
+	got, err := encoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(purpose, p)) {
+		return time.Time{}, ErrInvalid
+	}
+	payload, err := encoding.DecodeString(p)
+	if err != nil || len(payload) != payloadSize {
+		return time.Time{}, ErrInvalid
+	}
+
+	expires := time.UnixMilli(int64(binary.BigEndian.Uint64(payload)))
+	if !s.now().Before(expires) {
+		return expires, ErrExpired
+	}
+	return expires, nil
+}
+
+func (s *Signer) sign(purpose, payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Hash は DB に保存・検索するためのトークンのハッシュ (SHA-256 の16進数, 64文字)
+// DB が漏れても、保存されたハッシュからリンクを作ることはできない
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,85 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSigner(now time.Time) *Signer {
+	s := NewSigner([]byte("test-secret"))
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestSigner_IssueAndVerify(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestSigner(now)
+
+	tok, expires, err := s.Issue(PurposeInvite, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), expires)
+
+	got, err := s.Verify(PurposeInvite, tok)
+	assert.NoError(t, err)
+	assert.True(t, expires.Equal(got))
+
+	// 毎回違うトークンになる
+	other, _, err := s.Issue(PurposeInvite, time.Hour)
+	require.NoError(t, err)
+	assert.NotEqual(t, tok, other)
+}
+
+func TestSigner_Verify_Expired(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestSigner(now)
+	tok, _, err := s.Issue(PurposeInvite, time.Hour)
+	require.NoError(t, err)
+
+	s.now = func() time.Time { return now.Add(time.Hour) }
+	_, err = s.Verify(PurposeInvite, tok)
+
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestSigner_Verify_Invalid(t *testing.T) {
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	s := newTestSigner(now)
+	tok, _, err := s.Issue(PurposeInvite, time.Hour)
+	require.NoError(t, err)
+	payload, sig, _ := strings.Cut(tok, ".")
+	// payload の先頭の1文字を別の文字にする
+	tampered := "A" + payload[1:]
+	if payload[0] == 'A' {
+		tampered = "B" + payload[1:]
+	}
+
+	other := NewSigner([]byte("other-secret"))
+	other.now = s.now
+	otherTok, _, err := other.Issue(PurposeInvite, time.Hour)
+	require.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		purpose, token string
+	}{
+		"用途が違う":       {"reset", tok},
+		"別の鍵":         {PurposeInvite, otherTok},
+		"署名が無い":       {PurposeInvite, payload},
+		"payloadの改ざん": {PurposeInvite, tampered + "." + sig},
+		"空":           {PurposeInvite, ""},
+	} {
+		_, err := s.Verify(tt.purpose, tt.token)
+		assert.ErrorIs(t, err, ErrInvalid, name)
+	}
+}
+
+func TestHash(t *testing.T) {
+	h := Hash("abc")
+
+	assert.Len(t, h, 64)
+	assert.Equal(t, h, Hash("abc"))
+	assert.NotEqual(t, h, Hash("abd"))
+}
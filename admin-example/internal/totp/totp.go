@@ -0,0 +1,95 @@
+// Package totp は認証アプリ (Google Authenticator など) の6桁のコードを作って確かめる (RFC 6238)
+//
+// HMAC-SHA1、30秒ごと、6桁。どの認証アプリでも使える既定の組み合わせにしている
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// 前後いくつのコードまで受け付けるか（スマートフォンの時計のずれの分）
+	Skew = 1
+)
+
+// ErrInvalidSecret: 秘密鍵が base32 になっていない
+var ErrInvalidSecret = errors.New("秘密鍵の形式が正しくありません")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret は新しい秘密鍵 (160bit) を base32 で作る。認証アプリに手で入れる時もこの文字列を使う
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step は時刻 t が何番目の30秒か
+func Step(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(Period/time.Second)
+}
+
+// Code は step 番目のコード
+func Code(secret string, step uint64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], step)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// 最後の4ビットが示す位置から4バイトを取り出し、下6桁にする
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, n%1000000), nil
+}
+
+// Validate は code が時刻 t の前後 Skew 個以内のコードかを確かめ、一致したコードの step を返す
+// 同じコードを2回使わせないよう、呼び出し元で step を記録しておくこと
+func Validate(secret, code string, t time.Time) (uint64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Step(t)
+	for d := -Skew; d <= Skew; d++ {
+		step := uint64(int64(now) + int64(d))
+		want, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI は認証アプリに読ませる otpauth:// の URI（QR コードにする）
+// 例) otpauth://totp/管理画面:admin@example.com?secret=...&issuer=管理画面
+func URI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
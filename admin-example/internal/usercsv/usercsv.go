@@ -0,0 +1,200 @@
+// Package usercsv はユーザー一覧の CSV の書き出しと読み込みを行う。
+//
+// Excel でそのまま開けるよう、UTF-8 (BOM 付き) と Shift_JIS のどちらでも書き出せる。
+// 読み込みは BOM と中身から文字コードを判定するので、どちらで保存したファイルでもよい
+package usercsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-example/admin-example/internal/repository"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/transform"
+)
+
+// Encoding: 書き出す時の文字コード
+type Encoding string
+
+const (
+	UTF8     Encoding = "utf8" // BOM 付き（Excel が UTF-8 だと判断できるように）
+	ShiftJIS Encoding = "sjis" // 日本語版 Windows の Excel 向け
+)
+
+// ParseEncoding は ?encoding= の値を読む。空や知らない値なら UTF8
+func ParseEncoding(s string) Encoding {
+	if strings.EqualFold(s, string(ShiftJIS)) {
+		return ShiftJIS
+	}
+	return UTF8
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Header: 書き出す列。読み込みで使うのは name 列だけ（他の列は無視する）
+var Header = []string{"id", "name", "created_at", "updated_at"}
+
+const (
+	timeLayout = "2006-01-02 15:04:05"
+
+	// 読み込める行数の上限（ヘッダーを除く）
+	MaxRows = 1000
+)
+
+// Write は users を CSV で書き出す
+func Write(w io.Writer, users []repository.User, enc Encoding) error {
+	if enc == ShiftJIS {
+		// Shift_JIS に無い文字 (絵文字など) は ? に置き換える
+		w = transform.NewWriter(w, encoding.ReplaceUnsupported(japanese.ShiftJIS.NewEncoder()))
+	} else if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true // Excel に合わせる
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	for _, u := range users {
+		record := []string{
+			strconv.FormatUint(u.ID, 10),
+			escapeFormula(u.Name.String),
+			"", "",
+		}
+		if u.CreatedAt.Valid {
+			record[2] = u.CreatedAt.Time.Format(timeLayout)
+		}
+		if u.UpdatedAt.Valid {
+			record[3] = u.UpdatedAt.Time.Format(timeLayout)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	// transform.Writer は Close しないと最後の数バイトが書き出されない
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Excel が数式として実行してしまう先頭の文字
+const formulaPrefixes = "=+-@"
+
+// escapeFormula は =SUM(...) のような値を、Excel が数式ではなく文字として扱うよう ' を付ける
+// (CSV インジェクション対策)。読み込む時は unescapeFormula で戻す
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func unescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// Row: 読み込んだ1行。Line はファイルの行番号（ヘッダーが1行目）
+type Row struct {
+	Line int
+	Name string
+}
+
+// ErrNoNameColumn: ヘッダーに name 列が無い
+var ErrNoNameColumn = errors.New("1行目に name（または 名前）の列がありません")
+
+// Read は CSV を読み込む。1行目はヘッダーで、name か 名前 の列を使う。空行は飛ばす
+func Read(r io.Reader) ([]Row, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err = decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1 // 列の数が行ごとに違っても読む（Excel は末尾の空の列を省くことがある）
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, ErrNoNameColumn
+	}
+	if err != nil {
+		return nil, fmt.Errorf("CSV を読み込めません: %w", err)
+	}
+	col := -1
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "name" || h == "名前" {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, ErrNoNameColumn
+	}
+
+	var rows []Row
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV を読み込めません: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if isBlank(record) {
+			continue
+		}
+		if len(rows) >= MaxRows {
+			return nil, fmt.Errorf("一度に取り込めるのは %d 行までです", MaxRows)
+		}
+		name := ""
+		if col < len(record) {
+			name = unescapeFormula(strings.TrimSpace(record[col]))
+		}
+		rows = append(rows, Row{Line: line, Name: name})
+	}
+	return rows, nil
+}
+
+// decode は BOM を取り除き、UTF-8 でなければ Shift_JIS として UTF-8 に変換する
+func decode(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, utf8BOM) {
+		return data[len(utf8BOM):], nil
+	}
+	if utf8.Valid(data) {
+		return data, nil
+	}
+	decoded, _, err := transform.Bytes(japanese.ShiftJIS.NewDecoder(), data)
+	if err != nil {
+		return nil, errors.New("文字コードを判定できません。UTF-8 か Shift_JIS で保存してください")
+	}
+	return decoded, nil
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
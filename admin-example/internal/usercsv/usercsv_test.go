@@ -0,0 +1,82 @@
+package usercsv
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"go-example/admin-example/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func testUsers() []repository.User {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []repository.User{
+		{ID: 1, Name: sql.NullString{String: "山田太郎", Valid: true}, CreatedAt: sql.NullTime{Time: at, Valid: true}},
+		{ID: 2, Name: sql.NullString{String: "=1+1", Valid: true}},
+	}
+}
+
+func TestWrite_UTF8(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Write(&buf, testUsers(), UTF8)
+
+	assert.NoError(t, err)
+	// Excel が UTF-8 だと分かるように BOM を付ける
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), utf8BOM))
+	assert.Equal(t,
+		"id,name,created_at,updated_at\r\n"+
+			"1,山田太郎,2026-01-02 03:04:05,\r\n"+
+			"2,'=1+1,,\r\n", // 数式として実行されないように ' を付ける
+		string(buf.Bytes()[len(utf8BOM):]))
+}
+
+func TestWrite_ShiftJIS(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := Write(&buf, testUsers(), ShiftJIS)
+
+	assert.NoError(t, err)
+	decoded, _ := japanese.ShiftJIS.NewDecoder().Bytes(buf.Bytes())
+	assert.Contains(t, string(decoded), "1,山田太郎,")
+}
+
+func TestRead_RoundTrip(t *testing.T) {
+	for _, enc := range []Encoding{UTF8, ShiftJIS} {
+		var buf bytes.Buffer
+		assert.NoError(t, Write(&buf, testUsers(), enc))
+
+		rows, err := Read(&buf)
+
+		// 出力したファイルをそのまま読み込める
+		assert.NoError(t, err, enc)
+		assert.Equal(t, []Row{{Line: 2, Name: "山田太郎"}, {Line: 3, Name: "=1+1"}}, rows, enc)
+	}
+}
+
+func TestRead_NameColumnOnly(t *testing.T) {
+	rows, err := Read(strings.NewReader("メモ,名前\nあ, 佐藤花子 \n\n,\nい\n"))
+
+	// 空行は飛ばすが、行番号はファイルのまま
+	assert.NoError(t, err)
+	assert.Equal(t, []Row{{Line: 2, Name: "佐藤花子"}, {Line: 5, Name: ""}}, rows)
+}
+
+func TestRead_NoNameColumn(t *testing.T) {
+	_, err := Read(strings.NewReader("id,email\n1,a@example.com\n"))
+
+	assert.ErrorIs(t, err, ErrNoNameColumn)
+}
+
+func TestRead_TooManyRows(t *testing.T) {
+	csv := "name\n" + strings.Repeat("ユーザー\n", MaxRows+1)
+
+	_, err := Read(strings.NewReader(csv))
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IsSupported は対応している言語かを返す
+func IsSupported(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLocale は Accept-Language ヘッダー (例: "en-US,en;q=0.9,ja;q=0.8") から
+// 対応している言語を選ぶ。どれも無ければ DefaultLocale
+func MatchLocale(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		tag = strings.TrimSpace(tag)
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{strings.ToLower(tag), q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		// en-US のような地域付きは、言語の部分 (en) で比べる
+		base, _, _ := strings.Cut(c.tag, "-")
+		if IsSupported(base) {
+			return base
+		}
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,105 @@
+package validation
+
+// catalogs: 言語ごとのメッセージ。{0} は項目名、{1} はタグのパラメーター (min=3 の 3 など)
+// min / max などは messageKey で .string (文字数) / .number (値) / .items (個数) に分けている
+// (universal-translator の決まりで、{0} を {1} より前に書くこと)
+var catalogs = map[string]map[string]string{
+	"ja": {
+		"default":  "{0}が正しくありません",
+		"required": "{0}を入力してください",
+
+		"len.string": "{0}は{1}文字で入力してください",
+		"len.number": "{0}は{1}にしてください",
+		"len.items":  "{0}は{1}個にしてください",
+		"min.string": "{0}は{1}文字以上で入力してください",
+		"min.number": "{0}は{1}以上にしてください",
+		"min.items":  "{0}は{1}個以上にしてください",
+		"max.string": "{0}は{1}文字以内で入力してください",
+		"max.number": "{0}は{1}以下にしてください",
+		"max.items":  "{0}は{1}個以内にしてください",
+		"gt.string":  "{0}は{1}文字より長くしてください",
+		"gt.number":  "{0}は{1}より大きくしてください",
+		"gt.items":   "{0}は{1}個より多くしてください",
+		"gte.string": "{0}は{1}文字以上で入力してください",
+		"gte.number": "{0}は{1}以上にしてください",
+		"gte.items":  "{0}は{1}個以上にしてください",
+		"lt.string":  "{0}は{1}文字より短くしてください",
+		"lt.number":  "{0}は{1}より小さくしてください",
+		"lt.items":   "{0}は{1}個より少なくしてください",
+		"lte.string": "{0}は{1}文字以内で入力してください",
+		"lte.number": "{0}は{1}以下にしてください",
+		"lte.items":  "{0}は{1}個以内にしてください",
+		"eq.string":  "{0}は{1}にしてください",
+		"eq.number":  "{0}は{1}にしてください",
+		"eq.items":   "{0}は{1}個にしてください",
+		"ne.string":  "{0}に{1}は使えません",
+		"ne.number":  "{0}に{1}は使えません",
+		"ne.items":   "{0}は{1}個以外にしてください",
+
+		"oneof":    "{0}は次のどれかにしてください: {1}",
+		"email":    "{0}はメールアドレスの形式で入力してください",
+		"url":      "{0}はURLの形式で入力してください",
+		"uri":      "{0}はURIの形式で入力してください",
+		"numeric":  "{0}は数字で入力してください",
+		"number":   "{0}は数字で入力してください",
+		"alpha":    "{0}は英字で入力してください",
+		"alphanum": "{0}は英数字で入力してください",
+		"ascii":    "{0}は半角英数記号で入力してください",
+		"boolean":  "{0}は true か false にしてください",
+		"uuid":     "{0}はUUIDの形式で入力してください",
+		"datetime": "{0}は {1} の形式で入力してください",
+		"eqfield":  "{0}が{1}と一致しません",
+		"nefield":  "{0}は{1}と違うものにしてください",
+		"gtfield":  "{0}は{1}より大きくしてください",
+		"ltfield":  "{0}は{1}より小さくしてください",
+	},
+	"en": {
+		"default":  "{0} is invalid",
+		"required": "{0} is required",
+
+		"len.string": "{0} must be exactly {1} characters long",
+		"len.number": "{0} must be {1}",
+		"len.items":  "{0} must contain exactly {1} items",
+		"min.string": "{0} must be at least {1} characters long",
+		"min.number": "{0} must be {1} or greater",
+		"min.items":  "{0} must contain at least {1} items",
+		"max.string": "{0} must be at most {1} characters long",
+		"max.number": "{0} must be {1} or less",
+		"max.items":  "{0} must contain at most {1} items",
+		"gt.string":  "{0} must be longer than {1} characters",
+		"gt.number":  "{0} must be greater than {1}",
+		"gt.items":   "{0} must contain more than {1} items",
+		"gte.string": "{0} must be at least {1} characters long",
+		"gte.number": "{0} must be {1} or greater",
+		"gte.items":  "{0} must contain at least {1} items",
+		"lt.string":  "{0} must be shorter than {1} characters",
+		"lt.number":  "{0} must be less than {1}",
+		"lt.items":   "{0} must contain fewer than {1} items",
+		"lte.string": "{0} must be at most {1} characters long",
+		"lte.number": "{0} must be {1} or less",
+		"lte.items":  "{0} must contain at most {1} items",
+		"eq.string":  "{0} must be {1}",
+		"eq.number":  "{0} must be {1}",
+		"eq.items":   "{0} must contain {1} items",
+		"ne.string":  "{0} must not be {1}",
+		"ne.number":  "{0} must not be {1}",
+		"ne.items":   "{0} must not contain {1} items",
+
+		"oneof":    "{0} must be one of: {1}",
+		"email":    "{0} must be a valid email address",
+		"url":      "{0} must be a valid URL",
+		"uri":      "{0} must be a valid URI",
+		"numeric":  "{0} must be numeric",
+		"number":   "{0} must be a number",
+		"alpha":    "{0} must contain only letters",
+		"alphanum": "{0} must contain only letters and numbers",
+		"ascii":    "{0} must contain only ASCII characters",
+		"boolean":  "{0} must be true or false",
+		"uuid":     "{0} must be a valid UUID",
+		"datetime": "{0} must be in the format {1}",
+		"eqfield":  "{0} must match {1}",
+		"nefield":  "{0} must be different from {1}",
+		"gtfield":  "{0} must be greater than {1}",
+		"ltfield":  "{0} must be less than {1}",
+	},
+}
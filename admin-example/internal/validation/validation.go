@@ -0,0 +1,160 @@
+// Package validation は入力チェック (go-playground/validator) のエラーを、
+// 画面に出すメッセージ（日本語・英語）に変える。
+//
+// メッセージのキーはフォームの name (form タグ) で、項目名は label タグ（英語は label_en タグ）を使う
+//
+//	Name string `form:"name" validate:"required,min=3" label:"名前" label_en:"Name"`
+//	→ map[string]string{"name": "名前は3文字以上で入力してください"}
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ja"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+const DefaultLocale = "ja"
+
+// Locales: 対応している言語
+var Locales = []string{"ja", "en"}
+
+// CustomMessages はフォームごとにメッセージを変えたい時に実装する。
+// キーは "name.min" (項目.タグ) か "name" (その項目の全てのエラー)
+//
+//	func (f *UserCreateForm) ValidationMessages(locale string) map[string]string {
+//		return map[string]string{"name.min": "名前が短すぎます"}
+//	}
+type CustomMessages interface {
+	ValidationMessages(locale string) map[string]string
+}
+
+var translator = newTranslator()
+
+func newTranslator() *ut.UniversalTranslator {
+	uni := ut.New(ja.New(), ja.New(), en.New())
+	for locale, msgs := range catalogs {
+		trans, _ := uni.GetTranslator(locale)
+		for key, text := range msgs {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err) // カタログの書き間違い。起動時に気付けるように止める
+			}
+		}
+	}
+	return uni
+}
+
+// New はアプリで使う validator を作る。
+// エラーの Field() が Go のフィールド名 (Name) ではなく、フォームの name (name) になる
+func New() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	return v
+}
+
+// fieldName は form タグ → json タグ → Go のフィールド名の順に探す
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"form", "json"} {
+		// "-" を返すと validator がその項目をチェックしなくなるので、次の候補を見る
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Messages は err (validator.ValidationErrors) を「項目 → メッセージ」にする。
+// form は入力チェックしたフォーム（ラベルと CustomMessages に使う。nil なら項目名のまま）
+func Messages(err error, locale string, form interface{}) map[string]string {
+	messages := make(map[string]string)
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return messages
+	}
+
+	trans, ok := translator.GetTranslator(locale)
+	if !ok {
+		trans = translator.GetFallback()
+		locale = DefaultLocale
+	}
+	var custom map[string]string
+	if cm, ok := form.(CustomMessages); ok {
+		custom = cm.ValidationMessages(locale)
+	}
+	t := reflect.TypeOf(form)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	for _, fe := range ve {
+		key := fe.Field()
+		if msg, ok := custom[key+"."+fe.Tag()]; ok {
+			messages[key] = msg
+			continue
+		}
+		if msg, ok := custom[key]; ok {
+			messages[key] = msg
+			continue
+		}
+
+		label := labelOf(t, fe.StructField(), key, locale)
+		param := fe.Param()
+		switch fe.Tag() {
+		case "eqfield", "nefield", "gtfield", "ltfield":
+			// 比べる相手の項目もラベルで表示する
+			param = labelOf(t, param, param, locale)
+		case "oneof":
+			param = strings.Join(strings.Fields(param), " / ")
+		}
+
+		msg, err := trans.T(messageKey(fe), label, param)
+		if err != nil {
+			msg, _ = trans.T("default", label, param)
+		}
+		messages[key] = msg
+	}
+	return messages
+}
+
+// labelOf は Go のフィールド名 goName のラベルを返す（無ければ fallback）
+func labelOf(t reflect.Type, goName, fallback, locale string) string {
+	if t == nil || t.Kind() != reflect.Struct {
+		return fallback
+	}
+	field, found := t.FieldByName(goName)
+	if !found {
+		return fallback
+	}
+	if locale != DefaultLocale {
+		if l := field.Tag.Get("label_" + locale); l != "" {
+			return l
+		}
+		return fallback
+	}
+	if l := field.Tag.Get("label"); l != "" {
+		return l
+	}
+	return fallback
+}
+
+// messageKey はカタログのキーを返す。
+// min / max などは、文字列なら文字数・数値なら値・スライスなら個数なので、型ごとに分ける
+func messageKey(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "len", "min", "max", "gt", "gte", "lt", "lte", "eq", "ne":
+		switch fe.Kind() {
+		case reflect.String:
+			return fe.Tag() + ".string"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fe.Tag() + ".items"
+		default:
+			return fe.Tag() + ".number"
+		}
+	}
+	return fe.Tag()
+}
@@ -0,0 +1,128 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type signupForm struct {
+	Name     string   `form:"name" validate:"required,min=3,max=5" label:"名前" label_en:"Name"`
+	Email    string   `form:"email" validate:"omitempty,email" label:"メールアドレス" label_en:"Email"`
+	Age      int      `json:"age" validate:"omitempty,min=18" label:"年齢"`
+	Code     string   `form:"code" validate:"omitempty,len=4,numeric" label:"コード"`
+	Plan     string   `form:"plan" validate:"omitempty,oneof=free pro" label:"プラン"`
+	Site     string   `form:"site" validate:"omitempty,url" label:"サイト"`
+	Tags     []string `form:"tags" validate:"max=2" label:"タグ"`
+	Password string   `form:"password" label:"パスワード"`
+	Confirm  string   `form:"confirm" validate:"eqfield=Password" label:"パスワード(確認)"`
+	NoTag    string   `validate:"required"`
+}
+
+func validate(form interface{}, locale string) map[string]string {
+	return Messages(New().Struct(form), locale, form)
+}
+
+func TestMessages_Japanese(t *testing.T) {
+	msgs := validate(&signupForm{
+		Name: "ab", Email: "x", Age: 10, Code: "12a", Plan: "gold", Site: "nope",
+		Tags: []string{"a", "b", "c"}, Password: "p", Confirm: "q",
+	}, "ja")
+
+	// キーは form タグ (無ければ json タグ → Go の名前)、項目名は label タグ
+	assert.Equal(t, map[string]string{
+		"name":    "名前は3文字以上で入力してください",
+		"email":   "メールアドレスはメールアドレスの形式で入力してください",
+		"age":     "年齢は18以上にしてください",
+		"code":    "コードは4文字で入力してください",
+		"plan":    "プランは次のどれかにしてください: free / pro",
+		"site":    "サイトはURLの形式で入力してください",
+		"tags":    "タグは2個以内にしてください",
+		"confirm": "パスワード(確認)がパスワードと一致しません",
+		"NoTag":   "NoTagを入力してください",
+	}, msgs)
+}
+
+func TestMessages_English(t *testing.T) {
+	msgs := validate(&signupForm{Name: "abcdef", Code: "abcd"}, "en")
+
+	assert.Equal(t, "Name must be at most 5 characters long", msgs["name"])
+	// label_en が無ければキーのまま
+	assert.Equal(t, "code must be numeric", msgs["code"])
+	assert.Equal(t, "NoTag is required", msgs["NoTag"])
+}
+
+func TestMessages_UnknownLocaleFallsBackToJapanese(t *testing.T) {
+	msgs := validate(&signupForm{}, "fr")
+
+	assert.Equal(t, "名前を入力してください", msgs["name"])
+}
+
+type customForm struct {
+	Name  string `form:"name" validate:"required,min=3" label:"名前"`
+	Title string `form:"title" validate:"required,alpha" label:"タイトル"`
+}
+
+func (f *customForm) ValidationMessages(locale string) map[string]string {
+	if locale == "en" {
+		return map[string]string{"name.min": "Too short"}
+	}
+	return map[string]string{
+		"name.min": "名前が短すぎます",
+		"title":    "タイトルは英字で必ず入力してください",
+	}
+}
+
+func TestMessages_CustomMessages(t *testing.T) {
+	msgs := validate(&customForm{Name: "ab", Title: "1"}, "ja")
+
+	assert.Equal(t, "名前が短すぎます", msgs["name"])
+	assert.Equal(t, "タイトルは英字で必ず入力してください", msgs["title"])
+
+	// 他の言語では、そのタグの分だけ差し替える
+	msgs = validate(&customForm{Name: "ab"}, "en")
+	assert.Equal(t, "Too short", msgs["name"])
+	assert.Equal(t, "title is required", msgs["title"])
+}
+
+func TestMessages_UnknownTag(t *testing.T) {
+	v := New()
+	form := &struct {
+		Color string `form:"color" validate:"hexcolor" label:"色"`
+	}{Color: "red"}
+
+	// カタログに無いタグは「正しくありません」
+	msgs := Messages(v.Struct(form), "ja", form)
+	assert.Equal(t, "色が正しくありません", msgs["color"])
+}
+
+func TestMessages_NotValidationError(t *testing.T) {
+	assert.Empty(t, Messages(nil, "ja", nil))
+	assert.Empty(t, Messages(assert.AnError, "ja", nil))
+}
+
+func TestMatchLocale(t *testing.T) {
+	cases := map[string]string{
+		"":                            "ja",
+		"en-US,en;q=0.9":              "en",
+		"fr-FR,fr;q=0.9,en;q=0.8":     "en",
+		"en;q=0.5,ja;q=0.9":           "ja",
+		"de":                          "ja",
+		"EN-gb":                       "en",
+		"en;q=0,ja;q=0.1":             "ja",
+		"zh-CN, en-GB ; q=0.7, *;q=1": "en",
+	}
+	for header, want := range cases {
+		assert.Equal(t, want, MatchLocale(header), header)
+	}
+}
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	// 片方の言語だけにメッセージを足し忘れていないか
+	for key := range catalogs["ja"] {
+		assert.Contains(t, catalogs["en"], key)
+	}
+	for key := range catalogs["en"] {
+		assert.Contains(t, catalogs["ja"], key)
+	}
+}
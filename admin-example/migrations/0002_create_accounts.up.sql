@@ -0,0 +1,13 @@
+-- 管理画面にログインするアカウント
+-- role は admin / editor / viewer（権限の中身は internal/model/role.go）
+CREATE TABLE IF NOT EXISTS `accounts` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `email` varchar(255) NOT NULL,
+  /* bcrypt のハッシュ。平文のパスワードは保存しない */
+  `password_hash` varchar(255) NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'viewer',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_accounts_email` (`email`)
+);
@@ -0,0 +1,20 @@
+-- 監査ログ（誰が・いつ・何を・どう変えたか）
+-- 変更と同じトランザクションで書き込むので、変更だけが残ってログが無いという状態にはならない
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  /* 操作したアカウント。コマンドラインからの操作などは 0 */
+  `actor_id` bigint unsigned NOT NULL DEFAULT 0,
+  `actor_email` varchar(255) NOT NULL DEFAULT '',
+  /* 例) user.create / user.update / user.delete / user.restore */
+  `action` varchar(50) NOT NULL,
+  `target_type` varchar(50) NOT NULL,
+  `target_id` bigint unsigned NOT NULL,
+  `before_value` json DEFAULT NULL,
+  `after_value` json DEFAULT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  INDEX `idx_audit_logs_created_at` (`created_at`),
+  INDEX `idx_audit_logs_actor` (`actor_email`, `created_at`),
+  INDEX `idx_audit_logs_target` (`target_type`, `target_id`)
+);
@@ -0,0 +1,2 @@
+-- 楽観的ロック用のバージョン。更新のたびに +1 し、画面を開いた時の値と違えば更新しない
+ALTER TABLE `users` ADD COLUMN `version` int unsigned NOT NULL DEFAULT 1;
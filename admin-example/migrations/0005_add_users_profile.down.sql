@@ -0,0 +1,7 @@
+ALTER TABLE `users`
+  DROP INDEX `uk_users_email_deleted_at`,
+  DROP COLUMN `avatar_path`,
+  DROP COLUMN `role`,
+  DROP COLUMN `status`,
+  DROP COLUMN `display_name`,
+  DROP COLUMN `email`;
@@ -0,0 +1,11 @@
+-- プロフィール（メールアドレス・表示名・状態・役割・アバター画像）
+-- email は任意（CSV で取り込んだユーザーなどは NULL）。名前と同じく、有効なユーザーの中での重複は登録前に確認する
+-- status は active / suspended / invited、role は accounts.role と同じ admin / editor / viewer（internal/model）
+-- avatar_path はアップロードした画像のキー（保存先は server.upload_dir）。空なら画像なし
+ALTER TABLE `users`
+  ADD COLUMN `email` varchar(255) DEFAULT NULL,
+  ADD COLUMN `display_name` varchar(255) NOT NULL DEFAULT '',
+  ADD COLUMN `status` varchar(20) NOT NULL DEFAULT 'active',
+  ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'viewer',
+  ADD COLUMN `avatar_path` varchar(255) NOT NULL DEFAULT '',
+  ADD UNIQUE KEY `uk_users_email_deleted_at` (`email`, `deleted_at`);
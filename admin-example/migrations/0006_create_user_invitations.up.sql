@@ -0,0 +1,19 @@
+-- 招待（ユーザーを status = invited で登録し、メールで送ったリンクからパスワードを決めてもらう）
+-- リンクのトークンそのものは保存せず、SHA-256 のハッシュだけを持つ (internal/token)
+-- 使ったら accepted_at、取り消したら revoked_at を入れる。どちらも NULL で期限内のものだけが使える
+CREATE TABLE IF NOT EXISTS `user_invitations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  /* 招待した時のメールアドレス（アカウントはこのアドレスで作る） */
+  `email` varchar(255) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `accepted_at` datetime(3) DEFAULT NULL,
+  `revoked_at` datetime(3) DEFAULT NULL,
+  /* 招待したアカウント。コマンドラインからの操作などは 0 */
+  `created_by` bigint unsigned NOT NULL DEFAULT 0,
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_user_invitations_token_hash` (`token_hash`),
+  INDEX `idx_user_invitations_user_id` (`user_id`)
+);
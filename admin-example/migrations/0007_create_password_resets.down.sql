@@ -0,0 +1,2 @@
+ALTER TABLE `accounts` DROP COLUMN `session_version`;
+DROP TABLE IF EXISTS `password_resets`;
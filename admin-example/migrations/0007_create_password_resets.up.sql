@@ -0,0 +1,20 @@
+-- パスワードの再設定（メールで送ったリンクから新しいパスワードを決めてもらう）
+-- 招待 (user_invitations) と同じく、トークンは SHA-256 のハッシュだけを持つ (internal/token)
+-- 使ったら used_at を入れる。NULL で期限内のものだけが使える
+CREATE TABLE IF NOT EXISTS `password_resets` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` bigint unsigned NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `used_at` datetime(3) DEFAULT NULL,
+  /* 再設定を申し込んだ IP アドレス（調査用） */
+  `requested_ip` varchar(45) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_password_resets_token_hash` (`token_hash`),
+  INDEX `idx_password_resets_account_id` (`account_id`)
+);
+
+-- ログイン中のセッションを無効にするためのバージョン
+-- ログインした時の値をセッションに入れておき、パスワードを変えたら +1 する（値が違うセッションはログアウト扱い）
+ALTER TABLE `accounts` ADD COLUMN `session_version` int unsigned NOT NULL DEFAULT 1;
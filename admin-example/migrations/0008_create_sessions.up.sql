@@ -0,0 +1,17 @@
+-- サーバー側に保存するセッション (internal/sessionstore)
+-- Cookie にはランダムな ID だけを入れ、ここには ID の SHA-256 のハッシュと中身を置く
+-- 期限切れの行は、アプリが session.sweep_interval ごとに消す
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` char(64) NOT NULL,
+  /* ログインしていなければ 0 */
+  `account_id` bigint unsigned NOT NULL DEFAULT 0,
+  `data` mediumblob NOT NULL,
+  `ip` varchar(45) NOT NULL DEFAULT '',
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_sessions_account_id` (`account_id`),
+  INDEX `idx_sessions_expires_at` (`expires_at`)
+);
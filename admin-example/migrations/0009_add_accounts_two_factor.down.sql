@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `recovery_codes`;
+ALTER TABLE `accounts`
+  DROP COLUMN `totp_last_step`,
+  DROP COLUMN `totp_enabled_at`,
+  DROP COLUMN `totp_secret`;
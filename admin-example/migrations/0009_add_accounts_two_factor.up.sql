@@ -0,0 +1,19 @@
+-- 2要素認証 (TOTP) の設定
+-- totp_secret は secretbox で暗号化した秘密鍵。NULL なら未設定、totp_enabled_at が NULL なら登録の途中
+-- totp_last_step は最後に使ったコードの時間の区切り。同じコードを2回使えないようにする
+ALTER TABLE `accounts`
+  ADD COLUMN `totp_secret` varbinary(255) DEFAULT NULL,
+  ADD COLUMN `totp_enabled_at` datetime(3) DEFAULT NULL,
+  ADD COLUMN `totp_last_step` bigint unsigned NOT NULL DEFAULT 0;
+
+-- 認証アプリを無くした時のためのリカバリーコード（1回だけ使える）
+-- 招待などのトークンと同じく、SHA-256 のハッシュだけを持つ
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` bigint unsigned NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `used_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  INDEX `idx_recovery_codes_account_id` (`account_id`)
+);
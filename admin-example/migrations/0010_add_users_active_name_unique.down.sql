@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+  DROP INDEX `uk_users_active_name`,
+  ADD UNIQUE KEY `uk_name_deleted_at` (`name`, `deleted_at`);
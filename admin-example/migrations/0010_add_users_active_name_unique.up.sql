@@ -0,0 +1,7 @@
+-- 有効なユーザー（deleted_at が NULL）の中で名前を重複させない
+-- uk_name_deleted_at (name, deleted_at) は deleted_at が NULL 同士を別の値として扱うので、有効なユーザー同士の重複を弾けなかった
+-- 削除済みなら NULL になる式にユニーク制約を付ける（MySQL 8.0.13 からの関数インデックス。隠し列なので SELECT * には出てこない）
+-- すでに重複している場合はこのマイグレーションが失敗するので、先に片方の名前を変えるか削除しておくこと
+ALTER TABLE `users`
+  DROP INDEX `uk_name_deleted_at`,
+  ADD UNIQUE KEY `uk_users_active_name` ((IF(`deleted_at` IS NULL, `name`, NULL)));
@@ -0,0 +1,3 @@
+ALTER TABLE `users`
+  DROP INDEX `uk_users_active_email`,
+  ADD UNIQUE KEY `uk_users_email_deleted_at` (`email`, `deleted_at`);
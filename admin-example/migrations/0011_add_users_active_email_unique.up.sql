@@ -0,0 +1,6 @@
+-- 有効なユーザーの中でメールアドレスを重複させない
+-- uk_users_email_deleted_at (email, deleted_at) は deleted_at が NULL 同士を別の値として扱うので、有効なユーザー同士の重複を弾けなかった
+-- 名前 (uk_users_active_name) と同じく、削除済みなら NULL になる式にユニーク制約を付ける。メール無し (NULL) 同士は重複にならない
+ALTER TABLE `users`
+  DROP INDEX `uk_users_email_deleted_at`,
+  ADD UNIQUE KEY `uk_users_active_email` ((IF(`deleted_at` IS NULL, `email`, NULL)));
@@ -0,0 +1,14 @@
+// Package migrations はDBのマイグレーション（番号付きのSQLファイル）をバイナリに埋め込む。
+//
+// ファイル名は <番号>_<名前>.up.sql / <番号>_<名前>.down.sql。
+// 新しいテーブルや変更はここに次の番号で追加し、schema.sql は手で書き換えずに
+//
+//	UPDATE_SCHEMA=1 go test ./migrations
+//
+// で作り直す (sqlc はその schema.sql を読む)
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"go-example/admin-example/internal/migrate"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlc が読む schema.sql が、マイグレーションと食い違っていないかを確認する。
+// マイグレーションを追加したら UPDATE_SCHEMA=1 go test ./migrations で作り直す
+func TestSchemaSQLInSync(t *testing.T) {
+	migrations, err := migrate.Load(FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := migrate.Schema(migrations)
+
+	const path = "../schema.sql"
+	if os.Getenv("UPDATE_SCHEMA") != "" {
+		if err := os.WriteFile(path, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, string(got), "schema.sql が古いままです。UPDATE_SCHEMA=1 go test ./migrations で作り直してください")
+}
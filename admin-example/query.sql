@@ -8,13 +8,171 @@ WHERE deleted_at IS NULL
 ORDER BY id DESC;
 
 -- name: CreateUser :execresult
-INSERT INTO users (name) VALUES (?);
+INSERT INTO users (name, email, display_name, status, role, avatar_path) VALUES (?, ?, ?, ?, ?, ?);
 
--- name: UpdateUser :exec
-UPDATE users SET name = ? 
-WHERE id = ? AND deleted_at IS NULL;
+-- name: UpdateUser :execrows
+-- 楽観的ロック: 画面を開いた時の version と一致する時だけ更新し、version を +1 する
+-- 他の人が先に更新していたら（または削除済みなら）影響行数が 0 になる
+UPDATE users SET name = ?, email = ?, display_name = ?, status = ?, role = ?, avatar_path = ?, version = version + 1 
+WHERE id = ? AND version = ? AND deleted_at IS NULL;
 
--- name: DeleteUser :exec
+-- name: DeleteUser :execrows
 -- 物理削除ではなく、現在時刻を入れて「論理削除」にする
+-- 削除済みの行は対象外にし、影響行数で「見つからない」を判定できるようにする
 UPDATE users SET deleted_at = NOW(3) 
-WHERE id = ?;
\ No newline at end of file
+WHERE id = ? AND deleted_at IS NULL;
+
+-- name: ListDeletedUsers :many
+-- ゴミ箱（論理削除済み）の一覧を1ページ分。新しく削除したものから順に並べる
+SELECT * FROM users 
+WHERE deleted_at IS NOT NULL 
+ORDER BY deleted_at DESC, id DESC 
+LIMIT ? OFFSET ?;
+
+-- name: CountDeletedUsers :one
+-- ゴミ箱のページ送り用の総件数
+SELECT COUNT(*) FROM users 
+WHERE deleted_at IS NOT NULL;
+
+-- name: GetDeletedUser :one
+SELECT * FROM users 
+WHERE id = ? AND deleted_at IS NOT NULL LIMIT 1;
+
+-- name: CountActiveUsersByName :one
+-- 復元前に「同じ名前の有効なユーザー」がいないか確認する
+SELECT COUNT(*) FROM users 
+WHERE name = ? AND deleted_at IS NULL;
+
+-- name: CountActiveUsersByEmail :one
+-- メールアドレスも名前と同じく、有効なユーザーの中で重複させない (uk_users_active_email)
+SELECT COUNT(*) FROM users 
+WHERE email = ? AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+-- deleted_at を NULL に戻して論理削除を取り消す
+UPDATE users SET deleted_at = NULL 
+WHERE id = ? AND deleted_at IS NOT NULL;
+
+
+-- name: GetAccount :one
+SELECT * FROM accounts 
+WHERE id = ? LIMIT 1;
+
+-- name: GetAccountByEmail :one
+SELECT * FROM accounts 
+WHERE email = ? LIMIT 1;
+
+-- name: CreateAccount :execresult
+INSERT INTO accounts (email, password_hash, role) VALUES (?, ?, ?);
+
+-- name: UpdateAccountPassword :execrows
+-- パスワードを変えると session_version も +1 し、今までのログイン中のセッションを使えなくする
+UPDATE accounts SET password_hash = ?, session_version = session_version + 1
+WHERE id = ?;
+
+
+-- name: CreateAuditLog :exec
+INSERT INTO audit_logs (
+  actor_id, actor_email, action, target_type, target_id, before_value, after_value, request_id
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: CreateUserInvitation :execresult
+INSERT INTO user_invitations (user_id, email, token_hash, expires_at, created_by) VALUES (?, ?, ?, ?, ?);
+
+-- name: GetUserInvitationByTokenHash :one
+SELECT * FROM user_invitations
+WHERE token_hash = ? LIMIT 1;
+
+-- name: AcceptUserInvitation :execrows
+-- 招待を使用済みにする。未使用で取り消されていないものだけが対象
+-- 同時に2回受諾されても、影響行数が 1 になるのは片方だけ
+UPDATE user_invitations SET accepted_at = NOW(3)
+WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeUserInvitations :execrows
+-- ユーザーの使われていない招待を全て取り消す（再送の前にも呼ぶ）
+UPDATE user_invitations SET revoked_at = NOW(3)
+WHERE user_id = ? AND accepted_at IS NULL AND revoked_at IS NULL;
+
+-- name: CreatePasswordReset :execresult
+INSERT INTO password_resets (account_id, token_hash, expires_at, requested_ip) VALUES (?, ?, ?, ?);
+
+-- name: GetPasswordResetByTokenHash :one
+SELECT * FROM password_resets
+WHERE token_hash = ? LIMIT 1;
+
+-- name: UsePasswordReset :execrows
+-- 再設定のリンクを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+UPDATE password_resets SET used_at = NOW(3)
+WHERE id = ? AND used_at IS NULL;
+
+-- name: ExpirePasswordResets :execrows
+-- アカウントの使われていないリンクを全て使えなくする（新しく申し込まれた時と、再設定した後に呼ぶ）
+UPDATE password_resets SET used_at = NOW(3)
+WHERE account_id = ? AND used_at IS NULL;
+
+-- name: SetAccountTOTPSecret :execrows
+-- 2要素認証の登録を始める（秘密鍵は暗号化したもの）。有効にした後は上書きできない
+UPDATE accounts SET totp_secret = ?, totp_last_step = 0
+WHERE id = ? AND totp_enabled_at IS NULL;
+
+-- name: EnableAccountTOTP :execrows
+-- 確認に使ったコードの区切りを totp_last_step に入れ、同じコードでログインできないようにする
+UPDATE accounts SET totp_enabled_at = NOW(3), totp_last_step = ?
+WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: DisableAccountTOTP :exec
+UPDATE accounts SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+WHERE id = ?;
+
+-- name: UseAccountTOTPStep :execrows
+-- コードを使ったことにする。同じか前の区切りのコードなら影響行数が 0 になる（使い回しを防ぐ）
+UPDATE accounts SET totp_last_step = ?
+WHERE id = ? AND totp_enabled_at IS NOT NULL AND totp_last_step < ?;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (account_id, code_hash) VALUES (?, ?);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE account_id = ?;
+
+-- name: UseRecoveryCode :execrows
+-- リカバリーコードを使用済みにする。同時に2回使われても、影響行数が 1 になるのは片方だけ
+UPDATE recovery_codes SET used_at = NOW(3)
+WHERE account_id = ? AND code_hash = ? AND used_at IS NULL;
+
+-- name: CountRecoveryCodes :one
+-- 残っている（使われていない）リカバリーコードの数
+SELECT COUNT(*) FROM recovery_codes
+WHERE account_id = ? AND used_at IS NULL;
+
+-- name: GetSession :one
+SELECT * FROM sessions
+WHERE id = ? AND expires_at > ? LIMIT 1;
+
+-- name: CreateSession :exec
+-- 新しく振った ID のセッションを作る
+INSERT INTO sessions (id, account_id, data, ip, user_agent, created_at, updated_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: UpdateSession :execrows
+-- 中身と期限を上書きする（created_at は最初の値のまま）
+-- ログアウト・強制終了で消えた行は作り直さない（影響行数が 0 になる）
+UPDATE sessions SET account_id = ?, data = ?, ip = ?, user_agent = ?, updated_at = ?, expires_at = ?
+WHERE id = ?;
+
+-- name: DeleteSession :execrows
+DELETE FROM sessions WHERE id = ?;
+
+-- name: DeleteAccountSessions :execrows
+-- アカウントのセッションを全て消す（パスワードの再設定で使う）
+DELETE FROM sessions WHERE account_id = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at <= ?;
+
+-- name: ListAuthenticatedSessions :many
+-- ログイン中のセッションの一覧（中身は読まない）
+SELECT id, account_id, ip, user_agent, created_at, updated_at, expires_at FROM sessions
+WHERE account_id <> 0 AND expires_at > ?
+ORDER BY updated_at DESC, id;
@@ -1,5 +1,12 @@
 -- schema.sql
-CREATE TABLE `users` (
+-- このファイルは migrations/*.up.sql から生成しています。直接編集しないこと
+-- テーブルを変える時は migrations に次の番号のファイルを追加してから
+--   UPDATE_SCHEMA=1 go test ./migrations
+-- で作り直す (sqlc はこのファイルを読む)
+
+-- 0001_create_users
+-- schema.sql を手で流していたDBにもそのまま適用できるよう、最初の3つは IF NOT EXISTS にしている
+CREATE TABLE IF NOT EXISTS `users` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
   `name` varchar(255) NOT NULL,
   `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
@@ -11,3 +18,157 @@ CREATE TABLE `users` (
   PRIMARY KEY (`id`),
   INDEX `idx_users_deleted_at` (`deleted_at`)
 );
+
+-- 0002_create_accounts
+-- 管理画面にログインするアカウント
+-- role は admin / editor / viewer（権限の中身は internal/model/role.go）
+CREATE TABLE IF NOT EXISTS `accounts` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `email` varchar(255) NOT NULL,
+  /* bcrypt のハッシュ。平文のパスワードは保存しない */
+  `password_hash` varchar(255) NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'viewer',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  `updated_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_accounts_email` (`email`)
+);
+
+-- 0003_create_audit_logs
+-- 監査ログ（誰が・いつ・何を・どう変えたか）
+-- 変更と同じトランザクションで書き込むので、変更だけが残ってログが無いという状態にはならない
+CREATE TABLE IF NOT EXISTS `audit_logs` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  /* 操作したアカウント。コマンドラインからの操作などは 0 */
+  `actor_id` bigint unsigned NOT NULL DEFAULT 0,
+  `actor_email` varchar(255) NOT NULL DEFAULT '',
+  /* 例) user.create / user.update / user.delete / user.restore */
+  `action` varchar(50) NOT NULL,
+  `target_type` varchar(50) NOT NULL,
+  `target_id` bigint unsigned NOT NULL,
+  `before_value` json DEFAULT NULL,
+  `after_value` json DEFAULT NULL,
+  `request_id` varchar(64) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  INDEX `idx_audit_logs_created_at` (`created_at`),
+  INDEX `idx_audit_logs_actor` (`actor_email`, `created_at`),
+  INDEX `idx_audit_logs_target` (`target_type`, `target_id`)
+);
+
+-- 0004_add_users_version
+-- 楽観的ロック用のバージョン。更新のたびに +1 し、画面を開いた時の値と違えば更新しない
+ALTER TABLE `users` ADD COLUMN `version` int unsigned NOT NULL DEFAULT 1;
+
+-- 0005_add_users_profile
+-- プロフィール（メールアドレス・表示名・状態・役割・アバター画像）
+-- email は任意（CSV で取り込んだユーザーなどは NULL）。名前と同じく、有効なユーザーの中での重複は登録前に確認する
+-- status は active / suspended / invited、role は accounts.role と同じ admin / editor / viewer（internal/model）
+-- avatar_path はアップロードした画像のキー（保存先は server.upload_dir）。空なら画像なし
+ALTER TABLE `users`
+  ADD COLUMN `email` varchar(255) DEFAULT NULL,
+  ADD COLUMN `display_name` varchar(255) NOT NULL DEFAULT '',
+  ADD COLUMN `status` varchar(20) NOT NULL DEFAULT 'active',
+  ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'viewer',
+  ADD COLUMN `avatar_path` varchar(255) NOT NULL DEFAULT '',
+  ADD UNIQUE KEY `uk_users_email_deleted_at` (`email`, `deleted_at`);
+
+-- 0006_create_user_invitations
+-- 招待（ユーザーを status = invited で登録し、メールで送ったリンクからパスワードを決めてもらう）
+-- リンクのトークンそのものは保存せず、SHA-256 のハッシュだけを持つ (internal/token)
+-- 使ったら accepted_at、取り消したら revoked_at を入れる。どちらも NULL で期限内のものだけが使える
+CREATE TABLE IF NOT EXISTS `user_invitations` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `user_id` bigint unsigned NOT NULL,
+  /* 招待した時のメールアドレス（アカウントはこのアドレスで作る） */
+  `email` varchar(255) NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `accepted_at` datetime(3) DEFAULT NULL,
+  `revoked_at` datetime(3) DEFAULT NULL,
+  /* 招待したアカウント。コマンドラインからの操作などは 0 */
+  `created_by` bigint unsigned NOT NULL DEFAULT 0,
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_user_invitations_token_hash` (`token_hash`),
+  INDEX `idx_user_invitations_user_id` (`user_id`)
+);
+
+-- 0007_create_password_resets
+-- パスワードの再設定（メールで送ったリンクから新しいパスワードを決めてもらう）
+-- 招待 (user_invitations) と同じく、トークンは SHA-256 のハッシュだけを持つ (internal/token)
+-- 使ったら used_at を入れる。NULL で期限内のものだけが使える
+CREATE TABLE IF NOT EXISTS `password_resets` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` bigint unsigned NOT NULL,
+  `token_hash` char(64) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  `used_at` datetime(3) DEFAULT NULL,
+  /* 再設定を申し込んだ IP アドレス（調査用） */
+  `requested_ip` varchar(45) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_password_resets_token_hash` (`token_hash`),
+  INDEX `idx_password_resets_account_id` (`account_id`)
+);
+
+-- ログイン中のセッションを無効にするためのバージョン
+-- ログインした時の値をセッションに入れておき、パスワードを変えたら +1 する（値が違うセッションはログアウト扱い）
+ALTER TABLE `accounts` ADD COLUMN `session_version` int unsigned NOT NULL DEFAULT 1;
+
+-- 0008_create_sessions
+-- サーバー側に保存するセッション (internal/sessionstore)
+-- Cookie にはランダムな ID だけを入れ、ここには ID の SHA-256 のハッシュと中身を置く
+-- 期限切れの行は、アプリが session.sweep_interval ごとに消す
+CREATE TABLE IF NOT EXISTS `sessions` (
+  `id` char(64) NOT NULL,
+  /* ログインしていなければ 0 */
+  `account_id` bigint unsigned NOT NULL DEFAULT 0,
+  `data` mediumblob NOT NULL,
+  `ip` varchar(45) NOT NULL DEFAULT '',
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `created_at` datetime(3) NOT NULL,
+  `updated_at` datetime(3) NOT NULL,
+  `expires_at` datetime(3) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_sessions_account_id` (`account_id`),
+  INDEX `idx_sessions_expires_at` (`expires_at`)
+);
+
+-- 0009_add_accounts_two_factor
+-- 2要素認証 (TOTP) の設定
+-- totp_secret は secretbox で暗号化した秘密鍵。NULL なら未設定、totp_enabled_at が NULL なら登録の途中
+-- totp_last_step は最後に使ったコードの時間の区切り。同じコードを2回使えないようにする
+ALTER TABLE `accounts`
+  ADD COLUMN `totp_secret` varbinary(255) DEFAULT NULL,
+  ADD COLUMN `totp_enabled_at` datetime(3) DEFAULT NULL,
+  ADD COLUMN `totp_last_step` bigint unsigned NOT NULL DEFAULT 0;
+
+-- 認証アプリを無くした時のためのリカバリーコード（1回だけ使える）
+-- 招待などのトークンと同じく、SHA-256 のハッシュだけを持つ
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` bigint unsigned NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `used_at` datetime(3) DEFAULT NULL,
+  `created_at` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+  PRIMARY KEY (`id`),
+  INDEX `idx_recovery_codes_account_id` (`account_id`)
+);
+
+-- 0010_add_users_active_name_unique
+-- 有効なユーザー（deleted_at が NULL）の中で名前を重複させない
+-- uk_name_deleted_at (name, deleted_at) は deleted_at が NULL 同士を別の値として扱うので、有効なユーザー同士の重複を弾けなかった
+-- 削除済みなら NULL になる式にユニーク制約を付ける（MySQL 8.0.13 からの関数インデックス。隠し列なので SELECT * には出てこない）
+-- すでに重複している場合はこのマイグレーションが失敗するので、先に片方の名前を変えるか削除しておくこと
+ALTER TABLE `users`
+  DROP INDEX `uk_name_deleted_at`,
+  ADD UNIQUE KEY `uk_users_active_name` ((IF(`deleted_at` IS NULL, `name`, NULL)));
+
+-- 0011_add_users_active_email_unique
+-- 有効なユーザーの中でメールアドレスを重複させない
+-- uk_users_email_deleted_at (email, deleted_at) は deleted_at が NULL 同士を別の値として扱うので、有効なユーザー同士の重複を弾けなかった
+-- 名前 (uk_users_active_name) と同じく、削除済みなら NULL になる式にユニーク制約を付ける。メール無し (NULL) 同士は重複にならない
+ALTER TABLE `users`
+  DROP INDEX `uk_users_email_deleted_at`,
+  ADD UNIQUE KEY `uk_users_active_email` ((IF(`deleted_at` IS NULL, `email`, NULL)));
@@ -0,0 +1,8 @@
+// Package views は Ace テンプレートをバイナリに埋め込む。
+// server.views_dir を空にすると、ファイルではなくこちらを使う
+package views
+
+import "embed"
+
+//go:embed */*.ace
+var FS embed.FS